@@ -19,8 +19,11 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/orangeAndSuns/go-ethereum/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -144,3 +147,22 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// revertSelector is the 4-byte id of the Error(string) pseudo-function which
+// Solidity uses to encode revert reasons.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// UnpackRevert resolves the abi-encoded revert reason. Solidity encodes revert
+// reasons as if they were a call to a function with signature Error(string).
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errors.New("invalid revert reason payload")
+	}
+	typ, _ := NewType("string", nil)
+
+	var reason string
+	if err := (Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+		return "", err
+	}
+	return reason, nil
+}
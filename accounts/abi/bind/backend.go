@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/orangeAndSuns/go-ethereum"
+	"github.com/orangeAndSuns/go-ethereum/accounts/abi"
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 )
@@ -41,6 +42,29 @@ var (
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
 )
 
+// RevertError is returned by contract calls which were reverted by the EVM. It
+// carries the raw data returned by the execution and, if the contract provided
+// one, the decoded Solidity revert reason.
+type RevertError struct {
+	Reason string // Decoded revert reason, empty if none was provided
+	Data   []byte // Raw data returned by the reverted execution
+}
+
+// NewRevertError creates a revert error from the data returned by a reverted
+// execution, decoding the revert reason if the data contains one.
+func NewRevertError(data []byte) *RevertError {
+	reason, _ := abi.UnpackRevert(data)
+	return &RevertError{Reason: reason, Data: data}
+}
+
+// Error implements error.
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
 // ContractCaller defines the methods needed to allow operating with contract on a read
 // only basis.
 type ContractCaller interface {
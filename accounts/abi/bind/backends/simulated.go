@@ -184,7 +184,10 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	if err != nil {
 		return nil, err
 	}
-	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	rval, _, failed, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	if err == nil && failed && len(rval) > 0 {
+		return nil, bind.NewRevertError(rval)
+	}
 	return rval, err
 }
 
@@ -194,7 +197,10 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	rval, _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	rval, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	if err == nil && failed && len(rval) > 0 {
+		return nil, bind.NewRevertError(rval)
+	}
 	return rval, err
 }
 
@@ -233,22 +239,22 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	executable := func(gas uint64) (bool, []byte) {
 		call.Gas = gas
 
 		snapshot := b.pendingState.Snapshot()
-		_, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		res, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
 		b.pendingState.RevertToSnapshot(snapshot)
 
 		if err != nil || failed {
-			return false
+			return false, res
 		}
-		return true
+		return true, nil
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -256,7 +262,11 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		if ok, ret := executable(hi); !ok {
+			// Only reverting executions return data, surface the reason if any
+			if len(ret) > 0 {
+				return 0, bind.NewRevertError(ret)
+			}
 			return 0, errGasEstimationFailed
 		}
 	}
@@ -0,0 +1,91 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends_test
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum"
+	"github.com/orangeAndSuns/go-ethereum/accounts/abi"
+	"github.com/orangeAndSuns/go-ethereum/accounts/abi/bind"
+	"github.com/orangeAndSuns/go-ethereum/accounts/abi/bind/backends"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core"
+)
+
+var (
+	// revertAddr holds a contract reverting every call with the Solidity reason
+	// "test reason", its code returning the revertData following it.
+	revertAddr = common.HexToAddress("0x0bad")
+	revertData = common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000b" +
+		"7465737420726561736f6e000000000000000000000000000000000000000000")
+	revertCode = append(common.FromHex("0x6064600c60003960646000fd"), revertData...)
+
+	revertABI = `[{"constant":true,"inputs":[],"name":"fail","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
+)
+
+// checkRevertError checks that err is a revert error carrying the reason and the
+// raw data of the reverting test contract.
+func checkRevertError(t *testing.T, name string, err error) {
+	revertErr, ok := err.(*bind.RevertError)
+	if !ok {
+		t.Errorf("%s: error type mismatch: have %T (%v), want *bind.RevertError", name, err, err)
+		return
+	}
+	if revertErr.Reason != "test reason" {
+		t.Errorf("%s: revert reason mismatch: have %q, want %q", name, revertErr.Reason, "test reason")
+	}
+	if !bytes.Equal(revertErr.Data, revertData) {
+		t.Errorf("%s: revert data mismatch: have %x, want %x", name, revertErr.Data, revertData)
+	}
+	if have, want := revertErr.Error(), "execution reverted: test reason"; have != want {
+		t.Errorf("%s: error message mismatch: have %q, want %q", name, have, want)
+	}
+}
+
+// Tests that reverted calls and gas estimations return the decoded revert reason
+// and the raw returned data, both directly and through bound contracts.
+func TestSimulatedBackendRevert(t *testing.T) {
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{revertAddr: {Code: revertCode, Balance: new(big.Int)}})
+	ctx := context.Background()
+
+	call := ethereum.CallMsg{To: &revertAddr}
+	_, err := sim.CallContract(ctx, call, nil)
+	checkRevertError(t, "call", err)
+
+	_, err = sim.PendingCallContract(ctx, call)
+	checkRevertError(t, "pending call", err)
+
+	_, err = sim.EstimateGas(ctx, call)
+	checkRevertError(t, "estimate", err)
+
+	parsed, err := abi.JSON(strings.NewReader(revertABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	contract := bind.NewBoundContract(revertAddr, parsed, sim, sim, sim)
+
+	var out *big.Int
+	checkRevertError(t, "bound call", contract.Call(nil, &out, "fail"))
+	checkRevertError(t, "bound pending call", contract.Call(&bind.CallOpts{Pending: true}, &out, "fail"))
+}
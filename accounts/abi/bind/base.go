@@ -25,6 +25,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum"
 	"github.com/orangeAndSuns/go-ethereum/accounts/abi"
 	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/event"
@@ -159,11 +160,35 @@ func (c *BoundContract) Call(opts *CallOpts, result interface{}, method string,
 		}
 	}
 	if err != nil {
-		return err
+		return toRevertError(err)
 	}
 	return c.abi.Unpack(result, method, output)
 }
 
+// rpcDataError is implemented by JSON-RPC errors carrying additional data.
+type rpcDataError interface {
+	ErrorCode() int
+	ErrorData() interface{}
+}
+
+// toRevertError converts JSON-RPC errors signalling a reverted execution into
+// a RevertError, passing through any other error untouched.
+func toRevertError(err error) error {
+	rpcErr, ok := err.(rpcDataError)
+	if !ok || rpcErr.ErrorCode() != 3 {
+		return err
+	}
+	hex, ok := rpcErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, errDecode := hexutil.Decode(hex)
+	if errDecode != nil {
+		return err
+	}
+	return NewRevertError(data)
+}
+
 // Transact invokes the (paid) contract method with params as input values.
 func (c *BoundContract) Transact(opts *TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
 	// Otherwise pack up the parameters and invoke the contract
@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
@@ -47,7 +48,10 @@ const (
 // manually maintain hard coded strings that break on runtime.
 func Bind(types []string, abis []string, bytecodes []string, pkg string, lang Lang) (string, error) {
 	// Process each individual contract requested binding
-	contracts := make(map[string]*tmplContract)
+	var (
+		contracts = make(map[string]*tmplContract)
+		structs   = make(map[string]*tmplStruct)
+	)
 
 	for i := 0; i < len(types); i++ {
 		// Parse the actual ABI to generate the binding for
@@ -117,6 +121,19 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 			// Append the event to the accumulator list
 			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
 		}
+		// Resolve all tuple types up front in a stable order, so the generated
+		// struct names don't depend on map iteration order
+		for _, args := range sortedArguments(evmABI) {
+			for _, arg := range args {
+				if !hasTuple(arg.Type) {
+					continue
+				}
+				if lang != LangGo {
+					return "", fmt.Errorf("tuple types are only supported by Go bindings")
+				}
+				bindTypeGo(arg.Type, structs)
+			}
+		}
 		contracts[types[i]] = &tmplContract{
 			Type:        capitalise(types[i]),
 			InputABI:    strings.Replace(strippedABI, "\"", "\\\"", -1),
@@ -131,15 +148,20 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 	data := &tmplData{
 		Package:   pkg,
 		Contracts: contracts,
+		Structs:   structs,
 	}
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
-		"bindtype":      bindType[lang],
-		"bindtopictype": bindTopicType[lang],
-		"namedtype":     namedType[lang],
-		"capitalise":    capitalise,
-		"decapitalise":  decapitalise,
+		"bindtype": func(kind abi.Type) string {
+			return bindType[lang](kind, structs)
+		},
+		"bindtopictype": func(kind abi.Type) string {
+			return bindTopicType[lang](kind, structs)
+		},
+		"namedtype":    namedType[lang],
+		"capitalise":   capitalise,
+		"decapitalise": decapitalise,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource[lang]))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -159,7 +181,7 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 
 // bindType is a set of type binders that convert Solidity types to some supported
 // programming language types.
-var bindType = map[Lang]func(kind abi.Type) string{
+var bindType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTypeGo,
 	LangJava: bindTypeJava,
 }
@@ -198,13 +220,87 @@ func arrayBindingGo(inner string, arraySizes []string) string {
 
 // bindTypeGo converts a Solidity type to a Go one. Since there is no clear mapping
 // from all Solidity types to Go ones (e.g. uint17), those that cannot be exactly
-// mapped will use an upscaled type (e.g. *big.Int).
-func bindTypeGo(kind abi.Type) string {
+// mapped will use an upscaled type (e.g. *big.Int). Tuples are mapped to
+// generated structs, which are recorded in the given map.
+func bindTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		return bindStructTypeGo(kind, structs)
+	case abi.ArrayTy:
+		if hasTuple(kind) {
+			return fmt.Sprintf("[%d]", kind.Size) + bindTypeGo(*kind.Elem, structs)
+		}
+	case abi.SliceTy:
+		if hasTuple(kind) {
+			return "[]" + bindTypeGo(*kind.Elem, structs)
+		}
+	}
 	stringKind := kind.String()
 	innerLen, innerMapping := bindUnnestedTypeGo(stringKind)
 	return arrayBindingGo(wrapArray(stringKind, innerLen, innerMapping))
 }
 
+// bindStructTypeGo converts a Solidity tuple type to a Go struct and records
+// the mapping in the given map. Nested tuples are resolved recursively, and
+// tuples with the same fields share a single struct definition.
+func bindStructTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	var (
+		fields []*tmplField
+		sig    []string
+	)
+	for i, elem := range kind.TupleElems {
+		field := &tmplField{
+			Type:    bindTypeGo(*elem, structs),
+			Name:    kind.Type.Field(i).Name, // must match the unpacked struct
+			SolKind: *elem,
+		}
+		fields = append(fields, field)
+		sig = append(sig, field.Name+" "+field.Type)
+	}
+	id := strings.Join(sig, ";")
+	if s, exist := structs[id]; exist {
+		return s.Name
+	}
+	name := fmt.Sprintf("Struct%d", len(structs))
+	structs[id] = &tmplStruct{Name: name, Fields: fields}
+	return name
+}
+
+// hasTuple checks whether a type is a tuple or an array/slice of tuples.
+func hasTuple(kind abi.Type) bool {
+	switch kind.T {
+	case abi.TupleTy:
+		return true
+	case abi.ArrayTy, abi.SliceTy:
+		return hasTuple(*kind.Elem)
+	}
+	return false
+}
+
+// sortedArguments returns the argument lists of the constructor, the methods
+// and the events of a contract ABI in a deterministic order.
+func sortedArguments(evmABI abi.ABI) []abi.Arguments {
+	args := []abi.Arguments{evmABI.Constructor.Inputs}
+
+	methods := make([]string, 0, len(evmABI.Methods))
+	for name := range evmABI.Methods {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	for _, name := range methods {
+		args = append(args, evmABI.Methods[name].Inputs, evmABI.Methods[name].Outputs)
+	}
+	events := make([]string, 0, len(evmABI.Events))
+	for name := range evmABI.Events {
+		events = append(events, name)
+	}
+	sort.Strings(events)
+	for _, name := range events {
+		args = append(args, evmABI.Events[name].Inputs)
+	}
+	return args
+}
+
 // The inner function of bindTypeGo, this finds the inner type of stringKind.
 // (Or just the type itself if it is not an array or slice)
 // The length of the matched part is returned, with the the translated type.
@@ -247,7 +343,7 @@ func arrayBindingJava(inner string, arraySizes []string) string {
 // bindTypeJava converts a Solidity type to a Java one. Since there is no clear mapping
 // from all Solidity types to Java ones (e.g. uint17), those that cannot be exactly
 // mapped will use an upscaled type (e.g. BigDecimal).
-func bindTypeJava(kind abi.Type) string {
+func bindTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
 	stringKind := kind.String()
 	innerLen, innerMapping := bindUnnestedTypeJava(stringKind)
 	return arrayBindingJava(wrapArray(stringKind, innerLen, innerMapping))
@@ -310,16 +406,16 @@ func bindUnnestedTypeJava(stringKind string) (int, string) {
 
 // bindTopicType is a set of type binders that convert Solidity types to some
 // supported programming language topic types.
-var bindTopicType = map[Lang]func(kind abi.Type) string{
+var bindTopicType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTopicTypeGo,
 	LangJava: bindTopicTypeJava,
 }
 
 // bindTypeGo converts a Solidity topic type to a Go one. It is almost the same
 // funcionality as for simple types, but dynamic types get converted to hashes.
-func bindTopicTypeGo(kind abi.Type) string {
-	bound := bindTypeGo(kind)
-	if bound == "string" || bound == "[]byte" {
+func bindTopicTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	bound := bindTypeGo(kind, structs)
+	if bound == "string" || bound == "[]byte" || kind.T == abi.TupleTy {
 		bound = "common.Hash"
 	}
 	return bound
@@ -327,8 +423,8 @@ func bindTopicTypeGo(kind abi.Type) string {
 
 // bindTypeGo converts a Solidity topic type to a Java one. It is almost the same
 // funcionality as for simple types, but dynamic types get converted to hashes.
-func bindTopicTypeJava(kind abi.Type) string {
-	bound := bindTypeJava(kind)
+func bindTopicTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
+	bound := bindTypeJava(kind, structs)
 	if bound == "String" || bound == "Bytes" {
 		bound = "Hash"
 	}
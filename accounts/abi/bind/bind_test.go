@@ -742,6 +742,54 @@ var bindTests = []struct {
 			}
 		`,
 	},
+	// Test that tuples are bound to generated structs
+	{
+		`Tuple`, ``, ``,
+		`
+			[
+				{"type":"function","name":"func1","constant":true,"inputs":[{"name":"a","type":"tuple","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"},{"name":"c","type":"tuple[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},{"name":"b","type":"tuple[2]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}],"outputs":[{"name":"","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},
+				{"type":"function","name":"func2","constant":false,"inputs":[{"name":"a","type":"tuple[]","components":[{"name":"a","type":"uint256"},{"name":"b","type":"uint256[]"},{"name":"c","type":"tuple[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]}],"outputs":[]},
+				{"type":"event","name":"TupleEvent","inputs":[{"name":"a","type":"tuple","indexed":false,"components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"b","type":"tuple","indexed":true,"components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}],"anonymous":false}
+			]
+		`,
+		`
+			if b, err := NewTuple(common.Address{}, nil); b == nil || err != nil {
+				t.Fatalf("binding (%v) nil or error (%v) not nil", b, nil)
+			} else if false { // Don't run, just compile and test types
+				var (
+					point Struct0
+					err   error
+				)
+				point, err = b.Func1(nil, Struct1{A: big.NewInt(1), B: []*big.Int{big.NewInt(2)}, C: []Struct0{{X: big.NewInt(3), Y: big.NewInt(4)}}}, [2]Struct0{})
+				_, err = b.Func2(nil, []Struct1{})
+
+				var event TupleTupleEvent
+				point, _ = event.A, event.B
+
+				fmt.Println(point, err)
+			}
+			// Ensure the generated structs round trip through the ABI encoder
+			parsed, err := abi.JSON(strings.NewReader(TupleABI))
+			if err != nil {
+				t.Fatalf("failed to parse ABI: %v", err)
+			}
+			input := Struct1{A: big.NewInt(1), B: []*big.Int{big.NewInt(2)}, C: []Struct0{{X: big.NewInt(3), Y: big.NewInt(4)}}}
+			packed, err := parsed.Methods["func1"].Inputs.Pack(input, [2]Struct0{{X: big.NewInt(5), Y: big.NewInt(6)}, {X: big.NewInt(7), Y: big.NewInt(8)}})
+			if err != nil {
+				t.Fatalf("failed to pack tuples: %v", err)
+			}
+			var output struct {
+				A Struct1
+				B [2]Struct0
+			}
+			if err := parsed.Methods["func1"].Inputs.Unpack(&output, packed); err != nil {
+				t.Fatalf("failed to unpack tuples: %v", err)
+			}
+			if !reflect.DeepEqual(output.A, input) || output.B[1].Y.Int64() != 8 {
+				t.Fatalf("tuple mismatch: have %+v", output)
+			}
+		`,
+	},
 	{
 		`DeeplyNestedArray`,
 		`
@@ -325,7 +325,7 @@ func TestPack(t *testing.T) {
 			common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000006666f6f6261720000000000000000000000000000000000000000000000000000"),
 		},
 	} {
-		typ, err := NewType(test.typ)
+		typ, err := NewType(test.typ, nil)
 		if err != nil {
 			t.Fatalf("%v failed. Unexpected parse error: %v", i, err)
 		}
@@ -441,3 +441,41 @@ func TestPackNumber(t *testing.T) {
 		}
 	}
 }
+
+func TestPackTuple(t *testing.T) {
+	components := []ArgumentMarshaling{
+		{Name: "a", Type: "uint256"},
+		{Name: "b", Type: "string"},
+		{Name: "c", Type: "uint8[2]"},
+	}
+	typ, err := NewType("tuple", components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := struct {
+		A *big.Int
+		B string
+		C [2]uint8
+	}{big.NewInt(1), "foobar", [2]uint8{2, 3}}
+
+	args := Arguments{{Type: typ}}
+	packed, err := args.Pack(input)
+	if err != nil {
+		t.Fatalf("failed to pack tuple: %v", err)
+	}
+	want := common.Hex2Bytes("" +
+		"0000000000000000000000000000000000000000000000000000000000000020" + // offset of the dynamic tuple
+		"0000000000000000000000000000000000000000000000000000000000000001" + // a
+		"0000000000000000000000000000000000000000000000000000000000000080" + // offset of b within the tuple
+		"0000000000000000000000000000000000000000000000000000000000000002" + // c[0]
+		"0000000000000000000000000000000000000000000000000000000000000003" + // c[1]
+		"0000000000000000000000000000000000000000000000000000000000000006" + // len(b)
+		"666f6f6261720000000000000000000000000000000000000000000000000000") // b
+	if !bytes.Equal(packed, want) {
+		t.Errorf("packed tuple mismatch:\nhave %x\nwant %x", packed, want)
+	}
+	// Missing fields must be reported instead of silently packed as zero
+	if _, err := args.Pack(struct{ A *big.Int }{big.NewInt(1)}); err == nil {
+		t.Errorf("expected error for missing tuple field")
+	}
+}
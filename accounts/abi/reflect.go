@@ -81,12 +81,63 @@ func set(dst, src reflect.Value, output Argument) error {
 		dst.Set(src)
 	case dstType.Kind() == reflect.Ptr:
 		return set(dst.Elem(), src, output)
+	case dstType.Kind() == reflect.Slice && srcType.Kind() == reflect.Slice:
+		return setSlice(dst, src, output)
+	case dstType.Kind() == reflect.Array && srcType.Kind() == reflect.Array:
+		return setArray(dst, src, output)
+	case dstType.Kind() == reflect.Struct && srcType.Kind() == reflect.Struct:
+		return setStruct(dst, src, output)
 	default:
 		return fmt.Errorf("abi: cannot unmarshal %v in to %v", src.Type(), dst.Type())
 	}
 	return nil
 }
 
+// setSlice assigns src to dst element by element when the slice types are not
+// directly assignable, e.g. unpacked tuple slices into user-defined structs.
+func setSlice(dst, src reflect.Value, output Argument) error {
+	slice := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+	for i := 0; i < src.Len(); i++ {
+		if err := set(slice.Index(i), src.Index(i), output); err != nil {
+			return err
+		}
+	}
+	dst.Set(slice)
+	return nil
+}
+
+// setArray assigns src to dst element by element when the array types are not
+// directly assignable.
+func setArray(dst, src reflect.Value, output Argument) error {
+	if dst.Len() != src.Len() {
+		return fmt.Errorf("abi: cannot unmarshal %v in to %v", src.Type(), dst.Type())
+	}
+	array := reflect.New(dst.Type()).Elem()
+	for i := 0; i < src.Len(); i++ {
+		if err := set(array.Index(i), src.Index(i), output); err != nil {
+			return err
+		}
+	}
+	dst.Set(array)
+	return nil
+}
+
+// setStruct assigns the fields of an unpacked tuple to the same named fields
+// of dst.
+func setStruct(dst, src reflect.Value, output Argument) error {
+	for i := 0; i < src.NumField(); i++ {
+		name := src.Type().Field(i).Name
+		field := dst.FieldByName(name)
+		if !field.IsValid() {
+			return fmt.Errorf("abi: field %s can't be found in the given value", name)
+		}
+		if err := set(field, src.Field(i), output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // requireAssignable assures that `dest` is a pointer and it's not an interface.
 func requireAssignable(dst, src reflect.Value) error {
 	if dst.Kind() != reflect.Ptr && dst.Kind() != reflect.Interface {
@@ -97,7 +97,7 @@ func TestTypeRegexp(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		typ, err := NewType(tt.blob)
+		typ, err := NewType(tt.blob, nil)
 		if err != nil {
 			t.Errorf("type %q: failed to parse type string: %v", tt.blob, err)
 		}
@@ -256,7 +256,7 @@ func TestTypeCheck(t *testing.T) {
 		{"invalidType", "", "unsupported arg type: invalidType"},
 		{"invalidSlice[]", "", "unsupported arg type: invalidSlice"},
 	} {
-		typ, err := NewType(test.typ)
+		typ, err := NewType(test.typ, nil)
 		if err != nil && len(test.err) == 0 {
 			t.Fatal("unexpected parse error:", err)
 		} else if err != nil && len(test.err) != 0 {
@@ -281,3 +281,36 @@ func TestTypeCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestTupleType(t *testing.T) {
+	components := []ArgumentMarshaling{
+		{Name: "a", Type: "uint256"},
+		{Name: "b", Type: "bytes32[]"},
+		{Name: "_c", Type: "tuple", Components: []ArgumentMarshaling{{Name: "d", Type: "bool"}}},
+	}
+	typ, err := NewType("tuple[2]", components)
+	if err != nil {
+		t.Fatalf("failed to parse tuple: %v", err)
+	}
+	if want := "(uint256,bytes32[],(bool))[2]"; typ.String() != want {
+		t.Errorf("signature mismatch: have %s, want %s", typ.String(), want)
+	}
+	if typ.T != ArrayTy || typ.Elem.T != TupleTy {
+		t.Fatalf("type mismatch: have %d/%d, want %d/%d", typ.T, typ.Elem.T, ArrayTy, TupleTy)
+	}
+	want := reflect.TypeOf([2]struct {
+		A *big.Int
+		B [][32]byte
+		C struct{ D bool }
+	}{})
+	if typ.Type != want {
+		t.Errorf("reflect type mismatch: have %v, want %v", typ.Type, want)
+	}
+	if names := typ.Elem.TupleRawNames; !reflect.DeepEqual(names, []string{"a", "b", "_c"}) {
+		t.Errorf("raw names mismatch: have %v", names)
+	}
+	// Anonymous fields can't be mapped to struct fields
+	if _, err := NewType("tuple", []ArgumentMarshaling{{Name: "_", Type: "uint256"}}); err == nil {
+		t.Errorf("expected error for anonymous tuple field")
+	}
+}
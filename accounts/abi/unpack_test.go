@@ -168,7 +168,7 @@ var unpackTests = []unpackTest{
 	// multi dimensional, if these pass, all types that don't require length prefix should pass
 	{
 		def:  `[{"type": "uint8[][]"}]`,
-		enc:  "00000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000008000000000000000000000000000000000000000000000000000000000000000E0000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002",
+		enc:  "00000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002",
 		want: [][]uint8{{1, 2}, {1, 2}},
 	},
 	{
@@ -178,7 +178,7 @@ var unpackTests = []unpackTest{
 	},
 	{
 		def:  `[{"type": "uint8[][2]"}]`,
-		enc:  "000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000800000000000000000000000000000000000000000000000000000000000000001000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000001",
+		enc:  "0000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000800000000000000000000000000000000000000000000000000000000000000001000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000001",
 		want: [2][]uint8{{1}, {1}},
 	},
 	{
@@ -815,3 +815,40 @@ func TestOOMMaliciousInput(t *testing.T) {
 		}
 	}
 }
+
+func TestUnpackTuple(t *testing.T) {
+	const def = `[{"name":"method","outputs":[
+		{"name":"ret","type":"tuple[]","components":[{"name":"a","type":"uint256"},{"name":"b","type":"string"}]},
+		{"name":"flag","type":"tuple","components":[{"name":"c","type":"bool"},{"name":"d","type":"uint8[2]"}]}
+	]}]`
+	abi, err := JSON(strings.NewReader(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type ret struct {
+		A *big.Int
+		B string
+	}
+	type flag struct {
+		C bool
+		D [2]uint8
+	}
+	want := struct {
+		Ret  []ret
+		Flag flag
+	}{
+		Ret:  []ret{{big.NewInt(1), "foo"}, {big.NewInt(2), "bar"}},
+		Flag: flag{true, [2]uint8{3, 4}},
+	}
+	packed, err := abi.Methods["method"].Outputs.Pack(want.Ret, want.Flag)
+	if err != nil {
+		t.Fatalf("failed to pack tuples: %v", err)
+	}
+	out := reflect.New(reflect.TypeOf(want))
+	if err := abi.Unpack(out.Interface(), "method", packed); err != nil {
+		t.Fatalf("failed to unpack tuples: %v", err)
+	}
+	if !reflect.DeepEqual(out.Elem().Interface(), want) {
+		t.Errorf("unpacked tuples mismatch: have %+v, want %+v", out.Elem().Interface(), want)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+// Chain is the test chain the node under test has been initialized with. The
+// first block is the genesis block.
+type Chain struct {
+	blocks []*types.Block
+	config *params.ChainConfig
+}
+
+// LoadChain loads a chain exported with 'geth export' (optionally gzipped) and
+// the genesis specification it was created from.
+func LoadChain(chainfile string, genesisfile string) (*Chain, error) {
+	blob, err := ioutil.ReadFile(genesisfile)
+	if err != nil {
+		return nil, err
+	}
+	var gen core.Genesis
+	if err := json.Unmarshal(blob, &gen); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+	if gen.Config == nil {
+		return nil, fmt.Errorf("genesis file has no chain config")
+	}
+	gblock := gen.ToBlock(nil)
+
+	fh, err := os.Open(chainfile)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(chainfile, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	stream := rlp.NewStream(reader, 0)
+	blocks := []*types.Block{gblock}
+	for {
+		var b types.Block
+		if err := stream.Decode(&b); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("at block %d: %v", len(blocks), err)
+		}
+		// 'geth export' includes the genesis block, skip it
+		if b.NumberU64() == 0 {
+			if b.Hash() != gblock.Hash() {
+				return nil, fmt.Errorf("chain file genesis %x doesn't match genesis file %x", b.Hash(), gblock.Hash())
+			}
+			continue
+		}
+		if parent := blocks[len(blocks)-1]; b.NumberU64() != parent.NumberU64()+1 || b.ParentHash() != parent.Hash() {
+			return nil, fmt.Errorf("block %d (%x) doesn't extend the chain", b.NumberU64(), b.Hash())
+		}
+		blocks = append(blocks, &b)
+	}
+	return &Chain{blocks: blocks, config: gen.Config}, nil
+}
+
+// Len returns the number of blocks in the chain, including the genesis.
+func (c *Chain) Len() int {
+	return len(c.blocks)
+}
+
+// Genesis returns the genesis block of the chain.
+func (c *Chain) Genesis() *types.Block {
+	return c.blocks[0]
+}
+
+// Head returns the last block of the chain.
+func (c *Chain) Head() *types.Block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+// TD returns the total difficulty of the chain up to and including the given
+// block number.
+func (c *Chain) TD(number uint64) *big.Int {
+	td := new(big.Int)
+	for _, block := range c.blocks[:number+1] {
+		td.Add(td, block.Difficulty())
+	}
+	return td
+}
+
+// ForkID returns the fork ID of the chain head.
+func (c *Chain) ForkID() forkid.ID {
+	return forkid.NewID(c.config, c.Genesis().Hash(), c.Head().NumberU64())
+}
+
+// Status returns the status message announcing the chain head.
+func (c *Chain) Status(version uint, network uint64) *Status {
+	head := c.Head()
+	return &Status{
+		ProtocolVersion: uint32(version),
+		NetworkID:       network,
+		TD:              c.TD(head.NumberU64()),
+		Head:            head.Hash(),
+		Genesis:         c.Genesis().Hash(),
+		ForkID:          c.ForkID(),
+	}
+}
+
+// blockByHash returns the block with the given hash, or nil if it's not part of
+// the chain.
+func (c *Chain) blockByHash(hash common.Hash) *types.Block {
+	for _, block := range c.blocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
+// GetHeaders returns the headers answering the given query, following the same
+// rules as a node serving it would.
+func (c *Chain) GetHeaders(req *GetBlockHeaders) BlockHeaders {
+	var number uint64
+	if req.Origin.Hash != (common.Hash{}) {
+		block := c.blockByHash(req.Origin.Hash)
+		if block == nil {
+			return nil
+		}
+		number = block.NumberU64()
+	} else {
+		number = req.Origin.Number
+	}
+	var headers BlockHeaders
+	for uint64(len(headers)) < req.Amount && number < uint64(len(c.blocks)) {
+		headers = append(headers, c.blocks[number].Header())
+
+		step := req.Skip + 1
+		if req.Reverse {
+			if number < step {
+				break
+			}
+			number -= step
+		} else {
+			number += step
+		}
+	}
+	return headers
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+// writeTestChain generates a chain of n blocks and writes it to dir in the
+// format of 'geth export', together with its genesis file.
+func writeTestChain(t *testing.T, dir string, n int) (string, string) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Difficulty: big.NewInt(131072), Alloc: core.GenesisAlloc{}}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, n, nil)
+
+	gblob, err := json.Marshal(gspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisfile := filepath.Join(dir, "genesis.json")
+	if err := ioutil.WriteFile(genesisfile, gblob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	chain := new(bytes.Buffer)
+	if err := rlp.Encode(chain, genesis); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		if err := rlp.Encode(chain, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chainfile := filepath.Join(dir, "chain.rlp")
+	if err := ioutil.WriteFile(chainfile, chain.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return chainfile, genesisfile
+}
+
+func TestLoadChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chain, err := LoadChain(writeTestChain(t, dir, 10))
+	if err != nil {
+		t.Fatalf("failed to load chain: %v", err)
+	}
+	if chain.Len() != 11 {
+		t.Fatalf("wrong chain length %d, want 11", chain.Len())
+	}
+	if chain.Head().NumberU64() != 10 {
+		t.Errorf("wrong head number %d, want 10", chain.Head().NumberU64())
+	}
+	if td := chain.TD(10); td.Cmp(chain.Head().Difficulty()) <= 0 {
+		t.Errorf("total difficulty %v doesn't include ancestors", td)
+	}
+}
+
+func TestChainGetHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chain, err := LoadChain(writeTestChain(t, dir, 10))
+	if err != nil {
+		t.Fatalf("failed to load chain: %v", err)
+	}
+	tests := []struct {
+		req  GetBlockHeaders
+		want []uint64
+	}{
+		{GetBlockHeaders{Origin: hashOrNumber{Number: 1}, Amount: 3}, []uint64{1, 2, 3}},
+		{GetBlockHeaders{Origin: hashOrNumber{Number: 0}, Amount: 4, Skip: 2}, []uint64{0, 3, 6, 9}},
+		{GetBlockHeaders{Origin: hashOrNumber{Number: 9}, Amount: 5, Skip: 3}, []uint64{9}},
+		{GetBlockHeaders{Origin: hashOrNumber{Number: 10}, Amount: 3, Skip: 4, Reverse: true}, []uint64{10, 5, 0}},
+		{GetBlockHeaders{Origin: hashOrNumber{Number: 2}, Amount: 3, Reverse: true}, []uint64{2, 1, 0}},
+		{GetBlockHeaders{Origin: hashOrNumber{Hash: chain.blocks[5].Hash()}, Amount: 2}, []uint64{5, 6}},
+		{GetBlockHeaders{Origin: hashOrNumber{Number: 11}, Amount: 1}, nil},
+		{GetBlockHeaders{Origin: hashOrNumber{Hash: common.HexToHash("0xdeadbeef")}, Amount: 1}, nil},
+	}
+	for i, tt := range tests {
+		headers := chain.GetHeaders(&tt.req)
+		if len(headers) != len(tt.want) {
+			t.Errorf("test %d: wrong number of headers %d, want %d", i, len(headers), len(tt.want))
+			continue
+		}
+		for j, header := range headers {
+			if header.Number.Uint64() != tt.want[j] {
+				t.Errorf("test %d: header %d has number %d, want %d", i, j, header.Number, tt.want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/p2p"
+	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+const (
+	dialTimeout     = 10 * time.Second // Maximum time to set up a connection
+	responseTimeout = 10 * time.Second // Maximum time to wait for a message of the node
+)
+
+var (
+	errDisconnected = errors.New("disconnected by the node")
+	errTimeout      = errors.New("timeout waiting for message")
+)
+
+// Msg is a message received from the node under test.
+type Msg struct {
+	Code uint64
+	Data []byte
+}
+
+// Conn is an ess protocol connection to the node under test. The connection is
+// set up by a p2p server of its own, so it goes through the same RLPx and devp2p
+// handshakes as any other peer of the node.
+type Conn struct {
+	version uint
+	srv     *p2p.Server
+	rw      p2p.MsgReadWriter
+	chain   *Chain // Chain to serve the requests of the node from, set by Handshake
+
+	in      chan Msg      // Messages received from the node, closed on disconnect
+	closing chan struct{} // Closed when the connection is torn down locally
+	reqID   uint64        // ID of the last request sent since ess/65
+}
+
+// Dial connects to the given node over RLPx and negotiates the given version of
+// the ess protocol. The protocol handshake is not done yet.
+func Dial(dest *discover.Node, version uint) (*Conn, error) {
+	length, ok := protocolLength(version)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ess protocol version %d", version)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{
+		version: version,
+		in:      make(chan Msg, 64),
+		closing: make(chan struct{}),
+	}
+	connected := make(chan p2p.MsgReadWriter, 1)
+	c.srv = &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		Name:        "ethtest",
+		MaxPeers:    1,
+		NoDiscovery: true,
+		NoDial:      true,
+		Protocols: []p2p.Protocol{{
+			Name:    protocolName,
+			Version: version,
+			Length:  length,
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				connected <- rw
+				return c.readLoop(rw)
+			},
+		}},
+	}}
+	if err := c.srv.Start(); err != nil {
+		return nil, err
+	}
+	fd, err := net.DialTimeout("tcp", fmt.Sprintf("%v:%d", dest.IP, dest.TCP), dialTimeout)
+	if err != nil {
+		c.srv.Stop()
+		return nil, err
+	}
+	if err := c.srv.SetupConn(fd, 0, dest); err != nil {
+		c.srv.Stop()
+		return nil, fmt.Errorf("connection setup failed: %v", err)
+	}
+	select {
+	case c.rw = <-connected:
+		return c, nil
+	case <-time.After(dialTimeout):
+		c.srv.Stop()
+		return nil, errors.New("ess protocol not started")
+	}
+}
+
+// readLoop forwards the messages of the node until the connection goes down.
+func (c *Conn) readLoop(rw p2p.MsgReadWriter) error {
+	defer close(c.in)
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(msg.Payload)
+		if err != nil {
+			return err
+		}
+		select {
+		case c.in <- Msg{Code: msg.Code, Data: data}:
+		case <-c.closing:
+			return nil
+		}
+	}
+}
+
+// Close disconnects from the node.
+func (c *Conn) Close() {
+	close(c.closing)
+	c.srv.Stop()
+}
+
+// Write sends a message to the node.
+func (c *Conn) Write(code uint64, data interface{}) error {
+	return p2p.Send(c.rw, code, data)
+}
+
+// WriteRaw sends a message with the given payload to the node, regardless of
+// whether it is valid RLP.
+func (c *Conn) WriteRaw(code uint64, payload []byte) error {
+	return c.rw.WriteMsg(p2p.Msg{Code: code, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+}
+
+// Read returns the next message received from the node.
+func (c *Conn) Read(timeout time.Duration) (Msg, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case msg, ok := <-c.in:
+		if !ok {
+			return Msg{}, errDisconnected
+		}
+		return msg, nil
+	case <-timer.C:
+		return Msg{}, errTimeout
+	}
+}
+
+// ReadMsg waits for a message with the given code. Block and transaction
+// propagation is skipped and header requests of the node are served from the
+// chain passed to Handshake, anything else is an error.
+func (c *Conn) ReadMsg(code uint64, timeout time.Duration) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := c.Read(time.Until(deadline))
+		if err != nil {
+			return nil, err
+		}
+		switch msg.Code {
+		case code:
+			return msg.Data, nil
+		case NewBlockHashesMsg, TxMsg, NewBlockMsg, NewPooledTransactionHashesMsg:
+		case GetBlockHeadersMsg:
+			if err := c.serveHeaders(msg.Data); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected message: code %d, want %d", msg.Code, code)
+		}
+	}
+}
+
+// serveHeaders answers a header request of the node, e.g. the DAO challenge.
+func (c *Conn) serveHeaders(data []byte) error {
+	var (
+		req GetBlockHeaders
+		id  uint64
+	)
+	if c.version >= 65 {
+		var packet requestPacket
+		if err := rlp.DecodeBytes(data, &packet); err != nil {
+			return fmt.Errorf("invalid header request of the node: %v", err)
+		}
+		id, data = packet.RequestID, packet.Data
+	}
+	if err := rlp.DecodeBytes(data, &req); err != nil {
+		return fmt.Errorf("invalid header request of the node: %v", err)
+	}
+	var headers BlockHeaders
+	if c.chain != nil {
+		headers = c.chain.GetHeaders(&req)
+	}
+	if c.version >= 65 {
+		enc, err := rlp.EncodeToBytes(headers)
+		if err != nil {
+			return err
+		}
+		return c.Write(BlockHeadersMsg, &requestPacket{RequestID: id, Data: enc})
+	}
+	return c.Write(BlockHeadersMsg, headers)
+}
+
+// WaitForDisconnect waits for the node to drop the connection, discarding any
+// messages received meanwhile.
+func (c *Conn) WaitForDisconnect(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := c.Read(time.Until(deadline))
+		switch err {
+		case nil:
+		case errDisconnected:
+			return nil
+		case errTimeout:
+			return errors.New("node didn't disconnect")
+		default:
+			return err
+		}
+	}
+}
+
+// ReadStatus reads the status message of the node and checks that it matches
+// the negotiated protocol version and the genesis of the given chain.
+func (c *Conn) ReadStatus(chain *Chain) (*Status, error) {
+	msg, err := c.Read(responseTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Code != StatusMsg {
+		return nil, fmt.Errorf("first message has code %d, want status", msg.Code)
+	}
+	status := new(Status)
+	if err := rlp.DecodeBytes(msg.Data, status); err != nil {
+		return nil, fmt.Errorf("invalid status message: %v", err)
+	}
+	if status.ProtocolVersion != uint32(c.version) {
+		return status, fmt.Errorf("status announces version %d, want %d", status.ProtocolVersion, c.version)
+	}
+	if want := chain.Genesis().Hash(); status.Genesis != want {
+		return status, fmt.Errorf("status announces genesis %x, want %x", status.Genesis, want)
+	}
+	return status, nil
+}
+
+// Handshake exchanges status messages with the node, announcing the head of the
+// given chain. The chain is also used to serve requests of the node afterwards.
+func (c *Conn) Handshake(chain *Chain) (*Status, error) {
+	status, err := c.ReadStatus(chain)
+	if err != nil {
+		return status, err
+	}
+	if err := c.Write(StatusMsg, chain.Status(c.version, status.NetworkID)); err != nil {
+		return status, err
+	}
+	c.chain = chain
+	return status, nil
+}
+
+// request sends a request message, wrapped into a request packet with a fresh
+// ID since ess/65. It returns the ID of the request.
+func (c *Conn) request(code uint64, data interface{}) (uint64, error) {
+	if c.version < 65 {
+		return 0, c.Write(code, data)
+	}
+	enc, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return 0, err
+	}
+	c.reqID++
+	return c.reqID, c.Write(code, &requestPacket{RequestID: c.reqID, Data: enc})
+}
+
+// response waits for the response message with the given code and decodes it,
+// checking that it answers the request with the given ID since ess/65.
+func (c *Conn) response(code uint64, id uint64, data interface{}) error {
+	payload, err := c.ReadMsg(code, responseTimeout)
+	if err != nil {
+		return err
+	}
+	if c.version >= 65 {
+		var packet requestPacket
+		if err := rlp.DecodeBytes(payload, &packet); err != nil {
+			return fmt.Errorf("invalid response packet: %v", err)
+		}
+		if packet.RequestID != id {
+			return fmt.Errorf("response has request ID %d, want %d", packet.RequestID, id)
+		}
+		payload = packet.Data
+	}
+	if err := rlp.DecodeBytes(payload, data); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// GetBlockHeaders retrieves the headers answering the given query.
+func (c *Conn) GetBlockHeaders(req *GetBlockHeaders) (BlockHeaders, error) {
+	id, err := c.request(GetBlockHeadersMsg, req)
+	if err != nil {
+		return nil, err
+	}
+	var headers BlockHeaders
+	return headers, c.response(BlockHeadersMsg, id, &headers)
+}
+
+// GetBlockBodies retrieves the bodies of the blocks with the given hashes.
+func (c *Conn) GetBlockBodies(hashes []common.Hash) (BlockBodies, error) {
+	id, err := c.request(GetBlockBodiesMsg, GetBlockBodies(hashes))
+	if err != nil {
+		return nil, err
+	}
+	var bodies BlockBodies
+	return bodies, c.response(BlockBodiesMsg, id, &bodies)
+}
+
+// GetReceipts retrieves the receipts of the blocks with the given hashes.
+func (c *Conn) GetReceipts(hashes []common.Hash) (Receipts, error) {
+	id, err := c.request(GetReceiptsMsg, GetReceipts(hashes))
+	if err != nil {
+		return nil, err
+	}
+	var receipts Receipts
+	return receipts, c.response(ReceiptsMsg, id, &receipts)
+}
@@ -0,0 +1,306 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ethtest implements a conformance test suite for the ess wire protocol.
+// The suite connects to a running node which has been initialized with a known
+// test chain and checks its answers against that chain.
+package ethtest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/internal/utesting"
+	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+// Versions are the ess protocol versions tested by the suite.
+var Versions = []uint{64, 65, 66, 67}
+
+// disconnectTimeout is the time the node may take to drop a misbehaving peer.
+const disconnectTimeout = 10 * time.Second
+
+// Suite represents a structure used to test the ess protocol of a node.
+type Suite struct {
+	Dest  *discover.Node
+	chain *Chain
+}
+
+// NewSuite creates a suite testing the node at dest, which must have been
+// initialized with the given chain and genesis files.
+func NewSuite(dest *discover.Node, chainfile string, genesisfile string) (*Suite, error) {
+	chain, err := LoadChain(chainfile, genesisfile)
+	if err != nil {
+		return nil, err
+	}
+	if chain.Len() < 2 {
+		return nil, fmt.Errorf("test chain has no blocks besides the genesis")
+	}
+	return &Suite{Dest: dest, chain: chain}, nil
+}
+
+// AllTests returns all tests of the suite, for each tested protocol version.
+func (s *Suite) AllTests() []utesting.Test {
+	tests := []struct {
+		name string
+		fn   func(*utesting.T, uint)
+	}{
+		{"Status", s.testStatus},
+		{"StatusMismatch", s.testStatusMismatch},
+		{"GetBlockHeaders", s.testGetBlockHeaders},
+		{"GetBlockBodies", s.testGetBlockBodies},
+		{"GetReceipts", s.testGetReceipts},
+		{"MalformedRequest", s.testMalformedRequest},
+		{"UnknownMessage", s.testUnknownMessage},
+		{"OversizedMessage", s.testOversizedMessage},
+	}
+	var all []utesting.Test
+	for _, version := range Versions {
+		for _, test := range tests {
+			version, fn := version, test.fn
+			all = append(all, utesting.Test{
+				Name: fmt.Sprintf("%s/ess%d", test.name, version),
+				Fn:   func(t *utesting.T) { fn(t, version) },
+			})
+		}
+	}
+	return all
+}
+
+// dial connects to the node, failing the test on error.
+func (s *Suite) dial(t *utesting.T, version uint) *Conn {
+	conn, err := Dial(s.Dest, version)
+	if err != nil {
+		t.Fatalf("could not connect to node: %v", err)
+	}
+	return conn
+}
+
+// connect connects to the node and does the status handshake, failing the test
+// on error.
+func (s *Suite) connect(t *utesting.T, version uint) *Conn {
+	conn := s.dial(t, version)
+	if _, err := conn.Handshake(s.chain); err != nil {
+		conn.Close()
+		t.Fatalf("handshake failed: %v", err)
+	}
+	return conn
+}
+
+// testStatus checks that the node announces the head of the test chain and
+// accepts a peer on the same chain.
+func (s *Suite) testStatus(t *utesting.T, version uint) {
+	conn := s.dial(t, version)
+	defer conn.Close()
+
+	status, err := conn.Handshake(s.chain)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	t.Logf("node status: %v", status)
+
+	head := s.chain.Head()
+	if status.Head != head.Hash() {
+		t.Errorf("wrong head block %x, want %x", status.Head, head.Hash())
+	}
+	if want := s.chain.TD(head.NumberU64()); status.TD == nil || status.TD.Cmp(want) != 0 {
+		t.Errorf("wrong total difficulty %v, want %v", status.TD, want)
+	}
+	if want := s.chain.ForkID(); status.ForkID != want {
+		t.Errorf("wrong fork ID %x/%d, want %x/%d", status.ForkID.Hash, status.ForkID.Next, want.Hash, want.Next)
+	}
+	// The connection should stay up after a valid handshake
+	switch _, err := conn.ReadMsg(StatusMsg, time.Second); err {
+	case errTimeout:
+	case errDisconnected:
+		t.Fatal("node disconnected after a valid handshake")
+	case nil:
+		t.Fatal("node sent a second status message")
+	default:
+		t.Fatalf("unexpected message after handshake: %v", err)
+	}
+}
+
+// testStatusMismatch checks that the node drops peers announcing a different
+// genesis block.
+func (s *Suite) testStatusMismatch(t *utesting.T, version uint) {
+	conn := s.dial(t, version)
+	defer conn.Close()
+
+	status, err := conn.ReadStatus(s.chain)
+	if err != nil {
+		t.Fatalf("invalid status: %v", err)
+	}
+	ours := s.chain.Status(version, status.NetworkID)
+	ours.Genesis = common.HexToHash("0xdeadbeef")
+	if err := conn.Write(StatusMsg, ours); err != nil {
+		t.Fatalf("could not send status: %v", err)
+	}
+	if err := conn.WaitForDisconnect(disconnectTimeout); err != nil {
+		t.Fatalf("peer with wrong genesis not dropped: %v", err)
+	}
+}
+
+// testGetBlockHeaders checks the answers to various header queries.
+func (s *Suite) testGetBlockHeaders(t *utesting.T, version uint) {
+	conn := s.connect(t, version)
+	defer conn.Close()
+
+	var (
+		head   = s.chain.Head().NumberU64()
+		middle = s.chain.blocks[head/2].Hash()
+	)
+	tests := []*GetBlockHeaders{
+		{Origin: hashOrNumber{Number: 1}, Amount: 5},
+		{Origin: hashOrNumber{Number: 0}, Amount: 4, Skip: 2},
+		{Origin: hashOrNumber{Number: head}, Amount: 4, Skip: 1, Reverse: true},
+		{Origin: hashOrNumber{Hash: middle}, Amount: 3},
+		{Origin: hashOrNumber{Hash: middle}, Amount: 3, Skip: 1, Reverse: true},
+		{Origin: hashOrNumber{Number: head + 1}, Amount: 1},
+		{Origin: hashOrNumber{Hash: common.HexToHash("0xdeadbeef")}, Amount: 1},
+	}
+	for i, req := range tests {
+		headers, err := conn.GetBlockHeaders(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		want := s.chain.GetHeaders(req)
+		if len(headers) != len(want) {
+			t.Errorf("request %d: wrong number of headers %d, want %d", i, len(headers), len(want))
+			continue
+		}
+		for j := range headers {
+			if headers[j].Hash() != want[j].Hash() {
+				t.Errorf("request %d: header %d mismatch: have %x, want %x", i, j, headers[j].Hash(), want[j].Hash())
+			}
+		}
+	}
+}
+
+// requestedBlocks returns the blocks retrieved by the body and receipt tests:
+// up to ten blocks from the start of the chain.
+func (s *Suite) requestedBlocks() ([]*types.Block, []common.Hash) {
+	blocks := s.chain.blocks[1:]
+	if len(blocks) > 10 {
+		blocks = blocks[:10]
+	}
+	hashes := make([]common.Hash, len(blocks))
+	for i, block := range blocks {
+		hashes[i] = block.Hash()
+	}
+	return blocks, hashes
+}
+
+// testGetBlockBodies checks that the node serves the bodies of the chain.
+func (s *Suite) testGetBlockBodies(t *utesting.T, version uint) {
+	conn := s.connect(t, version)
+	defer conn.Close()
+
+	blocks, hashes := s.requestedBlocks()
+	bodies, err := conn.GetBlockBodies(hashes)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if len(bodies) != len(blocks) {
+		t.Fatalf("wrong number of bodies %d, want %d", len(bodies), len(blocks))
+	}
+	for i, body := range bodies {
+		header := blocks[i].Header()
+		if hash := types.DeriveSha(types.Transactions(body.Transactions)); hash != header.TxHash {
+			t.Errorf("body %d: transactions don't match the header: hash %x, want %x", i, hash, header.TxHash)
+		}
+		if hash := types.CalcUncleHash(body.Uncles); hash != header.UncleHash {
+			t.Errorf("body %d: uncles don't match the header: hash %x, want %x", i, hash, header.UncleHash)
+		}
+	}
+	// Unknown blocks are skipped
+	bodies, err = conn.GetBlockBodies([]common.Hash{common.HexToHash("0xdeadbeef")})
+	if err != nil {
+		t.Fatalf("request of unknown body failed: %v", err)
+	}
+	if len(bodies) != 0 {
+		t.Errorf("node served %d bodies for an unknown block", len(bodies))
+	}
+}
+
+// testGetReceipts checks that the node serves the receipts of the chain.
+func (s *Suite) testGetReceipts(t *utesting.T, version uint) {
+	conn := s.connect(t, version)
+	defer conn.Close()
+
+	blocks, hashes := s.requestedBlocks()
+	receipts, err := conn.GetReceipts(hashes)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if len(receipts) != len(blocks) {
+		t.Fatalf("wrong number of receipt lists %d, want %d", len(receipts), len(blocks))
+	}
+	for i, list := range receipts {
+		want := blocks[i].ReceiptHash()
+		if hash := types.DeriveSha(list); hash != want {
+			t.Errorf("receipts %d: don't match the header: hash %x, want %x", i, hash, want)
+		}
+	}
+}
+
+// testMalformedRequest checks that the node drops peers sending requests which
+// can't be decoded.
+func (s *Suite) testMalformedRequest(t *utesting.T, version uint) {
+	conn := s.connect(t, version)
+	defer conn.Close()
+
+	// Header query with an origin that is neither a hash nor a number
+	req, _ := rlp.EncodeToBytes([]interface{}{make([]byte, 40), uint64(1), uint64(0), false})
+	if _, err := conn.request(GetBlockHeadersMsg, rlp.RawValue(req)); err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+	if err := conn.WaitForDisconnect(disconnectTimeout); err != nil {
+		t.Fatalf("peer sending malformed request not dropped: %v", err)
+	}
+}
+
+// testUnknownMessage checks that the node drops peers sending messages with a
+// code which isn't used by the protocol.
+func (s *Suite) testUnknownMessage(t *utesting.T, version uint) {
+	conn := s.connect(t, version)
+	defer conn.Close()
+
+	// Codes 0x0b and 0x0c are unused by all tested versions
+	if err := conn.WriteRaw(0x0b, []byte{0xc0}); err != nil {
+		t.Fatalf("could not send message: %v", err)
+	}
+	if err := conn.WaitForDisconnect(disconnectTimeout); err != nil {
+		t.Fatalf("peer sending unknown message not dropped: %v", err)
+	}
+}
+
+// testOversizedMessage checks that the node drops peers sending messages which
+// exceed the protocol's size limit.
+func (s *Suite) testOversizedMessage(t *utesting.T, version uint) {
+	conn := s.connect(t, version)
+	defer conn.Close()
+
+	if err := conn.WriteRaw(TxMsg, make([]byte, protocolMaxMsgSize+1)); err != nil {
+		t.Fatalf("could not send message: %v", err)
+	}
+	if err := conn.WaitForDisconnect(disconnectTimeout); err != nil {
+		t.Fatalf("peer sending oversized message not dropped: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	ess "github.com/orangeAndSuns/go-ethereum/eth"
+	"github.com/orangeAndSuns/go-ethereum/internal/utesting"
+	"github.com/orangeAndSuns/go-ethereum/node"
+	"github.com/orangeAndSuns/go-ethereum/p2p"
+)
+
+// Runs the suite against an in-process node initialized with the test chain.
+func TestEthSuite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chainfile, genesisfile := writeTestChain(t, dir, 20)
+	stack := runNode(t, chainfile, genesisfile)
+	defer stack.Stop()
+
+	suite, err := NewSuite(stack.Server().Self(), chainfile, genesisfile)
+	if err != nil {
+		t.Fatalf("could not create suite: %v", err)
+	}
+	for _, test := range suite.AllTests() {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			if failed, output := utesting.Run(test); failed {
+				t.Fatal(output)
+			}
+		})
+	}
+}
+
+// runNode starts a node serving the ess protocol, with the given chain imported.
+func runNode(t *testing.T, chainfile string, genesisfile string) *node.Node {
+	chain, err := LoadChain(chainfile, genesisfile)
+	if err != nil {
+		t.Fatalf("could not load chain: %v", err)
+	}
+	blob, err := ioutil.ReadFile(genesisfile)
+	if err != nil {
+		t.Fatalf("could not read genesis: %v", err)
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(blob, genesis); err != nil {
+		t.Fatalf("invalid genesis: %v", err)
+	}
+	stack, err := node.New(&node.Config{
+		Name: "ethtest-node",
+		P2P: p2p.Config{
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			NoDial:      true,
+			MaxPeers:    10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	config := ess.DefaultConfig
+	config.Genesis = genesis
+	config.Ethash.PowMode = ethash.ModeFake
+	config.TxPool.Journal = ""
+
+	var backend *ess.Essentia
+	err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var err error
+		backend, err = ess.New(ctx, &config)
+		return backend, err
+	})
+	if err != nil {
+		t.Fatalf("could not register ess service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	if _, err := backend.BlockChain().InsertChain(chain.blocks[1:]); err != nil {
+		stack.Stop()
+		t.Fatalf("could not import chain: %v", err)
+	}
+	return stack
+}
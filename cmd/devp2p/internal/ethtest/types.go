@@ -0,0 +1,145 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	ess "github.com/orangeAndSuns/go-ethereum/eth"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+// The protocol parameters and message codes are shared with the protocol
+// implementation of the node. The network packets are defined by the suite on
+// its own, so it checks the encoding on the wire rather than the node's types.
+var (
+	protocolName       = ess.ProtocolName
+	protocolMaxMsgSize = ess.ProtocolMaxMsgSize
+)
+
+// protocolLength returns the number of message codes of a protocol version, or
+// false if the version isn't supported by the node.
+func protocolLength(version uint) (uint64, bool) {
+	for i, v := range ess.ProtocolVersions {
+		if v == version {
+			return ess.ProtocolLengths[i], true
+		}
+	}
+	return 0, false
+}
+
+// Message codes of the ess protocol used by the tests.
+const (
+	StatusMsg          = ess.StatusMsg
+	NewBlockHashesMsg  = ess.NewBlockHashesMsg
+	TxMsg              = ess.TxMsg
+	GetBlockHeadersMsg = ess.GetBlockHeadersMsg
+	BlockHeadersMsg    = ess.BlockHeadersMsg
+	GetBlockBodiesMsg  = ess.GetBlockBodiesMsg
+	BlockBodiesMsg     = ess.BlockBodiesMsg
+	NewBlockMsg        = ess.NewBlockMsg
+	GetReceiptsMsg     = ess.GetReceiptsMsg
+	ReceiptsMsg        = ess.ReceiptsMsg
+
+	NewPooledTransactionHashesMsg = ess.NewPooledTransactionHashesMsg
+)
+
+// Status is the network packet for the status message of ess/64 and later.
+type Status struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+}
+
+func (s *Status) String() string {
+	return fmt.Sprintf("[version %d, network %d, td %v, head %x, genesis %x, forkid %x/%d]",
+		s.ProtocolVersion, s.NetworkID, s.TD, s.Head[:8], s.Genesis[:8], s.ForkID.Hash, s.ForkID.Next)
+}
+
+// requestPacket wraps requests and responses since ess/65, pairing them up by
+// the request ID. Data is the message content as sent in earlier versions.
+type requestPacket struct {
+	RequestID uint64
+	Data      rlp.RawValue
+}
+
+// GetBlockHeaders represents a block header query.
+type GetBlockHeaders struct {
+	Origin  hashOrNumber
+	Amount  uint64
+	Skip    uint64
+	Reverse bool
+}
+
+// hashOrNumber is a combined field for specifying an origin block.
+type hashOrNumber struct {
+	Hash   common.Hash
+	Number uint64
+}
+
+// EncodeRLP is a specialized encoder for hashOrNumber to encode only one of the
+// two contained union fields.
+func (hn *hashOrNumber) EncodeRLP(w io.Writer) error {
+	if hn.Hash == (common.Hash{}) {
+		return rlp.Encode(w, hn.Number)
+	}
+	if hn.Number != 0 {
+		return fmt.Errorf("both origin hash (%x) and number (%d) provided", hn.Hash, hn.Number)
+	}
+	return rlp.Encode(w, hn.Hash)
+}
+
+// DecodeRLP is a specialized decoder for hashOrNumber to decode the contents
+// into either a block hash or a block number.
+func (hn *hashOrNumber) DecodeRLP(s *rlp.Stream) error {
+	_, size, _ := s.Kind()
+	origin, err := s.Raw()
+	if err == nil {
+		switch {
+		case size == 32:
+			err = rlp.DecodeBytes(origin, &hn.Hash)
+		case size <= 8:
+			err = rlp.DecodeBytes(origin, &hn.Number)
+		default:
+			err = fmt.Errorf("invalid input size %d for origin", size)
+		}
+	}
+	return err
+}
+
+// BlockHeaders is the network packet for block header responses.
+type BlockHeaders []*types.Header
+
+// GetBlockBodies is the network packet for block body queries.
+type GetBlockBodies []common.Hash
+
+// BlockBodies is the network packet for block body responses.
+type BlockBodies []*types.Body
+
+// GetReceipts is the network packet for receipt queries.
+type GetReceipts []common.Hash
+
+// Receipts is the network packet for receipt responses.
+type Receipts []types.Receipts
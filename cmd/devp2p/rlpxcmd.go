@@ -0,0 +1,76 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/orangeAndSuns/go-ethereum/cmd/devp2p/internal/ethtest"
+	"github.com/orangeAndSuns/go-ethereum/internal/utesting"
+	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	rlpxCommand = cli.Command{
+		Name:  "rlpx",
+		Usage: "RLPx Commands",
+		Subcommands: []cli.Command{
+			rlpxEthTestCommand,
+		},
+	}
+	rlpxEthTestCommand = cli.Command{
+		Name:      "eth-test",
+		Usage:     "Runs tests against a node",
+		ArgsUsage: "<node> <chain.rlp> <genesis.json>",
+		Action:    rlpxEthTest,
+		Flags:     []cli.Flag{testPatternFlag},
+	}
+)
+
+var (
+	testPatternFlag = cli.StringFlag{
+		Name:  "run",
+		Usage: "Run only tests whose name contains the given string",
+	}
+)
+
+// rlpxEthTest performs rlpxEthTestCommand.
+func rlpxEthTest(ctx *cli.Context) error {
+	if ctx.NArg() < 3 {
+		return fmt.Errorf("need node URL, chain file and genesis file as arguments")
+	}
+	node, err := discover.ParseNode(ctx.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("invalid node URL: %v", err)
+	}
+	suite, err := ethtest.NewSuite(node, ctx.Args().Get(1), ctx.Args().Get(2))
+	if err != nil {
+		return err
+	}
+	tests := suite.AllTests()
+	if ctx.IsSet(testPatternFlag.Name) {
+		tests = utesting.MatchTests(tests, ctx.String(testPatternFlag.Name))
+	}
+	results := utesting.RunTests(tests, os.Stdout)
+	if fails := utesting.CountFailures(results); fails > 0 {
+		return fmt.Errorf("%v of %v tests passed.", len(tests)-fails, len(tests))
+	}
+	fmt.Printf("%v tests passed.\n", len(tests))
+	return nil
+}
@@ -175,6 +175,8 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
+	// Add the GraphQL query server if requested.
+	utils.RegisterGraphQLService(stack, ctx)
 	return stack
 }
 
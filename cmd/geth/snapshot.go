@@ -0,0 +1,97 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/orangeAndSuns/go-ethereum/cmd/utils"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/core/state/pruner"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "A set of commands based on the state of the chain",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+Offline maintenance operations on the persisted chain state.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Prune stale state data from the database",
+				ArgsUsage: "[<root>]",
+				Action:    utils.MigrateFlags(pruneState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					utils.BloomFilterSizeFlag,
+				},
+				Description: `
+geth snapshot prune-state <state-root>
+will prune historical state data with the help of a bloom filter. All the trie
+nodes and contract codes not belonging to the specified state (or to the most
+recent state available on disk if none is given), to the genesis state or to
+the recent states persisted on shutdown will be deleted from the database.
+
+The bloom filter is written to the data directory before anything is deleted,
+so an interrupted pruning is automatically resumed by rerunning the command or
+by starting the node. The node must not be running while pruning.
+
+The bloom filter size can be configured with --bloomfilter.size; a larger filter
+retains fewer stale entries due to false positives.`,
+			},
+		},
+	}
+)
+
+// pruneState walks the live state, collects it into a bloom filter and deletes
+// everything else from the chain database.
+func pruneState(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		utils.Fatalf("This command requires at most one argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	chaindb := utils.MakeChainDatabase(ctx, stack)
+	defer chaindb.Close()
+
+	var root common.Hash
+	if ctx.NArg() == 1 {
+		blob, err := hexutil.Decode(ctx.Args().First())
+		if err != nil || len(blob) != common.HashLength {
+			utils.Fatalf("Invalid state root: %s", ctx.Args().First())
+		}
+		root = common.BytesToHash(blob)
+	}
+	size := ctx.Uint64(utils.BloomFilterSizeFlag.Name)
+	if size < 256 {
+		log.Warn("Small bloom filter configured, pruning will be less effective", "size", size)
+	}
+	statePruner, err := pruner.NewPruner(chaindb, stack.ResolvePath(""), size)
+	if err != nil {
+		utils.Fatalf("Failed to open state pruner: %v", err)
+	}
+	if err := statePruner.Prune(root); err != nil {
+		utils.Fatalf("Failed to prune state: %v", err)
+	}
+	return nil
+}
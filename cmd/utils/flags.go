@@ -33,10 +33,12 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/accounts/keystore"
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/common/fdlimit"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
 	"github.com/orangeAndSuns/go-ethereum/consensus"
 	"github.com/orangeAndSuns/go-ethereum/consensus/clique"
 	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
 	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
 	"github.com/orangeAndSuns/go-ethereum/core/state"
 	"github.com/orangeAndSuns/go-ethereum/core/vm"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
@@ -46,10 +48,12 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/eth/gasprice"
 	"github.com/orangeAndSuns/go-ethereum/ethdb"
 	"github.com/orangeAndSuns/go-ethereum/ethstats"
+	"github.com/orangeAndSuns/go-ethereum/graphql"
 	"github.com/orangeAndSuns/go-ethereum/les"
 	"github.com/orangeAndSuns/go-ethereum/log"
 	"github.com/orangeAndSuns/go-ethereum/metrics"
 	"github.com/orangeAndSuns/go-ethereum/metrics/influxdb"
+	"github.com/orangeAndSuns/go-ethereum/miner"
 	"github.com/orangeAndSuns/go-ethereum/node"
 	"github.com/orangeAndSuns/go-ethereum/p2p"
 	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
@@ -120,6 +124,15 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	AncientThresholdFlag = cli.Uint64Flag{
+		Name:  "ancient.threshold",
+		Usage: "Number of recent blocks to keep in the key-value store before moving them to the ancient store",
+		Value: params.ImmutabilityThreshold,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -251,9 +264,14 @@ var (
 		Usage: "Disk journal for local transaction to survive node restarts",
 		Value: core.DefaultTxPoolConfig.Journal,
 	}
+	TxPoolFullJournalFlag = cli.StringFlag{
+		Name:  "txpool.fulljournal",
+		Usage: "Disk journal for the whole transaction pool, remote transactions included, unlike the locals-only --txpool.journal (disabled if empty)",
+		Value: core.DefaultTxPoolConfig.FullJournal,
+	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
-		Usage: "Time interval to regenerate the local transaction journal",
+		Usage: "Time interval to regenerate the transaction journals",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
@@ -291,6 +309,18 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: ess.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolDenySendersFlag = cli.StringFlag{
+		Name:  "txpool.denysenders",
+		Usage: "Comma separated senders whose transactions are rejected by the pool",
+	}
+	TxPoolDenyRecipientsFlag = cli.StringFlag{
+		Name:  "txpool.denyrecipients",
+		Usage: "Comma separated recipients whose transactions are rejected by the pool",
+	}
+	TxPoolDenyDataFlag = cli.StringFlag{
+		Name:  "txpool.denydata",
+		Usage: "Comma separated hex prefixes of call data rejected by the pool (e.g. method selectors)",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -307,6 +337,11 @@ var (
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
 		Value: 25,
 	}
+	BloomFilterSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to the bloom filter used for state pruning",
+		Value: 2048,
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -341,6 +376,24 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerRecommitIntervalFlag = cli.DurationFlag{
+		Name:  "minerrecommit",
+		Usage: "Time interval to recreate the block being mined",
+		Value: ess.DefaultConfig.MinerRecommit,
+	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "minerordering",
+		Usage: `Transaction ordering policy of mined blocks ("price", "priority", "fifo" or "quota")`,
+		Value: miner.PriceOrderingName,
+	}
+	MinerPriorityFlag = cli.StringFlag{
+		Name:  "minerpriority",
+		Usage: "Comma separated senders whose transactions are included first by the priority ordering",
+	}
+	MinerQuotaFlag = cli.Uint64Flag{
+		Name:  "minerquota",
+		Usage: "Gas each sender may use per mined block with the quota ordering",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -432,6 +485,30 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL query server",
+	}
+	GraphQLListenAddrFlag = cli.StringFlag{
+		Name:  "graphql.addr",
+		Usage: "GraphQL server listening interface",
+		Value: node.DefaultGraphQLHost,
+	}
+	GraphQLPortFlag = cli.IntFlag{
+		Name:  "graphql.port",
+		Usage: "GraphQL server listening port",
+		Value: node.DefaultGraphQLPort,
+	}
+	GraphQLCORSDomainFlag = cli.StringFlag{
+		Name:  "graphql.corsdomain",
+		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
+		Value: "",
+	}
+	GraphQLVirtualHostsFlag = cli.StringFlag{
+		Name:  "graphql.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.HTTPVirtualHosts, ","),
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -497,6 +574,10 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to dial nodes from",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -679,6 +760,18 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setDNSDiscovery sets the URLs of the DNS node lists from the command line flags.
+func setDNSDiscovery(ctx *cli.Context, cfg *p2p.Config) {
+	if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
+		cfg.DNSDiscovery = nil
+		for _, url := range strings.Split(ctx.GlobalString(DNSDiscoveryFlag.Name), ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				cfg.DNSDiscovery = append(cfg.DNSDiscovery, url)
+			}
+		}
+	}
+}
+
 // setListenAddress creates a TCP listening address string from set command
 // line flags.
 func setListenAddress(ctx *cli.Context, cfg *p2p.Config) {
@@ -844,6 +937,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setListenAddress(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	setBootstrapNodesV5(ctx, cfg)
+	setDNSDiscovery(ctx, cfg)
 
 	lightClient := ctx.GlobalBool(LightModeFlag.Name) || ctx.GlobalString(SyncModeFlag.Name) == "light"
 	lightServer := ctx.GlobalInt(LightServFlag.Name) != 0
@@ -951,6 +1045,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
 		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolFullJournalFlag.Name) {
+		cfg.FullJournal = ctx.GlobalString(TxPoolFullJournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
@@ -975,6 +1072,33 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	var (
+		senders    = parseAddressList(ctx, TxPoolDenySendersFlag.Name)
+		recipients = parseAddressList(ctx, TxPoolDenyRecipientsFlag.Name)
+		data       [][]byte
+	)
+	for _, prefix := range splitAndTrim(ctx.GlobalString(TxPoolDenyDataFlag.Name)) {
+		blob, err := hexutil.Decode(prefix)
+		if err != nil {
+			Fatalf("Invalid call data prefix in --%s: %s", TxPoolDenyDataFlag.Name, prefix)
+		}
+		data = append(data, blob)
+	}
+	if len(senders) > 0 || len(recipients) > 0 || len(data) > 0 {
+		cfg.Admission = core.NewTxFilter(senders, recipients, data)
+	}
+}
+
+// parseAddressList parses the comma separated accounts of the given flag.
+func parseAddressList(ctx *cli.Context, name string) []common.Address {
+	var addrs []common.Address
+	for _, addr := range splitAndTrim(ctx.GlobalString(name)) {
+		if !common.IsHexAddress(addr) {
+			Fatalf("Invalid account in --%s: %s", name, addr)
+		}
+		addrs = append(addrs, common.HexToAddress(addr))
+	}
+	return addrs
 }
 
 func setEthash(ctx *cli.Context, cfg *ess.Config) {
@@ -1082,6 +1206,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ess.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientThresholdFlag.Name) {
+		cfg.DatabaseFreezerThreshold = ctx.GlobalUint64(AncientThresholdFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1103,6 +1233,31 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ess.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerRecommitIntervalFlag.Name) {
+		cfg.MinerRecommit = ctx.GlobalDuration(MinerRecommitIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.MinerOrdering = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPriorityFlag.Name) {
+		cfg.MinerPriority = nil
+		for _, addr := range splitAndTrim(ctx.GlobalString(MinerPriorityFlag.Name)) {
+			if !common.IsHexAddress(addr) {
+				Fatalf("Invalid sender in --%s: %s", MinerPriorityFlag.Name, addr)
+			}
+			cfg.MinerPriority = append(cfg.MinerPriority, common.HexToAddress(addr))
+		}
+	}
+	if ctx.GlobalIsSet(MinerQuotaFlag.Name) {
+		cfg.MinerQuota = ctx.GlobalUint64(MinerQuotaFlag.Name)
+	}
+	// Parameters of the other orderings would be silently ignored
+	if len(cfg.MinerPriority) > 0 && cfg.MinerOrdering != miner.PriorityOrderingName {
+		Fatalf("--%s requires --%s=%s", MinerPriorityFlag.Name, MinerOrderingFlag.Name, miner.PriorityOrderingName)
+	}
+	if cfg.MinerQuota > 0 && cfg.MinerOrdering != miner.QuotaOrderingName {
+		Fatalf("--%s requires --%s=%s", MinerQuotaFlag.Name, MinerOrderingFlag.Name, miner.QuotaOrderingName)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1124,6 +1279,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ess.Config) {
 		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
 			cfg.NetworkId = 1337
 		}
+		cfg.Developer = true
 		// Create new developer account or reuse existing one
 		var (
 			developer accounts.Account
@@ -1215,6 +1371,22 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterGraphQLService configures the GraphQL query server and adds it to
+// the given node if it was enabled on the command line.
+func RegisterGraphQLService(stack *node.Node, ctx *cli.Context) {
+	if !ctx.GlobalBool(GraphQLEnabledFlag.Name) {
+		return
+	}
+	var (
+		endpoint = fmt.Sprintf("%s:%d", ctx.GlobalString(GraphQLListenAddrFlag.Name), ctx.GlobalInt(GraphQLPortFlag.Name))
+		cors     = splitAndTrim(ctx.GlobalString(GraphQLCORSDomainFlag.Name))
+		vhosts   = splitAndTrim(ctx.GlobalString(GraphQLVirtualHostsFlag.Name))
+	)
+	if err := graphql.RegisterGraphQLService(stack, endpoint, cors, vhosts); err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
@@ -1256,6 +1428,19 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	// Full nodes move finalized chain segments into the ancient store
+	if !ctx.GlobalBool(LightModeFlag.Name) {
+		dir := ctx.GlobalString(AncientFlag.Name)
+		if dir == "" {
+			dir = filepath.Join(name, "ancient")
+		}
+		if dir = stack.ResolvePath(dir); dir != "" {
+			chainDb, err = rawdb.NewDatabaseWithFreezer(chainDb, dir, "ess/db/"+name+"/", ctx.GlobalUint64(AncientThresholdFlag.Name))
+			if err != nil {
+				Fatalf("Could not open ancient database: %v", err)
+			}
+		}
+	}
 	return chainDb
 }
 
@@ -72,6 +72,19 @@ func (b Bytes) String() string {
 	return Encode(b)
 }
 
+// ImplementsGraphQLType returns true if Bytes implements the specified GraphQL type.
+func (b Bytes) ImplementsGraphQLType(name string) bool { return name == "Bytes" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (b *Bytes) UnmarshalGraphQL(input interface{}) error {
+	switch input := input.(type) {
+	case string:
+		return b.UnmarshalText([]byte(input))
+	default:
+		return fmt.Errorf("unexpected type %T for Bytes", input)
+	}
+}
+
 // UnmarshalFixedJSON decodes the input as a string with 0x prefix. The length of out
 // determines the required input length. This function is commonly used to implement the
 // UnmarshalJSON method for fixed-size types.
@@ -187,6 +200,38 @@ func (b *Big) String() string {
 	return EncodeBig(b.ToInt())
 }
 
+// ImplementsGraphQLType returns true if Big implements the provided GraphQL type.
+func (b Big) ImplementsGraphQLType(name string) bool { return name == "BigInt" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data. Strings may be
+// either decimal or 0x-prefixed hexadecimal.
+func (b *Big) UnmarshalGraphQL(input interface{}) error {
+	switch input := input.(type) {
+	case string:
+		if has0xPrefix(input) {
+			return b.UnmarshalText([]byte(input))
+		}
+		num, ok := new(big.Int).SetString(input, 10)
+		if !ok {
+			return fmt.Errorf("invalid decimal string %q for BigInt", input)
+		}
+		if num.BitLen() > 256 {
+			return ErrBig256Range
+		}
+		*b = Big(*num)
+	case int32:
+		*b = Big(*new(big.Int).SetInt64(int64(input)))
+	case float64:
+		if input != float64(int64(input)) {
+			return fmt.Errorf("non-integral value %v for BigInt", input)
+		}
+		*b = Big(*new(big.Int).SetInt64(int64(input)))
+	default:
+		return fmt.Errorf("unexpected type %T for BigInt", input)
+	}
+	return nil
+}
+
 // Uint64 marshals/unmarshals as a JSON string with 0x prefix.
 // The zero value marshals as "0x0".
 type Uint64 uint64
@@ -0,0 +1,125 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/accounts"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/params"
+)
+
+// Tests that SealNow signs empty blocks on 0-period chains right away, producing
+// blocks the chain accepts, while refusing signers the chain would reject.
+func TestSealNow(t *testing.T) {
+	pool := newTesterAccountPool()
+	signers := []common.Address{pool.address("A"), pool.address("B")}
+	if bytes.Compare(signers[0][:], signers[1][:]) > 0 {
+		signers[0], signers[1] = signers[1], signers[0]
+	}
+	// Create a 0-period chain with two signers
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = &params.CliqueConfig{Period: 0, Epoch: 30000}
+
+	genspec := &core.Genesis{
+		Config:    &config,
+		ExtraData: make([]byte, extraVanity+common.AddressLength*len(signers)+extraSeal),
+	}
+	for i, signer := range signers {
+		copy(genspec.ExtraData[extraVanity+i*common.AddressLength:], signer[:])
+	}
+	db := ethdb.NewMemDatabase()
+	genesis := genspec.MustCommit(db)
+
+	engine := New(config.Clique, db)
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	authorize := func(signer string) {
+		engine.Authorize(pool.address(signer), func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, pool.accounts[signer])
+		})
+	}
+	// makeBlock assembles an empty block on top of parent for the current signer
+	makeBlock := func(parent *types.Block) *types.Block {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:   core.CalcGasLimit(parent),
+		}
+		if err := engine.Prepare(chain, header); err != nil {
+			t.Fatalf("failed to prepare header: %v", err)
+		}
+		statedb, err := chain.StateAt(parent.Root())
+		if err != nil {
+			t.Fatalf("failed to retrieve parent state: %v", err)
+		}
+		block, err := engine.Finalize(chain, header, statedb, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to finalize block: %v", err)
+		}
+		return block
+	}
+	authorize("A")
+	if _, err := engine.SealNow(chain, genesis); err != errUnknownBlock {
+		t.Errorf("genesis sealing error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+	// Regular sealing waits for transactions, instant sealing doesn't
+	block := makeBlock(genesis)
+	if _, err := engine.Seal(chain, block, nil); err != errWaitTransactions {
+		t.Errorf("empty block sealing error mismatch: have %v, want %v", err, errWaitTransactions)
+	}
+	sealed, err := engine.SealNow(chain, block)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if signer, err := ecrecover(sealed.Header(), engine.signatures); err != nil || signer != pool.address("A") {
+		t.Fatalf("block signer mismatch: have %x (%v), want %x", signer, err, pool.address("A"))
+	}
+	if _, err := chain.InsertChain(types.Blocks{sealed}); err != nil {
+		t.Fatalf("failed to import sealed block: %v", err)
+	}
+	// Signing again too soon would get the block rejected, so is refused
+	block = makeBlock(sealed)
+	if _, err := engine.SealNow(chain, block); err != errUnauthorized {
+		t.Errorf("recent signer sealing error mismatch: have %v, want %v", err, errUnauthorized)
+	}
+	authorize("B")
+	block = makeBlock(sealed)
+	if sealed, err = engine.SealNow(chain, block); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if _, err := chain.InsertChain(types.Blocks{sealed}); err != nil {
+		t.Fatalf("failed to import sealed block: %v", err)
+	}
+	// Outsiders are refused altogether
+	authorize("C")
+	if _, err := engine.SealNow(chain, makeBlock(sealed)); err != errUnauthorized {
+		t.Errorf("unauthorized sealing error mismatch: have %v, want %v", err, errUnauthorized)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+// TestCreation tests that different genesis and fork rule combinations result in
+// the correct fork ID.
+func TestCreation(t *testing.T) {
+	type testcase struct {
+		head uint64
+		want ID
+	}
+	tests := []struct {
+		config  *params.ChainConfig
+		genesis common.Hash
+		cases   []testcase
+	}{
+		// Mainnet test cases
+		{
+			params.MainnetChainConfig,
+			params.MainnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},       // Unsynced
+				{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}}, // Last Frontier block
+				{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, // First Homestead block
+				{1919999, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, // Last Homestead block
+				{1920000, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, // First DAO block
+				{2462999, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, // Last DAO block
+				{2463000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, // First Tangerine block
+				{2674999, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, // Last Tangerine block
+				{2675000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, // First Spurious block
+				{4369999, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, // Last Spurious block
+				{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}},       // First Byzantium block
+				{7000000, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}},       // Future Byzantium block
+			},
+		},
+		// Ropsten test cases
+		{
+			params.TestnetChainConfig,
+			params.TestnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x30c7ddbc), Next: 10}},            // Unsynced, last Frontier, Homestead and first Tangerine block
+				{9, ID{Hash: checksumToBytes(0x30c7ddbc), Next: 10}},            // Last Tangerine block
+				{10, ID{Hash: checksumToBytes(0x63760190), Next: 1700000}},      // First Spurious block
+				{1699999, ID{Hash: checksumToBytes(0x63760190), Next: 1700000}}, // Last Spurious block
+				{1700000, ID{Hash: checksumToBytes(0x3ea159c7), Next: 0}},       // First Byzantium block
+				{4000000, ID{Hash: checksumToBytes(0x3ea159c7), Next: 0}},       // Future Byzantium block
+			},
+		},
+		// Rinkeby test cases
+		{
+			params.RinkebyChainConfig,
+			params.RinkebyGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x3b8e0691), Next: 1}},             // Unsynced, last Frontier block
+				{1, ID{Hash: checksumToBytes(0x60949295), Next: 2}},             // First and last Homestead block
+				{2, ID{Hash: checksumToBytes(0x8bde40dd), Next: 3}},             // First and last Tangerine block
+				{3, ID{Hash: checksumToBytes(0xcb3a64bb), Next: 1035301}},       // First Spurious block
+				{1035300, ID{Hash: checksumToBytes(0xcb3a64bb), Next: 1035301}}, // Last Spurious block
+				{1035301, ID{Hash: checksumToBytes(0x8d748b57), Next: 0}},       // First Byzantium block
+				{4000000, ID{Hash: checksumToBytes(0x8d748b57), Next: 0}},       // Future Byzantium block
+			},
+		},
+	}
+	for i, tt := range tests {
+		for j, ttt := range tt.cases {
+			if have := NewID(tt.config, tt.genesis, ttt.head); have != ttt.want {
+				t.Errorf("test %d, case %d: fork ID mismatch: have %x, want %x", i, j, have, ttt.want)
+			}
+		}
+	}
+}
+
+// TestValidation tests that a local peer correctly validates and accepts a remote
+// fork ID.
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet Byzantium, remote announces the same. No future fork is announced.
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+
+		// Local is mainnet Byzantium, remote announces the same. Remote also announces a next fork
+		// at block 0xffffffff, but that is uncertain.
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: math.MaxUint64}, nil},
+
+		// Local is mainnet currently in Spurious only (so it's aware of Byzantium), remote announces
+		// also Spurious, but it's not yet aware of Byzantium (e.g. non updated node before the fork).
+		// In this case we don't know if Byzantium passed yet or not.
+		{4369999, ID{Hash: checksumToBytes(0x3edd5b10), Next: 0}, nil},
+
+		// Local is mainnet currently in Spurious only (so it's aware of Byzantium), remote announces
+		// also Spurious, and it's also aware of Byzantium (e.g. updated node before the fork). We
+		// don't know if Byzantium passed yet (will pass) or not.
+		{4369999, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}, nil},
+
+		// Local is mainnet currently in Spurious only (so it's aware of Byzantium), remote announces
+		// also Spurious, and it's also aware of some random fork (e.g. misconfigured Byzantium). As
+		// neither forks passed at neither nodes, they may mismatch, but we still connect for now.
+		{4369999, ID{Hash: checksumToBytes(0x3edd5b10), Next: math.MaxUint64}, nil},
+
+		// Local is mainnet Byzantium, remote announces Spurious + knowledge about Byzantium. Remote
+		// is simply out of sync, accept.
+		{4370000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}, nil},
+
+		// Local is mainnet Byzantium, remote announces Spurious + knowledge about Byzantium. Remote
+		// is definitely out of sync. It may or may not need the Byzantium update, we don't know yet.
+		{7000000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}, nil},
+
+		// Local is mainnet Spurious, remote announces Byzantium, but is not aware of any further
+		// forks. Local out of sync. Local also knows about a future fork, but that is uncertain yet.
+		{4369999, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+
+		// Local is mainnet Byzantium. Remote announces Tangerine + knowledge about Spurious. Remote
+		// is simply out of sync, accept.
+		{4370000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}, nil},
+
+		// Local is mainnet Byzantium. Remote announces Tangerine but with a wrong next fork that
+		// doesn't match our Spurious block. Remote needs software update.
+		{4370000, ID{Hash: checksumToBytes(0x7a64da13), Next: 4370000}, ErrRemoteStale},
+
+		// Local is mainnet Spurious, remote announces Byzantium + some unknown future fork. Local
+		// is out of sync, and can't tell whether the remote is compatible, so accept.
+		{2675000, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}, nil},
+
+		// Local is mainnet Byzantium, remote announces a checksum we don't know at all (e.g. a
+		// different fork or genesis). Reject.
+		{4370000, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Byzantium at block 7279999, remote announces Byzantium with a fork at
+		// block 7000000 which we already passed without forking. The chains are incompatible.
+		{7279999, ID{Hash: checksumToBytes(0xa00bc324), Next: 7000000}, ErrLocalIncompatibleOrStale},
+
+		// Local is the mainnet genesis, remote announces the Ropsten genesis. Reject.
+		{0, ID{Hash: checksumToBytes(0x30c7ddbc), Next: 10}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := newFilter(params.MainnetChainConfig, params.MainnetGenesisHash, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that IDs are properly RLP encoded (specifically important because we
+// use uint32 to store the hash, but we need to encode it as [4]byte).
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math.MaxUint32), Next: math.MaxUint64}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
@@ -30,7 +30,7 @@ type TestManager struct {
 	eventMux *event.TypeMux
 
 	db         ethdb.Database
-	txPool     *TxPool
+	txPool     *PricedTxPool
 	blockChain *BlockChain
 	Blocks     []*types.Block
 }
@@ -55,7 +55,7 @@ func (tm *TestManager) BlockChain() *BlockChain {
 	return tm.blockChain
 }
 
-func (tm *TestManager) TxPool() *TxPool {
+func (tm *TestManager) TxPool() *PricedTxPool {
 	return tm.txPool
 }
 
@@ -23,13 +23,20 @@ import (
 
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
 	"github.com/orangeAndSuns/go-ethereum/log"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
 )
 
 // ReadCanonicalHash retrieves the hash assigned to a canonical block number.
 func ReadCanonicalHash(db DatabaseReader, number uint64) common.Hash {
-	data, _ := db.Get(headerHashKey(number))
+	var data []byte
+	if ancients, ok := db.(ethdb.AncientReader); ok {
+		data, _ = ancients.Ancient(freezerHashTable, number)
+	}
+	if len(data) == 0 {
+		data, _ = db.Get(headerHashKey(number))
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
@@ -50,6 +57,23 @@ func DeleteCanonicalHash(db DatabaseDeleter, number uint64) {
 	}
 }
 
+// ReadAllHashes retrieves all the hashes assigned to blocks at a certain heights,
+// both canonical and reorged forks included.
+func ReadAllHashes(db ethdb.Iteratee, number uint64) []common.Hash {
+	prefix := headerKeyPrefix(number)
+
+	hashes := make([]common.Hash, 0, 1)
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+32 {
+			hashes = append(hashes, common.BytesToHash(key[len(key)-32:]))
+		}
+	}
+	return hashes
+}
+
 // ReadHeaderNumber returns the header number assigned to a hash.
 func ReadHeaderNumber(db DatabaseReader, hash common.Hash) *uint64 {
 	data, _ := db.Get(headerNumberKey(hash))
@@ -128,12 +152,20 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	// Try to look up the data in the ancient store first, falling back to the
+	// key-value store for recent or non-canonical headers
+	if data := readAncient(db, freezerHeaderTable, hash, number); len(data) > 0 {
+		return data
+	}
 	data, _ := db.Get(headerKey(number, hash))
 	return data
 }
 
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if isCanonicalAncient(db, hash, number) {
+		return true
+	}
 	if has, err := db.Has(headerKey(number, hash)); !has || err != nil {
 		return false
 	}
@@ -190,6 +222,9 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 
 // ReadBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func ReadBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := readAncient(db, freezerBodiesTable, hash, number); len(data) > 0 {
+		return data
+	}
 	data, _ := db.Get(blockBodyKey(number, hash))
 	return data
 }
@@ -203,6 +238,9 @@ func WriteBodyRLP(db DatabaseWriter, hash common.Hash, number uint64, rlp rlp.Ra
 
 // HasBody verifies the existence of a block body corresponding to the hash.
 func HasBody(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if isCanonicalAncient(db, hash, number) {
+		return true
+	}
 	if has, err := db.Has(blockBodyKey(number, hash)); !has || err != nil {
 		return false
 	}
@@ -239,9 +277,19 @@ func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// readTdRLP retrieves a block's total difficulty corresponding to the hash in
+// its raw RLP database encoding.
+func readTdRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := readAncient(db, freezerDifficultyTable, hash, number); len(data) > 0 {
+		return data
+	}
+	data, _ := db.Get(headerTDKey(number, hash))
+	return data
+}
+
 // ReadTd retrieves a block's total difficulty corresponding to the hash.
 func ReadTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(headerTDKey(number, hash))
+	data := readTdRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -271,10 +319,20 @@ func DeleteTd(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// readReceiptsRLP retrieves all the transaction receipts belonging to a block
+// in their raw RLP database encoding.
+func readReceiptsRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := readAncient(db, freezerReceiptTable, hash, number); len(data) > 0 {
+		return data
+	}
+	data, _ := db.Get(blockReceiptsKey(number, hash))
+	return data
+}
+
 // ReadReceipts retrieves all the transaction receipts belonging to a block.
 func ReadReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	// Retrieve the flattened receipt slice
-	data, _ := db.Get(blockReceiptsKey(number, hash))
+	data := readReceiptsRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -347,6 +405,17 @@ func DeleteBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
 	DeleteTd(db, hash, number)
 }
 
+// DeleteBlockWithoutNumber removes all block data associated with a hash, except
+// the hash to number mapping.
+func DeleteBlockWithoutNumber(db DatabaseDeleter, hash common.Hash, number uint64) {
+	DeleteReceipts(db, hash, number)
+	if err := db.Delete(headerKey(number, hash)); err != nil {
+		log.Crit("Failed to delete header", "err", err)
+	}
+	DeleteBody(db, hash, number)
+	DeleteTd(db, hash, number)
+}
+
 // FindCommonAncestor returns the last common ancestor of two block headers
 func FindCommonAncestor(db DatabaseReader, a, b *types.Header) *types.Header {
 	for bn := b.Number.Uint64(); a.Number.Uint64() > bn; {
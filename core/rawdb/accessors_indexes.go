@@ -42,18 +42,22 @@ func ReadTxLookupEntry(db DatabaseReader, hash common.Hash) (common.Hash, uint64
 // a block, enabling hash based transaction and receipt lookups.
 func WriteTxLookupEntries(db DatabaseWriter, block *types.Block) {
 	for i, tx := range block.Transactions() {
-		entry := TxLookupEntry{
+		WriteTxLookupEntry(db, tx.Hash(), TxLookupEntry{
 			BlockHash:  block.Hash(),
 			BlockIndex: block.NumberU64(),
 			Index:      uint64(i),
-		}
-		data, err := rlp.EncodeToBytes(entry)
-		if err != nil {
-			log.Crit("Failed to encode transaction lookup entry", "err", err)
-		}
-		if err := db.Put(txLookupKey(tx.Hash()), data); err != nil {
-			log.Crit("Failed to store transaction lookup entry", "err", err)
-		}
+		})
+	}
+}
+
+// WriteTxLookupEntry stores the positional metadata of a single transaction.
+func WriteTxLookupEntry(db DatabaseWriter, hash common.Hash, entry TxLookupEntry) {
+	data, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		log.Crit("Failed to encode transaction lookup entry", "err", err)
+	}
+	if err := db.Put(txLookupKey(hash), data); err != nil {
+		log.Crit("Failed to store transaction lookup entry", "err", err)
 	}
 }
 
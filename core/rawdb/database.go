@@ -0,0 +1,86 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/log"
+)
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	ethdb.Database
+	*freezer
+}
+
+// Close implements ethdb.Database, closing both the fast key-value store as well
+// as the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments older than
+// threshold blocks into cold storage at the given directory.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string, namespace string, threshold uint64) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer, namespace, threshold)
+	if err != nil {
+		return nil, err
+	}
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// KeyValueStore returns the key-value store backing a chain database, stripping
+// away the ancient store wrapper if there's any.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// isCanonicalAncient checks whether the block with the given hash and number has
+// been moved into the ancient store backing the database, if any.
+func isCanonicalAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	ancients, ok := db.(ethdb.AncientReader)
+	if !ok {
+		return false
+	}
+	data, _ := ancients.Ancient(freezerHashTable, number)
+	return len(data) > 0 && common.BytesToHash(data) == hash
+}
+
+// readAncient retrieves an item of the given kind from the ancient store backing
+// the database, if any. Since the ancient store only maintains canonical data,
+// nothing is returned unless the block hash at the given height matches.
+func readAncient(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if !isCanonicalAncient(db, hash, number) {
+		return nil
+	}
+	data, _ := db.(ethdb.AncientReader).Ancient(kind, number)
+	return data
+}
@@ -0,0 +1,370 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/metrics"
+)
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that is
+	// not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// freezer is an append-only database to store immutable chain data into flat
+// files. The append only nature ensures that disk writes are minimized, and moving
+// finalized chain segments out of the key-value store keeps the latter small and
+// allows placing the bulk of the chain on cheaper storage.
+type freezer struct {
+	frozen    uint64 // Number of blocks already frozen (atomic)
+	threshold uint64 // Number of recent blocks not to freeze
+
+	tables map[string]*freezerTable // Data tables for storing everything
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer that moves ancient chain data into
+// append-only flat file containers.
+func newFreezer(datadir string, namespace string, threshold uint64) (*freezer, error) {
+	// Create the initial freezer object
+	var (
+		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
+		writeMeter = metrics.NewRegisteredMeter(namespace+"ancient/write", nil)
+	)
+	// Open all the supported data tables
+	freezer := &freezer{
+		threshold: threshold,
+		tables:    make(map[string]*freezerTable),
+		quit:      make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		table, err := newTable(datadir, name, readMeter, writeMeter, disableSnappy)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// Close terminates the chain freezer, closing all the data files.
+func (f *freezer) Close() error {
+	close(f.quit)
+	f.wg.Wait()
+
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (f *freezer) AncientSize(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.size()
+	}
+	return 0, errUnknownTable
+}
+
+// AppendAncient injects all binary blobs belong to block at the end of the
+// append-only immutable table files.
+//
+// Out-of-order injections are rejected, but the method is not safe for concurrent
+// appends of the same number; the freezer loop is expected to be the only writer.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	// Ensure the binary blobs we are appending is continuous with freezer.
+	if atomic.LoadUint64(&f.frozen) != number {
+		return errOutOrderInsertion
+	}
+	// Rollback all inserted data if any insertion below failed to ensure
+	// the tables won't out of sync.
+	defer func() {
+		if err != nil {
+			if rerr := f.repair(); rerr != nil {
+				log.Crit("Failed to repair freezer", "err", rerr)
+			}
+			log.Info("Append ancient failed", "number", number, "err", err)
+		}
+	}()
+	// Inject all the components into the relevant data tables
+	if err := f.tables[freezerHashTable].Append(number, hash); err != nil {
+		log.Error("Failed to append ancient hash", "number", number, "hash", common.BytesToHash(hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerHeaderTable].Append(number, header); err != nil {
+		log.Error("Failed to append ancient header", "number", number, "hash", common.BytesToHash(hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerBodiesTable].Append(number, body); err != nil {
+		log.Error("Failed to append ancient body", "number", number, "hash", common.BytesToHash(hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerReceiptTable].Append(number, receipts); err != nil {
+		log.Error("Failed to append ancient receipts", "number", number, "hash", common.BytesToHash(hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerDifficultyTable].Append(number, td); err != nil {
+		log.Error("Failed to append ancient difficulty", "number", number, "hash", common.BytesToHash(hash), "err", err)
+		return err
+	}
+	atomic.AddUint64(&f.frozen, 1) // Only modify atomically
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *freezer) TruncateAncients(items uint64) error {
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all data tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+//
+// This functionality is deliberately broken off from block importing to avoid
+// incurring additional data shuffling delays on block propagation.
+func (f *freezer) freeze(db ethdb.Database) {
+	defer f.wg.Done()
+
+	var backoff bool
+	for {
+		select {
+		case <-f.quit:
+			log.Info("Freezer shutting down")
+			return
+		default:
+		}
+		if backoff {
+			select {
+			case <-time.NewTimer(freezerRecheckInterval).C:
+				backoff = false
+			case <-f.quit:
+				return
+			}
+		}
+		// Retrieve the freezing threshold.
+		hash := ReadHeadBlockHash(db)
+		if hash == (common.Hash{}) {
+			log.Debug("Current full block hash unavailable") // new chain, empty database
+			backoff = true
+			continue
+		}
+		number := ReadHeaderNumber(db, hash)
+		frozen := atomic.LoadUint64(&f.frozen)
+		switch {
+		case number == nil:
+			log.Error("Current full block number unavailable", "hash", hash)
+			backoff = true
+			continue
+
+		case *number < f.threshold:
+			log.Debug("Current full block not old enough", "number", *number, "hash", hash, "delay", f.threshold)
+			backoff = true
+			continue
+
+		case *number-f.threshold <= frozen:
+			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", frozen)
+			backoff = true
+			continue
+		}
+		// Seems we have data ready to be frozen, process in usable batches
+		limit := *number - f.threshold
+		if limit-frozen > freezerBatchLimit {
+			limit = frozen + freezerBatchLimit
+		}
+		var (
+			start    = time.Now()
+			first    = frozen
+			ancients = make([]common.Hash, 0, limit-frozen)
+		)
+		for num := first; num < limit; num++ {
+			// Retrieves all the components of the canonical block
+			hash := ReadCanonicalHash(db, num)
+			if hash == (common.Hash{}) {
+				log.Error("Canonical hash missing, can't freeze", "number", num)
+				break
+			}
+			header := ReadHeaderRLP(db, hash, num)
+			if len(header) == 0 {
+				log.Error("Block header missing, can't freeze", "number", num, "hash", hash)
+				break
+			}
+			body := ReadBodyRLP(db, hash, num)
+			if len(body) == 0 {
+				log.Error("Block body missing, can't freeze", "number", num, "hash", hash)
+				break
+			}
+			receipts := readReceiptsRLP(db, hash, num)
+			if len(receipts) == 0 {
+				log.Error("Block receipts missing, can't freeze", "number", num, "hash", hash)
+				break
+			}
+			td := readTdRLP(db, hash, num)
+			if len(td) == 0 {
+				log.Error("Total difficulty missing, can't freeze", "number", num, "hash", hash)
+				break
+			}
+			// Inject all the components into the relevant data tables
+			if err := f.AppendAncient(num, hash[:], header, body, receipts, td); err != nil {
+				break
+			}
+			ancients = append(ancients, hash)
+		}
+		// Batch of blocks have been frozen, flush them before wiping from leveldb
+		if err := f.Sync(); err != nil {
+			log.Crit("Failed to flush frozen tables", "err", err)
+		}
+		// Wipe out all data from the active database, side chains at the frozen
+		// heights included as they can never become canonical any more
+		batch := db.NewBatch()
+		iteratee, _ := db.(ethdb.Iteratee)
+		for i := 0; i < len(ancients); i++ {
+			// Always keep the genesis block in active database
+			number := first + uint64(i)
+			if number == 0 {
+				continue
+			}
+			DeleteBlockWithoutNumber(batch, ancients[i], number)
+			DeleteCanonicalHash(batch, number)
+
+			if iteratee == nil {
+				continue
+			}
+			for _, hash := range ReadAllHashes(iteratee, number) {
+				if hash != ancients[i] {
+					DeleteBlock(batch, hash, number)
+				}
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete frozen canonical blocks", "err", err)
+		}
+		// Log something friendly for the user
+		if n := len(ancients); n > 0 {
+			log.Info("Deep froze chain segment", "blocks", n, "elapsed", common.PrettyDuration(time.Since(start)), "number", first+uint64(n)-1, "hash", ancients[n-1])
+		}
+		// Avoid database thrashing with tiny writes
+		if uint64(len(ancients)) < freezerBatchLimit {
+			backoff = true
+		}
+	}
+}
+
+// repair truncates all data tables to the same length.
+func (f *freezer) repair() error {
+	min := uint64(math.MaxUint64)
+	for _, table := range f.tables {
+		items := atomic.LoadUint64(&table.items)
+		if min > items {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/metrics"
+)
+
+// getChunk returns a chunk of data of the given size, filled with b.
+func getChunk(size int, b int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(b)
+	}
+	return data
+}
+
+// newTestTable creates a freezer table in a fresh temporary directory.
+func newTestTable(t *testing.T, maxFileSize uint32, noCompression bool) (*freezerTable, string) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	table, err := newCustomTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, maxFileSize, noCompression)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to create table: %v", err)
+	}
+	return table, dir
+}
+
+// reopenTestTable closes the given table and opens it again from disk.
+func reopenTestTable(t *testing.T, table *freezerTable, dir string, maxFileSize uint32, noCompression bool) *freezerTable {
+	table.Close()
+	table, err := newCustomTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, maxFileSize, noCompression)
+	if err != nil {
+		t.Fatalf("failed to reopen table: %v", err)
+	}
+	return table
+}
+
+// Tests that items can be appended and retrieved, also after a restart and
+// across multiple data files.
+func TestFreezerBasics(t *testing.T) {
+	for _, noCompression := range []bool{false, true} {
+		// Set a max file size that forces a data file split every few items
+		table, dir := newTestTable(t, 50, noCompression)
+		defer os.RemoveAll(dir)
+
+		for i := 0; i < 255; i++ {
+			if err := table.Append(uint64(i), getChunk(15, i)); err != nil {
+				t.Fatalf("failed to append item %d: %v", i, err)
+			}
+		}
+		if err := table.Append(300, getChunk(15, 0)); err == nil {
+			t.Fatalf("out of order append succeeded")
+		}
+		table = reopenTestTable(t, table, dir, 50, noCompression)
+		defer table.Close()
+
+		for i := 0; i < 255; i++ {
+			blob, err := table.Retrieve(uint64(i))
+			if err != nil {
+				t.Fatalf("failed to retrieve item %d: %v", i, err)
+			}
+			if !bytes.Equal(blob, getChunk(15, i)) {
+				t.Fatalf("item %d mismatch: have %x, want %x", i, blob, getChunk(15, i))
+			}
+		}
+		if _, err := table.Retrieve(255); err != errOutOfBounds {
+			t.Fatalf("out of bounds retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+	}
+}
+
+// Tests that a table whose head data file lost some data (e.g. crash before the
+// fsync) truncates its index to the surviving items on restart.
+func TestFreezerRepairDanglingHead(t *testing.T) {
+	table, dir := newTestTable(t, 50, true)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 255; i++ {
+		if err := table.Append(uint64(i), getChunk(15, i)); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	table.Close()
+
+	// Chop off half of the last item from the head data file
+	head := filepath.Join(dir, fmt.Sprintf("test.%04d.rdat", 254/3))
+	stat, err := os.Stat(head)
+	if err != nil {
+		t.Fatalf("failed to stat head file: %v", err)
+	}
+	if err := os.Truncate(head, stat.Size()-7); err != nil {
+		t.Fatalf("failed to truncate head file: %v", err)
+	}
+	table, err = newCustomTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, 50, true)
+	if err != nil {
+		t.Fatalf("failed to reopen table: %v", err)
+	}
+	defer table.Close()
+
+	if table.items != 254 {
+		t.Fatalf("item count mismatch: have %d, want %d", table.items, 254)
+	}
+	if _, err := table.Retrieve(254); err == nil {
+		t.Fatalf("retrieved truncated item")
+	}
+	if blob, err := table.Retrieve(253); err != nil || !bytes.Equal(blob, getChunk(15, 253)) {
+		t.Fatalf("surviving item mismatch: have %x (%v), want %x", blob, err, getChunk(15, 253))
+	}
+	// Make sure the table can be extended again from the repaired position
+	if err := table.Append(254, getChunk(15, 0xff)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	if blob, err := table.Retrieve(254); err != nil || !bytes.Equal(blob, getChunk(15, 0xff)) {
+		t.Fatalf("re-appended item mismatch: have %x (%v)", blob, err)
+	}
+}
+
+// Tests that truncating a table discards items, including any data files that
+// are no longer needed, and that the table can be appended to afterwards.
+func TestFreezerTruncate(t *testing.T) {
+	table, dir := newTestTable(t, 50, false)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 30; i++ {
+		if err := table.Append(uint64(i), getChunk(15, i)); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	if err := table.truncate(10); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+	table = reopenTestTable(t, table, dir, 50, false)
+	defer table.Close()
+
+	if table.items != 10 {
+		t.Fatalf("item count mismatch: have %d, want %d", table.items, 10)
+	}
+	if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("test.%04d.cdat", table.headID+1))); !os.IsNotExist(err) {
+		t.Fatalf("stale data file not removed: %v", err)
+	}
+	for i := 10; i < 20; i++ {
+		if err := table.Append(uint64(i), getChunk(15, 0xff-i)); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		want := getChunk(15, i)
+		if i >= 10 {
+			want = getChunk(15, 0xff-i)
+		}
+		if blob, err := table.Retrieve(uint64(i)); err != nil || !bytes.Equal(blob, want) {
+			t.Fatalf("item %d mismatch: have %x (%v), want %x", i, blob, err, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+)
+
+// Tests that the freezer moves old canonical blocks out of the key-value store
+// and that the accessors transparently retrieve them from the ancient store.
+func TestFreezerMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Assemble a simple canonical chain in the key-value store
+	kvdb := ethdb.NewMemDatabase()
+
+	var blocks []*types.Block
+	for i := 0; i < 16; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Difficulty: big.NewInt(1), Extra: []byte("test block")}
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		block := types.NewBlockWithHeader(header)
+		WriteBlock(kvdb, block)
+		WriteReceipts(kvdb, block.Hash(), block.NumberU64(), nil)
+		WriteTd(kvdb, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteCanonicalHash(kvdb, block.Hash(), block.NumberU64())
+
+		blocks = append(blocks, block)
+	}
+	WriteHeadBlockHash(kvdb, blocks[len(blocks)-1].Hash())
+
+	// Add side blocks both within and beyond the range to be frozen
+	var sides []*types.Block
+	for _, number := range []int64{5, 14} {
+		side := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Extra: []byte("side block")})
+		WriteBlock(kvdb, side)
+		WriteReceipts(kvdb, side.Hash(), side.NumberU64(), nil)
+		WriteTd(kvdb, side.Hash(), side.NumberU64(), big.NewInt(number))
+
+		sides = append(sides, side)
+	}
+
+	// Open the freezer and wait for it to move all but the recent blocks
+	db, err := NewDatabaseWithFreezer(kvdb, dir, "", 4)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	ancients := db.(ethdb.AncientReader)
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if frozen, _ := ancients.Ancients(); frozen == 11 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			frozen, _ := ancients.Ancients()
+			t.Fatalf("frozen block count mismatch: have %d, want %d", frozen, 11)
+		}
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if have := ReadBlock(db, hash, number); have == nil || have.Hash() != hash {
+			t.Errorf("block %d: block mismatch: have %v", number, have)
+		}
+		if have := ReadTd(db, hash, number); have == nil || have.Int64() != int64(number+1) {
+			t.Errorf("block %d: total difficulty mismatch: have %v, want %d", number, have, number+1)
+		}
+		if have := ReadReceipts(db, hash, number); have == nil {
+			t.Errorf("block %d: receipts missing", number)
+		}
+		// Frozen blocks (apart from the genesis) must be gone from the key-value store
+		if frozen := number < 11; HasBody(kvdb, hash, number) == frozen && number != 0 {
+			t.Errorf("block %d: key-value store presence mismatch: frozen %v", number, frozen)
+		}
+	}
+	// Side blocks at frozen heights must be pruned, the others retained
+	for _, side := range sides {
+		hash, number := side.Hash(), side.NumberU64()
+
+		pruned := number < 11
+		if HasHeader(db, hash, number) == pruned || (ReadHeaderRLP(db, hash, number) == nil) != pruned {
+			t.Errorf("side block %d: header presence mismatch: pruned %v", number, pruned)
+		}
+		if HasBody(kvdb, hash, number) == pruned || (ReadTd(kvdb, hash, number) == nil) != pruned {
+			t.Errorf("side block %d: body or total difficulty presence mismatch: pruned %v", number, pruned)
+		}
+		if (ReadHeaderNumber(kvdb, hash) == nil) != pruned {
+			t.Errorf("side block %d: number mapping presence mismatch: pruned %v", number, pruned)
+		}
+	}
+	// Truncate the ancient store and ensure the dropped blocks are gone
+	if err := db.(ethdb.AncientWriter).TruncateAncients(8); err != nil {
+		t.Fatalf("failed to truncate ancients: %v", err)
+	}
+	if frozen, _ := ancients.Ancients(); frozen != 8 {
+		t.Fatalf("frozen block count mismatch after truncation: have %d, want %d", frozen, 8)
+	}
+	if ReadBlock(db, blocks[9].Hash(), 9) != nil {
+		t.Errorf("truncated block still retrievable")
+	}
+	if ReadBlock(db, blocks[7].Hash(), 7) == nil {
+		t.Errorf("retained block not retrievable")
+	}
+	db.Close()
+}
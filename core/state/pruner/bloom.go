@@ -0,0 +1,137 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+)
+
+// stateBloomHashes is the number of bit positions set for every inserted key.
+const stateBloomHashes = 4
+
+// errBloomCorrupted is returned if a persisted state bloom cannot be loaded.
+var errBloomCorrupted = errors.New("state bloom corrupted")
+
+// stateBloom is a bloom filter used during state pruning to track all the live
+// trie nodes and contract codes. False positives are harmless (some stale data
+// is retained), but a false negative would delete live state, which the filter
+// by construction never produces.
+//
+// Since all the inserted keys are Keccak256 hashes, the bit positions are taken
+// directly from the key instead of rehashing it.
+type stateBloom struct {
+	bits []uint64 // Bit vector of the filter
+}
+
+// newStateBloomWithSize creates an empty state bloom of the given size in bytes.
+func newStateBloomWithSize(size uint64) *stateBloom {
+	words := size / 8
+	if words == 0 {
+		words = 1
+	}
+	return &stateBloom{bits: make([]uint64, words)}
+}
+
+// positions returns the bit indices associated with the given hash key.
+func (bloom *stateBloom) positions(key []byte) [stateBloomHashes]uint64 {
+	var (
+		size = uint64(len(bloom.bits)) * 64
+		pos  [stateBloomHashes]uint64
+	)
+	for i := 0; i < stateBloomHashes; i++ {
+		pos[i] = binary.BigEndian.Uint64(key[i*8:]) % size
+	}
+	return pos
+}
+
+// add inserts a hash into the bloom filter.
+func (bloom *stateBloom) add(hash common.Hash) {
+	for _, pos := range bloom.positions(hash[:]) {
+		bloom.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+// contains reports whether the given 32 byte key might have been inserted into
+// the filter. It returns false for keys of any other length.
+func (bloom *stateBloom) contains(key []byte) bool {
+	if len(key) != common.HashLength {
+		return false
+	}
+	for _, pos := range bloom.positions(key) {
+		if bloom.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// commit flushes the bloom filter into the given file. The data is first written
+// into a temporary file which is atomically moved into place after an fsync, so
+// that the existence of the file signals a fully generated filter.
+func (bloom *stateBloom) commit(filename string) error {
+	tmp := filename + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var (
+		buf  = bufio.NewWriter(f)
+		word [8]byte
+	)
+	for _, bits := range bloom.bits {
+		binary.BigEndian.PutUint64(word[:], bits)
+		if _, err := buf.Write(word[:]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// loadStateBloom reads a previously committed state bloom from the given file.
+func loadStateBloom(filename string) (*stateBloom, error) {
+	blob, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) == 0 || len(blob)%8 != 0 {
+		return nil, errBloomCorrupted
+	}
+	bloom := &stateBloom{bits: make([]uint64, len(blob)/8)}
+	for i := range bloom.bits {
+		bloom.bits[i] = binary.BigEndian.Uint64(blob[i*8:])
+	}
+	return bloom, nil
+}
@@ -0,0 +1,379 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline pruning of stale state trie data.
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+	"github.com/orangeAndSuns/go-ethereum/trie"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// stateBloomFilePrefix is the filename prefix of state bloom filter.
+	stateBloomFilePrefix = "statebloom"
+
+	// stateBloomFileSuffix is the filename suffix of state bloom filter.
+	stateBloomFileSuffix = "bf"
+
+	// recentStateLimit is the number of recent blocks searched for a state that
+	// is available on disk when no explicit pruning target is given. It matches
+	// the number of tries the blockchain keeps in memory before flushing.
+	recentStateLimit = 128
+
+	// logInterval is the frequency of progress reports during pruning.
+	logInterval = 8 * time.Second
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256(nil)
+
+	// errNoKeyValueIteration is returned if the database backing the pruner does
+	// not support iterating over all its keys.
+	errNoKeyValueIteration = errors.New("database does not support key iteration")
+)
+
+// Pruner is an offline tool to prune the stale state with the help of a bloom
+// filter. The pruner walks the state tries of the target and retained roots,
+// marks every reachable trie node and contract code in the bloom, then deletes
+// all other state entries from the database.
+//
+// The bloom filter is persisted to disk before any data is deleted, so that an
+// interrupted pruning can be resumed via RecoverPruning. The node must not be
+// running while pruning, since state written afterwards would not be part of
+// the bloom and would get deleted.
+type Pruner struct {
+	db        ethdb.Database     // Chain database (possibly with an ancient store attached)
+	diskdb    *ethdb.LDBDatabase // Key-value store to sweep
+	datadir   string             // Directory to persist the state bloom into
+	bloomSize uint64             // Size of the state bloom in megabytes
+	head      *types.Block       // Current head block of the chain
+	genesis   *types.Block       // Genesis block, whose state is always retained
+}
+
+// NewPruner creates a pruner operating on the given chain database. The bloom
+// size is specified in megabytes; larger filters retain fewer stale entries.
+func NewPruner(db ethdb.Database, datadir string, bloomSize uint64) (*Pruner, error) {
+	diskdb, ok := rawdb.KeyValueStore(db).(*ethdb.LDBDatabase)
+	if !ok {
+		return nil, errNoKeyValueIteration
+	}
+	head := rawdb.ReadHeadBlockHash(db)
+	if head == (common.Hash{}) {
+		return nil, errors.New("head block hash missing")
+	}
+	number := rawdb.ReadHeaderNumber(db, head)
+	if number == nil {
+		return nil, fmt.Errorf("head block number missing: %x", head)
+	}
+	block := rawdb.ReadBlock(db, head, *number)
+	if block == nil {
+		return nil, fmt.Errorf("head block missing: #%d [%x]", *number, head)
+	}
+	genesis := rawdb.ReadBlock(db, rawdb.ReadCanonicalHash(db, 0), 0)
+	if genesis == nil {
+		return nil, errors.New("genesis block missing")
+	}
+	if bloomSize == 0 {
+		bloomSize = 1
+	}
+	return &Pruner{
+		db:        db,
+		diskdb:    diskdb,
+		datadir:   datadir,
+		bloomSize: bloomSize,
+		head:      block,
+		genesis:   genesis,
+	}, nil
+}
+
+// Prune deletes all the state entries not reachable from the target state root
+// or from any retained root. The retained roots are the genesis state and the
+// states of the HEAD, HEAD-1 and HEAD-127 blocks if they are present on disk,
+// these being the ones the blockchain persists on shutdown.
+//
+// If the target root is empty, the most recent state available on disk within
+// the last 128 blocks is used. If an interrupted pruning is detected, it will
+// be finished instead and the target root ignored.
+func (p *Pruner) Prune(root common.Hash) error {
+	// Finish any previously interrupted pruning before starting a new one
+	if filename, err := findBloomFilter(p.datadir); err != nil {
+		return err
+	} else if filename != "" {
+		log.Warn("Resuming interrupted state pruning", "bloom", filename)
+		return p.resume(filename)
+	}
+	// Drop any partially written bloom of a pruning interrupted during marking
+	if leftovers, err := filepath.Glob(filepath.Join(p.datadir, stateBloomFilePrefix+".*."+stateBloomFileSuffix+".tmp")); err == nil {
+		for _, leftover := range leftovers {
+			os.Remove(leftover)
+		}
+	}
+	// Resolve the target state root and all the roots to retain alongside it
+	if root == (common.Hash{}) {
+		var number uint64
+		if root, number = p.findRecentState(); root == (common.Hash{}) {
+			return fmt.Errorf("no state available within the last %d blocks", recentStateLimit)
+		}
+		log.Info("Selected recent state for pruning", "number", number, "root", root)
+	} else if !p.hasState(root) {
+		return fmt.Errorf("associated state[%x] is not present", root)
+	}
+	roots := []common.Hash{root, p.genesis.Root()}
+	for _, offset := range []uint64{0, 1, recentStateLimit - 1} {
+		if p.head.NumberU64() < offset {
+			continue
+		}
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, p.head.NumberU64()-offset), p.head.NumberU64()-offset)
+		if header != nil && p.hasState(header.Root) {
+			roots = append(roots, header.Root)
+		}
+	}
+	// Mark all the live state entries in the bloom filter and persist it
+	start := time.Now()
+
+	bloom := newStateBloomWithSize(p.bloomSize * 1024 * 1024)
+	if err := markStates(p.db, bloom, roots); err != nil {
+		return err
+	}
+	filename := bloomFilterName(p.datadir, root)
+	if err := bloom.commit(filename); err != nil {
+		return err
+	}
+	log.Info("Committed state bloom filter", "path", filename, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	return p.finish(bloom, filename, start)
+}
+
+// resume loads a persisted state bloom and completes the pruning with it.
+func (p *Pruner) resume(filename string) error {
+	bloom, err := loadStateBloom(filename)
+	if err != nil {
+		return err
+	}
+	return p.finish(bloom, filename, time.Now())
+}
+
+// finish sweeps the database with the given bloom, removes the bloom file and
+// compacts the database to reclaim the freed space.
+func (p *Pruner) finish(bloom *stateBloom, filename string, start time.Time) error {
+	if err := sweep(p.diskdb, bloom); err != nil {
+		return err
+	}
+	// Pruning is done, delete the bloom so it's not picked up again on restart
+	if err := os.Remove(filename); err != nil {
+		return err
+	}
+	log.Info("Compacting database")
+	cstart := time.Now()
+	if err := p.diskdb.LDB().CompactRange(util.Range{}); err != nil {
+		return err
+	}
+	log.Info("Database compaction finished", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	log.Info("State pruning successful", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// findRecentState returns the root and number of the most recent canonical
+// block within the last 128 blocks whose state is present on disk.
+func (p *Pruner) findRecentState() (common.Hash, uint64) {
+	for i := uint64(0); i < recentStateLimit && i <= p.head.NumberU64(); i++ {
+		number := p.head.NumberU64() - i
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, number), number)
+		if header != nil && p.hasState(header.Root) {
+			return header.Root, number
+		}
+	}
+	return common.Hash{}, 0
+}
+
+// hasState reports whether the root node of the given state trie is on disk.
+// Since tries are committed bottom-up, this implies the entire trie is present.
+func (p *Pruner) hasState(root common.Hash) bool {
+	if root == emptyRoot {
+		return true
+	}
+	ok, _ := p.db.Has(root[:])
+	return ok
+}
+
+// RecoverPruning checks whether a previous pruning was interrupted after its
+// state bloom was committed, and if so, finishes it. It must be called before
+// the database is modified by anything else, otherwise newly written state
+// would be deleted.
+func RecoverPruning(datadir string, db ethdb.Database) error {
+	filename, err := findBloomFilter(datadir)
+	if err != nil {
+		return err
+	}
+	if filename == "" {
+		return nil // nothing to recover
+	}
+	diskdb, ok := rawdb.KeyValueStore(db).(*ethdb.LDBDatabase)
+	if !ok {
+		return errNoKeyValueIteration
+	}
+	log.Warn("Resuming interrupted state pruning", "bloom", filename)
+
+	p := &Pruner{db: db, diskdb: diskdb, datadir: datadir}
+	return p.resume(filename)
+}
+
+// markStates walks all the state tries (accounts, storage and contract codes)
+// rooted at the given hashes and inserts every referenced entry into the bloom.
+func markStates(db ethdb.Database, bloom *stateBloom, roots []common.Hash) error {
+	var (
+		triedb  = trie.NewDatabase(db)
+		storage = make(map[common.Hash]struct{}) // Storage tries already marked
+		nodes   int
+		codes   int
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	markTrie := func(root common.Hash, onLeaf func(blob []byte) error) error {
+		if root == emptyRoot {
+			return nil
+		}
+		t, err := trie.New(root, triedb)
+		if err != nil {
+			return err
+		}
+		it := t.NodeIterator(nil)
+		for it.Next(true) {
+			if hash := it.Hash(); hash != (common.Hash{}) {
+				bloom.add(hash)
+				nodes++
+			}
+			if it.Leaf() && onLeaf != nil {
+				if err := onLeaf(it.LeafBlob()); err != nil {
+					return err
+				}
+			}
+			if time.Since(logged) > logInterval {
+				log.Info("Marking live state entries", "nodes", nodes, "codes", codes, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		return it.Error()
+	}
+	for _, root := range roots {
+		err := markTrie(root, func(blob []byte) error {
+			var account state.Account
+			if err := rlp.DecodeBytes(blob, &account); err != nil {
+				return err
+			}
+			if !bytes.Equal(account.CodeHash, emptyCode) {
+				bloom.add(common.BytesToHash(account.CodeHash))
+				codes++
+			}
+			if _, ok := storage[account.Root]; ok {
+				return nil
+			}
+			storage[account.Root] = struct{}{}
+			return markTrie(account.Root, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Info("Marked live state entries", "roots", len(roots), "nodes", nodes, "codes", codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// sweep deletes every trie node and contract code from the key-value store that
+// is not contained in the bloom filter. Both are stored keyed by the Keccak256
+// hash of their content, which is verified before deletion to avoid touching
+// any unrelated data.
+func sweep(db *ethdb.LDBDatabase, bloom *stateBloom) error {
+	var (
+		count  int
+		size   common.StorageSize
+		start  = time.Now()
+		logged = time.Now()
+		batch  = db.NewBatch()
+		it     = db.NewIterator()
+	)
+	defer it.Release()
+
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) != common.HashLength || bloom.contains(key) {
+			continue
+		}
+		if !bytes.Equal(crypto.Keccak256(value), key) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(value))
+		batch.Delete(common.CopyBytes(key))
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > logInterval {
+			log.Info("Pruning state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// bloomFilterName returns the path of the state bloom persisted for the root.
+func bloomFilterName(datadir string, root common.Hash) string {
+	return filepath.Join(datadir, fmt.Sprintf("%s.%s.%s", stateBloomFilePrefix, root.Hex(), stateBloomFileSuffix))
+}
+
+// findBloomFilter looks for a committed state bloom in the data directory and
+// returns its path, or an empty string if none exists.
+func findBloomFilter(datadir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(datadir, stateBloomFilePrefix+".*."+stateBloomFileSuffix))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return matches[0], nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+)
+
+// testChain is a tiny chain with a stale state committed to disk next to it.
+type testChain struct {
+	db      *ethdb.LDBDatabase
+	datadir string
+
+	genesisRoot common.Hash // State of the genesis block
+	headRoot    common.Hash // State of the head block
+	staleRoot   common.Hash // State not referenced by any block
+
+	staleCode []byte // Contract code only present in the stale state
+	headCode  []byte // Contract code present in the head state
+}
+
+// newTestChain creates a two block chain on disk with an additional stale state
+// derived from the genesis one.
+func newTestChain(t *testing.T) *testChain {
+	datadir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	db, err := ethdb.NewLDBDatabase(filepath.Join(datadir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	chain := &testChain{
+		db:        db,
+		datadir:   datadir,
+		staleCode: []byte("stale contract code"),
+		headCode:  []byte("head contract code"),
+	}
+	// Create the genesis state and two diverging children of it
+	sdb := state.NewDatabase(db)
+	commit := func(statedb *state.StateDB) common.Hash {
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to flush state: %v", err)
+		}
+		return root
+	}
+	statedb, _ := state.New(common.Hash{}, sdb)
+	statedb.AddBalance(common.Address{0x01}, big.NewInt(1))
+	statedb.SetState(common.Address{0x01}, common.Hash{0x01}, common.Hash{0x01})
+	chain.genesisRoot = commit(statedb)
+
+	statedb, _ = state.New(chain.genesisRoot, sdb)
+	statedb.AddBalance(common.Address{0x02}, big.NewInt(2))
+	statedb.SetCode(common.Address{0x02}, chain.staleCode)
+	statedb.SetState(common.Address{0x02}, common.Hash{0x02}, common.Hash{0x02})
+	chain.staleRoot = commit(statedb)
+
+	statedb, _ = state.New(chain.genesisRoot, sdb)
+	statedb.AddBalance(common.Address{0x03}, big.NewInt(3))
+	statedb.SetCode(common.Address{0x03}, chain.headCode)
+	statedb.SetState(common.Address{0x03}, common.Hash{0x03}, common.Hash{0x03})
+	chain.headRoot = commit(statedb)
+
+	// Assemble the canonical chain referencing the genesis and head states
+	genesis := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Root: chain.genesisRoot})
+	head := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), Root: chain.headRoot})
+	for _, block := range []*types.Block{genesis, head} {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	rawdb.WriteHeadBlockHash(db, head.Hash())
+
+	return chain
+}
+
+// close releases the database and removes all data of the test chain.
+func (c *testChain) close() {
+	c.db.Close()
+	os.RemoveAll(c.datadir)
+}
+
+// checkState verifies that the entire state rooted at the given hash is present.
+func checkState(t *testing.T, db ethdb.Database, root common.Hash) {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("state %x missing: %v", root, err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		t.Fatalf("state %x incomplete: %v", root, it.Error)
+	}
+}
+
+// Tests that pruning deletes all the state entries not reachable from the head
+// or the genesis state, but leaves everything else intact.
+func TestPrune(t *testing.T) {
+	chain := newTestChain(t)
+	defer chain.close()
+
+	pruner, err := NewPruner(chain.db, chain.datadir, 1)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(common.Hash{}); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	checkState(t, chain.db, chain.headRoot)
+	checkState(t, chain.db, chain.genesisRoot)
+
+	if ok, _ := chain.db.Has(chain.staleRoot[:]); ok {
+		t.Errorf("stale state root not pruned")
+	}
+	if ok, _ := chain.db.Has(crypto.Keccak256(chain.staleCode)); ok {
+		t.Errorf("stale contract code not pruned")
+	}
+	if ok, _ := chain.db.Has(crypto.Keccak256(chain.headCode)); !ok {
+		t.Errorf("live contract code pruned")
+	}
+	if rawdb.ReadHeadBlockHash(chain.db) == (common.Hash{}) {
+		t.Errorf("non-state data pruned")
+	}
+	if filename, _ := findBloomFilter(chain.datadir); filename != "" {
+		t.Errorf("state bloom not removed: %s", filename)
+	}
+}
+
+// Tests that pruning refuses a target state that isn't present on disk.
+func TestPruneMissingState(t *testing.T) {
+	chain := newTestChain(t)
+	defer chain.close()
+
+	pruner, err := NewPruner(chain.db, chain.datadir, 1)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(common.Hash{0xde, 0xad}); err == nil {
+		t.Fatalf("pruning with missing state succeeded")
+	}
+	checkState(t, chain.db, chain.staleRoot)
+}
+
+// Tests that a pruning interrupted after committing its bloom filter is finished
+// by the recovery, using the persisted filter rather than the current chain.
+func TestRecoverPruning(t *testing.T) {
+	chain := newTestChain(t)
+	defer chain.close()
+
+	// Simulate a crash after marking only the stale state as live
+	bloom := newStateBloomWithSize(1024 * 1024)
+	if err := markStates(chain.db, bloom, []common.Hash{chain.staleRoot}); err != nil {
+		t.Fatalf("failed to mark states: %v", err)
+	}
+	if err := bloom.commit(bloomFilterName(chain.datadir, chain.staleRoot)); err != nil {
+		t.Fatalf("failed to commit bloom: %v", err)
+	}
+	if err := RecoverPruning(chain.datadir, chain.db); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	checkState(t, chain.db, chain.staleRoot)
+
+	if ok, _ := chain.db.Has(chain.headRoot[:]); ok {
+		t.Errorf("head state root not pruned by the recovered bloom")
+	}
+	if filename, _ := findBloomFilter(chain.datadir); filename != "" {
+		t.Errorf("state bloom not removed: %s", filename)
+	}
+	// Without an interrupted pruning, recovery must be a noop
+	if err := RecoverPruning(chain.datadir, chain.db); err != nil {
+		t.Fatalf("failed to run noop recovery: %v", err)
+	}
+	checkState(t, chain.db, chain.staleRoot)
+}
@@ -79,6 +79,7 @@ type stateObject struct {
 
 	cachedStorage Storage // Storage entry cache to avoid duplicate reads
 	dirtyStorage  Storage // Storage entries that need to be flushed to disk
+	fakeStorage   Storage // Storage replacement set by the caller for call simulation
 
 	// Cache flags.
 	// When an object is marked suicided it will be delete from the trie
@@ -161,6 +162,10 @@ func (c *stateObject) getTrie(db Database) Trie {
 
 // GetState returns a value in account storage.
 func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
+	// If the storage was replaced wholesale, ignore the database
+	if self.fakeStorage != nil {
+		return self.fakeStorage[key]
+	}
 	value, exists := self.cachedStorage[key]
 	if exists {
 		return value
@@ -193,10 +198,30 @@ func (self *stateObject) SetState(db Database, key, value common.Hash) {
 }
 
 func (self *stateObject) setState(key, value common.Hash) {
+	// If the storage was replaced wholesale, operate on the replacement only
+	if self.fakeStorage != nil {
+		self.fakeStorage[key] = value
+		return
+	}
 	self.cachedStorage[key] = value
 	self.dirtyStorage[key] = value
 }
 
+// SetStorage replaces the entire storage of the object with the given one. Any
+// subsequent lookups only ever hit the replacement, never the storage trie.
+//
+// The replacement itself is neither journalled nor committed, it is only meant
+// to be used for simulating calls against a modified state. Writes on top of it
+// are journalled as usual though, so reverts within the call roll them back.
+func (self *stateObject) SetStorage(storage map[common.Hash]common.Hash) {
+	if self.fakeStorage == nil {
+		self.fakeStorage = make(Storage)
+	}
+	for key, value := range storage {
+		self.fakeStorage[key] = value
+	}
+}
+
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
@@ -280,6 +305,9 @@ func (self *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject.code = self.code
 	stateObject.dirtyStorage = self.dirtyStorage.Copy()
 	stateObject.cachedStorage = self.dirtyStorage.Copy()
+	if self.fakeStorage != nil {
+		stateObject.fakeStorage = self.fakeStorage.Copy()
+	}
 	stateObject.suicided = self.suicided
 	stateObject.dirtyCode = self.dirtyCode
 	stateObject.deleted = self.deleted
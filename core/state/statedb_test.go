@@ -32,7 +32,10 @@ import (
 
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+	"github.com/orangeAndSuns/go-ethereum/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -435,3 +438,104 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that account and storage proofs generated from the state can be
+// verified against the state root and the account's storage root.
+func TestStateProofs(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+
+	addr := common.BytesToAddress([]byte{0x01})
+	state.SetBalance(addr, big.NewInt(42))
+	state.SetNonce(addr, 7)
+	state.SetState(addr, common.Hash{0x01}, common.Hash{0x02})
+	root, _ := state.Commit(false)
+
+	// Verify the account proof against the state root
+	proof, err := state.GetProof(addr)
+	if err != nil {
+		t.Fatalf("failed to generate account proof: %v", err)
+	}
+	blob, _, err := trie.VerifyProof(root, crypto.Keccak256(addr.Bytes()), proofDatabase(proof))
+	if err != nil {
+		t.Fatalf("failed to verify account proof: %v", err)
+	}
+	var account Account
+	if err := rlp.DecodeBytes(blob, &account); err != nil {
+		t.Fatalf("failed to decode proven account: %v", err)
+	}
+	if account.Balance.Cmp(big.NewInt(42)) != 0 || account.Nonce != 7 {
+		t.Fatalf("proven account mismatch: balance %v, nonce %d", account.Balance, account.Nonce)
+	}
+	// Verify the storage proof against the account's storage root
+	proof, err = state.GetStorageProof(addr, common.Hash{0x01})
+	if err != nil {
+		t.Fatalf("failed to generate storage proof: %v", err)
+	}
+	blob, _, err = trie.VerifyProof(account.Root, crypto.Keccak256(common.Hash{0x01}.Bytes()), proofDatabase(proof))
+	if err != nil {
+		t.Fatalf("failed to verify storage proof: %v", err)
+	}
+	var value []byte
+	if err := rlp.DecodeBytes(blob, &value); err != nil {
+		t.Fatalf("failed to decode proven slot: %v", err)
+	}
+	if common.BytesToHash(value) != (common.Hash{0x02}) {
+		t.Fatalf("proven slot mismatch: have %x, want %x", value, common.Hash{0x02})
+	}
+	// Non-existent accounts should prove absence and have no storage proofs
+	proof, err = state.GetProof(common.BytesToAddress([]byte{0x02}))
+	if err != nil {
+		t.Fatalf("failed to generate absence proof: %v", err)
+	}
+	if blob, _, err = trie.VerifyProof(root, crypto.Keccak256(common.BytesToAddress([]byte{0x02}).Bytes()), proofDatabase(proof)); err != nil || blob != nil {
+		t.Fatalf("absence proof mismatch: value %x, err %v", blob, err)
+	}
+	if _, err := state.GetStorageProof(common.BytesToAddress([]byte{0x02}), common.Hash{}); err == nil {
+		t.Fatalf("storage proof of missing account succeeded")
+	}
+}
+
+// proofDatabase inserts a list of proof nodes into a database keyed by hash.
+func proofDatabase(proof [][]byte) *ethdb.MemDatabase {
+	db := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// Tests that replacing the storage of an account hides all the original slots,
+// and that subsequent writes land in the replacement and can be reverted.
+func TestSetStorage(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+
+	addr := common.BytesToAddress([]byte{0x01})
+	state.SetState(addr, common.Hash{0x01}, common.Hash{0x01})
+	state.SetState(addr, common.Hash{0x02}, common.Hash{0x02})
+	root, _ := state.Commit(false)
+	state, _ = New(root, state.Database())
+
+	state.SetStorage(addr, map[common.Hash]common.Hash{{0x02}: {0x03}})
+	if value := state.GetState(addr, common.Hash{0x01}); value != (common.Hash{}) {
+		t.Errorf("replaced slot 1 mismatch: have %x, want empty", value)
+	}
+	if value := state.GetState(addr, common.Hash{0x02}); value != (common.Hash{0x03}) {
+		t.Errorf("replaced slot 2 mismatch: have %x, want %x", value, common.Hash{0x03})
+	}
+	state.SetState(addr, common.Hash{0x04}, common.Hash{0x04})
+	if value := state.GetState(addr, common.Hash{0x04}); value != (common.Hash{0x04}) {
+		t.Errorf("written slot 4 mismatch: have %x, want %x", value, common.Hash{0x04})
+	}
+	// Writes to the replacement are rolled back on revert
+	snapshot := state.Snapshot()
+	state.SetState(addr, common.Hash{0x02}, common.Hash{0x05})
+	state.SetState(addr, common.Hash{0x06}, common.Hash{0x06})
+	state.RevertToSnapshot(snapshot)
+
+	if value := state.GetState(addr, common.Hash{0x02}); value != (common.Hash{0x03}) {
+		t.Errorf("reverted slot 2 mismatch: have %x, want %x", value, common.Hash{0x03})
+	}
+	if value := state.GetState(addr, common.Hash{0x06}); value != (common.Hash{}) {
+		t.Errorf("reverted slot 6 mismatch: have %x, want empty", value)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+)
+
+// ErrTxDenied is returned if a transaction is rejected by the admission policy
+// of the transaction pool.
+var ErrTxDenied = errors.New("transaction denied by admission policy")
+
+// AdmissionPolicy decides whether a transaction may enter the transaction pool.
+// It is consulted after the signature of the transaction was verified, before
+// any of the state dependent checks.
+type AdmissionPolicy interface {
+	// Admit returns an error if the transaction of the given sender should be
+	// rejected. Local transactions are those submitted through the node itself
+	// or originating from an account considered local.
+	Admit(tx *types.Transaction, from common.Address, local bool) error
+}
+
+// TxFilter is an admission policy rejecting transactions by their sender, their
+// recipient or a prefix of their call data, e.g. a method selector.
+type TxFilter struct {
+	senders    map[common.Address]struct{}
+	recipients map[common.Address]struct{}
+	data       [][]byte
+}
+
+// NewTxFilter creates an admission policy denying the transactions sent by or
+// to any of the given accounts, or having call data starting with any of the
+// given prefixes.
+func NewTxFilter(senders, recipients []common.Address, data [][]byte) *TxFilter {
+	f := &TxFilter{
+		senders:    make(map[common.Address]struct{}, len(senders)),
+		recipients: make(map[common.Address]struct{}, len(recipients)),
+		data:       data,
+	}
+	for _, addr := range senders {
+		f.senders[addr] = struct{}{}
+	}
+	for _, addr := range recipients {
+		f.recipients[addr] = struct{}{}
+	}
+	return f
+}
+
+// Admit implements AdmissionPolicy.
+func (f *TxFilter) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if _, ok := f.senders[from]; ok {
+		return ErrTxDenied
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := f.recipients[*to]; ok {
+			return ErrTxDenied
+		}
+	}
+	for _, prefix := range f.data {
+		if bytes.HasPrefix(tx.Data(), prefix) {
+			return ErrTxDenied
+		}
+	}
+	return nil
+}
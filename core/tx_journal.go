@@ -178,3 +178,103 @@ func (journal *txJournal) close() error {
 	}
 	return err
 }
+
+// fullJournalEntry is a transaction stored in the full pool journal, along with
+// the metadata needed to restore it the way it was pooled.
+type fullJournalEntry struct {
+	Tx    *types.Transaction
+	Local bool   // Whether the sender of the transaction was tracked as local
+	Time  uint64 // Unix time the transaction arrived into the pool
+}
+
+// txFullJournal is a snapshot of the whole transaction pool, both local and
+// remote transactions, regenerated periodically with the aim of letting busy
+// nodes keep their pool across restarts instead of relearning it from peers.
+//
+// Contrary to txJournal, new transactions aren't appended to the journal as they
+// arrive, as a full pool churns too fast for it to be worthwhile.
+type txFullJournal struct {
+	path string // Filesystem path to store the transactions at
+}
+
+// newTxFullJournal creates a new full transaction pool journal.
+func newTxFullJournal(path string) *txFullJournal {
+	return &txFullJournal{
+		path: path,
+	}
+}
+
+// load parses a transaction pool journal dump from disk, loading its contents
+// into the specified pool in batches.
+func (journal *txFullJournal) load(add func([]*fullJournalEntry) []error) error {
+	// Skip the parsing if the journal file doesn't exist at all
+	if _, err := os.Stat(journal.path); os.IsNotExist(err) {
+		return nil
+	}
+	input, err := os.Open(journal.path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(input, 0)
+	total, dropped := 0, 0
+
+	loadBatch := func(entries []*fullJournalEntry) {
+		for _, err := range add(entries) {
+			if err != nil {
+				log.Debug("Failed to add journaled pool transaction", "err", err)
+				dropped++
+			}
+		}
+	}
+	var (
+		failure error
+		batch   []*fullJournalEntry
+	)
+	for {
+		entry := new(fullJournalEntry)
+		if err = stream.Decode(entry); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			if len(batch) > 0 {
+				loadBatch(batch)
+			}
+			break
+		}
+		total++
+
+		if batch = append(batch, entry); len(batch) > 1024 {
+			loadBatch(batch)
+			batch = batch[:0]
+		}
+	}
+	log.Info("Loaded full transaction journal", "transactions", total, "dropped", dropped)
+
+	return failure
+}
+
+// rotate regenerates the transaction pool journal from the given snapshot of
+// the pool contents.
+func (journal *txFullJournal) rotate(entries []*fullJournalEntry) error {
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = rlp.Encode(replacement, entry); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	if err = replacement.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+		return err
+	}
+	log.Info("Regenerated full transaction journal", "transactions", len(entries))
+
+	return nil
+}
@@ -77,7 +77,7 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
-func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+func setupTxPool() (*PricedTxPool, *ecdsa.PrivateKey) {
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
 	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
 
@@ -88,7 +88,7 @@ func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 }
 
 // validateTxPoolInternals checks various consistency invariants within the pool.
-func validateTxPoolInternals(pool *TxPool) error {
+func validateTxPoolInternals(pool *PricedTxPool) error {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
@@ -259,6 +259,59 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// Tests that the admission policy of the pool rejects transactions by sender,
+// recipient and call data, for local transactions too.
+func TestTransactionAdmissionPolicy(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	denied, _ := crypto.GenerateKey()
+	for _, k := range []*ecdsa.PrivateKey{key, denied} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000000))
+	}
+	pool.SetAdmissionPolicy(NewTxFilter(
+		[]common.Address{crypto.PubkeyToAddress(denied.PublicKey)},
+		[]common.Address{{0x01}},
+		[][]byte{{0xa9, 0x05, 0x9c, 0xbb}},
+	))
+	signer := types.HomesteadSigner{}
+	send := func(nonce uint64, key *ecdsa.PrivateKey, to common.Address, data []byte) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(100), 100000, big.NewInt(1), data), signer, key)
+		return tx
+	}
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{send(0, denied, common.Address{}, nil), ErrTxDenied},
+		{send(0, key, common.Address{0x01}, nil), ErrTxDenied},
+		{send(0, key, common.Address{}, []byte{0xa9, 0x05, 0x9c, 0xbb, 0x00}), ErrTxDenied},
+		{send(0, key, common.Address{}, []byte{0xa9, 0x05}), nil},
+		{send(1, key, common.Address{0x02}, nil), nil},
+	}
+	for i, tt := range tests {
+		if err := pool.AddRemote(tt.tx); err != tt.err {
+			t.Errorf("test %d: remote admission error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if err := pool.AddLocal(send(0, denied, common.Address{}, nil)); err != ErrTxDenied {
+		t.Errorf("local admission error mismatch: have %v, want %v", err, ErrTxDenied)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Errorf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	// Dropping the policy admits everything again
+	pool.SetAdmissionPolicy(nil)
+	if err := pool.AddRemote(send(0, denied, common.Address{}, nil)); err != nil {
+		t.Errorf("transaction rejected without policy: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
@@ -1680,6 +1733,87 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that the full pool journal restores both local and remote transactions,
+// along with their arrival metadata, dropping those invalidated in between.
+func TestTransactionFullJournaling(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	// Create the original pool to inject transaction into the journal
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.FullJournal = journal
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a local and three remote transactions, one of them non-executable
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	for _, nonce := range []uint64{0, 1, 3} {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), remote)); err != nil {
+			t.Fatalf("failed to add remote transaction: %v", err)
+		}
+	}
+	queuedTx := pricedTransaction(3, 100000, big.NewInt(1), remote)
+	arrived := time.Now().Add(-time.Hour)
+	pool.all.SetArrival(queuedTx.Hash(), arrived)
+
+	pending, queued := pool.Stats()
+	if pending != 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 3)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	// Terminate the old pool, bump the remote nonce, create a new pool and ensure relevant transaction survive
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pending, queued = pool.Stats()
+	if pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)) {
+		t.Errorf("local account not restored as local")
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Errorf("remote account restored as local")
+	}
+	if have := pool.all.Arrival(queuedTx.Hash()); have.Unix() != arrived.Unix() {
+		t.Errorf("arrival time mismatch: have %v, want %v", have, arrived)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -17,9 +17,14 @@
 package core
 
 import (
+	"math/big"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/core/state"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/event"
 )
 
 // Validator is an interface which defines the standard for block validation. It
@@ -44,3 +49,53 @@ type Validator interface {
 type Processor interface {
 	Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error)
 }
+
+// TxPool is an interface which defines the standard for transaction pools,
+// gathering the transactions waiting to be included in a block. The default
+// implementation is PricedTxPool.
+type TxPool interface {
+	// AddLocal and AddLocals add transactions submitted through the node itself,
+	// which are exempt from the pricing constraints of the pool.
+	AddLocal(tx *types.Transaction) error
+	AddLocals(txs []*types.Transaction) []error
+
+	// AddRemote and AddRemotes add transactions received from the network.
+	AddRemote(tx *types.Transaction) error
+	AddRemotes(txs []*types.Transaction) []error
+
+	// Get returns the transaction with the given hash if it is in the pool, or
+	// nil otherwise.
+	Get(hash common.Hash) *types.Transaction
+
+	// Arrival returns the time the transaction with the given hash entered the
+	// pool, or the zero time if it isn't in the pool.
+	Arrival(hash common.Hash) time.Time
+
+	// Status returns the status (unknown/pending/queued) of a batch of
+	// transactions identified by their hashes.
+	Status(hashes []common.Hash) []TxStatus
+
+	// Pending returns the processable transactions, grouped by sender and
+	// sorted by nonce. The returned map is a copy the caller may modify.
+	Pending() (map[common.Address]types.Transactions, error)
+
+	// Content returns both the processable and the queued transactions,
+	// grouped by sender and sorted by nonce.
+	Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+
+	// SubscribeNewTxsEvent subscribes to the transactions entering the pool.
+	SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription
+
+	// Stats returns the number of processable and queued transactions.
+	Stats() (int, int)
+
+	// Nonce returns the next nonce of an account, taking the transactions
+	// pending in the pool into account.
+	Nonce(addr common.Address) uint64
+
+	// SetGasPrice updates the minimum gas price of remote transactions.
+	SetGasPrice(price *big.Int)
+
+	// Stop terminates the transaction pool.
+	Stop()
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ess
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+)
+
+// errOverrideWithPeers is returned if the state is attempted to be overridden
+// while peers are connected, which would be handed blocks they can't verify.
+var errOverrideWithPeers = errors.New("state overrides are only allowed on single node developer chains")
+
+// devSnapshot is a chain head saved by dev_snapshot to return to later.
+type devSnapshot struct {
+	number uint64
+	hash   common.Hash
+}
+
+// PrivateDevAPI is the collection of Essentia APIs controlling block production
+// and the chain state of developer chains, letting tests drive the node without
+// a separate simulator.
+//
+// State changes are applied by producing a new block, flagged in its extra-data,
+// whose state root doesn't follow from its transactions. Such a chain can't be
+// imported or verified by other nodes, so they're refused with peers connected.
+type PrivateDevAPI struct {
+	e *Essentia
+
+	lock      sync.Mutex
+	snapshots map[uint64]devSnapshot
+	nextID    uint64
+}
+
+// NewPrivateDevAPI creates a new API definition for the developer mode methods
+// of the Essentia service.
+func NewPrivateDevAPI(e *Essentia) *PrivateDevAPI {
+	return &PrivateDevAPI{e: e, snapshots: make(map[uint64]devSnapshot), nextID: 1}
+}
+
+// Mine produces the given number of blocks right away, one if unspecified, and
+// returns their hashes. The first block includes the pending transactions.
+func (api *PrivateDevAPI) Mine(blocks *hexutil.Uint64) ([]common.Hash, error) {
+	n := uint64(1)
+	if blocks != nil {
+		n = uint64(*blocks)
+	}
+	hashes := make([]common.Hash, 0, n)
+	for i := uint64(0); i < n; i++ {
+		block, err := api.e.Miner().MineBlock()
+		if err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, block.Hash())
+	}
+	return hashes, nil
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next block produced.
+func (api *PrivateDevAPI) SetNextBlockTimestamp(timestamp hexutil.Uint64) error {
+	return api.e.Miner().SetNextTimestamp(uint64(timestamp))
+}
+
+// Snapshot saves the current head of the chain and returns an ID to revert to it.
+func (api *PrivateDevAPI) Snapshot() hexutil.Uint64 {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	head := api.e.BlockChain().CurrentBlock()
+	id := api.nextID
+	api.snapshots[id] = devSnapshot{number: head.NumberU64(), hash: head.Hash()}
+	api.nextID++
+	return hexutil.Uint64(id)
+}
+
+// Revert sets the head of the chain back to the given snapshot, discarding all
+// blocks produced since. The snapshot and any taken after it are invalidated.
+// It returns false if the snapshot is unknown.
+func (api *PrivateDevAPI) Revert(id hexutil.Uint64) (bool, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	snap, ok := api.snapshots[uint64(id)]
+	if !ok {
+		return false, nil
+	}
+	for later := range api.snapshots {
+		if later >= uint64(id) {
+			delete(api.snapshots, later)
+		}
+	}
+	if err := api.e.Miner().Rewind(snap.number, snap.hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetBalance sets the balance of the given account.
+func (api *PrivateDevAPI) SetBalance(address common.Address, balance hexutil.Big) error {
+	return api.override(func(statedb *state.StateDB) {
+		statedb.SetBalance(address, (*big.Int)(&balance))
+	})
+}
+
+// SetCode sets the code of the given account.
+func (api *PrivateDevAPI) SetCode(address common.Address, code hexutil.Bytes) error {
+	return api.override(func(statedb *state.StateDB) {
+		statedb.SetCode(address, code)
+	})
+}
+
+// SetStorageAt sets a storage slot of the given account.
+func (api *PrivateDevAPI) SetStorageAt(address common.Address, key common.Hash, value common.Hash) error {
+	return api.override(func(statedb *state.StateDB) {
+		statedb.SetState(address, key, value)
+	})
+}
+
+// override produces a block applying the given state changes and nothing else,
+// refusing if any peer could receive it.
+func (api *PrivateDevAPI) override(modify func(*state.StateDB)) error {
+	if api.e.protocolManager.peers.Len() > 0 {
+		return errOverrideWithPeers
+	}
+	_, err := api.e.Miner().MineStateOverride(modify)
+	return err
+}
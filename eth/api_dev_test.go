@@ -0,0 +1,211 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ess
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/accounts"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/eth/downloader"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/event"
+	"github.com/orangeAndSuns/go-ethereum/miner"
+	"github.com/orangeAndSuns/go-ethereum/params"
+)
+
+// newTestDevBackend creates a minimal developer node with a real chain, pool and
+// miner, suitable for driving through the dev API.
+func newTestDevBackend(t *testing.T) *Essentia {
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+	)
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	e := &Essentia{
+		config:         &DefaultConfig,
+		chainConfig:    gspec.Config,
+		txPool:         core.NewTxPool(poolConfig, gspec.Config, chain),
+		blockchain:     chain,
+		chainDb:        db,
+		eventMux:       new(event.TypeMux),
+		engine:         engine,
+		accountManager: accounts.NewManager(),
+	}
+	if e.protocolManager, err = NewProtocolManager(gspec.Config, downloader.FullSync, DefaultConfig.NetworkId, e.eventMux, e.txPool, engine, chain, db); err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	e.protocolManager.Start(1000)
+	e.miner = miner.New(e, gspec.Config, e.eventMux, engine, time.Second, nil)
+	return e
+}
+
+func (e *Essentia) stopTestDevBackend() {
+	e.miner.Stop()
+	e.protocolManager.Stop()
+	e.txPool.Stop()
+	e.blockchain.Stop()
+}
+
+// Tests that reverting to a snapshot rewinds the chain, resets the transaction
+// pool to the old head and invalidates the snapshots taken since.
+func TestDevSnapshotRevert(t *testing.T) {
+	e := newTestDevBackend(t)
+	defer e.stopTestDevBackend()
+
+	api := NewPrivateDevAPI(e)
+	if _, err := api.Mine(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	base := e.BlockChain().CurrentBlock()
+	first := api.Snapshot()
+
+	tx := newTestTransaction(testBankKey, 0, 0)
+	if err := e.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	count := hexutil.Uint64(2)
+	hashes, err := api.Mine(&count)
+	if err != nil || len(hashes) != 2 {
+		t.Fatalf("failed to mine blocks: %v (%d)", err, len(hashes))
+	}
+	if txs := e.BlockChain().GetBlockByHash(hashes[0]).Transactions(); len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+		t.Fatalf("pending transaction not mined: %v", txs)
+	}
+	second := api.Snapshot()
+
+	// Revert to the first snapshot and check the chain and pool followed
+	if ok, err := api.Revert(first); !ok || err != nil {
+		t.Fatalf("failed to revert: %v, %v", ok, err)
+	}
+	if head := e.BlockChain().CurrentBlock(); head.Hash() != base.Hash() {
+		t.Fatalf("head mismatch after revert: have #%d, want #%d", head.NumberU64(), base.NumberU64())
+	}
+	for start := time.Now(); e.TxPool().Nonce(testBank) != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("transaction pool not reset: nonce %d, want 0", e.TxPool().Nonce(testBank))
+		}
+	}
+	// Both snapshots are gone after the revert
+	for _, id := range []hexutil.Uint64{first, second} {
+		if ok, err := api.Revert(id); ok || err != nil {
+			t.Errorf("snapshot %d: revert result mismatch: have %v (%v), want false", id, ok, err)
+		}
+	}
+	// The discarded transaction can be mined again on the rewound chain
+	if err := e.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to re-add transaction: %v", err)
+	}
+	if hashes, err = api.Mine(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	block := e.BlockChain().GetBlockByHash(hashes[0])
+	if block.NumberU64() != base.NumberU64()+1 || len(block.Transactions()) != 1 {
+		t.Fatalf("block mismatch: have #%d with %d txs, want #%d with 1", block.NumberU64(), len(block.Transactions()), base.NumberU64()+1)
+	}
+}
+
+// Tests that block timestamps invalid on top of the head are rejected.
+func TestDevSetNextBlockTimestamp(t *testing.T) {
+	e := newTestDevBackend(t)
+	defer e.stopTestDevBackend()
+
+	api := NewPrivateDevAPI(e)
+	head := e.BlockChain().CurrentBlock().Time().Uint64()
+	for _, timestamp := range []uint64{0, head} {
+		if err := api.SetNextBlockTimestamp(hexutil.Uint64(timestamp)); err == nil {
+			t.Errorf("timestamp %d accepted on top of %d", timestamp, head)
+		}
+	}
+	want := uint64(time.Now().Unix()) + 3600
+	if err := api.SetNextBlockTimestamp(hexutil.Uint64(want)); err != nil {
+		t.Fatalf("failed to set timestamp: %v", err)
+	}
+	hashes, err := api.Mine(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if have := e.BlockChain().GetBlockByHash(hashes[0]).Time().Uint64(); have != want {
+		t.Errorf("timestamp mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that state overrides produce flagged blocks without pending transactions,
+// and are refused once a peer is connected.
+func TestDevStateOverride(t *testing.T) {
+	e := newTestDevBackend(t)
+	defer e.stopTestDevBackend()
+
+	api := NewPrivateDevAPI(e)
+	if err := e.TxPool().AddLocal(newTestTransaction(testBankKey, 0, 0)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	addr := common.HexToAddress("0xdeadbeef")
+	if err := api.SetBalance(addr, hexutil.Big(*big.NewInt(1000))); err != nil {
+		t.Fatalf("failed to set balance: %v", err)
+	}
+	head := e.BlockChain().CurrentBlock()
+	if !miner.IsStateOverride(head.Header()) {
+		t.Errorf("override block not flagged")
+	}
+	if len(head.Transactions()) != 0 {
+		t.Errorf("override block includes %d transactions", len(head.Transactions()))
+	}
+	statedb, err := e.BlockChain().State()
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if balance := statedb.GetBalance(addr); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1000", balance)
+	}
+	if pending, _ := e.TxPool().Stats(); pending != 1 {
+		t.Errorf("pending transactions mismatch: have %d, want 1", pending)
+	}
+	// Connect a peer and make sure no more overrides are produced
+	peer, _ := newTestPeer("peer", eth63, e.protocolManager, true)
+	defer peer.close()
+
+	for start := time.Now(); e.protocolManager.peers.Len() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("peer not registered")
+		}
+	}
+	if err := api.SetCode(addr, []byte{0x00}); err != errOverrideWithPeers {
+		t.Errorf("override error mismatch: have %v, want %v", err, errOverrideWithPeers)
+	}
+	if e.BlockChain().CurrentBlock().Hash() != head.Hash() {
+		t.Errorf("chain head changed by refused override")
+	}
+}
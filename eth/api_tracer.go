@@ -28,6 +28,7 @@ import (
 
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/common/math"
 	"github.com/orangeAndSuns/go-ethereum/core"
 	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
 	"github.com/orangeAndSuns/go-ethereum/core/state"
@@ -60,6 +61,13 @@ type TraceConfig struct {
 	Reexec  *uint64
 }
 
+// TraceCallConfig holds extra parameters to call tracing functions, on top of
+// the ones used for tracing transactions.
+type TraceCallConfig struct {
+	TraceConfig
+	StateOverrides *ethapi.StateOverride
+}
+
 // txTraceResult is the result of a single transaction trace.
 type txTraceResult struct {
 	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
@@ -554,6 +562,67 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
 
+// TraceCall lets you trace a given eth_call. It collects the structured logs
+// created during the execution of EVM if the given transaction was added on
+// top of the provided block and returns them as a JSON object. Like eth_call,
+// the sender can pay for any gas, which defaults to the block gas limit.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args ethapi.CallArgs, number rpc.BlockNumber, config *TraceCallConfig) (interface{}, error) {
+	// Fetch the block and state that we want to trace on top of
+	var (
+		block   *types.Block
+		statedb *state.StateDB
+		err     error
+	)
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	switch number {
+	case rpc.PendingBlockNumber:
+		block, statedb = api.ess.miner.Pending()
+	case rpc.LatestBlockNumber:
+		block = api.ess.blockchain.CurrentBlock()
+	default:
+		block = api.ess.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	if statedb == nil {
+		if statedb, err = api.computeStateDB(block, reexec); err != nil {
+			return nil, err
+		}
+	}
+	// Fill in the defaults the same way eth_call does, capping the gas at the
+	// block limit and funding the sender so the call can always pay for it
+	if args.From == (common.Address{}) {
+		if wallets := api.ess.AccountManager().Wallets(); len(wallets) > 0 {
+			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
+				args.From = accounts[0].Address
+			}
+		}
+	}
+	if args.Gas == 0 || uint64(args.Gas) > block.GasLimit() {
+		args.Gas = hexutil.Uint64(block.GasLimit())
+	}
+	msg := args.ToMessage()
+	statedb.SetBalance(msg.From(), math.MaxBig256)
+
+	// Apply the customized state rules if required, after the funding so that
+	// any balance override of the sender takes precedence
+	var traceConfig *TraceConfig
+	if config != nil {
+		if err := config.StateOverrides.Apply(statedb); err != nil {
+			return nil, err
+		}
+		traceConfig = &config.TraceConfig
+	}
+	// Execute the trace
+	vmctx := core.NewEVMContext(msg, block.Header(), api.ess.blockchain, nil)
+
+	return api.traceTx(ctx, msg, vmctx, statedb, traceConfig)
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
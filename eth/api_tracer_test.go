@@ -0,0 +1,202 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ess
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/accounts"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/event"
+	"github.com/orangeAndSuns/go-ethereum/internal/ethapi"
+	"github.com/orangeAndSuns/go-ethereum/miner"
+	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rpc"
+)
+
+// newTestTraceBackend creates a node with a chain of the given number of empty
+// blocks and a miner assembling the pending block on top of it.
+func newTestTraceBackend(t *testing.T, blocks int) *Essentia {
+	var (
+		db     = ethdb.NewMemDatabase()
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{Config: params.TestChainConfig}
+	)
+	genesis := gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	generated, _ := core.GenerateChain(gspec.Config, genesis, engine, db, blocks, nil)
+	if _, err := chain.InsertChain(generated); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	e := &Essentia{
+		chainConfig:    gspec.Config,
+		txPool:         core.NewTxPool(poolConfig, gspec.Config, chain),
+		blockchain:     chain,
+		chainDb:        db,
+		eventMux:       new(event.TypeMux),
+		engine:         engine,
+		accountManager: accounts.NewManager(),
+	}
+	e.miner = miner.New(e, gspec.Config, e.eventMux, engine, 0, nil)
+	return e
+}
+
+// Tests that calls can be traced on top of the pending, latest and numbered
+// blocks, with both the struct logger and JavaScript tracers, and with the state
+// overridden.
+func TestTraceCall(t *testing.T) {
+	e := newTestTraceBackend(t, 2)
+	defer func() {
+		e.miner.Stop()
+		e.txPool.Stop()
+		e.blockchain.Stop()
+	}()
+
+	// Install a contract returning the number of the block it executes in:
+	// NUMBER PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	var (
+		contract = common.HexToAddress("0xc0de")
+		code     = hexutil.Bytes(common.FromHex("0x4360005260206000f3"))
+		override = &ethapi.StateOverride{contract: ethapi.OverrideAccount{Code: &code}}
+		jsTracer = "{steps: 0, step: function() { this.steps++ }, fault: function() {}, result: function() { return this.steps }}"
+	)
+	api := NewPrivateDebugAPI(e.chainConfig, e)
+
+	tests := []struct {
+		number rpc.BlockNumber
+		want   uint64
+	}{
+		{rpc.PendingBlockNumber, 3},
+		{rpc.LatestBlockNumber, 2},
+		{rpc.BlockNumber(1), 1},
+	}
+	for _, tt := range tests {
+		// No sender, gas nor gas price given, defaults must be able to pay for the call
+		args := ethapi.CallArgs{To: &contract}
+
+		res, err := api.TraceCall(context.Background(), args, tt.number, &TraceCallConfig{StateOverrides: override})
+		if err != nil {
+			t.Fatalf("block %d: failed to trace call: %v", tt.number, err)
+		}
+		result := res.(*ethapi.ExecutionResult)
+		if result.Failed {
+			t.Errorf("block %d: traced call failed", tt.number)
+		}
+		if len(result.StructLogs) != 6 {
+			t.Errorf("block %d: struct log length mismatch: have %d, want 6", tt.number, len(result.StructLogs))
+		}
+		if want := fmt.Sprintf("%064x", tt.want); result.ReturnValue != want {
+			t.Errorf("block %d: return value mismatch: have %s, want %s", tt.number, result.ReturnValue, want)
+		}
+		// Trace the same call with a JavaScript tracer
+		res, err = api.TraceCall(context.Background(), args, tt.number, &TraceCallConfig{TraceConfig: TraceConfig{Tracer: &jsTracer}, StateOverrides: override})
+		if err != nil {
+			t.Fatalf("block %d: failed to trace call with JavaScript: %v", tt.number, err)
+		}
+		var steps int
+		if err := json.Unmarshal(res.(json.RawMessage), &steps); err != nil {
+			t.Fatalf("block %d: failed to decode JavaScript result: %v", tt.number, err)
+		}
+		if steps != 6 {
+			t.Errorf("block %d: JavaScript step count mismatch: have %d, want 6", tt.number, steps)
+		}
+	}
+	// Without the override there is no code to run
+	res, err := api.TraceCall(context.Background(), ethapi.CallArgs{To: &contract}, rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	if logs := res.(*ethapi.ExecutionResult).StructLogs; len(logs) != 0 {
+		t.Errorf("struct log length mismatch without override: have %d, want 0", len(logs))
+	}
+	// Unknown blocks are reported
+	if _, err := api.TraceCall(context.Background(), ethapi.CallArgs{To: &contract}, rpc.BlockNumber(100), nil); err == nil {
+		t.Errorf("traced call on unknown block")
+	}
+}
+
+// Tests that the sender is funded to pay for traced calls, unless its balance is
+// explicitly overridden.
+func TestTraceCallBalanceOverride(t *testing.T) {
+	e := newTestTraceBackend(t, 1)
+	defer func() {
+		e.miner.Stop()
+		e.txPool.Stop()
+		e.blockchain.Stop()
+	}()
+
+	// Install a contract returning the balance of its caller:
+	// CALLER BALANCE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	var (
+		sender   = common.HexToAddress("0x5e4d")
+		contract = common.HexToAddress("0xc0de")
+		code     = hexutil.Bytes(common.FromHex("0x333160005260206000f3"))
+		gas      = hexutil.Uint64(100000)
+		price    = hexutil.Big(*big.NewInt(1))
+		args     = ethapi.CallArgs{From: sender, To: &contract, Gas: gas, GasPrice: price}
+	)
+	api := NewPrivateDebugAPI(e.chainConfig, e)
+
+	tests := []struct {
+		balance *big.Int
+		want    *big.Int // Balance seen by the call, nil if it can't be paid for
+	}{
+		{nil, nil},
+		{big.NewInt(1000000), big.NewInt(1000000 - 100000)},
+		{big.NewInt(1000), nil},
+	}
+	for i, tt := range tests {
+		override := ethapi.StateOverride{contract: ethapi.OverrideAccount{Code: &code}}
+		if tt.balance != nil {
+			override[sender] = ethapi.OverrideAccount{Balance: (*hexutil.Big)(tt.balance)}
+		}
+		res, err := api.TraceCall(context.Background(), args, rpc.LatestBlockNumber, &TraceCallConfig{StateOverrides: &override})
+		if tt.balance != nil && tt.want == nil {
+			if err == nil {
+				t.Errorf("test %d: call paid for beyond the overridden balance", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: failed to trace call: %v", i, err)
+		}
+		result := res.(*ethapi.ExecutionResult)
+		if tt.want != nil {
+			if want := fmt.Sprintf("%064x", tt.want); result.ReturnValue != want {
+				t.Errorf("test %d: balance mismatch: have %s, want %s", i, result.ReturnValue, want)
+			}
+		} else if result.Failed {
+			t.Errorf("test %d: traced call failed", i)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -34,6 +35,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/core"
 	"github.com/orangeAndSuns/go-ethereum/core/bloombits"
 	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
+	"github.com/orangeAndSuns/go-ethereum/core/state/pruner"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/core/vm"
 	"github.com/orangeAndSuns/go-ethereum/eth/downloader"
@@ -67,7 +69,7 @@ type Essentia struct {
 	shutdownChan chan bool // Channel for shutting down the Essentia
 
 	// Handlers
-	txPool          *core.TxPool
+	txPool          core.TxPool
 	blockchain      *core.BlockChain
 	protocolManager *ProtocolManager
 	lesServer       LesServer
@@ -112,6 +114,15 @@ func New(ctx *node.ServiceContext, config *Config) (*Essentia, error) {
 	if err != nil {
 		return nil, err
 	}
+	if chainDb, err = CreateFreezer(ctx, config, chainDb, "chaindata"); err != nil {
+		return nil, err
+	}
+	// Finish any offline state pruning that was interrupted before touching the state
+	if datadir := ctx.ResolvePath(""); datadir != "" {
+		if err := pruner.RecoverPruning(datadir, chainDb); err != nil {
+			return nil, err
+		}
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -161,13 +172,28 @@ func New(ctx *node.ServiceContext, config *Config) (*Essentia, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.FullJournal != "" {
+		config.TxPool.FullJournal = ctx.ResolvePath(config.TxPool.FullJournal)
+	}
 	ess.txPool = core.NewTxPool(config.TxPool, ess.chainConfig, ess.blockchain)
 
 	if ess.protocolManager, err = NewProtocolManager(ess.chainConfig, config.SyncMode, config.NetworkId, ess.eventMux, ess.txPool, ess.engine, ess.blockchain, chainDb); err != nil {
 		return nil, err
 	}
-	ess.miner = miner.New(ess, ess.chainConfig, ess.EventMux(), ess.engine)
+	ordering, err := miner.NewTxOrdering(config.MinerOrdering, miner.OrderingConfig{
+		Priority: config.MinerPriority,
+		Quota:    config.MinerQuota,
+		Arrival:  ess.txPool.Arrival,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ess.miner = miner.New(ess, ess.chainConfig, ess.EventMux(), ess.engine, config.MinerRecommit, ordering)
 	ess.miner.SetExtra(makeExtraData(config.ExtraData))
+	if config.Developer && ess.chainConfig.Clique != nil && ess.chainConfig.Clique.Period == 0 {
+		// Seal every transaction right away instead of waiting for its slot
+		ess.miner.SetInstantSealing(true)
+	}
 
 	ess.APIBackend = &EthAPIBackend{ess, nil}
 	gpoParams := config.GPO
@@ -208,6 +234,30 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	return db, nil
 }
 
+// CreateFreezer wraps the chain database with an ancient store that moves finalized
+// chain segments out of the key-value store into flat files. By default the
+// ancient store resides within the database directory. Ephemeral nodes without
+// an explicitly configured ancient directory keep everything in the database.
+func CreateFreezer(ctx *node.ServiceContext, config *Config, db ethdb.Database, name string) (ethdb.Database, error) {
+	dir := config.DatabaseFreezer
+	if dir == "" {
+		dir = filepath.Join(name, "ancient")
+	}
+	if dir = ctx.ResolvePath(dir); dir == "" {
+		return db, nil
+	}
+	threshold := config.DatabaseFreezerThreshold
+	if threshold == 0 {
+		threshold = params.ImmutabilityThreshold
+	}
+	frdb, err := rawdb.NewDatabaseWithFreezer(db, dir, "ess/db/"+name+"/", threshold)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Essentia service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
@@ -247,6 +297,14 @@ func (s *Essentia) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the developer mode APIs if enabled
+	if s.config.Developer {
+		apis = append(apis, rpc.API{
+			Namespace: "dev",
+			Version:   "1.0",
+			Service:   NewPrivateDevAPI(s),
+		})
+	}
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -363,7 +421,7 @@ func (s *Essentia) Miner() *miner.Miner { return s.miner }
 
 func (s *Essentia) AccountManager() *accounts.Manager  { return s.accountManager }
 func (s *Essentia) BlockChain() *core.BlockChain       { return s.blockchain }
-func (s *Essentia) TxPool() *core.TxPool               { return s.txPool }
+func (s *Essentia) TxPool() core.TxPool                { return s.txPool }
 func (s *Essentia) EventMux() *event.TypeMux           { return s.eventMux }
 func (s *Essentia) Engine() consensus.Engine           { return s.engine }
 func (s *Essentia) ChainDb() ethdb.Database            { return s.chainDb }
@@ -387,6 +445,11 @@ func (s *Essentia) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Advertise the fork ID of the chain in the local node record
+	if ln := srvr.LocalNode(); ln != nil {
+		ln.Set(s.currentEthEntry())
+		s.startEthEntryUpdate(ln)
+	}
 	// Start the RPC service
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
 
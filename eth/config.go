@@ -43,12 +43,14 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     1,
-	LightPeers:    100,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   60 * time.Minute,
-	GasPrice:      big.NewInt(18 * params.Shannon),
+	NetworkId:                1,
+	LightPeers:               100,
+	DatabaseCache:            768,
+	DatabaseFreezerThreshold: params.ImmutabilityThreshold,
+	TrieCache:                256,
+	TrieTimeout:              60 * time.Minute,
+	GasPrice:                 big.NewInt(18 * params.Shannon),
+	MinerRecommit:            3 * time.Second,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -94,11 +96,20 @@ type Config struct {
 	TrieCache          int
 	TrieTimeout        time.Duration
 
+	// Ancient store options, moving finalized chain segments out of the
+	// key-value store once they are older than the threshold
+	DatabaseFreezer          string `toml:",omitempty"`
+	DatabaseFreezerThreshold uint64
+
 	// Mining-related options
-	Etherbase    common.Address `toml:",omitempty"`
-	MinerThreads int            `toml:",omitempty"`
-	ExtraData    []byte         `toml:",omitempty"`
-	GasPrice     *big.Int
+	Etherbase     common.Address `toml:",omitempty"`
+	MinerThreads  int            `toml:",omitempty"`
+	ExtraData     []byte         `toml:",omitempty"`
+	GasPrice      *big.Int
+	MinerRecommit time.Duration
+	MinerOrdering string           `toml:",omitempty"` // Transaction ordering policy of produced blocks
+	MinerPriority []common.Address `toml:",omitempty"` // Senders included first by the priority ordering
+	MinerQuota    uint64           `toml:",omitempty"` // Gas available to each sender per block with the quota ordering
 
 	// Ethash options
 	Ethash ethash.Config
@@ -112,6 +123,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Developer mode, producing blocks on demand through the dev API
+	Developer bool `toml:",omitempty"`
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
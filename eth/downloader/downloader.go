@@ -37,13 +37,14 @@ import (
 )
 
 var (
-	MaxHashFetch    = 512 // Amount of hashes to be fetched per retrieval request
-	MaxBlockFetch   = 128 // Amount of blocks to be fetched per retrieval request
-	MaxHeaderFetch  = 192 // Amount of block headers to be fetched per retrieval request
-	MaxSkeletonSize = 128 // Number of header fetches to need for a skeleton assembly
-	MaxBodyFetch    = 128 // Amount of block bodies to be fetched per retrieval request
-	MaxReceiptFetch = 256 // Amount of transaction receipts to allow fetching per request
-	MaxStateFetch   = 384 // Amount of node state values to allow fetching per request
+	MaxHashFetch         = 512 // Amount of hashes to be fetched per retrieval request
+	MaxBlockFetch        = 128 // Amount of blocks to be fetched per retrieval request
+	MaxHeaderFetch       = 192 // Amount of block headers to be fetched per retrieval request
+	MaxSkeletonSize      = 128 // Number of header fetches to need for a skeleton assembly
+	MaxBodyFetch         = 128 // Amount of block bodies to be fetched per retrieval request
+	MaxReceiptFetch      = 256 // Amount of transaction receipts to allow fetching per request
+	MaxStateFetch        = 384 // Amount of node state values to allow fetching per request
+	MaxStorageRangeFetch = 128 // Amount of storage ranges to allow fetching per request
 
 	MaxForkAncestry  = 3 * params.EpochDuration // Maximum chain reorganisation
 	rttMinEstimate   = 2 * time.Second          // Minimum round-trip time to target for download requests
@@ -133,6 +134,7 @@ type Downloader struct {
 	stateSyncStart chan *stateSync
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [ess/63] Channel receiving inbound node state data
+	rangeCh        chan dataPack // [ess/67] Channel receiving inbound account and storage ranges
 
 	// Cancellation and termination
 	cancelPeer string         // Identifier of the peer currently being used as the master (cancel on drop)
@@ -222,6 +224,7 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 		headerProcCh:   make(chan []*types.Header, 1),
 		quitCh:         make(chan struct{}),
 		stateCh:        make(chan dataPack),
+		rangeCh:        make(chan dataPack),
 		stateSyncStart: make(chan *stateSync),
 		syncStatsState: stateSyncStats{
 			processed: rawdb.ReadFastTrieProgress(stateDb),
@@ -548,7 +551,8 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 
 	// Request the advertised remote head block and wait for the response
 	head, _ := p.peer.Head()
-	go p.peer.RequestHeadersByHash(head, 1, 0, false)
+	reqID := genReqID()
+	go p.peer.RequestHeadersByHash(reqID, head, 1, 0, false)
 
 	ttl := d.requestTTL()
 	timeout := time.After(ttl)
@@ -558,11 +562,15 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 			return nil, errCancelBlockFetch
 
 		case packet := <-d.headerCh:
-			// Discard anything not from the origin peer
+			// Discard anything not from the origin peer, or not answering this request
 			if packet.PeerId() != p.id {
 				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
 				break
 			}
+			if !answers(packet, reqID) {
+				p.log.Debug("Received headers for stale request", "reqid", packet.ReqId())
+				break
+			}
 			// Make sure the peer actually gave something valid
 			headers := packet.(*headerPack).headers
 			if len(headers) != 1 {
@@ -618,7 +626,8 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 	if count > limit {
 		count = limit
 	}
-	go p.peer.RequestHeadersByNumber(uint64(from), count, 15, false)
+	reqID := genReqID()
+	go p.peer.RequestHeadersByNumber(reqID, uint64(from), count, 15, false)
 
 	// Wait for the remote response to the head fetch
 	number, hash := uint64(0), common.Hash{}
@@ -632,11 +641,15 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 			return 0, errCancelHeaderFetch
 
 		case packet := <-d.headerCh:
-			// Discard anything not from the origin peer
+			// Discard anything not from the origin peer, or not answering this request
 			if packet.PeerId() != p.id {
 				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
 				break
 			}
+			if !answers(packet, reqID) {
+				p.log.Debug("Received headers for stale request", "reqid", packet.ReqId())
+				break
+			}
 			// Make sure the peer actually gave something valid
 			headers := packet.(*headerPack).headers
 			if len(headers) == 0 {
@@ -700,7 +713,8 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 		ttl := d.requestTTL()
 		timeout := time.After(ttl)
 
-		go p.peer.RequestHeadersByNumber(check, 1, 0, false)
+		reqID := genReqID()
+		go p.peer.RequestHeadersByNumber(reqID, check, 1, 0, false)
 
 		// Wait until a reply arrives to this request
 		for arrived := false; !arrived; {
@@ -709,11 +723,15 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 				return 0, errCancelHeaderFetch
 
 			case packer := <-d.headerCh:
-				// Discard anything not from the origin peer
+				// Discard anything not from the origin peer, or not answering this request
 				if packer.PeerId() != p.id {
 					log.Debug("Received headers from incorrect peer", "peer", packer.PeerId())
 					break
 				}
+				if !answers(packer, reqID) {
+					p.log.Debug("Received headers for stale request", "reqid", packer.ReqId())
+					break
+				}
 				// Make sure the peer actually gave something valid
 				headers := packer.(*headerPack).headers
 				if len(headers) != 1 {
@@ -772,19 +790,23 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 	<-timeout.C                 // timeout channel should be initially empty
 	defer timeout.Stop()
 
-	var ttl time.Duration
+	var (
+		ttl   time.Duration
+		reqID uint64 // ID of the last skeleton fetch request
+	)
 	getHeaders := func(from uint64) {
 		request = time.Now()
+		reqID = genReqID()
 
 		ttl = d.requestTTL()
 		timeout.Reset(ttl)
 
 		if skeleton {
 			p.log.Trace("Fetching skeleton headers", "count", MaxHeaderFetch, "from", from)
-			go p.peer.RequestHeadersByNumber(from+uint64(MaxHeaderFetch)-1, MaxSkeletonSize, MaxHeaderFetch-1, false)
+			go p.peer.RequestHeadersByNumber(reqID, from+uint64(MaxHeaderFetch)-1, MaxSkeletonSize, MaxHeaderFetch-1, false)
 		} else {
 			p.log.Trace("Fetching full headers", "count", MaxHeaderFetch, "from", from)
-			go p.peer.RequestHeadersByNumber(from, MaxHeaderFetch, 0, false)
+			go p.peer.RequestHeadersByNumber(reqID, from, MaxHeaderFetch, 0, false)
 		}
 	}
 	// Start pulling the header chain skeleton until all is done
@@ -801,6 +823,10 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 				log.Debug("Received skeleton from incorrect peer", "peer", packet.PeerId())
 				break
 			}
+			if !answers(packet, reqID) {
+				p.log.Debug("Received skeleton for stale request", "reqid", packet.ReqId())
+				break
+			}
 			headerReqTimer.UpdateSince(request)
 			timeout.Stop()
 
@@ -900,14 +926,14 @@ func (d *Downloader) fillHeaderSkeleton(from uint64, skeleton []*types.Header) (
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*headerPack)
-			return d.queue.DeliverHeaders(pack.peerID, pack.headers, d.headerProcCh)
+			return d.queue.DeliverHeaders(pack.peerID, pack.reqID, pack.headers, d.headerProcCh)
 		}
 		expire   = func() map[string]int { return d.queue.ExpireHeaders(d.requestTTL()) }
 		throttle = func() bool { return false }
 		reserve  = func(p *peerConnection, count int) (*fetchRequest, bool, error) {
 			return d.queue.ReserveHeaders(p, count), false, nil
 		}
-		fetch    = func(p *peerConnection, req *fetchRequest) error { return p.FetchHeaders(req.From, MaxHeaderFetch) }
+		fetch    = func(p *peerConnection, req *fetchRequest) error { return p.FetchHeaders(req, MaxHeaderFetch) }
 		capacity = func(p *peerConnection) int { return p.HeaderCapacity(d.requestRTT()) }
 		setIdle  = func(p *peerConnection, accepted int) { p.SetHeadersIdle(accepted) }
 	)
@@ -930,7 +956,7 @@ func (d *Downloader) fetchBodies(from uint64) error {
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*bodyPack)
-			return d.queue.DeliverBodies(pack.peerID, pack.transactions, pack.uncles)
+			return d.queue.DeliverBodies(pack.peerID, pack.reqID, pack.transactions, pack.uncles)
 		}
 		expire   = func() map[string]int { return d.queue.ExpireBodies(d.requestTTL()) }
 		fetch    = func(p *peerConnection, req *fetchRequest) error { return p.FetchBodies(req) }
@@ -954,7 +980,7 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*receiptPack)
-			return d.queue.DeliverReceipts(pack.peerID, pack.receipts)
+			return d.queue.DeliverReceipts(pack.peerID, pack.reqID, pack.receipts)
 		}
 		expire   = func() map[string]int { return d.queue.ExpireReceipts(d.requestTTL()) }
 		fetch    = func(p *peerConnection, req *fetchRequest) error { return p.FetchReceipts(req) }
@@ -1023,7 +1049,8 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 				}
 				// Unless a peer delivered something completely else than requested (usually
 				// caused by a timed out request which came through in the end), set it to
-				// idle. If the delivery's stale, the peer should have already been idled.
+				// idle. If the delivery's stale, the peer was either idled already when its
+				// request timed out, or it's still busy with a newer request.
 				if err != errStaleDelivery {
 					setIdle(peer, accepted)
 				}
@@ -1522,24 +1549,36 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 }
 
 // DeliverHeaders injects a new batch of block headers received from a remote
-// node into the download schedule.
-func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) (err error) {
-	return d.deliver(id, d.headerCh, &headerPack{id, headers}, headerInMeter, headerDropMeter)
+// node into the download schedule. The reqID is the ID of the answered request,
+// or zero if the peer's protocol doesn't support request IDs.
+func (d *Downloader) DeliverHeaders(id string, reqID uint64, headers []*types.Header) (err error) {
+	return d.deliver(id, d.headerCh, &headerPack{id, reqID, headers}, headerInMeter, headerDropMeter)
 }
 
 // DeliverBodies injects a new batch of block bodies received from a remote node.
-func (d *Downloader) DeliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error) {
-	return d.deliver(id, d.bodyCh, &bodyPack{id, transactions, uncles}, bodyInMeter, bodyDropMeter)
+func (d *Downloader) DeliverBodies(id string, reqID uint64, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error) {
+	return d.deliver(id, d.bodyCh, &bodyPack{id, reqID, transactions, uncles}, bodyInMeter, bodyDropMeter)
 }
 
 // DeliverReceipts injects a new batch of receipts received from a remote node.
-func (d *Downloader) DeliverReceipts(id string, receipts [][]*types.Receipt) (err error) {
-	return d.deliver(id, d.receiptCh, &receiptPack{id, receipts}, receiptInMeter, receiptDropMeter)
+func (d *Downloader) DeliverReceipts(id string, reqID uint64, receipts [][]*types.Receipt) (err error) {
+	return d.deliver(id, d.receiptCh, &receiptPack{id, reqID, receipts}, receiptInMeter, receiptDropMeter)
 }
 
 // DeliverNodeData injects a new batch of node state data received from a remote node.
-func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
-	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
+func (d *Downloader) DeliverNodeData(id string, reqID uint64, data [][]byte) (err error) {
+	return d.deliver(id, d.stateCh, &statePack{id, reqID, data}, stateInMeter, stateDropMeter)
+}
+
+// DeliverAccountRange injects a new range of accounts received from a remote node.
+func (d *Downloader) DeliverAccountRange(id string, reqID uint64, hashes []common.Hash, accounts [][]byte, proof [][]byte) (err error) {
+	return d.deliver(id, d.rangeCh, &accountRangePack{id, reqID, hashes, accounts, proof}, rangeInMeter, rangeDropMeter)
+}
+
+// DeliverStorageRanges injects a new batch of storage ranges received from a
+// remote node.
+func (d *Downloader) DeliverStorageRanges(id string, reqID uint64, hashes [][]common.Hash, slots [][][]byte, proof [][]byte) (err error) {
+	return d.deliver(id, d.rangeCh, &storageRangesPack{id, reqID, hashes, slots, proof}, rangeInMeter, rangeDropMeter)
 }
 
 // deliver injects a new batch of data received from a remote node.
@@ -17,6 +17,7 @@
 package downloader
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -28,11 +29,13 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
 	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/ethdb"
 	"github.com/orangeAndSuns/go-ethereum/event"
 	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
 	"github.com/orangeAndSuns/go-ethereum/trie"
 )
 
@@ -457,10 +460,11 @@ func (dl *downloadTester) dropPeer(id string) {
 }
 
 type downloadTesterPeer struct {
-	dl    *downloadTester
-	id    string
-	delay time.Duration
-	lock  sync.RWMutex
+	dl     *downloadTester
+	id     string
+	delay  time.Duration
+	ranges int32 // Number of state range requests served
+	lock   sync.RWMutex
 }
 
 // setDelay is a thread safe setter for the network delay value.
@@ -492,32 +496,44 @@ func (dlp *downloadTesterPeer) Head() (common.Hash, *big.Int) {
 // RequestHeadersByHash constructs a GetBlockHeaders function based on a hashed
 // origin; associated with a particular peer in the download tester. The returned
 // function can be used to retrieve batches of headers from the particular peer.
-func (dlp *downloadTesterPeer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
-	// Find the canonical number of the hash
-	dlp.dl.lock.RLock()
-	number := uint64(0)
-	for num, hash := range dlp.dl.peerHashes[dlp.id] {
-		if hash == origin {
-			number = uint64(len(dlp.dl.peerHashes[dlp.id]) - num - 1)
-			break
-		}
-	}
-	dlp.dl.lock.RUnlock()
-
+func (dlp *downloadTesterPeer) RequestHeadersByHash(id uint64, origin common.Hash, amount int, skip int, reverse bool) error {
 	// Use the absolute header fetcher to satisfy the query
-	return dlp.RequestHeadersByNumber(number, amount, skip, reverse)
+	return dlp.RequestHeadersByNumber(id, dlp.number(origin), amount, skip, reverse)
 }
 
 // RequestHeadersByNumber constructs a GetBlockHeaders function based on a numbered
 // origin; associated with a particular peer in the download tester. The returned
 // function can be used to retrieve batches of headers from the particular peer.
-func (dlp *downloadTesterPeer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
+func (dlp *downloadTesterPeer) RequestHeadersByNumber(id uint64, origin uint64, amount int, skip int, reverse bool) error {
 	dlp.waitDelay()
+	result := dlp.headers(origin, amount, skip)
 
+	// Delay delivery a bit to allow attacks to unfold
+	go func() {
+		time.Sleep(time.Millisecond)
+		dlp.dl.downloader.DeliverHeaders(dlp.id, id, result)
+	}()
+	return nil
+}
+
+// number returns the canonical number of a hash in the peer's chain.
+func (dlp *downloadTesterPeer) number(origin common.Hash) uint64 {
+	dlp.dl.lock.RLock()
+	defer dlp.dl.lock.RUnlock()
+
+	for num, hash := range dlp.dl.peerHashes[dlp.id] {
+		if hash == origin {
+			return uint64(len(dlp.dl.peerHashes[dlp.id]) - num - 1)
+		}
+	}
+	return 0
+}
+
+// headers gathers a batch of headers from the peer's chain.
+func (dlp *downloadTesterPeer) headers(origin uint64, amount int, skip int) []*types.Header {
 	dlp.dl.lock.RLock()
 	defer dlp.dl.lock.RUnlock()
 
-	// Gather the next batch of headers
 	hashes := dlp.dl.peerHashes[dlp.id]
 	headers := dlp.dl.peerHeaders[dlp.id]
 	result := make([]*types.Header, 0, amount)
@@ -526,18 +542,13 @@ func (dlp *downloadTesterPeer) RequestHeadersByNumber(origin uint64, amount int,
 			result = append(result, header)
 		}
 	}
-	// Delay delivery a bit to allow attacks to unfold
-	go func() {
-		time.Sleep(time.Millisecond)
-		dlp.dl.downloader.DeliverHeaders(dlp.id, result)
-	}()
-	return nil
+	return result
 }
 
 // RequestBodies constructs a getBlockBodies method associated with a particular
 // peer in the download tester. The returned function can be used to retrieve
 // batches of block bodies from the particularly requested peer.
-func (dlp *downloadTesterPeer) RequestBodies(hashes []common.Hash) error {
+func (dlp *downloadTesterPeer) RequestBodies(id uint64, hashes []common.Hash) error {
 	dlp.waitDelay()
 
 	dlp.dl.lock.RLock()
@@ -554,7 +565,7 @@ func (dlp *downloadTesterPeer) RequestBodies(hashes []common.Hash) error {
 			uncles = append(uncles, block.Uncles())
 		}
 	}
-	go dlp.dl.downloader.DeliverBodies(dlp.id, transactions, uncles)
+	go dlp.dl.downloader.DeliverBodies(dlp.id, id, transactions, uncles)
 
 	return nil
 }
@@ -562,7 +573,7 @@ func (dlp *downloadTesterPeer) RequestBodies(hashes []common.Hash) error {
 // RequestReceipts constructs a getReceipts method associated with a particular
 // peer in the download tester. The returned function can be used to retrieve
 // batches of block receipts from the particularly requested peer.
-func (dlp *downloadTesterPeer) RequestReceipts(hashes []common.Hash) error {
+func (dlp *downloadTesterPeer) RequestReceipts(id uint64, hashes []common.Hash) error {
 	dlp.waitDelay()
 
 	dlp.dl.lock.RLock()
@@ -576,7 +587,7 @@ func (dlp *downloadTesterPeer) RequestReceipts(hashes []common.Hash) error {
 			results = append(results, receipt)
 		}
 	}
-	go dlp.dl.downloader.DeliverReceipts(dlp.id, results)
+	go dlp.dl.downloader.DeliverReceipts(dlp.id, id, results)
 
 	return nil
 }
@@ -584,7 +595,7 @@ func (dlp *downloadTesterPeer) RequestReceipts(hashes []common.Hash) error {
 // RequestNodeData constructs a getNodeData method associated with a particular
 // peer in the download tester. The returned function can be used to retrieve
 // batches of node state data from the particularly requested peer.
-func (dlp *downloadTesterPeer) RequestNodeData(hashes []common.Hash) error {
+func (dlp *downloadTesterPeer) RequestNodeData(id uint64, hashes []common.Hash) error {
 	dlp.waitDelay()
 
 	dlp.dl.lock.RLock()
@@ -598,11 +609,138 @@ func (dlp *downloadTesterPeer) RequestNodeData(hashes []common.Hash) error {
 			}
 		}
 	}
-	go dlp.dl.downloader.DeliverNodeData(dlp.id, results)
+	go dlp.dl.downloader.DeliverNodeData(dlp.id, id, results)
 
 	return nil
 }
 
+// testRangeBytes is the response size limit of the tester peers, small enough
+// to force state ranges to be retrieved in many pieces.
+const testRangeBytes = 4096
+
+// RequestAccountRange constructs a getAccountRange method associated with a
+// particular peer in the download tester. The returned function can be used to
+// retrieve ranges of accounts from the particularly requested peer.
+func (dlp *downloadTesterPeer) RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, size uint64) error {
+	dlp.waitDelay()
+	atomic.AddInt32(&dlp.ranges, 1)
+
+	dlp.dl.lock.RLock()
+	defer dlp.dl.lock.RUnlock()
+
+	tr, err := trie.New(root, trie.NewDatabase(dlp.dl.peerDb))
+	if err != nil {
+		go dlp.dl.downloader.DeliverAccountRange(dlp.id, id, nil, nil, nil)
+		return nil
+	}
+	var (
+		hashes   []common.Hash
+		accounts [][]byte
+		served   uint64
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		hashes = append(hashes, hash)
+		accounts = append(accounts, common.CopyBytes(it.Value))
+
+		served += uint64(common.HashLength + len(it.Value))
+		if bytes.Compare(hash[:], limit[:]) >= 0 || served >= size || served >= testRangeBytes {
+			break
+		}
+	}
+	last := origin
+	if len(hashes) > 0 {
+		last = hashes[len(hashes)-1]
+	}
+	go dlp.dl.downloader.DeliverAccountRange(dlp.id, id, hashes, accounts, testRangeProof(tr, origin, last))
+
+	return nil
+}
+
+// RequestStorageRanges constructs a getStorageRanges method associated with a
+// particular peer in the download tester. The returned function can be used to
+// retrieve batches of storage ranges from the particularly requested peer.
+func (dlp *downloadTesterPeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin common.Hash, size uint64) error {
+	dlp.waitDelay()
+	atomic.AddInt32(&dlp.ranges, 1)
+
+	dlp.dl.lock.RLock()
+	defer dlp.dl.lock.RUnlock()
+
+	var (
+		triedb = trie.NewDatabase(dlp.dl.peerDb)
+		hashes [][]common.Hash
+		slots  [][][]byte
+		proof  [][]byte
+		served uint64
+	)
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		go dlp.dl.downloader.DeliverStorageRanges(dlp.id, id, nil, nil, nil)
+		return nil
+	}
+	for i, account := range accounts {
+		if served >= size || served >= testRangeBytes {
+			break
+		}
+		var start common.Hash
+		if i == 0 {
+			start = origin
+		}
+		var data state.Account
+		if err := rlp.DecodeBytes(accTrie.Get(account[:]), &data); err != nil {
+			break
+		}
+		stTrie, err := trie.New(data.Root, triedb)
+		if err != nil {
+			break
+		}
+		var (
+			keys    []common.Hash
+			values  [][]byte
+			partial bool
+		)
+		it := trie.NewIterator(stTrie.NodeIterator(start[:]))
+		for it.Next() {
+			if served >= size || served >= testRangeBytes {
+				partial = true
+				break
+			}
+			keys = append(keys, common.BytesToHash(it.Key))
+			values = append(values, common.CopyBytes(it.Value))
+			served += uint64(common.HashLength + len(it.Value))
+		}
+		hashes = append(hashes, keys)
+		slots = append(slots, values)
+
+		if partial || start != (common.Hash{}) {
+			last := start
+			if len(keys) > 0 {
+				last = keys[len(keys)-1]
+			}
+			proof = testRangeProof(stTrie, start, last)
+			break
+		}
+	}
+	go dlp.dl.downloader.DeliverStorageRanges(dlp.id, id, hashes, slots, proof)
+
+	return nil
+}
+
+// testRangeProof creates the Merkle proofs of the boundaries of a trie range.
+func testRangeProof(tr *trie.Trie, first, last common.Hash) [][]byte {
+	proofDb := ethdb.NewMemDatabase()
+	tr.ProveRange(first[:], last[:], proofDb)
+
+	var proof [][]byte
+	for _, key := range proofDb.Keys() {
+		node, _ := proofDb.Get(key)
+		proof = append(proof, node)
+	}
+	return proof
+}
+
 // assertOwnChain checks if the local chain contains the correct number of items
 // of the various chain components.
 func assertOwnChain(t *testing.T, tester *downloadTester, length int) {
@@ -665,6 +803,15 @@ func TestCanonicalSynchronisation63Fast(t *testing.T)  { testCanonicalSynchronis
 func TestCanonicalSynchronisation64Full(t *testing.T)  { testCanonicalSynchronisation(t, 64, FullSync) }
 func TestCanonicalSynchronisation64Fast(t *testing.T)  { testCanonicalSynchronisation(t, 64, FastSync) }
 func TestCanonicalSynchronisation64Light(t *testing.T) { testCanonicalSynchronisation(t, 64, LightSync) }
+func TestCanonicalSynchronisation65Full(t *testing.T)  { testCanonicalSynchronisation(t, 65, FullSync) }
+func TestCanonicalSynchronisation65Fast(t *testing.T)  { testCanonicalSynchronisation(t, 65, FastSync) }
+func TestCanonicalSynchronisation65Light(t *testing.T) { testCanonicalSynchronisation(t, 65, LightSync) }
+func TestCanonicalSynchronisation66Full(t *testing.T)  { testCanonicalSynchronisation(t, 66, FullSync) }
+func TestCanonicalSynchronisation66Fast(t *testing.T)  { testCanonicalSynchronisation(t, 66, FastSync) }
+func TestCanonicalSynchronisation66Light(t *testing.T) { testCanonicalSynchronisation(t, 66, LightSync) }
+func TestCanonicalSynchronisation67Full(t *testing.T)  { testCanonicalSynchronisation(t, 67, FullSync) }
+func TestCanonicalSynchronisation67Fast(t *testing.T)  { testCanonicalSynchronisation(t, 67, FastSync) }
+func TestCanonicalSynchronisation67Light(t *testing.T) { testCanonicalSynchronisation(t, 67, LightSync) }
 
 func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	t.Parallel()
@@ -920,10 +1067,10 @@ func TestInactiveDownloader62(t *testing.T) {
 	defer tester.terminate()
 
 	// Check that neither block headers nor bodies are accepted
-	if err := tester.downloader.DeliverHeaders("bad peer", []*types.Header{}); err != errNoSyncActive {
+	if err := tester.downloader.DeliverHeaders("bad peer", 0, []*types.Header{}); err != errNoSyncActive {
 		t.Errorf("error mismatch: have %v, want %v", err, errNoSyncActive)
 	}
-	if err := tester.downloader.DeliverBodies("bad peer", [][]*types.Transaction{}, [][]*types.Header{}); err != errNoSyncActive {
+	if err := tester.downloader.DeliverBodies("bad peer", 0, [][]*types.Transaction{}, [][]*types.Header{}); err != errNoSyncActive {
 		t.Errorf("error mismatch: have %v, want %v", err, errNoSyncActive)
 	}
 }
@@ -937,13 +1084,13 @@ func TestInactiveDownloader63(t *testing.T) {
 	defer tester.terminate()
 
 	// Check that neither block headers nor bodies are accepted
-	if err := tester.downloader.DeliverHeaders("bad peer", []*types.Header{}); err != errNoSyncActive {
+	if err := tester.downloader.DeliverHeaders("bad peer", 0, []*types.Header{}); err != errNoSyncActive {
 		t.Errorf("error mismatch: have %v, want %v", err, errNoSyncActive)
 	}
-	if err := tester.downloader.DeliverBodies("bad peer", [][]*types.Transaction{}, [][]*types.Header{}); err != errNoSyncActive {
+	if err := tester.downloader.DeliverBodies("bad peer", 0, [][]*types.Transaction{}, [][]*types.Header{}); err != errNoSyncActive {
 		t.Errorf("error mismatch: have %v, want %v", err, errNoSyncActive)
 	}
-	if err := tester.downloader.DeliverReceipts("bad peer", [][]*types.Receipt{}); err != errNoSyncActive {
+	if err := tester.downloader.DeliverReceipts("bad peer", 0, [][]*types.Receipt{}); err != errNoSyncActive {
 		t.Errorf("error mismatch: have %v, want %v", err, errNoSyncActive)
 	}
 }
@@ -1687,33 +1834,33 @@ type floodingTestPeer struct {
 }
 
 func (ftp *floodingTestPeer) Head() (common.Hash, *big.Int) { return ftp.peer.Head() }
-func (ftp *floodingTestPeer) RequestHeadersByHash(hash common.Hash, count int, skip int, reverse bool) error {
-	return ftp.peer.RequestHeadersByHash(hash, count, skip, reverse)
+func (ftp *floodingTestPeer) RequestHeadersByHash(id uint64, hash common.Hash, count int, skip int, reverse bool) error {
+	return ftp.peer.RequestHeadersByHash(id, hash, count, skip, reverse)
 }
-func (ftp *floodingTestPeer) RequestBodies(hashes []common.Hash) error {
-	return ftp.peer.RequestBodies(hashes)
+func (ftp *floodingTestPeer) RequestBodies(id uint64, hashes []common.Hash) error {
+	return ftp.peer.RequestBodies(id, hashes)
 }
-func (ftp *floodingTestPeer) RequestReceipts(hashes []common.Hash) error {
-	return ftp.peer.RequestReceipts(hashes)
+func (ftp *floodingTestPeer) RequestReceipts(id uint64, hashes []common.Hash) error {
+	return ftp.peer.RequestReceipts(id, hashes)
 }
-func (ftp *floodingTestPeer) RequestNodeData(hashes []common.Hash) error {
-	return ftp.peer.RequestNodeData(hashes)
+func (ftp *floodingTestPeer) RequestNodeData(id uint64, hashes []common.Hash) error {
+	return ftp.peer.RequestNodeData(id, hashes)
 }
 
-func (ftp *floodingTestPeer) RequestHeadersByNumber(from uint64, count, skip int, reverse bool) error {
+func (ftp *floodingTestPeer) RequestHeadersByNumber(id uint64, from uint64, count, skip int, reverse bool) error {
 	deliveriesDone := make(chan struct{}, 500)
 	for i := 0; i < cap(deliveriesDone); i++ {
 		peer := fmt.Sprintf("fake-peer%d", i)
 		ftp.pend.Add(1)
 
 		go func() {
-			ftp.tester.downloader.DeliverHeaders(peer, []*types.Header{{}, {}, {}, {}})
+			ftp.tester.downloader.DeliverHeaders(peer, 0, []*types.Header{{}, {}, {}, {}})
 			deliveriesDone <- struct{}{}
 			ftp.pend.Done()
 		}()
 	}
 	// Deliver the actual requested headers.
-	go ftp.peer.RequestHeadersByNumber(from, count, skip, reverse)
+	go ftp.peer.RequestHeadersByNumber(id, from, count, skip, reverse)
 	// None of the extra deliveries should block.
 	timeout := time.After(60 * time.Second)
 	for i := 0; i < cap(deliveriesDone); i++ {
@@ -1753,3 +1900,162 @@ func testDeliverHeadersHang(t *testing.T, protocol int, mode SyncMode) {
 		tester.downloader.peers.peers["peer"].peer.(*floodingTestPeer).pend.Wait()
 	}
 }
+
+// Tests that responses are matched to their requests by ID, so that the late reply
+// to an earlier request isn't mistaken for the reply to a newer one sent to the
+// same peer.
+func TestInterleavedRequests65Full(t *testing.T)  { testInterleavedRequests(t, 65, FullSync) }
+func TestInterleavedRequests65Fast(t *testing.T)  { testInterleavedRequests(t, 65, FastSync) }
+func TestInterleavedRequests65Light(t *testing.T) { testInterleavedRequests(t, 65, LightSync) }
+
+// interleavingTestPeer is a tester peer which, before answering a request,
+// answers the previous request of the same kind once more, as if its reply
+// was overtaken by the newer request.
+type interleavingTestPeer struct {
+	*downloadTesterPeer
+
+	headers  func() // Repeats the reply to the previous header request
+	bodies   func() // Repeats the reply to the previous body request
+	receipts func() // Repeats the reply to the previous receipt request
+	stale    int32  // Number of stale replies delivered
+	lock     sync.Mutex
+}
+
+// interleave stores the reply to the current request and delivers the one to
+// the previous request.
+func (itp *interleavingTestPeer) interleave(prev *func(), next func()) {
+	itp.lock.Lock()
+	reply := *prev
+	*prev = next
+	itp.lock.Unlock()
+
+	if reply != nil {
+		reply()
+		atomic.AddInt32(&itp.stale, 1)
+	}
+}
+
+func (itp *interleavingTestPeer) RequestHeadersByHash(id uint64, origin common.Hash, amount int, skip int, reverse bool) error {
+	return itp.RequestHeadersByNumber(id, itp.number(origin), amount, skip, reverse)
+}
+
+func (itp *interleavingTestPeer) RequestHeadersByNumber(id uint64, origin uint64, amount int, skip int, reverse bool) error {
+	headers := itp.downloadTesterPeer.headers(origin, amount, skip)
+	itp.interleave(&itp.headers, func() { itp.dl.downloader.DeliverHeaders(itp.id, id, headers) })
+
+	return itp.downloadTesterPeer.RequestHeadersByNumber(id, origin, amount, skip, reverse)
+}
+
+func (itp *interleavingTestPeer) RequestBodies(id uint64, hashes []common.Hash) error {
+	var (
+		transactions [][]*types.Transaction
+		uncles       [][]*types.Header
+	)
+	itp.dl.lock.RLock()
+	for _, hash := range hashes {
+		block := itp.dl.peerBlocks[itp.id][hash]
+		transactions = append(transactions, block.Transactions())
+		uncles = append(uncles, block.Uncles())
+	}
+	itp.dl.lock.RUnlock()
+	itp.interleave(&itp.bodies, func() { itp.dl.downloader.DeliverBodies(itp.id, id, transactions, uncles) })
+
+	return itp.downloadTesterPeer.RequestBodies(id, hashes)
+}
+
+func (itp *interleavingTestPeer) RequestReceipts(id uint64, hashes []common.Hash) error {
+	var receipts [][]*types.Receipt
+
+	itp.dl.lock.RLock()
+	for _, hash := range hashes {
+		receipts = append(receipts, itp.dl.peerReceipts[itp.id][hash])
+	}
+	itp.dl.lock.RUnlock()
+	itp.interleave(&itp.receipts, func() { itp.dl.downloader.DeliverReceipts(itp.id, id, receipts) })
+
+	return itp.downloadTesterPeer.RequestReceipts(id, hashes)
+}
+
+func testInterleavedRequests(t *testing.T, protocol int, mode SyncMode) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain long enough to need many requests of every kind
+	targetBlocks := 4*blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	peer := &interleavingTestPeer{downloadTesterPeer: tester.downloader.peers.peers["peer"].peer.(*downloadTesterPeer)}
+	tester.downloader.peers.peers["peer"].peer = peer
+
+	// Synchronise with the peer, all stale replies should be discarded
+	if err := tester.sync("peer", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	if stale := atomic.LoadInt32(&peer.stale); stale == 0 {
+		t.Errorf("no stale replies delivered")
+	}
+}
+
+// Tests that the state is retrieved by account and storage ranges from ess/67
+// peers, and that the trie node sync heals the range boundaries afterwards.
+func TestRangeStateSync(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a state with enough accounts and storage to span many ranges
+	sdb, _ := state.New(common.Hash{}, state.NewDatabase(tester.peerDb))
+	for i := 0; i < 1000; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		sdb.AddBalance(addr, big.NewInt(int64(i+1)))
+		if i%100 == 0 {
+			sdb.SetCode(addr, []byte{byte(i), 0x01, 0x02})
+			for j := 0; j < 200; j++ {
+				sdb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i*j+1))))
+			}
+		}
+	}
+	root, err := sdb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := sdb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	// Retrieve the state from a few range capable peers
+	hashes, headers, blocks, receipts := tester.makeChain(1, 0, tester.genesis, nil, false)
+	for i := 0; i < 3; i++ {
+		tester.newPeer(fmt.Sprintf("peer #%d", i), 67, hashes, headers, blocks, receipts)
+	}
+	tester.downloader.cancelLock.Lock()
+	tester.downloader.cancelCh = make(chan struct{})
+	tester.downloader.cancelLock.Unlock()
+
+	if err := tester.downloader.syncState(root).Wait(); err != nil {
+		t.Fatalf("failed to sync state: %v", err)
+	}
+	// Make sure the ranges were actually used and the entire state is present
+	var ranges int32
+	for _, p := range tester.downloader.peers.AllPeers() {
+		ranges += atomic.LoadInt32(&p.peer.(*downloadTesterPeer).ranges)
+	}
+	if ranges < rangeAccountTasks {
+		t.Errorf("state range requests mismatch: have %d, want at least %d", ranges, rangeAccountTasks)
+	}
+	synced, err := state.New(root, state.NewDatabase(tester.stateDb))
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	it := state.NewNodeIterator(synced)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		t.Fatalf("synced state incomplete: %v", it.Error)
+	}
+}
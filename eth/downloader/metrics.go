@@ -40,4 +40,7 @@ var (
 
 	stateInMeter   = metrics.NewRegisteredMeter("ess/downloader/states/in", nil)
 	stateDropMeter = metrics.NewRegisteredMeter("ess/downloader/states/drop", nil)
+
+	rangeInMeter   = metrics.NewRegisteredMeter("ess/downloader/ranges/in", nil)
+	rangeDropMeter = metrics.NewRegisteredMeter("ess/downloader/ranges/drop", nil)
 )
@@ -20,6 +20,8 @@
 package downloader
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
@@ -76,18 +78,39 @@ type peerConnection struct {
 }
 
 // LightPeer encapsulates the methods required to synchronise with a remote light peer.
+//
+// All requests carry an ID chosen by the downloader, which the response has to be
+// delivered with. Protocols without request IDs deliver responses with a zero ID.
 type LightPeer interface {
 	Head() (common.Hash, *big.Int)
-	RequestHeadersByHash(common.Hash, int, int, bool) error
-	RequestHeadersByNumber(uint64, int, int, bool) error
+	RequestHeadersByHash(uint64, common.Hash, int, int, bool) error
+	RequestHeadersByNumber(uint64, uint64, int, int, bool) error
 }
 
 // Peer encapsulates the methods required to synchronise with a remote full peer.
 type Peer interface {
 	LightPeer
-	RequestBodies([]common.Hash) error
-	RequestReceipts([]common.Hash) error
-	RequestNodeData([]common.Hash) error
+	RequestBodies(uint64, []common.Hash) error
+	RequestReceipts(uint64, []common.Hash) error
+	RequestNodeData(uint64, []common.Hash) error
+}
+
+// RangePeer encapsulates the methods required to retrieve the state of a remote
+// peer by account and storage ranges.
+type RangePeer interface {
+	RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error
+	RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error
+}
+
+// genReqID generates a new random request ID. IDs are random so responses to
+// requests of an earlier sync cycle can't be mistaken for the current ones.
+func genReqID() uint64 {
+	var rnd [8]byte
+	rand.Read(rnd[:])
+	if id := binary.BigEndian.Uint64(rnd[:]); id != 0 {
+		return id
+	}
+	return 1 // Zero is reserved for deliveries without ID
 }
 
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
@@ -96,19 +119,19 @@ type lightPeerWrapper struct {
 }
 
 func (w *lightPeerWrapper) Head() (common.Hash, *big.Int) { return w.peer.Head() }
-func (w *lightPeerWrapper) RequestHeadersByHash(h common.Hash, amount int, skip int, reverse bool) error {
-	return w.peer.RequestHeadersByHash(h, amount, skip, reverse)
+func (w *lightPeerWrapper) RequestHeadersByHash(id uint64, h common.Hash, amount int, skip int, reverse bool) error {
+	return w.peer.RequestHeadersByHash(id, h, amount, skip, reverse)
 }
-func (w *lightPeerWrapper) RequestHeadersByNumber(i uint64, amount int, skip int, reverse bool) error {
-	return w.peer.RequestHeadersByNumber(i, amount, skip, reverse)
+func (w *lightPeerWrapper) RequestHeadersByNumber(id uint64, i uint64, amount int, skip int, reverse bool) error {
+	return w.peer.RequestHeadersByNumber(id, i, amount, skip, reverse)
 }
-func (w *lightPeerWrapper) RequestBodies([]common.Hash) error {
+func (w *lightPeerWrapper) RequestBodies(uint64, []common.Hash) error {
 	panic("RequestBodies not supported in light client mode sync")
 }
-func (w *lightPeerWrapper) RequestReceipts([]common.Hash) error {
+func (w *lightPeerWrapper) RequestReceipts(uint64, []common.Hash) error {
 	panic("RequestReceipts not supported in light client mode sync")
 }
-func (w *lightPeerWrapper) RequestNodeData([]common.Hash) error {
+func (w *lightPeerWrapper) RequestNodeData(uint64, []common.Hash) error {
 	panic("RequestNodeData not supported in light client mode sync")
 }
 
@@ -144,7 +167,7 @@ func (p *peerConnection) Reset() {
 }
 
 // FetchHeaders sends a header retrieval request to the remote peer.
-func (p *peerConnection) FetchHeaders(from uint64, count int) error {
+func (p *peerConnection) FetchHeaders(request *fetchRequest, count int) error {
 	// Sanity check the protocol version
 	if p.version < 62 {
 		panic(fmt.Sprintf("header fetch [ess/62+] requested on ess/%d", p.version))
@@ -156,7 +179,7 @@ func (p *peerConnection) FetchHeaders(from uint64, count int) error {
 	p.headerStarted = time.Now()
 
 	// Issue the header retrieval request (absolut upwards without gaps)
-	go p.peer.RequestHeadersByNumber(from, count, 0, false)
+	go p.peer.RequestHeadersByNumber(request.ID, request.From, count, 0, false)
 
 	return nil
 }
@@ -178,7 +201,7 @@ func (p *peerConnection) FetchBodies(request *fetchRequest) error {
 	for _, header := range request.Headers {
 		hashes = append(hashes, header.Hash())
 	}
-	go p.peer.RequestBodies(hashes)
+	go p.peer.RequestBodies(request.ID, hashes)
 
 	return nil
 }
@@ -200,13 +223,14 @@ func (p *peerConnection) FetchReceipts(request *fetchRequest) error {
 	for _, header := range request.Headers {
 		hashes = append(hashes, header.Hash())
 	}
-	go p.peer.RequestReceipts(hashes)
+	go p.peer.RequestReceipts(request.ID, hashes)
 
 	return nil
 }
 
-// FetchNodeData sends a node state data retrieval request to the remote peer.
-func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
+// FetchNodeData sends a node state data retrieval request with the given ID to
+// the remote peer.
+func (p *peerConnection) FetchNodeData(id uint64, hashes []common.Hash) error {
 	// Sanity check the protocol version
 	if p.version < 63 {
 		panic(fmt.Sprintf("node data fetch [ess/63+] requested on ess/%d", p.version))
@@ -217,7 +241,43 @@ func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
 	}
 	p.stateStarted = time.Now()
 
-	go p.peer.RequestNodeData(hashes)
+	go p.peer.RequestNodeData(id, hashes)
+
+	return nil
+}
+
+// FetchAccountRange sends an account range retrieval request with the given ID
+// to the remote peer.
+func (p *peerConnection) FetchAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error {
+	// Sanity check the protocol version
+	if p.version < 67 {
+		panic(fmt.Sprintf("account range fetch [ess/67+] requested on ess/%d", p.version))
+	}
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.stateStarted = time.Now()
+
+	go p.peer.(RangePeer).RequestAccountRange(id, root, origin, limit, bytes)
+
+	return nil
+}
+
+// FetchStorageRanges sends a storage ranges retrieval request with the given ID
+// to the remote peer.
+func (p *peerConnection) FetchStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error {
+	// Sanity check the protocol version
+	if p.version < 67 {
+		panic(fmt.Sprintf("storage ranges fetch [ess/67+] requested on ess/%d", p.version))
+	}
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.stateStarted = time.Now()
+
+	go p.peer.(RangePeer).RequestStorageRanges(id, root, accounts, origin, bytes)
 
 	return nil
 }
@@ -477,7 +537,7 @@ func (ps *peerSet) HeaderIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.headerThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 67, idle, throughput)
 }
 
 // BodyIdlePeers retrieves a flat list of all the currently body-idle peers within
@@ -491,7 +551,7 @@ func (ps *peerSet) BodyIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.blockThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 67, idle, throughput)
 }
 
 // ReceiptIdlePeers retrieves a flat list of all the currently receipt-idle peers
@@ -505,7 +565,7 @@ func (ps *peerSet) ReceiptIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.receiptThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 67, idle, throughput)
 }
 
 // NodeDataIdlePeers retrieves a flat list of all the currently node-data-idle
@@ -519,7 +579,25 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.stateThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 67, idle, throughput)
+}
+
+// RangeIdlePeers retrieves a flat list of all the currently node-data-idle peers
+// within the active peer set capable of serving state ranges, ordered by their
+// reputation.
+func (ps *peerSet) RangeIdlePeers() ([]*peerConnection, int) {
+	idle := func(p *peerConnection) bool {
+		if _, ok := p.peer.(RangePeer); !ok {
+			return false
+		}
+		return atomic.LoadInt32(&p.stateIdle) == 0
+	}
+	throughput := func(p *peerConnection) float64 {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+		return p.stateThroughput
+	}
+	return ps.idlePeers(67, 67, idle, throughput)
 }
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
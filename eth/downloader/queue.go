@@ -45,6 +45,7 @@ var (
 
 // fetchRequest is a currently running data retrieval operation.
 type fetchRequest struct {
+	ID      uint64          // ID of the request, to match the response with
 	Peer    *peerConnection // Peer to which the request was sent
 	From    uint64          // [ess/62] Requested chain element index (used for skeleton fills only)
 	Headers []*types.Header // [ess/62] Requested headers, sorted by request order
@@ -443,6 +444,7 @@ func (q *queue) ReserveHeaders(p *peerConnection, count int) *fetchRequest {
 		return nil
 	}
 	request := &fetchRequest{
+		ID:   genReqID(),
 		Peer: p,
 		From: send,
 		Time: time.Now(),
@@ -553,6 +555,7 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 		return nil, progress, nil
 	}
 	request := &fetchRequest{
+		ID:      genReqID(),
 		Peer:    p,
 		Headers: send,
 		Time:    time.Now(),
@@ -680,15 +683,18 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 // If the headers are accepted, the method makes an attempt to deliver the set
 // of ready headers to the processor to keep the pipeline full. However it will
 // not block to prevent stalling other pending deliveries.
-func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh chan []*types.Header) (int, error) {
+func (q *queue) DeliverHeaders(id string, reqID uint64, headers []*types.Header, headerProcCh chan []*types.Header) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	// Short circuit if the data was never requested
+	// Short circuit if the data was never requested, or answers an earlier request
 	request := q.headerPendPool[id]
 	if request == nil {
 		return 0, errNoFetchesPending
 	}
+	if reqID != 0 && reqID != request.ID {
+		return 0, errStaleDelivery
+	}
 	headerReqTimer.UpdateSince(request.Time)
 	delete(q.headerPendPool, id)
 
@@ -764,7 +770,7 @@ func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh
 // DeliverBodies injects a block body retrieval response into the results queue.
 // The method returns the number of blocks bodies accepted from the delivery and
 // also wakes any threads waiting for data delivery.
-func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLists [][]*types.Header) (int, error) {
+func (q *queue) DeliverBodies(id string, reqID uint64, txLists [][]*types.Transaction, uncleLists [][]*types.Header) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -776,13 +782,13 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLi
 		result.Uncles = uncleLists[index]
 		return nil
 	}
-	return q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
+	return q.deliver(id, reqID, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
 }
 
 // DeliverReceipts injects a receipt retrieval response into the results queue.
 // The method returns the number of transaction receipts accepted from the delivery
 // and also wakes any threads waiting for data delivery.
-func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int, error) {
+func (q *queue) DeliverReceipts(id string, reqID uint64, receiptList [][]*types.Receipt) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -793,7 +799,7 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 		result.Receipts = receiptList[index]
 		return nil
 	}
-	return q.deliver(id, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, receiptReqTimer, len(receiptList), reconstruct)
+	return q.deliver(id, reqID, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, receiptReqTimer, len(receiptList), reconstruct)
 }
 
 // deliver injects a data retrieval response into the results queue.
@@ -801,15 +807,18 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 // Note, this method expects the queue lock to be already held for writing. The
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
-func (q *queue) deliver(id string, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
+func (q *queue) deliver(id string, reqID uint64, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
 	pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}, reqTimer metrics.Timer,
 	results int, reconstruct func(header *types.Header, index int, result *fetchResult) error) (int, error) {
 
-	// Short circuit if the data was never requested
+	// Short circuit if the data was never requested, or answers an earlier request
 	request := pendPool[id]
 	if request == nil {
 		return 0, errNoFetchesPending
 	}
+	if reqID != 0 && reqID != request.ID {
+		return 0, errStaleDelivery
+	}
 	reqTimer.UpdateSince(request.Time)
 	delete(pendPool, id)
 
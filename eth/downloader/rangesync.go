@@ -0,0 +1,503 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+	"github.com/orangeAndSuns/go-ethereum/trie"
+)
+
+const (
+	rangeAccountTasks  = 16         // Number of account ranges the state is split into for concurrent retrieval
+	rangeRequestBytes  = 512 * 1024 // Soft size limit of the data requested in a single range request
+	rangeResponseQueue = 64         // Maximum number of range responses buffered for processing
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+var (
+	errRangeUnavailable = errors.New("state range unavailable")
+	errRangeInvalid     = errors.New("invalid state range")
+)
+
+// accountTask is a contiguous section of the account trie to be retrieved by
+// ranges, along with the partial trie rebuilt from the accounts retrieved so far.
+type accountTask struct {
+	next common.Hash // First account hash of the next range to retrieve
+	last common.Hash // Last account hash belonging to this section
+	trie *trie.Trie  // Partial account trie assembled from the delivered ranges
+	req  *rangeReq   // Currently pending request for this section
+	done bool        // Flag whether the section was fully retrieved
+}
+
+// storageTask is the storage trie of a single account to be retrieved by ranges.
+type storageTask struct {
+	account common.Hash // Hash of the account owning the storage trie
+	root    common.Hash // Root hash of the storage trie
+	next    common.Hash // First slot hash of the next range to retrieve
+	trie    *trie.Trie  // Partial storage trie assembled from the delivered ranges
+}
+
+// rangeReq is a pending account or storage range request.
+type rangeReq struct {
+	id      uint64          // ID of the request, to match the response with
+	peer    *peerConnection // Peer that we're requesting from
+	account *accountTask    // Account section requested (nil for storage requests)
+	storage []*storageTask  // Storage tries requested (nil for account requests)
+	timer   *time.Timer     // Timer to fire when the RTT timeout expires
+}
+
+// rangeSync is the state of the range retrieval phase of a state sync.
+type rangeSync struct {
+	triedb   *trie.Database       // Trie database to assemble the partial tries in
+	accounts []*accountTask       // Account trie sections to retrieve
+	storage  []*storageTask       // Storage tries waiting to be retrieved
+	active   map[string]*rangeReq // Currently in-flight requests
+	lacking  map[string]struct{}  // Peers that don't have the state being synced
+	timeout  chan *rangeReq       // Timed out active requests
+	quit     chan struct{}        // Channel to stop the request timers on exit
+	written  int                  // Number of state entries retrieved since the last stats update
+}
+
+// newRangeSync splits the account trie into evenly sized sections, each to be
+// retrieved independently.
+func newRangeSync(db ethdb.Database) *rangeSync {
+	r := &rangeSync{
+		triedb:  trie.NewDatabase(db),
+		active:  make(map[string]*rangeReq),
+		lacking: make(map[string]struct{}),
+		timeout: make(chan *rangeReq),
+		quit:    make(chan struct{}),
+	}
+	var (
+		step = new(big.Int).Div(new(big.Int).Lsh(common.Big1, 256), big.NewInt(rangeAccountTasks))
+		next = new(big.Int)
+	)
+	for i := 0; i < rangeAccountTasks; i++ {
+		last := new(big.Int).Sub(new(big.Int).Add(next, step), common.Big1)
+		tr, _ := trie.New(common.Hash{}, r.triedb)
+		r.accounts = append(r.accounts, &accountTask{
+			next: common.BigToHash(next),
+			last: common.BigToHash(last),
+			trie: tr,
+		})
+		next = new(big.Int).Add(last, common.Big1)
+	}
+	return r
+}
+
+// finished returns whether all account sections and storage tries have been
+// retrieved.
+func (r *rangeSync) finished() bool {
+	if len(r.storage) > 0 || len(r.active) > 0 {
+		return false
+	}
+	for _, task := range r.accounts {
+		if !task.done {
+			return false
+		}
+	}
+	return true
+}
+
+// syncRanges retrieves the bulk of the state by account and storage ranges from
+// ess/67 peers, verifying each range against the state root with the Merkle
+// proofs of its boundaries. The partial tries assembled this way are missing
+// the nodes along the range boundaries, which are subsequently healed by the
+// trie node sync.
+//
+// If no connected peer is able to serve the state ranges, the phase is aborted
+// and the remainder of the state is left to the trie node sync.
+func (s *stateSync) syncRanges() error {
+	// Short circuit if there's nothing to retrieve
+	if s.root == emptyRoot {
+		return nil
+	}
+	if ok, _ := s.d.stateDB.Has(s.root[:]); ok {
+		return nil
+	}
+	r := newRangeSync(s.d.stateDB)
+	defer func() {
+		// Cancel active request timers on exit. Also set peers to idle so they're
+		// available for the trie node sync.
+		close(r.quit)
+		for _, req := range r.active {
+			req.timer.Stop()
+			req.peer.SetNodeDataIdle(0)
+		}
+	}()
+	// Listen for peer arrival and departure events to assign and cancel tasks
+	newPeer := make(chan *peerConnection, 1024)
+	newSub := s.d.peers.SubscribeNewPeers(newPeer)
+	defer newSub.Unsubscribe()
+
+	peerDrop := make(chan *peerConnection, 1024)
+	dropSub := s.d.peers.SubscribePeerDrops(peerDrop)
+	defer dropSub.Unsubscribe()
+
+	for !r.finished() {
+		s.assignRanges(r)
+		if len(r.active) == 0 {
+			// No peer is able to serve the remaining ranges, hand them over to the
+			// trie node sync
+			log.Debug("No peers to sync state ranges from, falling back to trie sync", "storage", len(r.storage))
+			for _, task := range r.storage {
+				s.sched.AddSubTrie(task.root, 64, s.root, nil)
+			}
+			return nil
+		}
+		// Tasks assigned, wait for something to happen
+		select {
+		case <-newPeer:
+			// New peer arrived, try to assign it download tasks
+
+		case <-s.cancel:
+			return errCancelStateFetch
+
+		case <-s.d.cancelCh:
+			return errCancelStateFetch
+
+		case pack := <-s.ranges:
+			// Discard any data not requested (or previously timed out)
+			req := r.active[pack.PeerId()]
+			if req == nil || !answers(pack, req.id) {
+				log.Debug("Unrequested state range", "peer", pack.PeerId(), "reqid", pack.ReqId(), "len", pack.Items())
+				continue
+			}
+			req.timer.Stop()
+			delete(r.active, req.peer.id)
+
+			// Process the retrieved range, dropping the peer if it's invalid
+			start := time.Now()
+			switch err := s.processRange(r, req, pack); err {
+			case nil:
+				req.peer.SetNodeDataIdle(pack.Items())
+				s.updateStats(r.written, 0, 0, time.Since(start))
+				r.written = 0
+
+			case errRangeUnavailable:
+				log.Debug("Peer lacks the state being synced", "peer", req.peer.id, "root", s.root)
+				r.lacking[req.peer.id] = struct{}{}
+				r.revert(req)
+				req.peer.SetNodeDataIdle(0)
+
+			case errRangeInvalid:
+				log.Warn("Invalid state range, dropping peer", "peer", req.peer.id)
+				r.revert(req)
+				s.d.dropPeer(req.peer.id)
+
+			default:
+				log.Warn("State range write error", "err", err)
+				return err
+			}
+
+		case p := <-peerDrop:
+			// Skip if no request is currently pending
+			req := r.active[p.id]
+			if req == nil {
+				continue
+			}
+			req.timer.Stop()
+			delete(r.active, p.id)
+			r.revert(req)
+
+		case req := <-r.timeout:
+			// If the peer is already requesting something else, ignore the stale timeout
+			if r.active[req.peer.id] != req {
+				continue
+			}
+			delete(r.active, req.peer.id)
+			r.revert(req)
+			req.peer.SetNodeDataIdle(0)
+		}
+	}
+	return nil
+}
+
+// assignRanges attempts to assign a range request to all idle peers capable of
+// serving them, preferring queued storage tries over new account ranges to keep
+// the backlog small.
+func (s *stateSync) assignRanges(r *rangeSync) {
+	peers, _ := s.d.peers.RangeIdlePeers()
+	for _, p := range peers {
+		if _, ok := r.lacking[p.id]; ok {
+			continue
+		}
+		if _, ok := r.active[p.id]; ok {
+			continue
+		}
+		req := &rangeReq{id: genReqID(), peer: p}
+		if len(r.storage) > 0 {
+			// Request either a single storage trie being continued, or a batch of
+			// fresh ones to be retrieved from their origin
+			n := 1
+			if r.storage[0].next == (common.Hash{}) {
+				for n < len(r.storage) && n < MaxStorageRangeFetch && r.storage[n].next == (common.Hash{}) {
+					n++
+				}
+			}
+			req.storage = append([]*storageTask{}, r.storage[:n]...)
+			r.storage = r.storage[n:]
+		} else {
+			for _, task := range r.accounts {
+				if !task.done && task.req == nil {
+					req.account = task
+					break
+				}
+			}
+			if req.account == nil {
+				return
+			}
+			req.account.req = req
+		}
+		// Send the network request and track it
+		var err error
+		if req.account != nil {
+			p.log.Trace("Requesting account range", "origin", req.account.next, "limit", req.account.last)
+			err = p.FetchAccountRange(req.id, s.root, req.account.next, req.account.last, rangeRequestBytes)
+		} else {
+			accounts := make([]common.Hash, len(req.storage))
+			for i, task := range req.storage {
+				accounts[i] = task.account
+			}
+			p.log.Trace("Requesting storage ranges", "accounts", len(accounts), "origin", req.storage[0].next)
+			err = p.FetchStorageRanges(req.id, s.root, accounts, req.storage[0].next, rangeRequestBytes)
+		}
+		if err != nil {
+			r.revert(req)
+			continue
+		}
+		req.timer = time.AfterFunc(s.d.requestTTL(), func() {
+			select {
+			case r.timeout <- req:
+			case <-r.quit:
+			}
+		})
+		r.active[p.id] = req
+	}
+}
+
+// revert returns the tasks of a failed request into the queue.
+func (r *rangeSync) revert(req *rangeReq) {
+	if req.account != nil {
+		req.account.req = nil
+	}
+	r.storage = append(req.storage, r.storage...)
+}
+
+// processRange verifies a delivered account or storage range response and
+// injects its contents into the partial tries being assembled.
+func (s *stateSync) processRange(r *rangeSync, req *rangeReq, pack dataPack) error {
+	switch pack := pack.(type) {
+	case *accountRangePack:
+		if req.account == nil {
+			return errRangeInvalid
+		}
+		return s.processAccounts(r, req.account, pack)
+
+	case *storageRangesPack:
+		if req.storage == nil {
+			return errRangeInvalid
+		}
+		return s.processStorage(r, req, pack)
+	}
+	return errRangeInvalid
+}
+
+// processAccounts verifies an account range delivered for the given section of
+// the account trie, inserts the accounts into the partial trie and schedules the
+// retrieval of their storage and code.
+func (s *stateSync) processAccounts(r *rangeSync, task *accountTask, pack *accountRangePack) error {
+	task.req = nil
+
+	// An empty response without proofs signals the peer doesn't have the state
+	if len(pack.accounts) == 0 && len(pack.proof) == 0 {
+		return errRangeUnavailable
+	}
+	if len(pack.hashes) != len(pack.accounts) {
+		return errRangeInvalid
+	}
+	keys := make([][]byte, len(pack.hashes))
+	for i, hash := range pack.hashes {
+		keys[i] = common.CopyBytes(hash[:])
+	}
+	last := task.next
+	if len(pack.hashes) > 0 {
+		last = pack.hashes[len(pack.hashes)-1]
+	}
+	more, err := trie.VerifyRangeProof(s.root, task.next[:], last[:], keys, pack.accounts, rangeProofDb(pack.proof))
+	if err != nil {
+		log.Debug("Account range verification failed", "origin", task.next, "err", err)
+		return errRangeInvalid
+	}
+	if more && len(keys) == 0 {
+		return errRangeInvalid
+	}
+	// Range verified, inject all the accounts belonging to this section
+	for i, hash := range pack.hashes {
+		if bytes.Compare(hash[:], task.last[:]) > 0 {
+			more = false
+			break
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(pack.accounts[i], &account); err != nil {
+			return errRangeInvalid
+		}
+		if err := task.trie.TryUpdate(hash[:], pack.accounts[i]); err != nil {
+			return err
+		}
+		r.written++
+
+		if account.Root != emptyRoot {
+			if ok, _ := s.d.stateDB.Has(account.Root[:]); !ok {
+				tr, _ := trie.New(common.Hash{}, r.triedb)
+				r.storage = append(r.storage, &storageTask{account: hash, root: account.Root, trie: tr})
+			}
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			s.sched.AddRawEntry(codeHash, 64, s.root)
+		}
+	}
+	if err := commitRange(r.triedb, task.trie); err != nil {
+		return err
+	}
+	// Move the section forward, or mark it done if everything was retrieved
+	if !more || last == task.last {
+		task.done = true
+		return nil
+	}
+	task.next = incHash(last)
+	return nil
+}
+
+// processStorage verifies a batch of storage ranges delivered for the requested
+// storage tries and inserts the slots into the partial tries. Storage tries not
+// retrieved entirely are put back into the queue.
+func (s *stateSync) processStorage(r *rangeSync, req *rangeReq, pack *storageRangesPack) error {
+	// An empty response without proofs signals the peer doesn't have the state
+	if len(pack.slots) == 0 && len(pack.proof) == 0 {
+		return errRangeUnavailable
+	}
+	if len(pack.hashes) != len(pack.slots) || len(pack.slots) > len(req.storage) {
+		return errRangeInvalid
+	}
+	// Verify all the ranges before touching any of the tries
+	var retry []*storageTask
+	for i, slots := range pack.slots {
+		task := req.storage[i]
+		if len(pack.hashes[i]) != len(slots) {
+			return errRangeInvalid
+		}
+		// Only the last range might be partial and carry a proof
+		var proof trie.DatabaseReader
+		if i == len(pack.slots)-1 {
+			proof = rangeProofDb(pack.proof)
+		}
+		keys := make([][]byte, len(slots))
+		for j, hash := range pack.hashes[i] {
+			keys[j] = common.CopyBytes(hash[:])
+		}
+		last := task.next
+		if len(keys) > 0 {
+			last = pack.hashes[i][len(keys)-1]
+		}
+		more, err := trie.VerifyRangeProof(task.root, task.next[:], last[:], keys, slots, proof)
+		if err != nil {
+			log.Debug("Storage range verification failed", "account", task.account, "origin", task.next, "err", err)
+			return errRangeInvalid
+		}
+		if more {
+			if len(keys) == 0 {
+				return errRangeInvalid
+			}
+			retry = append(retry, task)
+		}
+	}
+	// Ranges verified, inject all the slots and commit the tries
+	for i, slots := range pack.slots {
+		task := req.storage[i]
+		for j, hash := range pack.hashes[i] {
+			if err := task.trie.TryUpdate(hash[:], slots[j]); err != nil {
+				return err
+			}
+			r.written++
+		}
+		if err := commitRange(r.triedb, task.trie); err != nil {
+			return err
+		}
+		if len(slots) > 0 {
+			task.next = incHash(pack.hashes[i][len(slots)-1])
+		}
+	}
+	// Requeue any partially retrieved tries and those that weren't served
+	r.storage = append(append(retry, req.storage[len(pack.slots):]...), r.storage...)
+	return nil
+}
+
+// commitRange flushes a partial trie assembled from state ranges into the
+// database.
+func commitRange(triedb *trie.Database, tr *trie.Trie) error {
+	root, err := tr.Commit(nil)
+	if err != nil {
+		return err
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		return fmt.Errorf("DB write error: %v", err)
+	}
+	return nil
+}
+
+// rangeProofDb collects the Merkle proof nodes of a state range into a database
+// for verification. A missing proof is returned as nil.
+func rangeProofDb(proof [][]byte) trie.DatabaseReader {
+	if len(proof) == 0 {
+		return nil
+	}
+	db := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// incHash returns the hash directly following the given one.
+func incHash(h common.Hash) common.Hash {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			break
+		}
+	}
+	return h
+}
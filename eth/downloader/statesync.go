@@ -34,6 +34,7 @@ import (
 // stateReq represents a batch of state fetch requests grouped together into
 // a single data retrieval network packet.
 type stateReq struct {
+	id       uint64                     // ID of the request, to match the response with
 	items    []common.Hash              // Hashes of the state items to download
 	tasks    map[common.Hash]*stateTask // Download tasks to track previous attempts
 	timeout  time.Duration              // Maximum round trip time for this to complete
@@ -80,6 +81,8 @@ func (d *Downloader) stateFetcher() {
 			}
 		case <-d.stateCh:
 			// Ignore state responses while no sync is running.
+		case <-d.rangeCh:
+			// Ignore state range responses while no sync is running.
 		case <-d.quitCh:
 			return
 		}
@@ -141,8 +144,8 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 		case pack := <-d.stateCh:
 			// Discard any data not requested (or previously timed out)
 			req := active[pack.PeerId()]
-			if req == nil {
-				log.Debug("Unrequested node data", "peer", pack.PeerId(), "len", pack.Items())
+			if req == nil || !answers(pack, req.id) {
+				log.Debug("Unrequested node data", "peer", pack.PeerId(), "reqid", pack.ReqId(), "len", pack.Items())
 				continue
 			}
 			// Finalize the request and queue up for processing
@@ -152,6 +155,14 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			finished = append(finished, req)
 			delete(active, pack.PeerId())
 
+		// Forward incoming state range packs to the range sync:
+		case pack := <-d.rangeCh:
+			select {
+			case s.ranges <- pack:
+			default:
+				log.Debug("Dropped state range", "peer", pack.PeerId(), "len", pack.Items())
+			}
+
 			// Handle dropped peer connections:
 		case p := <-peerDrop:
 			// Skip if no request is currently pending
@@ -212,7 +223,8 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 type stateSync struct {
-	d *Downloader // Downloader instance to access and manage current peerset
+	d    *Downloader // Downloader instance to access and manage current peerset
+	root common.Hash // State root currently being synced
 
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
@@ -222,6 +234,7 @@ type stateSync struct {
 	bytesUncommitted int
 
 	deliver    chan *stateReq // Delivery channel multiplexing peer responses
+	ranges     chan dataPack  // Delivery channel of the state range responses
 	cancel     chan struct{}  // Channel to signal a termination request
 	cancelOnce sync.Once      // Ensures cancel only ever gets called once
 	done       chan struct{}  // Channel to signal termination completion
@@ -239,10 +252,12 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
+		root:    root,
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
+		ranges:  make(chan dataPack, rangeResponseQueue),
 		cancel:  make(chan struct{}),
 		done:    make(chan struct{}),
 	}
@@ -250,9 +265,12 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 
 // run starts the task assignment and response processing loop, blocking until
 // it finishes, and finally notifying any goroutines waiting for the loop to
-// finish.
+// finish. The bulk of the state is retrieved by ranges first if possible, the
+// trie node sync only healing what's left.
 func (s *stateSync) run() {
-	s.err = s.loop()
+	if s.err = s.syncRanges(); s.err == nil {
+		s.err = s.loop()
+	}
 	close(s.done)
 }
 
@@ -349,7 +367,7 @@ func (s *stateSync) assignTasks() {
 	for _, p := range peers {
 		// Assign a batch of fetches proportional to the estimated latency/bandwidth
 		cap := p.NodeDataCapacity(s.d.requestRTT())
-		req := &stateReq{peer: p, timeout: s.d.requestTTL()}
+		req := &stateReq{id: genReqID(), peer: p, timeout: s.d.requestTTL()}
 		s.fillTasks(cap, req)
 
 		// If the peer was assigned tasks to fetch, send the network request
@@ -357,7 +375,7 @@ func (s *stateSync) assignTasks() {
 			req.peer.log.Trace("Requesting new batch of data", "type", "state", "count", len(req.items))
 			select {
 			case s.d.trackStateReq <- req:
-				req.peer.FetchNodeData(req.items)
+				req.peer.FetchNodeData(req.id, req.items)
 			case <-s.cancel:
 			case <-s.d.cancelCh:
 			}
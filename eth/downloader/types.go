@@ -19,6 +19,7 @@ package downloader
 import (
 	"fmt"
 
+	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 )
 
@@ -28,28 +29,40 @@ type peerDropFn func(id string)
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
 	PeerId() string
+	ReqId() uint64
 	Items() int
 	Stats() string
 }
 
+// answers reports whether a data pack is the response to the request with the
+// given ID. Packs without an ID come from protocol versions predating request
+// IDs, they are matched to the pending request of their peer.
+func answers(pack dataPack, id uint64) bool {
+	return pack.ReqId() == 0 || pack.ReqId() == id
+}
+
 // headerPack is a batch of block headers returned by a peer.
 type headerPack struct {
 	peerID  string
+	reqID   uint64
 	headers []*types.Header
 }
 
 func (p *headerPack) PeerId() string { return p.peerID }
+func (p *headerPack) ReqId() uint64  { return p.reqID }
 func (p *headerPack) Items() int     { return len(p.headers) }
 func (p *headerPack) Stats() string  { return fmt.Sprintf("%d", len(p.headers)) }
 
 // bodyPack is a batch of block bodies returned by a peer.
 type bodyPack struct {
 	peerID       string
+	reqID        uint64
 	transactions [][]*types.Transaction
 	uncles       [][]*types.Header
 }
 
 func (p *bodyPack) PeerId() string { return p.peerID }
+func (p *bodyPack) ReqId() uint64  { return p.reqID }
 func (p *bodyPack) Items() int {
 	if len(p.transactions) <= len(p.uncles) {
 		return len(p.transactions)
@@ -61,19 +74,53 @@ func (p *bodyPack) Stats() string { return fmt.Sprintf("%d:%d", len(p.transactio
 // receiptPack is a batch of receipts returned by a peer.
 type receiptPack struct {
 	peerID   string
+	reqID    uint64
 	receipts [][]*types.Receipt
 }
 
 func (p *receiptPack) PeerId() string { return p.peerID }
+func (p *receiptPack) ReqId() uint64  { return p.reqID }
 func (p *receiptPack) Items() int     { return len(p.receipts) }
 func (p *receiptPack) Stats() string  { return fmt.Sprintf("%d", len(p.receipts)) }
 
 // statePack is a batch of states returned by a peer.
 type statePack struct {
 	peerID string
+	reqID  uint64
 	states [][]byte
 }
 
 func (p *statePack) PeerId() string { return p.peerID }
+func (p *statePack) ReqId() uint64  { return p.reqID }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// accountRangePack is a range of accounts returned by a peer, along with the
+// Merkle proofs of its boundaries.
+type accountRangePack struct {
+	peerID   string
+	reqID    uint64
+	hashes   []common.Hash
+	accounts [][]byte
+	proof    [][]byte
+}
+
+func (p *accountRangePack) PeerId() string { return p.peerID }
+func (p *accountRangePack) ReqId() uint64  { return p.reqID }
+func (p *accountRangePack) Items() int     { return len(p.accounts) }
+func (p *accountRangePack) Stats() string  { return fmt.Sprintf("%d:%d", len(p.accounts), len(p.proof)) }
+
+// storageRangesPack is a batch of storage ranges returned by a peer, along with
+// the Merkle proofs of the boundaries of the last range.
+type storageRangesPack struct {
+	peerID string
+	reqID  uint64
+	hashes [][]common.Hash
+	slots  [][][]byte
+	proof  [][]byte
+}
+
+func (p *storageRangesPack) PeerId() string { return p.peerID }
+func (p *storageRangesPack) ReqId() uint64  { return p.reqID }
+func (p *storageRangesPack) Items() int     { return len(p.slots) }
+func (p *storageRangesPack) Stats() string  { return fmt.Sprintf("%d:%d", len(p.slots), len(p.proof)) }
@@ -81,6 +81,7 @@ type fetcherTester struct {
 	hashes []common.Hash                // Hash chain belonging to the tester
 	blocks map[common.Hash]*types.Block // Blocks belonging to the tester
 	drops  map[string]bool              // Map of peers dropped by the fetcher
+	credit map[string]int               // Number of imported blocks credited to peers
 
 	lock sync.RWMutex
 }
@@ -91,8 +92,9 @@ func newTester() *fetcherTester {
 		hashes: []common.Hash{genesis.Hash()},
 		blocks: map[common.Hash]*types.Block{genesis.Hash(): genesis},
 		drops:  make(map[string]bool),
+		credit: make(map[string]int),
 	}
-	tester.fetcher = New(tester.getBlock, tester.verifyHeader, tester.broadcastBlock, tester.chainHeight, tester.insertChain, tester.dropPeer)
+	tester.fetcher = New(tester.getBlock, tester.verifyHeader, tester.broadcastBlock, tester.chainHeight, tester.insertChain, tester.dropPeer, tester.creditPeer)
 	tester.fetcher.Start()
 
 	return tester
@@ -153,6 +155,14 @@ func (f *fetcherTester) dropPeer(peer string) {
 	f.drops[peer] = true
 }
 
+// creditPeer is an emulator for the peer crediting, counting the credits.
+func (f *fetcherTester) creditPeer(peer string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.credit[peer]++
+}
+
 // makeHeaderFetcher retrieves a block header fetcher associated with a simulated peer.
 func (f *fetcherTester) makeHeaderFetcher(peer string, blocks map[common.Hash]*types.Block, drift time.Duration) headerRequesterFn {
 	closure := make(map[common.Hash]*types.Block)
@@ -295,6 +305,14 @@ func testSequentialAnnouncements(t *testing.T, protocol int) {
 		verifyImportEvent(t, imported, true)
 	}
 	verifyImportDone(t, imported)
+
+	// The announcing peer should be credited for every imported block
+	tester.lock.RLock()
+	defer tester.lock.RUnlock()
+
+	if credit := tester.credit["valid"]; credit != targetBlocks {
+		t.Errorf("peer credit mismatch: have %d, want %d", credit, targetBlocks)
+	}
 }
 
 // Tests that if blocks are announced by multiple peers (or even the same buggy
@@ -40,4 +40,11 @@ var (
 	headerFilterOutMeter = metrics.NewRegisteredMeter("ess/fetcher/filter/headers/out", nil)
 	bodyFilterInMeter    = metrics.NewRegisteredMeter("ess/fetcher/filter/bodies/in", nil)
 	bodyFilterOutMeter   = metrics.NewRegisteredMeter("ess/fetcher/filter/bodies/out", nil)
+
+	txAnnounceInMeter     = metrics.NewRegisteredMeter("ess/fetcher/tx/announces/in", nil)
+	txAnnounceDOSMeter    = metrics.NewRegisteredMeter("ess/fetcher/tx/announces/dos", nil)
+	txBroadcastInMeter    = metrics.NewRegisteredMeter("ess/fetcher/tx/broadcasts/in", nil)
+	txRequestOutMeter     = metrics.NewRegisteredMeter("ess/fetcher/tx/requests/out", nil)
+	txRequestTimeoutMeter = metrics.NewRegisteredMeter("ess/fetcher/tx/requests/timeout", nil)
+	txReplyInMeter        = metrics.NewRegisteredMeter("ess/fetcher/tx/replies/in", nil)
 )
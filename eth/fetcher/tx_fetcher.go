@@ -0,0 +1,297 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/log"
+)
+
+const (
+	// MaxTransactionFetch is the maximum number of transactions that can be
+	// requested from a peer in a single retrieval request.
+	MaxTransactionFetch = 256
+
+	txArriveTimeout = 500 * time.Millisecond // Time allowance for an announced transaction to be broadcast before being requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txFetchTick     = 100 * time.Millisecond // Interval of checking for fetchable announcements and timed out requests
+	txAnnounceLimit = 4096                   // Maximum number of unique transactions a peer may have announced
+)
+
+// txRequest represents an in-flight transaction retrieval request to a peer.
+type txRequest struct {
+	hashes []common.Hash // Transactions having been requested
+	time   time.Time     // Timestamp of the request
+}
+
+// TxFetcher is responsible for retrieving new transactions based on hash
+// announcements.
+//
+// Announced transactions are given some time to arrive via direct broadcast
+// first. If they don't, they are requested from one of the announcing peers.
+// Each peer has at most one request in flight, and each transaction is fetched
+// from at most one peer at a time. If a peer fails to deliver a transaction in
+// time, it is considered not to have it and another announcer is tried.
+type TxFetcher struct {
+	lock sync.Mutex
+
+	announces map[string]map[common.Hash]struct{} // Transactions announced by each peer
+	announced map[common.Hash]map[string]struct{} // Peers having announced each transaction
+	firstSeen map[common.Hash]time.Time           // Time of the first announcement of each transaction
+	fetching  map[common.Hash]string              // Peer each transaction is being fetched from
+	requests  map[string]*txRequest               // In-flight request of each peer
+
+	// Callbacks
+	hasTx    func(common.Hash) bool             // Checks whether a transaction is already known locally
+	addTxs   func([]*types.Transaction) []error // Adds a batch of transactions to the local pool
+	fetchTxs func(string, []common.Hash) error  // Requests a batch of transactions from a peer
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error) *TxFetcher {
+	return &TxFetcher{
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		firstSeen: make(map[common.Hash]time.Time),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start boots up the background loop scheduling retrievals and expiring
+// timed out requests.
+func (f *TxFetcher) Start() {
+	f.wg.Add(1)
+	go f.loop()
+}
+
+// Stop terminates the background loop of the fetcher.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+	f.wg.Wait()
+}
+
+// Notify announces the availability of a batch of transactions at a peer.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) {
+	txAnnounceInMeter.Mark(int64(len(hashes)))
+	f.notify(peer, hashes, time.Now())
+}
+
+// Enqueue imports a batch of transactions received from a peer into the pool.
+// If direct is set, the transactions are the response to a retrieval request,
+// otherwise they were broadcast.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) {
+	if direct {
+		txReplyInMeter.Mark(int64(len(txs)))
+	} else {
+		txBroadcastInMeter.Mark(int64(len(txs)))
+	}
+	f.addTxs(txs)
+	f.dispatch(f.enqueue(peer, txs, direct, time.Now()))
+}
+
+// Drop removes all announcements and requests of a disconnected peer.
+func (f *TxFetcher) Drop(peer string) {
+	f.dispatch(f.drop(peer, time.Now()))
+}
+
+func (f *TxFetcher) loop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(txFetchTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.dispatch(f.tick(time.Now()))
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// dispatch sends out the scheduled retrieval requests.
+func (f *TxFetcher) dispatch(requests map[string][]common.Hash) {
+	for peer, hashes := range requests {
+		txRequestOutMeter.Mark(int64(len(hashes)))
+		peer, hashes := peer, hashes
+		go func() {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "count", len(hashes), "err", err)
+			}
+		}()
+	}
+}
+
+// notify records the announcement of some transactions.
+func (f *TxFetcher) notify(peer string, hashes []common.Hash, now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	announces := f.announces[peer]
+	if announces == nil {
+		announces = make(map[common.Hash]struct{})
+		f.announces[peer] = announces
+	}
+	for _, hash := range hashes {
+		if _, ok := announces[hash]; ok || f.hasTx(hash) {
+			continue
+		}
+		if len(announces) >= txAnnounceLimit {
+			log.Debug("Peer exceeded outstanding transaction announces", "peer", peer, "limit", txAnnounceLimit)
+			txAnnounceDOSMeter.Mark(1)
+			break
+		}
+		announces[hash] = struct{}{}
+		if f.announced[hash] == nil {
+			f.announced[hash] = make(map[string]struct{})
+			f.firstSeen[hash] = now
+		}
+		f.announced[hash][peer] = struct{}{}
+	}
+}
+
+// enqueue drops all state of delivered transactions. If the transactions were
+// requested, the ones not delivered are assumed to be unavailable at the peer.
+func (f *TxFetcher) enqueue(peer string, txs []*types.Transaction, direct bool, now time.Time) map[string][]common.Hash {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delivered := make(map[common.Hash]struct{}, len(txs))
+	for _, tx := range txs {
+		hash := tx.Hash()
+		delivered[hash] = struct{}{}
+		f.forget(hash)
+	}
+	if req := f.requests[peer]; direct && req != nil {
+		for _, hash := range req.hashes {
+			if _, ok := delivered[hash]; !ok {
+				f.unannounce(peer, hash)
+			}
+		}
+		delete(f.requests, peer)
+	}
+	return f.schedule(now)
+}
+
+// drop removes all state related to a peer.
+func (f *TxFetcher) drop(peer string, now time.Time) map[string][]common.Hash {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for hash := range f.announces[peer] {
+		f.unannounce(peer, hash)
+	}
+	if req := f.requests[peer]; req != nil {
+		for _, hash := range req.hashes {
+			if f.fetching[hash] == peer {
+				delete(f.fetching, hash)
+			}
+		}
+		delete(f.requests, peer)
+	}
+	delete(f.announces, peer)
+	return f.schedule(now)
+}
+
+// tick expires timed out requests and schedules new ones.
+func (f *TxFetcher) tick(now time.Time) map[string][]common.Hash {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for peer, req := range f.requests {
+		if now.Sub(req.time) <= txFetchTimeout {
+			continue
+		}
+		log.Debug("Transaction request timed out", "peer", peer, "count", len(req.hashes))
+		txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+		for _, hash := range req.hashes {
+			f.unannounce(peer, hash)
+		}
+		delete(f.requests, peer)
+	}
+	return f.schedule(now)
+}
+
+// schedule assigns fetchable transactions to idle peers having announced them.
+// Transactions are fetchable once their arrival timeout elapsed and they are not
+// being fetched already.
+func (f *TxFetcher) schedule(now time.Time) map[string][]common.Hash {
+	requests := make(map[string][]common.Hash)
+	for peer, announces := range f.announces {
+		if f.requests[peer] != nil {
+			continue
+		}
+		var hashes []common.Hash
+		for hash := range announces {
+			if _, ok := f.fetching[hash]; ok || now.Sub(f.firstSeen[hash]) < txArriveTimeout {
+				continue
+			}
+			hashes = append(hashes, hash)
+			if len(hashes) == MaxTransactionFetch {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		for _, hash := range hashes {
+			f.fetching[hash] = peer
+		}
+		f.requests[peer] = &txRequest{hashes: hashes, time: now}
+		requests[peer] = hashes
+	}
+	return requests
+}
+
+// unannounce removes the announcement of a transaction by a peer, forgetting
+// the transaction altogether if no other peer announced it.
+func (f *TxFetcher) unannounce(peer string, hash common.Hash) {
+	delete(f.announces[peer], hash)
+	if f.fetching[hash] == peer {
+		delete(f.fetching, hash)
+	}
+	if peers := f.announced[hash]; peers != nil {
+		delete(peers, peer)
+		if len(peers) == 0 {
+			f.forget(hash)
+		}
+	}
+}
+
+// forget removes all state related to a transaction.
+func (f *TxFetcher) forget(hash common.Hash) {
+	for peer := range f.announced[hash] {
+		delete(f.announces[peer], hash)
+	}
+	delete(f.announced, hash)
+	delete(f.firstSeen, hash)
+	delete(f.fetching, hash)
+}
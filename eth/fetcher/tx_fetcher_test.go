@@ -0,0 +1,142 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+)
+
+// makeTestTxs creates a batch of distinct transactions for the fetcher tests.
+func makeTestTxs(n int) ([]*types.Transaction, []common.Hash) {
+	txs := make([]*types.Transaction, n)
+	hashes := make([]common.Hash, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+		hashes[i] = txs[i].Hash()
+	}
+	return txs, hashes
+}
+
+// newTestTxFetcher creates a tx fetcher with no-op callbacks, treating the given
+// hashes as known locally.
+func newTestTxFetcher(known ...common.Hash) *TxFetcher {
+	return NewTxFetcher(
+		func(hash common.Hash) bool {
+			for _, k := range known {
+				if k == hash {
+					return true
+				}
+			}
+			return false
+		},
+		func(txs []*types.Transaction) []error { return make([]error, len(txs)) },
+		func(string, []common.Hash) error { return nil },
+	)
+}
+
+// checkTxRequests verifies the scheduled retrievals, ignoring hash ordering.
+func checkTxRequests(t *testing.T, have, want map[string][]common.Hash) {
+	t.Helper()
+	for _, m := range []map[string][]common.Hash{have, want} {
+		for _, hashes := range m {
+			sort.Slice(hashes, func(i, j int) bool { return hashes[i].Big().Cmp(hashes[j].Big()) < 0 })
+		}
+	}
+	if len(have) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("scheduled requests mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that announced transactions are only requested after the arrival
+// timeout, and that known or broadcast transactions are not requested at all.
+func TestTxFetcherWaitAndFetch(t *testing.T) {
+	txs, hashes := makeTestTxs(4)
+	f := newTestTxFetcher(hashes[3])
+	now := time.Now()
+
+	f.notify("A", hashes, now)
+	checkTxRequests(t, f.tick(now.Add(txArriveTimeout/2)), nil)
+
+	// A direct broadcast arrives in the meantime.
+	checkTxRequests(t, f.enqueue("B", txs[:1], false, now.Add(txArriveTimeout/2)), nil)
+	checkTxRequests(t, f.tick(now.Add(txArriveTimeout)), map[string][]common.Hash{"A": hashes[1:3]})
+
+	// No new request is sent while one is in flight.
+	f.notify("A", []common.Hash{{0x01}}, now)
+	checkTxRequests(t, f.tick(now.Add(txArriveTimeout)), nil)
+}
+
+// Tests that transactions are fetched from another announcer if a request times
+// out or if the peer doesn't deliver them.
+func TestTxFetcherRetry(t *testing.T) {
+	txs, hashes := makeTestTxs(2)
+	f := newTestTxFetcher()
+	now := time.Now()
+
+	f.notify("A", hashes, now)
+	checkTxRequests(t, f.tick(now.Add(txArriveTimeout)), map[string][]common.Hash{"A": hashes})
+	f.notify("B", hashes, now.Add(txArriveTimeout))
+
+	// A delivers only the first transaction, the second one is retried at B.
+	checkTxRequests(t, f.enqueue("A", txs[:1], true, now.Add(txArriveTimeout)), map[string][]common.Hash{"B": hashes[1:]})
+
+	// B doesn't answer in time, and there's no one else to ask.
+	checkTxRequests(t, f.tick(now.Add(txArriveTimeout+txFetchTimeout+time.Millisecond)), nil)
+	if len(f.announced) != 0 || len(f.fetching) != 0 || len(f.firstSeen) != 0 {
+		t.Fatalf("leftover state: announced %d, fetching %d, firstSeen %d", len(f.announced), len(f.fetching), len(f.firstSeen))
+	}
+}
+
+// Tests that the in-flight retrievals of a dropped peer are rescheduled.
+func TestTxFetcherDrop(t *testing.T) {
+	_, hashes := makeTestTxs(3)
+	f := newTestTxFetcher()
+	now := time.Now()
+
+	f.notify("A", hashes, now)
+	checkTxRequests(t, f.tick(now.Add(txArriveTimeout)), map[string][]common.Hash{"A": hashes})
+	f.notify("B", hashes[:2], now)
+
+	checkTxRequests(t, f.drop("A", now.Add(txArriveTimeout)), map[string][]common.Hash{"B": hashes[:2]})
+	if _, ok := f.announced[hashes[2]]; ok {
+		t.Fatal("transaction announced only by the dropped peer not forgotten")
+	}
+}
+
+// Tests that a peer can't have more than a limited number of outstanding
+// announcements.
+func TestTxFetcherAnnounceLimit(t *testing.T) {
+	hashes := make([]common.Hash, txAnnounceLimit+10)
+	for i := range hashes {
+		hashes[i] = common.BigToHash(big.NewInt(int64(i + 1)))
+	}
+	f := newTestTxFetcher()
+	f.notify("A", hashes, time.Now())
+	if n := len(f.announces["A"]); n != txAnnounceLimit {
+		t.Fatalf("announcement count mismatch: have %d, want %d", n, txAnnounceLimit)
+	}
+}
@@ -4,6 +4,7 @@ package ess
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
@@ -17,23 +18,30 @@ var _ = (*configMarshaling)(nil)
 
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               uint64
-		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
-		DatabaseCache           int
-		Etherbase               common.Address `toml:",omitempty"`
-		MinerThreads            int            `toml:",omitempty"`
-		ExtraData               hexutil.Bytes  `toml:",omitempty"`
-		GasPrice                *big.Int
-		Ethash                  ethash.Config
-		TxPool                  core.TxPoolConfig
-		GPO                     gasprice.Config
-		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
+		Genesis                  *core.Genesis `toml:",omitempty"`
+		NetworkId                uint64
+		SyncMode                 downloader.SyncMode
+		LightServ                int  `toml:",omitempty"`
+		LightPeers               int  `toml:",omitempty"`
+		SkipBcVersionCheck       bool `toml:"-"`
+		DatabaseHandles          int  `toml:"-"`
+		DatabaseCache            int
+		DatabaseFreezer          string `toml:",omitempty"`
+		DatabaseFreezerThreshold uint64
+		Etherbase                common.Address `toml:",omitempty"`
+		MinerThreads             int            `toml:",omitempty"`
+		ExtraData                hexutil.Bytes  `toml:",omitempty"`
+		GasPrice                 *big.Int
+		MinerRecommit            time.Duration
+		MinerOrdering            string           `toml:",omitempty"`
+		MinerPriority            []common.Address `toml:",omitempty"`
+		MinerQuota               uint64           `toml:",omitempty"`
+		Ethash                   ethash.Config
+		TxPool                   core.TxPoolConfig
+		GPO                      gasprice.Config
+		EnablePreimageRecording  bool
+		Developer                bool   `toml:",omitempty"`
+		DocRoot                  string `toml:"-"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -44,37 +52,51 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.DatabaseFreezerThreshold = c.DatabaseFreezerThreshold
 	enc.Etherbase = c.Etherbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.MinerRecommit = c.MinerRecommit
+	enc.MinerOrdering = c.MinerOrdering
+	enc.MinerPriority = c.MinerPriority
+	enc.MinerQuota = c.MinerQuota
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.Developer = c.Developer
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
 
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               *uint64
-		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
-		DatabaseCache           *int
-		Etherbase               *common.Address `toml:",omitempty"`
-		MinerThreads            *int            `toml:",omitempty"`
-		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
-		GasPrice                *big.Int
-		Ethash                  *ethash.Config
-		TxPool                  *core.TxPoolConfig
-		GPO                     *gasprice.Config
-		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
+		Genesis                  *core.Genesis `toml:",omitempty"`
+		NetworkId                *uint64
+		SyncMode                 *downloader.SyncMode
+		LightServ                *int  `toml:",omitempty"`
+		LightPeers               *int  `toml:",omitempty"`
+		SkipBcVersionCheck       *bool `toml:"-"`
+		DatabaseHandles          *int  `toml:"-"`
+		DatabaseCache            *int
+		DatabaseFreezer          *string `toml:",omitempty"`
+		DatabaseFreezerThreshold *uint64
+		Etherbase                *common.Address `toml:",omitempty"`
+		MinerThreads             *int            `toml:",omitempty"`
+		ExtraData                *hexutil.Bytes  `toml:",omitempty"`
+		GasPrice                 *big.Int
+		MinerRecommit            *time.Duration
+		MinerOrdering            *string          `toml:",omitempty"`
+		MinerPriority            []common.Address `toml:",omitempty"`
+		MinerQuota               *uint64          `toml:",omitempty"`
+		Ethash                   *ethash.Config
+		TxPool                   *core.TxPoolConfig
+		GPO                      *gasprice.Config
+		EnablePreimageRecording  *bool
+		Developer                *bool   `toml:",omitempty"`
+		DocRoot                  *string `toml:"-"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -104,6 +126,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
+	if dec.DatabaseFreezerThreshold != nil {
+		c.DatabaseFreezerThreshold = *dec.DatabaseFreezerThreshold
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -116,6 +144,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.MinerRecommit != nil {
+		c.MinerRecommit = *dec.MinerRecommit
+	}
+	if dec.MinerOrdering != nil {
+		c.MinerOrdering = *dec.MinerOrdering
+	}
+	if dec.MinerPriority != nil {
+		c.MinerPriority = dec.MinerPriority
+	}
+	if dec.MinerQuota != nil {
+		c.MinerQuota = *dec.MinerQuota
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
@@ -128,6 +168,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.Developer != nil {
+		c.Developer = *dec.Developer
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
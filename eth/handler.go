@@ -17,6 +17,7 @@
 package ess
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -30,6 +31,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/consensus"
 	"github.com/orangeAndSuns/go-ethereum/consensus/misc"
 	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/eth/downloader"
 	"github.com/orangeAndSuns/go-ethereum/eth/fetcher"
@@ -49,6 +51,13 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// Usefulness signals fed into the peer scoring of the p2p server.
+	newBlockUsefulness = 1   // Peer announced or propagated a block we imported
+	txUsefulness       = 0.1 // Peer relayed a batch of transactions
+
+	requestTimeoutPenalty    = -0.5 // Peer didn't answer a request in time (ess/65+)
+	unmatchedResponsePenalty = -0.5 // Peer sent a response to no pending request of that type (ess/65+)
 )
 
 var (
@@ -72,10 +81,12 @@ type ProtocolManager struct {
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
+	forkFilter  forkid.Filter // Fork ID filter, constant across the lifetime of the node
 	maxPeers    int
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
@@ -106,6 +117,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		txpool:      txpool,
 		blockchain:  blockchain,
 		chainconfig: config,
+		forkFilter:  forkid.NewFilter(blockchain),
 		peers:       newPeerSet(),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
@@ -176,7 +188,24 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	crediter := func(id string) {
+		if p := manager.peers.Peer(id); p != nil {
+			p.ReportUsefulness(newBlockUsefulness)
+		}
+	}
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer, crediter)
+
+	hasTx := func(hash common.Hash) bool {
+		return manager.txpool.Get(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(hasTx, manager.txpool.AddRemotes, fetchTxs)
 
 	return manager, nil
 }
@@ -189,8 +218,9 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 	log.Debug("Removing Essentia peer", "peer", id)
 
-	// Unregister the peer from the downloader and Essentia peer set
+	// Unregister the peer from the downloader, the tx fetcher and Essentia peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Error("Peer removal failed", "peer", id, "err", err)
 	}
@@ -263,7 +293,8 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
-	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash()); err != nil {
+	forkID := forkid.NewID(pm.chainconfig, genesis.Hash(), number)
+	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash(), forkID, pm.forkFilter); err != nil {
 		p.Log().Debug("Essentia handshake failed", "err", err)
 		return err
 	}
@@ -288,7 +319,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	// If we're DAO hard-fork aware, validate any remote peer with regard to the hard-fork
 	if daoBlock := pm.chainconfig.DAOForkBlock; daoBlock != nil {
 		// Request the peer's DAO fork header for extra-data validation
-		if err := p.RequestHeadersByNumber(daoBlock.Uint64(), 1, 0, false); err != nil {
+		if err := p.RequestHeadersByNumber(genReqID(), daoBlock.Uint64(), 1, 0, false); err != nil {
 			return err
 		}
 		// Start a timer to disconnect if the peer doesn't reply in time
@@ -326,6 +357,30 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	}
 	defer msg.Discard()
 
+	// Since ess/65, requests and responses are wrapped into a packet carrying the
+	// request ID. Unwrap it, so the message handling below is version agnostic.
+	var reqID uint64
+	if _, isRequest := responseMsgs[msg.Code]; p.version >= eth65 && (isRequest || isResponseMsg(msg.Code)) {
+		var packet requestPacket65
+		if err := msg.Decode(&packet); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqID = packet.RequestID
+		msg.Payload, msg.Size = bytes.NewReader(packet.Data), uint32(len(packet.Data))
+
+		// Responses must answer one of our pending requests. Unsolicited ones and
+		// late responses to timed out requests are dropped and count against the
+		// peer, the downloader no longer expects them.
+		if !isRequest {
+			rtt, err := p.requests.fulfil(reqID, msg.Code, time.Now())
+			if err != nil {
+				p.Log().Debug("Dropping response", "code", msg.Code, "reqid", reqID, "err", err)
+				p.ReportUsefulness(unmatchedResponsePenalty)
+				return nil
+			}
+			p.ReportLatency(rtt)
+		}
+	}
 	// Handle the message depending on its contents
 	switch {
 	case msg.Code == StatusMsg:
@@ -418,7 +473,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				query.Origin.Number += query.Skip + 1
 			}
 		}
-		return p.SendBlockHeaders(headers)
+		return p.ReplyBlockHeaders(reqID, headers)
 
 	case msg.Code == BlockHeadersMsg:
 		// A batch of headers arrived to one of our previous requests
@@ -467,7 +522,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			headers = pm.fetcher.FilterHeaders(p.id, headers, time.Now())
 		}
 		if len(headers) > 0 || !filter {
-			err := pm.downloader.DeliverHeaders(p.id, headers)
+			err := pm.downloader.DeliverHeaders(p.id, reqID, headers)
 			if err != nil {
 				log.Debug("Failed to deliver headers", "err", err)
 			}
@@ -498,7 +553,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(data)
 			}
 		}
-		return p.SendBlockBodiesRLP(bodies)
+		return p.ReplyBlockBodiesRLP(reqID, bodies)
 
 	case msg.Code == BlockBodiesMsg:
 		// A batch of block bodies arrived to one of our previous requests
@@ -520,7 +575,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			transactions, uncles = pm.fetcher.FilterBodies(p.id, transactions, uncles, time.Now())
 		}
 		if len(transactions) > 0 || len(uncles) > 0 || !filter {
-			err := pm.downloader.DeliverBodies(p.id, transactions, uncles)
+			err := pm.downloader.DeliverBodies(p.id, reqID, transactions, uncles)
 			if err != nil {
 				log.Debug("Failed to deliver bodies", "err", err)
 			}
@@ -551,7 +606,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(entry)
 			}
 		}
-		return p.SendNodeData(data)
+		return p.ReplyNodeData(reqID, data)
 
 	case p.version >= eth63 && msg.Code == NodeDataMsg:
 		// A batch of node state data arrived to one of our previous requests
@@ -560,7 +615,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 		// Deliver all to the downloader
-		if err := pm.downloader.DeliverNodeData(p.id, data); err != nil {
+		if err := pm.downloader.DeliverNodeData(p.id, reqID, data); err != nil {
 			log.Debug("Failed to deliver node state data", "err", err)
 		}
 
@@ -598,7 +653,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(encoded)
 			}
 		}
-		return p.SendReceiptsRLP(receipts)
+		return p.ReplyReceiptsRLP(reqID, receipts)
 
 	case p.version >= eth63 && msg.Code == ReceiptsMsg:
 		// A batch of receipts arrived to one of our previous requests
@@ -607,7 +662,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 		// Deliver all to the downloader
-		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
+		if err := pm.downloader.DeliverReceipts(p.id, reqID, receipts); err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
 
@@ -628,7 +683,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 		}
 		for _, block := range unknown {
-			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, p.RequestBodies)
+			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, p.RequestFetcherBodies)
 		}
 
 	case msg.Code == NewBlockMsg:
@@ -663,7 +718,112 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 		}
 
-	case msg.Code == TxMsg:
+	case p.version >= eth67 && msg.Code == GetAccountRangeMsg:
+		// Decode the account range query and serve it from the state trie
+		var query getAccountRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return p.ReplyAccountRange(reqID, pm.serviceAccountRange(&query))
+
+	case p.version >= eth67 && msg.Code == AccountRangeMsg:
+		// A range of accounts arrived to one of our previous requests
+		var resp accountRangeData
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hashes := make([]common.Hash, len(resp.Accounts))
+		accounts := make([][]byte, len(resp.Accounts))
+		for i, account := range resp.Accounts {
+			if account == nil {
+				return errResp(ErrDecode, "account %d is nil", i)
+			}
+			hashes[i], accounts[i] = account.Hash, account.Body
+		}
+		// Deliver all to the downloader
+		if err := pm.downloader.DeliverAccountRange(p.id, reqID, hashes, accounts, resp.Proof); err != nil {
+			log.Debug("Failed to deliver account range", "err", err)
+		}
+
+	case p.version >= eth67 && msg.Code == GetStorageRangesMsg:
+		// Decode the storage range query and serve it from the state tries
+		var query getStorageRangesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return p.ReplyStorageRanges(reqID, pm.serviceStorageRanges(&query))
+
+	case p.version >= eth67 && msg.Code == StorageRangesMsg:
+		// A batch of storage ranges arrived to one of our previous requests
+		var resp storageRangesData
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hashes := make([][]common.Hash, len(resp.Slots))
+		slots := make([][][]byte, len(resp.Slots))
+		for i, rng := range resp.Slots {
+			hashes[i] = make([]common.Hash, len(rng))
+			slots[i] = make([][]byte, len(rng))
+			for j, slot := range rng {
+				if slot == nil {
+					return errResp(ErrDecode, "slot %d of range %d is nil", j, i)
+				}
+				hashes[i][j], slots[i][j] = slot.Hash, slot.Body
+			}
+		}
+		// Deliver all to the downloader
+		if err := pm.downloader.DeliverStorageRanges(p.id, reqID, hashes, slots, resp.Proof); err != nil {
+			log.Debug("Failed to deliver storage ranges", "err", err)
+		}
+
+	case p.version >= eth66 && msg.Code == NewPooledTransactionHashesMsg:
+		// New transactions were announced, make sure we can handle them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth66 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash  common.Hash
+			bytes int
+			txs   []rlp.RawValue
+		)
+		for bytes < softResponseLimit && len(txs) < fetcher.MaxTransactionFetch {
+			// Retrieve the hash of the next transaction
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested transaction, skipping if unknown to us
+			tx := pm.txpool.Get(hash)
+			if tx == nil {
+				continue
+			}
+			if encoded, err := rlp.EncodeToBytes(tx); err != nil {
+				log.Error("Failed to encode transaction", "err", err)
+			} else {
+				txs = append(txs, encoded)
+				bytes += len(encoded)
+			}
+		}
+		return p.ReplyPooledTransactionsRLP(reqID, txs)
+
+	case msg.Code == TxMsg || (p.version >= eth66 && msg.Code == PooledTransactionsMsg):
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
 		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
 			break
@@ -680,7 +840,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+		if len(txs) > 0 {
+			p.ReportUsefulness(txUsefulness)
+		}
+		pm.txFetcher.Enqueue(p.id, txs, msg.Code == PooledTransactionsMsg)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -721,23 +884,35 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 	}
 }
 
-// BroadcastTxs will propagate a batch of transactions to all peers which are not known to
-// already have the given transaction.
+// BroadcastTxs will propagate a batch of transactions to all peers which are not
+// known to already have the given transaction. Full transactions are only sent
+// to a subset of the peers, the rest is notified via hash announcements (or is
+// sent the full transactions too if it doesn't support ess/66).
 func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
-	var txset = make(map[*peer]types.Transactions)
-
+	var (
+		txset  = make(map[*peer]types.Transactions)
+		annset = make(map[*peer][]common.Hash)
+	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
 		peers := pm.peers.PeersWithoutTx(tx.Hash())
-		for _, peer := range peers {
-			txset[peer] = append(txset[peer], tx)
+
+		transfer := int(math.Sqrt(float64(len(peers))))
+		for i, peer := range peers {
+			if i < transfer || peer.version < eth66 {
+				txset[peer] = append(txset[peer], tx)
+			} else {
+				annset[peer] = append(annset[peer], tx.Hash())
+			}
 		}
 		log.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers))
 	}
-	// FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
 	for peer, txs := range txset {
 		peer.AsyncSendTransactions(txs)
 	}
+	for peer, hashes := range annset {
+		peer.AsyncSendPooledTransactionHashes(hashes)
+	}
 }
 
 // Mined broadcast loop
@@ -35,6 +35,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/event"
 	"github.com/orangeAndSuns/go-ethereum/p2p"
 	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/trie"
 )
 
 // Tests that protocol versions and modes of operations are matched up properly.
@@ -69,6 +70,8 @@ func TestProtocolCompatibility(t *testing.T) {
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeaders62(t *testing.T) { testGetBlockHeaders(t, 62) }
 func TestGetBlockHeaders63(t *testing.T) { testGetBlockHeaders(t, 63) }
+func TestGetBlockHeaders64(t *testing.T) { testGetBlockHeaders(t, 64) }
+func TestGetBlockHeaders65(t *testing.T) { testGetBlockHeaders(t, 65) }
 
 func testGetBlockHeaders(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxHashFetch+15, nil, nil)
@@ -207,8 +210,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 			headers = append(headers, pm.blockchain.GetBlockByHash(hash).Header())
 		}
 		// Send the hash request and verify the response
-		p2p.Send(peer.app, 0x03, tt.query)
-		if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+		sendRequest(peer.app, protocol, 0x03, tt.query)
+		if err := expectResponse(peer.app, protocol, 0x04, headers); err != nil {
 			t.Errorf("test %d: headers mismatch: %v", i, err)
 		}
 		// If the test used number origins, repeat with hashes as the too
@@ -216,8 +219,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 			if origin := pm.blockchain.GetBlockByNumber(tt.query.Origin.Number); origin != nil {
 				tt.query.Origin.Hash, tt.query.Origin.Number = origin.Hash(), 0
 
-				p2p.Send(peer.app, 0x03, tt.query)
-				if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+				sendRequest(peer.app, protocol, 0x03, tt.query)
+				if err := expectResponse(peer.app, protocol, 0x04, headers); err != nil {
 					t.Errorf("test %d: headers mismatch: %v", i, err)
 				}
 			}
@@ -228,6 +231,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodies62(t *testing.T) { testGetBlockBodies(t, 62) }
 func TestGetBlockBodies63(t *testing.T) { testGetBlockBodies(t, 63) }
+func TestGetBlockBodies64(t *testing.T) { testGetBlockBodies(t, 64) }
+func TestGetBlockBodies65(t *testing.T) { testGetBlockBodies(t, 65) }
 
 func testGetBlockBodies(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxBlockFetch+15, nil, nil)
@@ -290,8 +295,8 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 			}
 		}
 		// Send the hash request and verify the response
-		p2p.Send(peer.app, 0x05, hashes)
-		if err := p2p.ExpectMsg(peer.app, 0x06, bodies); err != nil {
+		sendRequest(peer.app, protocol, 0x05, hashes)
+		if err := expectResponse(peer.app, protocol, 0x06, bodies); err != nil {
 			t.Errorf("test %d: bodies mismatch: %v", i, err)
 		}
 	}
@@ -299,6 +304,8 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 
 // Tests that the node state database can be retrieved based on hashes.
 func TestGetNodeData63(t *testing.T) { testGetNodeData(t, 63) }
+func TestGetNodeData64(t *testing.T) { testGetNodeData(t, 64) }
+func TestGetNodeData65(t *testing.T) { testGetNodeData(t, 65) }
 
 func testGetNodeData(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -348,7 +355,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 			hashes = append(hashes, common.BytesToHash(key))
 		}
 	}
-	p2p.Send(peer.app, 0x0d, hashes)
+	sendRequest(peer.app, protocol, 0x0d, hashes)
 	msg, err := peer.app.ReadMsg()
 	if err != nil {
 		t.Fatalf("failed to read node data response: %v", err)
@@ -357,7 +364,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 		t.Fatalf("response packet code mismatch: have %x, want %x", msg.Code, 0x0c)
 	}
 	var data [][]byte
-	if err := msg.Decode(&data); err != nil {
+	if err := decodeResponse(msg, protocol, &data); err != nil {
 		t.Fatalf("failed to decode response node data: %v", err)
 	}
 	// Verify that all hashes correspond to the requested data, and reconstruct a state tree
@@ -391,6 +398,8 @@ func testGetNodeData(t *testing.T, protocol int) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
+func TestGetReceipt64(t *testing.T) { testGetReceipt(t, 64) }
+func TestGetReceipt65(t *testing.T) { testGetReceipt(t, 65) }
 
 func testGetReceipt(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -442,12 +451,315 @@ func testGetReceipt(t *testing.T, protocol int) {
 		receipts = append(receipts, pm.blockchain.GetReceiptsByHash(block.Hash()))
 	}
 	// Send the hash request and verify the response
-	p2p.Send(peer.app, 0x0f, hashes)
-	if err := p2p.ExpectMsg(peer.app, 0x10, receipts); err != nil {
+	sendRequest(peer.app, protocol, 0x0f, hashes)
+	if err := expectResponse(peer.app, protocol, 0x10, receipts); err != nil {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
 
+// Tests that since ess/65, outgoing requests carry a request ID and responses are
+// only accepted if they answer a pending request, unmatched ones being counted
+// against the peer.
+func TestRequestTracking65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	peer, errc := newTestPeer("peer", eth65, pm, true)
+	defer peer.close()
+
+	// Issue a request from the local side and check its wire format.
+	go peer.peer.RequestBodies(1, []common.Hash{pm.blockchain.CurrentBlock().Hash()})
+	msg, err := peer.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	if msg.Code != GetBlockBodiesMsg {
+		t.Fatalf("request code mismatch: have %x, want %x", msg.Code, GetBlockBodiesMsg)
+	}
+	var packet requestPacket65
+	if err := msg.Decode(&packet); err != nil {
+		t.Fatalf("failed to decode request packet: %v", err)
+	}
+	pending := func() int {
+		peer.requests.lock.Lock()
+		defer peer.requests.lock.Unlock()
+		return len(peer.requests.pending)
+	}
+	if n := pending(); n != 1 {
+		t.Fatalf("pending request count mismatch: have %d, want 1", n)
+	}
+	// waitUsefulness waits until the peer's usefulness reaches the expected value.
+	waitUsefulness := func(want float64) {
+		t.Helper()
+		for start := time.Now(); peer.Stats().Usefulness != want; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatalf("usefulness mismatch: have %v, want %v", peer.Stats().Usefulness, want)
+			}
+		}
+	}
+	usefulness := peer.Stats().Usefulness
+
+	// Responses to unknown requests or of the wrong type are dropped and penalized.
+	if err := sendRequest(peer.app, eth65, BlockBodiesMsg, blockBodiesData{}); err != nil {
+		t.Fatalf("failed to send response: %v", err)
+	}
+	usefulness += unmatchedResponsePenalty
+	waitUsefulness(usefulness)
+
+	if err := p2p.Send(peer.app, BlockHeadersMsg, &requestPacket65{RequestID: packet.RequestID, Data: common.FromHex("0xc0")}); err != nil {
+		t.Fatalf("failed to send response: %v", err)
+	}
+	usefulness += unmatchedResponsePenalty
+	waitUsefulness(usefulness)
+
+	if n := pending(); n != 1 {
+		t.Fatalf("pending request count mismatch after bad responses: have %d, want 1", n)
+	}
+	// The correct response fulfils the request without a penalty.
+	if err := p2p.Send(peer.app, BlockBodiesMsg, &requestPacket65{RequestID: packet.RequestID, Data: common.FromHex("0xc0")}); err != nil {
+		t.Fatalf("failed to send response: %v", err)
+	}
+	for start := time.Now(); pending() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("request not fulfilled by response")
+		}
+	}
+	if have := peer.Stats().Usefulness; have != usefulness {
+		t.Fatalf("usefulness changed by valid response: have %v, want %v", have, usefulness)
+	}
+	// A response arriving after its request timed out is dropped and penalized.
+	go peer.peer.RequestReceipts(1, []common.Hash{pm.blockchain.CurrentBlock().Hash()})
+	if msg, err = peer.app.ReadMsg(); err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	if err := msg.Decode(&packet); err != nil {
+		t.Fatalf("failed to decode request packet: %v", err)
+	}
+	peer.requests.lock.Lock()
+	for _, req := range peer.requests.pending {
+		req.sent = req.sent.Add(-2 * requestTimeout)
+	}
+	peer.requests.lock.Unlock()
+
+	if err := p2p.Send(peer.app, ReceiptsMsg, &requestPacket65{RequestID: packet.RequestID, Data: common.FromHex("0xc0")}); err != nil {
+		t.Fatalf("failed to send response: %v", err)
+	}
+	usefulness += unmatchedResponsePenalty
+	waitUsefulness(usefulness)
+
+	if n := pending(); n != 0 {
+		t.Fatalf("pending request count mismatch after timeout: have %d, want 0", n)
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("peer dropped: %v", err)
+	default:
+	}
+}
+
+// makeRangeTestState commits a state with plenty of accounts and a few storage
+// tries into the given database, returning its root and the storage owners.
+func makeRangeTestState(t *testing.T, db ethdb.Database) (common.Hash, []common.Address) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	var owners []common.Address
+	for i := 0; i < 256; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+		if i%64 == 0 {
+			for j := 0; j < 64; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i+j+1))))
+			}
+			owners = append(owners, addr)
+		}
+	}
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	return root, owners
+}
+
+// rangeProofDb collects the proof nodes of a state range response.
+func rangeProofDb(proof [][]byte) trie.DatabaseReader {
+	if len(proof) == 0 {
+		return nil
+	}
+	db := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// Tests that ranges of the account trie can be retrieved along with the proofs
+// of their boundaries.
+func TestGetAccountRange67(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	peer, _ := newTestPeer("peer", eth67, pm, true)
+	defer peer.close()
+
+	root, _ := makeRangeTestState(t, db)
+
+	var (
+		middle = common.HexToHash("0x8000000000000000000000000000000000000000000000000000000000000000")
+		limit  = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	)
+	tests := []struct {
+		origin common.Hash
+		bytes  uint64
+		more   bool
+	}{
+		{common.Hash{}, 0, false},   // Entire account trie
+		{common.Hash{}, 1024, true}, // Size limited range from the start
+		{middle, 1024, true},        // Size limited range from the middle
+	}
+	for i, tt := range tests {
+		sendRequest(peer.app, eth67, GetAccountRangeMsg, &getAccountRangeData{Root: root, Origin: tt.origin, Limit: limit, Bytes: tt.bytes})
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("test %d: failed to read account range: %v", i, err)
+		}
+		if msg.Code != AccountRangeMsg {
+			t.Fatalf("test %d: response packet code mismatch: have %x, want %x", i, msg.Code, AccountRangeMsg)
+		}
+		var resp accountRangeData
+		if err := decodeResponse(msg, eth67, &resp); err != nil {
+			t.Fatalf("test %d: failed to decode account range: %v", i, err)
+		}
+		keys := make([][]byte, len(resp.Accounts))
+		values := make([][]byte, len(resp.Accounts))
+		for j, account := range resp.Accounts {
+			keys[j], values[j] = account.Hash.Bytes(), account.Body
+		}
+		last := tt.origin
+		if len(resp.Accounts) > 0 {
+			last = resp.Accounts[len(resp.Accounts)-1].Hash
+		}
+		more, err := trie.VerifyRangeProof(root, tt.origin[:], last[:], keys, values, rangeProofDb(resp.Proof))
+		if err != nil {
+			t.Errorf("test %d: invalid account range: %v", i, err)
+		}
+		if more != tt.more {
+			t.Errorf("test %d: range continuation mismatch: have %v, want %v", i, more, tt.more)
+		}
+	}
+	// Ranges of unknown states are served empty
+	sendRequest(peer.app, eth67, GetAccountRangeMsg, &getAccountRangeData{Root: common.HexToHash("0xdeadbeef"), Limit: limit})
+	if err := expectResponse(peer.app, eth67, AccountRangeMsg, &accountRangeData{}); err != nil {
+		t.Errorf("unknown state response mismatch: %v", err)
+	}
+}
+
+// Tests that storage ranges can be retrieved in batches, with the last range
+// being cut off at the size limit and proven.
+func TestGetStorageRanges67(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	peer, _ := newTestPeer("peer", eth67, pm, true)
+	defer peer.close()
+
+	root, owners := makeRangeTestState(t, db)
+	statedb, _ := state.New(root, state.NewDatabase(db))
+
+	accounts := make([]common.Hash, len(owners))
+	for i, owner := range owners {
+		accounts[i] = crypto.Keccak256Hash(owner[:])
+	}
+	tests := []struct {
+		bytes   uint64
+		ranges  int
+		partial bool
+	}{
+		{0, len(owners), false}, // All storage tries in full
+		{3000, 2, true},         // Size limited, cut off in the second trie
+	}
+	for i, tt := range tests {
+		sendRequest(peer.app, eth67, GetStorageRangesMsg, &getStorageRangesData{Root: root, Accounts: accounts, Bytes: tt.bytes})
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("test %d: failed to read storage ranges: %v", i, err)
+		}
+		if msg.Code != StorageRangesMsg {
+			t.Fatalf("test %d: response packet code mismatch: have %x, want %x", i, msg.Code, StorageRangesMsg)
+		}
+		var resp storageRangesData
+		if err := decodeResponse(msg, eth67, &resp); err != nil {
+			t.Fatalf("test %d: failed to decode storage ranges: %v", i, err)
+		}
+		if len(resp.Slots) != tt.ranges {
+			t.Fatalf("test %d: storage range count mismatch: have %d, want %d", i, len(resp.Slots), tt.ranges)
+		}
+		for j, slots := range resp.Slots {
+			keys := make([][]byte, len(slots))
+			values := make([][]byte, len(slots))
+			for k, slot := range slots {
+				keys[k], values[k] = slot.Hash.Bytes(), slot.Body
+			}
+			var (
+				proof trie.DatabaseReader
+				last  common.Hash
+			)
+			if j == len(resp.Slots)-1 {
+				proof = rangeProofDb(resp.Proof)
+			}
+			if len(slots) > 0 {
+				last = slots[len(slots)-1].Hash
+			}
+			more, err := trie.VerifyRangeProof(statedb.StorageTrie(owners[j]).Hash(), make([]byte, common.HashLength), last[:], keys, values, proof)
+			if err != nil {
+				t.Errorf("test %d, range %d: invalid storage range: %v", i, j, err)
+			}
+			if want := tt.partial && j == len(resp.Slots)-1; more != want {
+				t.Errorf("test %d, range %d: range continuation mismatch: have %v, want %v", i, j, more, want)
+			}
+		}
+	}
+}
+
+// Tests that oversized storage range requests are capped both in the number of
+// accounts and in size, even if the accounts don't have any storage.
+func TestGetStorageRangesLimits67(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	peer, _ := newTestPeer("peer", eth67, pm, true)
+	defer peer.close()
+
+	root, _ := makeRangeTestState(t, db)
+
+	// Request the storage of way too many accounts, none of which has any
+	var accounts []common.Hash
+	for i := 0; i < 256; i++ {
+		if i%64 != 0 {
+			addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+			accounts = append(accounts, crypto.Keccak256Hash(addr[:]))
+		}
+	}
+	for len(accounts) <= 2*downloader.MaxStorageRangeFetch {
+		accounts = append(accounts, accounts...)
+	}
+	tests := []struct {
+		bytes  uint64
+		ranges int
+	}{
+		{0, downloader.MaxStorageRangeFetch}, // Capped at the maximum account count
+		{10 * common.HashLength, 10},         // Capped by the size limit
+	}
+	for i, tt := range tests {
+		sendRequest(peer.app, eth67, GetStorageRangesMsg, &getStorageRangesData{Root: root, Accounts: accounts, Bytes: tt.bytes})
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("test %d: failed to read storage ranges: %v", i, err)
+		}
+		var resp storageRangesData
+		if err := decodeResponse(msg, eth67, &resp); err != nil {
+			t.Fatalf("test %d: failed to decode storage ranges: %v", i, err)
+		}
+		if len(resp.Slots) != tt.ranges {
+			t.Errorf("test %d: storage range count mismatch: have %d, want %d", i, len(resp.Slots), tt.ranges)
+		}
+	}
+}
+
 // Tests that post ess protocol handshake, DAO fork-enabled clients also execute
 // a DAO "challenge" verifying each others' DAO fork headers to ensure they're on
 // compatible chains.
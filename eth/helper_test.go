@@ -22,6 +22,7 @@ package ess
 import (
 	"crypto/ecdsa"
 	"crypto/rand"
+	"fmt"
 	"math/big"
 	"sort"
 	"sync"
@@ -30,6 +31,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
 	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/core/vm"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
@@ -39,6 +41,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/p2p"
 	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
 	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
 )
 
 var (
@@ -108,6 +111,19 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// Get retrieves the transaction with the given hash from the pool, if known.
+func (p *testTxPool) Get(hash common.Hash) *types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tx := range p.pool {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) {
 	p.lock.RLock()
@@ -171,20 +187,33 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 			head    = pm.blockchain.CurrentHeader()
 			td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
 		)
-		tp.handshake(nil, td, head.Hash(), genesis.Hash())
+		tp.handshake(nil, td, head.Hash(), genesis.Hash(), forkid.NewIDFromChain(pm.blockchain))
 	}
 	return tp, errc
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
 // remote side as we are simulating locally.
-func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
-	msg := &statusData{
-		ProtocolVersion: uint32(p.version),
-		NetworkId:       DefaultConfig.NetworkId,
-		TD:              td,
-		CurrentBlock:    head,
-		GenesisBlock:    genesis,
+func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID) {
+	var msg interface{}
+	switch {
+	case p.version == eth63 || p.version == eth62:
+		msg = &statusData63{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       DefaultConfig.NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+		}
+	default:
+		msg = &statusData{
+			ProtocolVersion: uint32(p.version),
+			NetworkID:       DefaultConfig.NetworkId,
+			TD:              td,
+			Head:            head,
+			Genesis:         genesis,
+			ForkID:          forkID,
+		}
 	}
 	if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
 		t.Fatalf("status recv: %v", err)
@@ -199,3 +228,48 @@ func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesi
 func (p *testPeer) close() {
 	p.app.Close()
 }
+
+// testRequestID is the request ID of requests sent by tests since ess/65.
+const testRequestID = 42
+
+// sendRequest sends a request message, wrapping it into a request packet if the
+// protocol version requires it.
+func sendRequest(w p2p.MsgWriter, version int, code uint64, data interface{}) error {
+	if version >= eth65 {
+		enc, err := rlp.EncodeToBytes(data)
+		if err != nil {
+			return err
+		}
+		data = &requestPacket65{RequestID: testRequestID, Data: enc}
+	}
+	return p2p.Send(w, code, data)
+}
+
+// expectResponse reads a message and checks that it's the given response to a
+// request sent via sendRequest.
+func expectResponse(r p2p.MsgReader, version int, code uint64, data interface{}) error {
+	if version >= eth65 {
+		enc, err := rlp.EncodeToBytes(data)
+		if err != nil {
+			return err
+		}
+		data = &requestPacket65{RequestID: testRequestID, Data: enc}
+	}
+	return p2p.ExpectMsg(r, code, data)
+}
+
+// decodeResponse decodes the content of a response to a request sent via
+// sendRequest.
+func decodeResponse(msg p2p.Msg, version int, val interface{}) error {
+	if version < eth65 {
+		return msg.Decode(val)
+	}
+	var packet requestPacket65
+	if err := msg.Decode(&packet); err != nil {
+		return err
+	}
+	if packet.RequestID != testRequestID {
+		return fmt.Errorf("request ID mismatch: have %d, want %d", packet.RequestID, testRequestID)
+	}
+	return rlp.DecodeBytes(packet.Data, val)
+}
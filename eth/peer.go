@@ -25,6 +25,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/p2p"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
@@ -45,6 +46,11 @@ const (
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxQueuedTxAnns is the maximum number of transaction announcement lists to
+	// queue up before dropping broadcasts. Announcements are much cheaper than
+	// the full transactions, so allow some more.
+	maxQueuedTxAnns = 4 * maxQueuedTxs
+
 	// maxQueuedProps is the maximum number of block propagations to queue up before
 	// dropping broadcasts. There's not much point in queueing stale blocks, so a few
 	// that might cover uncles should be enough.
@@ -78,33 +84,37 @@ type peer struct {
 	*p2p.Peer
 	rw p2p.MsgReadWriter
 
-	version  int         // Protocol version negotiated
-	forkDrop *time.Timer // Timed connection dropper if forks aren't validated in time
+	version  int             // Protocol version negotiated
+	forkDrop *time.Timer     // Timed connection dropper if forks aren't validated in time
+	requests *requestTracker // Requests awaiting a response (ess/65+)
 
 	head common.Hash
 	td   *big.Int
 	lock sync.RWMutex
 
-	knownTxs    mapset.Set                // Set of transaction hashes known to be known by this peer
-	knownBlocks mapset.Set                // Set of block hashes known to be known by this peer
-	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
-	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
-	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
-	term        chan struct{}             // Termination channel to stop the broadcaster
+	knownTxs     mapset.Set                // Set of transaction hashes known to be known by this peer
+	knownBlocks  mapset.Set                // Set of block hashes known to be known by this peer
+	queuedTxs    chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedTxAnns chan []common.Hash        // Queue of transaction hashes to announce to the peer (ess/66+)
+	queuedProps  chan *propEvent           // Queue of blocks to broadcast to the peer
+	queuedAnns   chan *types.Block         // Queue of blocks to announce to the peer
+	term         chan struct{}             // Termination channel to stop the broadcaster
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		knownTxs:    mapset.NewSet(),
-		knownBlocks: mapset.NewSet(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		requests:     newRequestTracker(requestTimeout),
+		id:           fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownTxs:     mapset.NewSet(),
+		knownBlocks:  mapset.NewSet(),
+		queuedTxs:    make(chan []*types.Transaction, maxQueuedTxs),
+		queuedTxAnns: make(chan []common.Hash, maxQueuedTxAnns),
+		queuedProps:  make(chan *propEvent, maxQueuedProps),
+		queuedAnns:   make(chan *types.Block, maxQueuedAnns),
+		term:         make(chan struct{}),
 	}
 }
 
@@ -120,6 +130,12 @@ func (p *peer) broadcast() {
 			}
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedTxAnns:
+			if err := p.SendPooledTransactionHashes(hashes); err != nil {
+				return
+			}
+			p.Log().Trace("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -215,6 +231,30 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SendPooledTransactionHashes announces the availability of a batch of
+// transactions through their hashes and includes the hashes in the peer's
+// transaction hash set for future reference.
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+// AsyncSendPooledTransactionHashes queues a list of transaction hashes for
+// announcement to a remote peer. If the peer's announcement queue is full, the
+// event is silently dropped.
+func (p *peer) AsyncSendPooledTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedTxAnns <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		p.Log().Debug("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -286,65 +326,189 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 	return p2p.Send(p.rw, ReceiptsMsg, receipts)
 }
 
+// ReplyBlockHeaders sends a batch of block headers in response to the request
+// with the given ID.
+func (p *peer) ReplyBlockHeaders(id uint64, headers []*types.Header) error {
+	return p.sendResponse(BlockHeadersMsg, id, headers)
+}
+
+// ReplyBlockBodiesRLP sends a batch of already RLP encoded block contents in
+// response to the request with the given ID.
+func (p *peer) ReplyBlockBodiesRLP(id uint64, bodies []rlp.RawValue) error {
+	return p.sendResponse(BlockBodiesMsg, id, bodies)
+}
+
+// ReplyNodeData sends a batch of state data in response to the request with the
+// given ID.
+func (p *peer) ReplyNodeData(id uint64, data [][]byte) error {
+	return p.sendResponse(NodeDataMsg, id, data)
+}
+
+// ReplyReceiptsRLP sends a batch of already RLP encoded transaction receipts in
+// response to the request with the given ID.
+func (p *peer) ReplyReceiptsRLP(id uint64, receipts []rlp.RawValue) error {
+	return p.sendResponse(ReceiptsMsg, id, receipts)
+}
+
+// ReplyPooledTransactionsRLP sends a batch of already RLP encoded pooled
+// transactions in response to the request with the given ID.
+func (p *peer) ReplyPooledTransactionsRLP(id uint64, txs []rlp.RawValue) error {
+	return p.sendResponse(PooledTransactionsMsg, id, txs)
+}
+
+// ReplyAccountRange sends a range of accounts along with its boundary proofs in
+// response to the request with the given ID.
+func (p *peer) ReplyAccountRange(id uint64, accounts *accountRangeData) error {
+	return p.sendResponse(AccountRangeMsg, id, accounts)
+}
+
+// ReplyStorageRanges sends a batch of storage ranges along with the boundary
+// proofs of the last one in response to the request with the given ID.
+func (p *peer) ReplyStorageRanges(id uint64, slots *storageRangesData) error {
+	return p.sendResponse(StorageRangesMsg, id, slots)
+}
+
+// sendResponse sends a response message, wrapping it together with the ID of the
+// answered request since ess/65.
+func (p *peer) sendResponse(code uint64, id uint64, data interface{}) error {
+	if p.version < eth65 {
+		return p2p.Send(p.rw, code, data)
+	}
+	return p.sendPacket65(code, id, data)
+}
+
+// sendRequest sends a request message. Since ess/65, the request is sent with the
+// given ID and tracked until it is answered or times out.
+func (p *peer) sendRequest(code uint64, id uint64, data interface{}) error {
+	if p.version < eth65 {
+		return p2p.Send(p.rw, code, data)
+	}
+	expired := p.requests.track(id, responseMsgs[code], time.Now())
+	if expired > 0 {
+		p.Log().Debug("Requests timed out", "count", expired)
+		p.ReportUsefulness(float64(expired) * requestTimeoutPenalty)
+	}
+	return p.sendPacket65(code, id, data)
+}
+
+// sendPacket65 sends a message wrapped into an ess/65 request packet.
+func (p *peer) sendPacket65(code uint64, id uint64, data interface{}) error {
+	enc, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return err
+	}
+	return p2p.Send(p.rw, code, &requestPacket65{RequestID: id, Data: enc})
+}
+
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
 	p.Log().Debug("Fetching single header", "hash", hash)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
+	return p.sendRequest(GetBlockHeadersMsg, genReqID(), &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
 }
 
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
-func (p *peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
+func (p *peer) RequestHeadersByHash(id uint64, origin common.Hash, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromhash", origin, "skip", skip, "reverse", reverse)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+	return p.sendRequest(GetBlockHeadersMsg, id, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
-func (p *peer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
+func (p *peer) RequestHeadersByNumber(id uint64, origin uint64, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromnum", origin, "skip", skip, "reverse", reverse)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+	return p.sendRequest(GetBlockHeadersMsg, id, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
 // RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
 // specified.
-func (p *peer) RequestBodies(hashes []common.Hash) error {
+func (p *peer) RequestBodies(id uint64, hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of block bodies", "count", len(hashes))
-	return p2p.Send(p.rw, GetBlockBodiesMsg, hashes)
+	return p.sendRequest(GetBlockBodiesMsg, id, hashes)
+}
+
+// RequestFetcherBodies is a wrapper around RequestBodies to fetch the bodies of
+// announced blocks. It is used solely by the fetcher.
+func (p *peer) RequestFetcherBodies(hashes []common.Hash) error {
+	return p.RequestBodies(genReqID(), hashes)
 }
 
 // RequestNodeData fetches a batch of arbitrary data from a node's known state
 // data, corresponding to the specified hashes.
-func (p *peer) RequestNodeData(hashes []common.Hash) error {
+func (p *peer) RequestNodeData(id uint64, hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of state data", "count", len(hashes))
-	return p2p.Send(p.rw, GetNodeDataMsg, hashes)
+	return p.sendRequest(GetNodeDataMsg, id, hashes)
 }
 
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
-func (p *peer) RequestReceipts(hashes []common.Hash) error {
+func (p *peer) RequestReceipts(id uint64, hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
-	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
+	return p.sendRequest(GetReceiptsMsg, id, hashes)
+}
+
+// RequestAccountRange fetches a range of accounts from the account trie with the
+// given root, starting at origin and ending at or just after limit.
+func (p *peer) RequestAccountRange(id uint64, root common.Hash, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of accounts", "root", root, "origin", origin, "limit", limit, "bytes", common.StorageSize(bytes))
+	return p.sendRequest(GetAccountRangeMsg, id, &getAccountRangeData{Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestStorageRanges fetches the storage ranges of a batch of accounts of the
+// state with the given root, starting at origin for the first account.
+func (p *peer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching ranges of storage slots", "root", root, "accounts", len(accounts), "origin", origin, "bytes", common.StorageSize(bytes))
+	return p.sendRequest(GetStorageRangesMsg, id, &getStorageRangesData{Root: root, Accounts: accounts, Origin: origin, Bytes: bytes})
+}
+
+// RequestTxs fetches a batch of transactions from a remote node's pool.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	return p.sendRequest(GetPooledTransactionsMsg, genReqID(), hashes)
 }
 
 // Handshake executes the ess protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head, genesis blocks and, since ess/64, fork IDs.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
-	var status statusData // safe to read after two values have been received from errc
 
+	var (
+		status63 statusData63 // safe to read after two values have been received from errc
+		status   statusData   // safe to read after two values have been received from errc
+	)
 	go func() {
-		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
-			ProtocolVersion: uint32(p.version),
-			NetworkId:       network,
-			TD:              td,
-			CurrentBlock:    head,
-			GenesisBlock:    genesis,
-		})
+		switch {
+		case p.version == eth63 || p.version == eth62:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData63{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+			})
+		case p.version >= eth64:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+				ProtocolVersion: uint32(p.version),
+				NetworkID:       network,
+				TD:              td,
+				Head:            head,
+				Genesis:         genesis,
+				ForkID:          forkID,
+			})
+		default:
+			panic(fmt.Sprintf("unsupported ess protocol version: %d", p.version))
+		}
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		switch {
+		case p.version == eth63 || p.version == eth62:
+			errc <- p.readStatusLegacy(network, &status63, genesis)
+		case p.version >= eth64:
+			errc <- p.readStatus(network, &status, genesis, forkFilter)
+		default:
+			panic(fmt.Sprintf("unsupported ess protocol version: %d", p.version))
+		}
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -358,21 +522,20 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 			return p2p.DiscReadTimeout
 		}
 	}
-	p.td, p.head = status.TD, status.CurrentBlock
+	if p.version >= eth64 {
+		p.td, p.head = status.TD, status.Head
+	} else {
+		p.td, p.head = status63.TD, status63.CurrentBlock
+	}
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
-	msg, err := p.rw.ReadMsg()
+// readStatusLegacy reads and validates the status message of ess/62 and ess/63.
+func (p *peer) readStatusLegacy(network uint64, status *statusData63, genesis common.Hash) error {
+	msg, err := p.readStatusMsg()
 	if err != nil {
 		return err
 	}
-	if msg.Code != StatusMsg {
-		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
-	}
-	if msg.Size > ProtocolMaxMsgSize {
-		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
-	}
 	// Decode the handshake and make sure everything matches
 	if err := msg.Decode(&status); err != nil {
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
@@ -389,6 +552,48 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	return nil
 }
 
+// readStatus reads and validates the status message of ess/64 and later, also
+// rejecting peers whose fork ID is incompatible with the local chain.
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter) error {
+	msg, err := p.readStatusMsg()
+	if err != nil {
+		return err
+	}
+	// Decode the handshake and make sure everything matches
+	if err := msg.Decode(&status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.NetworkID != network {
+		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkID, network)
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	if status.Genesis != genesis {
+		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.Genesis[:8], genesis[:8])
+	}
+	if err := forkFilter(status.ForkID); err != nil {
+		return errResp(ErrForkIDRejected, "%v", err)
+	}
+	return nil
+}
+
+// readStatusMsg reads the first message of the remote side, ensuring it's a
+// status message of acceptable size.
+func (p *peer) readStatusMsg() (p2p.Msg, error) {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	if msg.Code != StatusMsg {
+		return msg, errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return msg, errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	return msg, nil
+}
+
 // String implements fmt.Stringer.
 func (p *peer) String() string {
 	return fmt.Sprintf("Peer %s [%s]", p.id,
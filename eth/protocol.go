@@ -23,6 +23,7 @@ import (
 
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
 	"github.com/orangeAndSuns/go-ethereum/event"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
@@ -32,16 +33,20 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth64 = 64
+	eth65 = 65
+	eth66 = 66
+	eth67 = 67
 )
 
 // ProtocolName is the official short name of the protocol used during capability negotiation.
 var ProtocolName = "ess"
 
 // ProtocolVersions are the upported versions of the ess protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth67, eth66, eth65, eth64, eth63, eth62}
 
 // ProtocolLengths are the number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{21, 17, 17, 17, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,6 +67,17 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to ess/66
+	NewPooledTransactionHashesMsg = 0x08
+	GetPooledTransactionsMsg      = 0x09
+	PooledTransactionsMsg         = 0x0a
+
+	// Protocol messages belonging to ess/67
+	GetAccountRangeMsg  = 0x11
+	AccountRangeMsg     = 0x12
+	GetStorageRangesMsg = 0x13
+	StorageRangesMsg    = 0x14
 )
 
 type errCode int
@@ -76,6 +92,7 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrForkIDRejected
 )
 
 func (e errCode) String() string {
@@ -93,12 +110,17 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrForkIDRejected:          "Fork ID rejected",
 }
 
 type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// Get should return the transaction with the given hash if it is in the
+	// pool, or nil otherwise.
+	Get(hash common.Hash) *types.Transaction
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
@@ -108,8 +130,8 @@ type txPool interface {
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 }
 
-// statusData is the network packet for the status message.
-type statusData struct {
+// statusData63 is the network packet for the status message for ess/63.
+type statusData63 struct {
 	ProtocolVersion uint32
 	NetworkId       uint64
 	TD              *big.Int
@@ -117,6 +139,46 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// statusData is the network packet for the status message for ess/64 and later.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+}
+
+// responseMsgs maps the codes of request messages to the codes of the messages
+// answering them. Since ess/65, both carry a request ID.
+var responseMsgs = map[uint64]uint64{
+	GetBlockHeadersMsg: BlockHeadersMsg,
+	GetBlockBodiesMsg:  BlockBodiesMsg,
+	GetNodeDataMsg:     NodeDataMsg,
+	GetReceiptsMsg:     ReceiptsMsg,
+
+	GetPooledTransactionsMsg: PooledTransactionsMsg,
+	GetAccountRangeMsg:       AccountRangeMsg,
+	GetStorageRangesMsg:      StorageRangesMsg,
+}
+
+// isResponseMsg reports whether code is the code of a response message.
+func isResponseMsg(code uint64) bool {
+	for _, resp := range responseMsgs {
+		if resp == code {
+			return true
+		}
+	}
+	return false
+}
+
+// requestPacket65 is the network packet wrapping requests and responses since
+// ess/65. Data is the message content as sent in earlier protocol versions.
+type requestPacket65 struct {
+	RequestID uint64
+	Data      rlp.RawValue
+}
+
 // newBlockHashesData is the network packet for the block announcements.
 type newBlockHashesData []struct {
 	Hash   common.Hash // Hash of one particular block being announced
@@ -181,3 +243,43 @@ type blockBody struct {
 
 // blockBodiesData is the network packet for block content distribution.
 type blockBodiesData []*blockBody
+
+// getAccountRangeData represents an account range query.
+type getAccountRangeData struct {
+	Root   common.Hash // Root hash of the account trie to serve
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit at which to stop returning data
+}
+
+// accountRangeData is the network packet for account range responses.
+type accountRangeData struct {
+	Accounts []*accountData // Consecutive accounts of the requested range
+	Proof    [][]byte       // Merkle proofs of the range boundaries
+}
+
+// accountData represents a single account in an account range response.
+type accountData struct {
+	Hash common.Hash  // Hash of the account address
+	Body rlp.RawValue // RLP encoded state account
+}
+
+// getStorageRangesData represents a storage range query.
+type getStorageRangesData struct {
+	Root     common.Hash   // Root hash of the account trie to serve
+	Accounts []common.Hash // Hashes of the accounts whose storage to retrieve
+	Origin   common.Hash   // Hash of the first storage slot to retrieve (first account only)
+	Bytes    uint64        // Soft limit at which to stop returning data
+}
+
+// storageRangesData is the network packet for storage range responses.
+type storageRangesData struct {
+	Slots [][]*storageData // Consecutive storage slots of each served account
+	Proof [][]byte         // Merkle proofs of the last served range, if incomplete
+}
+
+// storageData represents a single storage slot in a storage range response.
+type storageData struct {
+	Hash common.Hash // Hash of the storage slot key
+	Body []byte      // RLP encoded storage slot value
+}
@@ -18,15 +18,22 @@ package ess
 
 import (
 	"fmt"
+	"math/big"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/forkid"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/eth/downloader"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
 	"github.com/orangeAndSuns/go-ethereum/p2p"
+	"github.com/orangeAndSuns/go-ethereum/params"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
 )
 
@@ -59,15 +66,15 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
 		},
 		{
-			code: StatusMsg, data: statusData{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash()},
+			code: StatusMsg, data: statusData63{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash()},
 			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", protocol),
 		},
 		{
-			code: StatusMsg, data: statusData{uint32(protocol), 999, td, head.Hash(), genesis.Hash()},
+			code: StatusMsg, data: statusData63{uint32(protocol), 999, td, head.Hash(), genesis.Hash()},
 			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
 		},
 		{
-			code: StatusMsg, data: statusData{uint32(protocol), DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}},
+			code: StatusMsg, data: statusData63{uint32(protocol), DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}},
 			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
 		},
 	}
@@ -92,9 +99,123 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+func TestStatusMsgErrors64(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	var (
+		genesis = pm.blockchain.Genesis()
+		head    = pm.blockchain.CurrentHeader()
+		td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
+		forkID  = forkid.NewIDFromChain(pm.blockchain)
+	)
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: TxMsg, data: []interface{}{},
+			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
+		},
+		{
+			code: StatusMsg, data: statusData{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", 64),
+		},
+		{
+			code: StatusMsg, data: statusData{64, 999, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
+		},
+		{
+			code: StatusMsg, data: statusData{64, DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData{64, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			wantError: errResp(ErrForkIDRejected, "%v", forkid.ErrLocalIncompatibleOrStale),
+		},
+	}
+
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", 64, pm, false)
+		// The send call might hang until reset because
+		// the protocol might not read the payload.
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down within 2 seconds")
+		}
+		p.close()
+	}
+}
+
+// This test checks that peers on incompatible forks are rejected during the
+// handshake, while peers which are merely out of sync are accepted.
+func TestCheckCompatibility(t *testing.T) {
+	var (
+		engine = ethash.NewFaker()
+
+		configNoFork  = &params.ChainConfig{HomesteadBlock: big.NewInt(1)}
+		configProFork = &params.ChainConfig{HomesteadBlock: big.NewInt(1), EIP150Block: big.NewInt(2)}
+
+		dbNoFork  = ethdb.NewMemDatabase()
+		dbProFork = ethdb.NewMemDatabase()
+
+		gspecNoFork  = &core.Genesis{Config: configNoFork}
+		gspecProFork = &core.Genesis{Config: configProFork}
+
+		genesisNoFork  = gspecNoFork.MustCommit(dbNoFork)
+		genesisProFork = gspecProFork.MustCommit(dbProFork)
+
+		chainNoFork, _  = core.NewBlockChain(dbNoFork, nil, configNoFork, engine, vm.Config{})
+		chainProFork, _ = core.NewBlockChain(dbProFork, nil, configProFork, engine, vm.Config{})
+
+		blocksNoFork, _  = core.GenerateChain(configNoFork, genesisNoFork, engine, dbNoFork, 2, nil)
+		blocksProFork, _ = core.GenerateChain(configProFork, genesisProFork, engine, dbProFork, 2, nil)
+	)
+	defer chainNoFork.Stop()
+	defer chainProFork.Stop()
+
+	check := func(local *core.BlockChain, remote *core.BlockChain, want error) {
+		t.Helper()
+
+		filter := forkid.NewFilter(local)
+		if err := filter(forkid.NewIDFromChain(remote)); err != want {
+			t.Errorf("fork ID validation mismatch: have %v, want %v", err, want)
+		}
+	}
+	// Both nodes are at genesis and agree on the chain
+	check(chainNoFork, chainProFork, nil)
+	check(chainProFork, chainNoFork, nil)
+
+	// Progress both chains into the Homestead fork, they still agree
+	chainNoFork.InsertChain(blocksNoFork[:1])
+	chainProFork.InsertChain(blocksProFork[:1])
+
+	check(chainNoFork, chainProFork, nil)
+	check(chainProFork, chainNoFork, nil)
+
+	// Progress the pro-fork chain past its extra fork, the nodes are now
+	// on incompatible chains and must reject each other
+	chainNoFork.InsertChain(blocksNoFork[1:2])
+	chainProFork.InsertChain(blocksProFork[1:2])
+
+	check(chainNoFork, chainProFork, forkid.ErrLocalIncompatibleOrStale)
+	check(chainProFork, chainNoFork, forkid.ErrRemoteStale)
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
+func TestRecvTransactions66(t *testing.T) { testRecvTransactions(t, 66) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -123,6 +244,8 @@ func testRecvTransactions(t *testing.T, protocol int) {
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
+func TestSendTransactions66(t *testing.T) { testSendTransactions(t, 66) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -178,6 +301,107 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that pooled transactions can be retrieved by hash, and that unknown
+// hashes are skipped in the reply.
+func TestGetPooledTransactions66(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	p, _ := newTestPeer("peer", eth66, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	txs := []*types.Transaction{newTestTransaction(testAccount, 0, 0), newTestTransaction(testAccount, 1, 0)}
+	pm.txpool.AddRemotes(txs)
+
+	hashes := []common.Hash{txs[0].Hash(), {0x01}, txs[1].Hash()}
+	if err := sendRequest(p.app, eth66, GetPooledTransactionsMsg, hashes); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	if err := expectResponse(p.app, eth66, PooledTransactionsMsg, txs); err != nil {
+		t.Errorf("pooled transactions mismatch: %v", err)
+	}
+}
+
+// Tests that announced transactions are retrieved from the announcing peer and
+// added to the pool.
+func TestTransactionAnnouncement66(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", eth66, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("failed to send announcement: %v", err)
+	}
+	// Wait for the retrieval request and answer it.
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	if msg.Code != GetPooledTransactionsMsg {
+		t.Fatalf("request code mismatch: have %x, want %x", msg.Code, GetPooledTransactionsMsg)
+	}
+	var packet requestPacket65
+	if err := msg.Decode(&packet); err != nil {
+		t.Fatalf("failed to decode request packet: %v", err)
+	}
+	var hashes []common.Hash
+	if err := rlp.DecodeBytes(packet.Data, &hashes); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != tx.Hash() {
+		t.Fatalf("requested hashes mismatch: have %x, want [%x]", hashes, tx.Hash())
+	}
+	enc, _ := rlp.EncodeToBytes([]*types.Transaction{tx})
+	if err := p2p.Send(p.app, PooledTransactionsMsg, &requestPacket65{RequestID: packet.RequestID, Data: enc}); err != nil {
+		t.Fatalf("failed to send reply: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added transactions mismatch: have %v, want [%x]", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("announced transaction not added to the pool")
+	}
+}
+
+// Tests that new transactions are sent in full only to a square root of the
+// peers, while the others are notified of their hashes.
+func TestBroadcastTransactions66(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	var peers []*testPeer
+	for i := 0; i < 4; i++ {
+		p, _ := newTestPeer(fmt.Sprintf("peer #%d", i), eth66, pm, true)
+		defer p.close()
+		peers = append(peers, p)
+	}
+	for start := time.Now(); pm.peers.Len() < len(peers); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("peers not registered")
+		}
+	}
+	tx := newTestTransaction(testAccount, 0, 0)
+	pm.BroadcastTxs(types.Transactions{tx})
+
+	codes := make(map[uint64]int)
+	for _, p := range peers {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("%v: read error: %v", p.Peer, err)
+		}
+		codes[msg.Code]++
+		msg.Discard()
+	}
+	if codes[TxMsg] != 2 || codes[NewPooledTransactionHashesMsg] != 2 {
+		t.Errorf("broadcast mismatch: have %d full and %d announced, want 2 and 2", codes[TxMsg], codes[NewPooledTransactionHashesMsg])
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
@@ -0,0 +1,166 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ess
+
+import (
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/eth/downloader"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+	"github.com/orangeAndSuns/go-ethereum/trie"
+)
+
+// rangeResponseLimit caps the requested size of a state range response at the
+// soft response limit.
+func rangeResponseLimit(bytes uint64) uint64 {
+	if bytes == 0 || bytes > softResponseLimit {
+		return softResponseLimit
+	}
+	return bytes
+}
+
+// serviceAccountRange gathers the accounts of the requested range along with
+// the Merkle proofs of its boundaries. The first account beyond the limit is
+// included too, proving that there are no more accounts up to the limit. If
+// the requested state is not available, an empty response is returned.
+func (pm *ProtocolManager) serviceAccountRange(req *getAccountRangeData) *accountRangeData {
+	resp := new(accountRangeData)
+
+	tr, err := trie.New(req.Root, pm.blockchain.StateCache().TrieDB())
+	if err != nil {
+		return resp
+	}
+	var (
+		limit = rangeResponseLimit(req.Bytes)
+		size  uint64
+	)
+	it := trie.NewIterator(tr.NodeIterator(req.Origin[:]))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		resp.Accounts = append(resp.Accounts, &accountData{Hash: hash, Body: common.CopyBytes(it.Value)})
+		size += uint64(common.HashLength + len(it.Value))
+
+		if hash.Big().Cmp(req.Limit.Big()) >= 0 || size >= limit {
+			break
+		}
+	}
+	if it.Err != nil {
+		log.Debug("Failed to iterate account range", "root", req.Root, "err", it.Err)
+		return new(accountRangeData)
+	}
+	last := req.Origin
+	if len(resp.Accounts) > 0 {
+		last = resp.Accounts[len(resp.Accounts)-1].Hash
+	}
+	resp.Proof = proveRange(tr, req.Origin, last)
+	return resp
+}
+
+// serviceStorageRanges gathers the storage slots of the requested accounts,
+// starting at the requested origin for the first one. Storage ranges are only
+// served in full, except for the last one, which is cut off at the size limit.
+// If the last range is incomplete or doesn't start at the beginning of the
+// storage trie, the Merkle proofs of its boundaries are included.
+//
+// At most downloader.MaxStorageRangeFetch accounts are served, and each range
+// counts against the size limit with its account hash, so that long lists of
+// accounts without storage are bounded too.
+func (pm *ProtocolManager) serviceStorageRanges(req *getStorageRangesData) *storageRangesData {
+	resp := new(storageRangesData)
+
+	triedb := pm.blockchain.StateCache().TrieDB()
+	accTrie, err := trie.New(req.Root, triedb)
+	if err != nil {
+		return resp
+	}
+	var (
+		limit    = rangeResponseLimit(req.Bytes)
+		size     uint64
+		accounts = req.Accounts
+	)
+	if len(accounts) > downloader.MaxStorageRangeFetch {
+		accounts = accounts[:downloader.MaxStorageRangeFetch]
+	}
+	for i, hash := range accounts {
+		if size >= limit {
+			break
+		}
+		var origin common.Hash
+		if i == 0 {
+			origin = req.Origin
+		}
+		// Open the storage trie of the account
+		blob, err := accTrie.TryGet(hash[:])
+		if err != nil || blob == nil {
+			break
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			break
+		}
+		stTrie, err := trie.New(account.Root, triedb)
+		if err != nil {
+			break
+		}
+		// Gather the slots until the end of the trie or the size limit
+		var (
+			slots   []*storageData
+			partial bool
+		)
+		it := trie.NewIterator(stTrie.NodeIterator(origin[:]))
+		for it.Next() {
+			if size >= limit {
+				partial = true
+				break
+			}
+			slots = append(slots, &storageData{Hash: common.BytesToHash(it.Key), Body: common.CopyBytes(it.Value)})
+			size += uint64(common.HashLength + len(it.Value))
+		}
+		if it.Err != nil {
+			break
+		}
+		resp.Slots = append(resp.Slots, slots)
+		size += common.HashLength
+
+		if partial || origin != (common.Hash{}) {
+			last := origin
+			if len(slots) > 0 {
+				last = slots[len(slots)-1].Hash
+			}
+			resp.Proof = proveRange(stTrie, origin, last)
+			break
+		}
+	}
+	return resp
+}
+
+// proveRange creates the Merkle proofs of the boundaries of a trie range.
+func proveRange(tr *trie.Trie, first, last common.Hash) [][]byte {
+	proofDb := ethdb.NewMemDatabase()
+	if err := tr.ProveRange(first[:], last[:], proofDb); err != nil {
+		log.Debug("Failed to prove range boundaries", "err", err)
+		return nil
+	}
+	var proof [][]byte
+	for _, key := range proofDb.Keys() {
+		node, _ := proofDb.Get(key)
+		proof = append(proof, node)
+	}
+	return proof
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ess
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// requestTimeout is the time after which an unanswered request is considered
+// lost. It matches the maximum request TTL of the downloader, so no response is
+// dropped while the downloader is still waiting for it.
+const requestTimeout = time.Minute
+
+var (
+	errUnknownRequest   = errors.New("unknown or expired request ID")
+	errResponseMismatch = errors.New("response type doesn't match request")
+)
+
+// requestTracker keeps track of the requests sent to a peer since ess/65, so
+// responses can be matched with the request they answer. Requests not answered
+// within the timeout are forgotten, and late responses to them are rejected.
+//
+// The IDs are chosen by the requester: the downloader passes its own down, so it
+// can match the delivered responses with its requests, the rest are random. The
+// tracker filters out unsolicited and late responses, and measures round trip
+// times for the peer's score.
+type requestTracker struct {
+	timeout time.Duration
+	lock    sync.Mutex
+	pending map[uint64]*pendingRequest
+	expired int // Number of timed out requests not yet reported
+}
+
+// pendingRequest is a request waiting for its response.
+type pendingRequest struct {
+	code uint64    // Code of the expected response message
+	sent time.Time // Time the request was sent
+}
+
+func newRequestTracker(timeout time.Duration) *requestTracker {
+	return &requestTracker{
+		timeout: timeout,
+		pending: make(map[uint64]*pendingRequest),
+	}
+}
+
+// track registers a new request with the given ID, expecting a response with the
+// given message code. It returns the number of requests that timed out since the
+// last call.
+func (t *requestTracker) track(id uint64, code uint64, now time.Time) (expired int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.expire(now)
+	expired, t.expired = t.expired, 0
+	t.pending[id] = &pendingRequest{code: code, sent: now}
+	return expired
+}
+
+// fulfil marks the request with the given ID as answered by a response with the
+// given message code, returning the round trip time of the request.
+func (t *requestTracker) fulfil(id uint64, code uint64, now time.Time) (time.Duration, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.expire(now)
+	req := t.pending[id]
+	if req == nil {
+		return 0, errUnknownRequest
+	}
+	if req.code != code {
+		return 0, errResponseMismatch
+	}
+	delete(t.pending, id)
+	return now.Sub(req.sent), nil
+}
+
+// expire drops all requests which have been pending for longer than the timeout,
+// counting them until they're reported by track.
+func (t *requestTracker) expire(now time.Time) {
+	for id, req := range t.pending {
+		if now.Sub(req.sent) > t.timeout {
+			delete(t.pending, id)
+			t.expired++
+		}
+	}
+}
+
+// genReqID generates a new random request ID for requests not issued by the
+// downloader. Zero is avoided, the downloader treats it as a response without ID.
+func genReqID() uint64 {
+	var rnd [8]byte
+	rand.Read(rnd[:])
+	if id := binary.BigEndian.Uint64(rnd[:]); id != 0 {
+		return id
+	}
+	return 1
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestTracker(t *testing.T) {
+	var (
+		tracker = newRequestTracker(time.Minute)
+		now     = time.Now()
+	)
+	id1, id2 := genReqID(), genReqID()
+	if expired := tracker.track(id1, BlockHeadersMsg, now); expired != 0 {
+		t.Fatalf("expired requests on first track: %d", expired)
+	}
+	tracker.track(id2, BlockBodiesMsg, now)
+	// Responses must match the ID and type of a pending request.
+	if _, err := tracker.fulfil(id2+1, BlockBodiesMsg, now); err != errUnknownRequest {
+		t.Errorf("unknown ID: have error %v, want %v", err, errUnknownRequest)
+	}
+	if _, err := tracker.fulfil(id2, BlockHeadersMsg, now); err != errResponseMismatch {
+		t.Errorf("wrong response type: have error %v, want %v", err, errResponseMismatch)
+	}
+	rtt, err := tracker.fulfil(id2, BlockBodiesMsg, now.Add(time.Second))
+	if err != nil || rtt != time.Second {
+		t.Errorf("valid response: have rtt %v, error %v, want rtt %v", rtt, err, time.Second)
+	}
+	if _, err := tracker.fulfil(id2, BlockBodiesMsg, now); err != errUnknownRequest {
+		t.Errorf("duplicate response: have error %v, want %v", err, errUnknownRequest)
+	}
+	// The first request times out and is reported on the next track.
+	later := now.Add(2 * time.Minute)
+	if expired := tracker.track(genReqID(), NodeDataMsg, later); expired != 1 {
+		t.Errorf("expired request count mismatch: have %d, want 1", expired)
+	}
+	if _, err := tracker.fulfil(id1, BlockHeadersMsg, later); err != errUnknownRequest {
+		t.Errorf("late response: have error %v, want %v", err, errUnknownRequest)
+	}
+	// Requests expired while handling a late response are reported too.
+	id3 := genReqID()
+	tracker.track(id3, ReceiptsMsg, later)
+	latest := later.Add(2 * time.Minute)
+	if _, err := tracker.fulfil(id3, ReceiptsMsg, latest); err != errUnknownRequest {
+		t.Errorf("late response: have error %v, want %v", err, errUnknownRequest)
+	}
+	if expired := tracker.track(genReqID(), NodeDataMsg, latest); expired != 2 {
+		t.Errorf("expired request count mismatch: have %d, want 2", expired)
+	}
+}
@@ -360,6 +360,39 @@ func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumb
 	return uint64(result), err
 }
 
+// AccountResult is the account state and merkle proof returned by GetProof.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the value and merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// GetProof returns the merkle proof of the given account and of the requested
+// storage keys, as specified by EIP-1186. The block number can be nil, in which
+// case the proof is taken from the latest known block.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []common.Hash, blockNumber *big.Int) (*AccountResult, error) {
+	storageKeys := make([]string, len(keys))
+	for i, key := range keys {
+		storageKeys[i] = key.Hex()
+	}
+	var result AccountResult
+	if err := ec.c.CallContext(ctx, &result, "eth_getProof", account, storageKeys, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // Filters
 
 // FilterLogs executes a filter query.
@@ -455,6 +488,52 @@ func (ec *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg)
 	return hex, nil
 }
 
+// OverrideAccount specifies the state of an account to be overridden before
+// executing a call. Zero-valued fields are left untouched. State replaces the
+// entire storage of the account, whereas StateDiff only patches single slots.
+type OverrideAccount struct {
+	Nonce     *uint64
+	Code      []byte
+	Balance   *big.Int
+	State     map[common.Hash]common.Hash
+	StateDiff map[common.Hash]common.Hash
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a OverrideAccount) MarshalJSON() ([]byte, error) {
+	type account struct {
+		Nonce     *hexutil.Uint64              `json:"nonce,omitempty"`
+		Code      *hexutil.Bytes               `json:"code,omitempty"`
+		Balance   *hexutil.Big                 `json:"balance,omitempty"`
+		State     *map[common.Hash]common.Hash `json:"state,omitempty"`
+		StateDiff map[common.Hash]common.Hash  `json:"stateDiff,omitempty"`
+	}
+	enc := account{
+		Nonce:     (*hexutil.Uint64)(a.Nonce),
+		Balance:   (*hexutil.Big)(a.Balance),
+		StateDiff: a.StateDiff,
+	}
+	if a.Code != nil {
+		enc.Code = (*hexutil.Bytes)(&a.Code)
+	}
+	// An empty, but non-nil storage replacement wipes the account storage
+	if a.State != nil {
+		enc.State = &a.State
+	}
+	return json.Marshal(enc)
+}
+
+// CallContractWithOverrides executes a message call transaction like CallContract,
+// but overrides the state of the given accounts before execution.
+func (ec *Client) CallContractWithOverrides(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int, overrides map[common.Address]OverrideAccount) ([]byte, error) {
+	var hex hexutil.Bytes
+	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber), overrides)
+	if err != nil {
+		return nil, err
+	}
+	return hex, nil
+}
+
 // SuggestGasPrice retrieves the currently suggested gas price to allow a timely
 // execution of a transaction.
 func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
@@ -478,6 +557,28 @@ func (ec *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64
 	return uint64(hex), nil
 }
 
+// EstimateGasAt tries to estimate the gas needed to execute a specific transaction
+// on top of the given block. The block number can be nil, in which case the
+// estimation runs against the pending block.
+func (ec *Client) EstimateGasAt(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (uint64, error) {
+	return ec.EstimateGasWithOverrides(ctx, msg, blockNumber, nil)
+}
+
+// EstimateGasWithOverrides estimates the gas needed to execute a transaction like
+// EstimateGasAt, but overrides the state of the given accounts before execution.
+func (ec *Client) EstimateGasWithOverrides(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int, overrides map[common.Address]OverrideAccount) (uint64, error) {
+	number := "pending"
+	if blockNumber != nil {
+		number = toBlockNumArg(blockNumber)
+	}
+	var hex hexutil.Uint64
+	err := ec.c.CallContext(ctx, &hex, "eth_estimateGas", toCallArg(msg), number, overrides)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
+
 // SendTransaction injects a signed transaction into the pending pool for execution.
 //
 // If the transaction was a contract creation use the TransactionReceipt method to get the
@@ -16,6 +16,8 @@
 
 package ethdb
 
+import "github.com/syndtr/goleveldb/leveldb/iterator"
+
 // Code using batches should try to add this much data to the batch.
 // The value was determined empirically.
 const IdealBatchSize = 100 * 1024
@@ -40,6 +42,51 @@ type Database interface {
 	NewBatch() Batch
 }
 
+// Iteratee wraps the NewIteratorWithPrefix method of a backing data store.
+type Iteratee interface {
+	// NewIteratorWithPrefix creates an iterator over the subset of database
+	// content with a particular key prefix, in ascending key order.
+	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
+}
+
+// AncientReader contains the methods required to read from immutable ancient
+// chain data, such as the one maintained by a freezer.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of ancient items in the ancient store.
+	Ancients() (uint64, error)
+
+	// AncientSize returns the ancient size of the specified category.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientWriter contains the methods required to write to immutable ancient
+// chain data.
+type AncientWriter interface {
+	// AppendAncient injects all binary blobs belong to block at the end of the
+	// append-only immutable table files.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient data from the ancient store.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all in-memory ancient store data to disk.
+	Sync() error
+}
+
+// AncientStore contains all the methods required to allow handling different
+// ancient data stores backing immutable chain data store.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
+}
+
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
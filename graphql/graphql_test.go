@@ -0,0 +1,407 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/accounts"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/common/math"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/internal/ethapi"
+	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rpc"
+)
+
+func TestBuildSchema(t *testing.T) {
+	// Make sure the schema can be parsed and matched up to the object model.
+	if _, err := newHandler(nil); err != nil {
+		t.Errorf("Could not construct GraphQL handler: %v", err)
+	}
+}
+
+func TestUnmarshalLong(t *testing.T) {
+	tests := []struct {
+		input interface{}
+		want  Long
+		fail  bool
+	}{
+		{input: int32(42), want: 42},
+		{input: float64(1 << 40), want: 1 << 40},
+		{input: "9007199254740993", want: 9007199254740993},
+		{input: float64(1.5), fail: true},
+		{input: "0x10", fail: true},
+		{input: true, fail: true},
+	}
+	for i, tt := range tests {
+		var l Long
+		err := l.UnmarshalGraphQL(tt.input)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected error for %v", i, tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		} else if l != tt.want {
+			t.Errorf("test %d: value mismatch: have %d, want %d", i, l, tt.want)
+		}
+	}
+}
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	// emitterAddr holds a contract logging 42 under topic 1 when called:
+	// PUSH1 42 PUSH1 0 MSTORE PUSH1 1 PUSH1 32 PUSH1 0 LOG1 STOP
+	emitterAddr = common.HexToAddress("0xe1")
+	emitterCode = common.FromHex("0x602a600052600160206000a100")
+
+	// numberAddr holds a contract returning the number of the block it executes in:
+	// NUMBER PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	numberAddr = common.HexToAddress("0xc0de")
+	numberCode = common.FromHex("0x4360005260206000f3")
+)
+
+// testBackend is a Backend serving the queries from a local chain. The methods
+// not needed by the resolvers under test are left unimplemented.
+type testBackend struct {
+	ethapi.Backend
+	db    ethdb.Database
+	chain *core.BlockChain
+}
+
+// newTestBackend creates a chain of two blocks, the first one holding a value
+// transfer and a call to the log emitting contract.
+func newTestBackend(t *testing.T) (*testBackend, types.Transactions) {
+	var (
+		db    = ethdb.NewMemDatabase()
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testAddress: {Balance: big.NewInt(params.Ether)},
+				emitterAddr: {Code: emitterCode, Balance: new(big.Int)},
+				numberAddr:  {Code: numberCode, Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+		txs     types.Transactions
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, block *core.BlockGen) {
+		if i != 0 {
+			return
+		}
+		transfer, _ := types.SignTx(types.NewTransaction(0, common.HexToAddress("0xdead"), big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+		call, _ := types.SignTx(types.NewTransaction(1, emitterAddr, new(big.Int), 100000, nil, nil), signer, testKey)
+		block.AddTx(transfer)
+		block.AddTx(call)
+		txs = types.Transactions{transfer, call}
+	})
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	return &testBackend{db: db, chain: chain}, txs
+}
+
+func (b *testBackend) ChainDb() ethdb.Database                           { return b.db }
+func (b *testBackend) ChainConfig() *params.ChainConfig                  { return b.chain.Config() }
+func (b *testBackend) CurrentBlock() *types.Block                        { return b.chain.CurrentBlock() }
+func (b *testBackend) AccountManager() *accounts.Manager                 { return accounts.NewManager() }
+func (b *testBackend) BloomStatus() (uint64, uint64)                     { return params.BloomBitsBlocks, 0 }
+func (b *testBackend) GetTd(hash common.Hash) *big.Int                   { return b.chain.GetTdByHash(hash) }
+func (b *testBackend) GetPoolTransaction(common.Hash) *types.Transaction { return nil }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number < 0 {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number < 0 {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	if number := rawdb.ReadHeaderNumber(b.db, hash); number != nil {
+		return rawdb.ReadReceipts(b.db, hash, *number), nil
+	}
+	return nil, nil
+}
+
+func (b *testBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	receipts, _ := b.GetReceipts(ctx, hash)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
+func (b *testBackend) GetTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	tx, blockHash, number, index := rawdb.ReadTransaction(b.db, hash)
+	return tx, blockHash, number, index, nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vmCfg), state.Error, nil
+}
+
+// query runs a GraphQL query through the HTTP handler and decodes the returned
+// data into result.
+func query(t *testing.T, handler http.Handler, query string, result interface{}) {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(string(body)))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	var resp struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("query %q: failed to decode response: %v", query, err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("query %q: failed: %v", query, resp.Errors)
+	}
+	if err := json.Unmarshal(resp.Data, result); err != nil {
+		t.Fatalf("query %q: failed to decode data: %v", query, err)
+	}
+}
+
+func TestQueryBlock(t *testing.T) {
+	backend, txs := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	block1 := backend.chain.GetBlockByNumber(1)
+	block2 := backend.chain.GetBlockByNumber(2)
+
+	// Look up a block by number, along with its transactions
+	var byNumber struct {
+		Block struct {
+			Number           int64
+			Hash             common.Hash
+			TransactionCount int
+			Transactions     []struct {
+				Hash  common.Hash
+				Index int
+				From  struct{ Address common.Address }
+				To    struct{ Address common.Address }
+				Value hexutil.Big
+			}
+		}
+	}
+	query(t, handler, `{ block(number: 1) { number hash transactionCount transactions { hash index from { address } to { address } value } } }`, &byNumber)
+
+	if byNumber.Block.Number != 1 || byNumber.Block.Hash != block1.Hash() {
+		t.Errorf("block mismatch: have #%d [%x], want #1 [%x]", byNumber.Block.Number, byNumber.Block.Hash, block1.Hash())
+	}
+	if byNumber.Block.TransactionCount != 2 || len(byNumber.Block.Transactions) != 2 {
+		t.Fatalf("transaction count mismatch: have %d/%d, want 2", byNumber.Block.TransactionCount, len(byNumber.Block.Transactions))
+	}
+	for i, tx := range byNumber.Block.Transactions {
+		if tx.Hash != txs[i].Hash() || tx.Index != i {
+			t.Errorf("transaction %d: mismatch: have %x at %d, want %x", i, tx.Hash, tx.Index, txs[i].Hash())
+		}
+		if tx.From.Address != testAddress || tx.To.Address != *txs[i].To() {
+			t.Errorf("transaction %d: accounts mismatch: have %x -> %x", i, tx.From.Address, tx.To.Address)
+		}
+		if tx.Value.ToInt().Cmp(txs[i].Value()) != 0 {
+			t.Errorf("transaction %d: value mismatch: have %v, want %v", i, tx.Value.ToInt(), txs[i].Value())
+		}
+	}
+	// Look up a block by hash, along with its parent
+	var byHash struct {
+		Block struct {
+			Number int64
+			Parent struct{ Hash common.Hash }
+		}
+	}
+	query(t, handler, `{ block(hash: "`+block2.Hash().Hex()+`") { number parent { hash } } }`, &byHash)
+
+	if byHash.Block.Number != 2 || byHash.Block.Parent.Hash != block1.Hash() {
+		t.Errorf("block mismatch: have #%d with parent %x, want #2 with parent %x", byHash.Block.Number, byHash.Block.Parent.Hash, block1.Hash())
+	}
+	// Unknown blocks are reported as null
+	var unknown struct{ Block *struct{ Number int64 } }
+	query(t, handler, `{ block(number: 100) { number } }`, &unknown)
+
+	if unknown.Block != nil {
+		t.Errorf("unknown block found: #%d", unknown.Block.Number)
+	}
+}
+
+func TestQueryTransaction(t *testing.T) {
+	backend, txs := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var result struct {
+		Transaction struct {
+			Hash    common.Hash
+			Nonce   int64
+			Index   int
+			Status  int
+			GasUsed int64
+			Block   struct{ Number int64 }
+			Logs    []struct {
+				Topics  []common.Hash
+				Data    hexutil.Bytes
+				Account struct{ Address common.Address }
+			}
+		}
+	}
+	query(t, handler, `{ transaction(hash: "`+txs[1].Hash().Hex()+`") { hash nonce index status gasUsed block { number } logs { topics data account { address } } } }`, &result)
+
+	tx := result.Transaction
+	if tx.Hash != txs[1].Hash() || tx.Nonce != 1 || tx.Index != 1 || tx.Block.Number != 1 {
+		t.Errorf("transaction mismatch: have %x with nonce %d at #%d/%d", tx.Hash, tx.Nonce, tx.Block.Number, tx.Index)
+	}
+	if tx.Status != 1 || tx.GasUsed <= int64(params.TxGas) {
+		t.Errorf("receipt mismatch: have status %d, gas used %d", tx.Status, tx.GasUsed)
+	}
+	if len(tx.Logs) != 1 {
+		t.Fatalf("log count mismatch: have %d, want 1", len(tx.Logs))
+	}
+	if log := tx.Logs[0]; log.Account.Address != emitterAddr || len(log.Topics) != 1 || log.Topics[0] != common.BigToHash(common.Big1) || new(big.Int).SetBytes(log.Data).Int64() != 42 {
+		t.Errorf("log mismatch: have %x %x %x", log.Account.Address, log.Topics, log.Data)
+	}
+	// Unknown transactions are reported as null
+	var unknown struct{ Transaction *struct{ Hash common.Hash } }
+	query(t, handler, `{ transaction(hash: "0x0000000000000000000000000000000000000000000000000000000000000001") { hash } }`, &unknown)
+
+	if unknown.Transaction != nil {
+		t.Errorf("unknown transaction found: %x", unknown.Transaction.Hash)
+	}
+}
+
+func TestQueryLogs(t *testing.T) {
+	backend, txs := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var result struct {
+		Logs []struct {
+			Topics      []common.Hash
+			Transaction struct{ Hash common.Hash }
+		}
+		Block struct {
+			Logs []struct{ Index int }
+		}
+	}
+	query(t, handler, `{
+		logs(filter: { fromBlock: 0, toBlock: 2, addresses: ["`+emitterAddr.Hex()+`"] }) { topics transaction { hash } }
+		block(number: 1) { logs(filter: { topics: [["`+common.BigToHash(common.Big1).Hex()+`"]] }) { index } }
+	}`, &result)
+
+	if len(result.Logs) != 1 || result.Logs[0].Transaction.Hash != txs[1].Hash() {
+		t.Errorf("range logs mismatch: have %v, want one log of %x", result.Logs, txs[1].Hash())
+	}
+	if len(result.Block.Logs) != 1 || result.Block.Logs[0].Index != 0 {
+		t.Errorf("block logs mismatch: have %v, want one log", result.Block.Logs)
+	}
+}
+
+func TestQueryCall(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var result struct {
+		Latest struct {
+			Call struct {
+				Data   hexutil.Bytes
+				Status int
+			}
+		}
+		First struct {
+			Call struct{ Data hexutil.Bytes }
+		}
+	}
+	query(t, handler, `{
+		latest: block { call(data: { to: "`+numberAddr.Hex()+`" }) { data status } }
+		first: block(number: 1) { call(data: { to: "`+numberAddr.Hex()+`" }) { data } }
+	}`, &result)
+
+	if result.Latest.Call.Status != 1 {
+		t.Errorf("call status mismatch: have %d, want 1", result.Latest.Call.Status)
+	}
+	if number := new(big.Int).SetBytes(result.Latest.Call.Data); number.Int64() != 2 {
+		t.Errorf("latest call result mismatch: have %v, want 2", number)
+	}
+	if number := new(big.Int).SetBytes(result.First.Call.Data); number.Int64() != 1 {
+		t.Errorf("numbered call result mismatch: have %v, want 1", number)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/orangeAndSuns/go-ethereum/eth"
+	"github.com/orangeAndSuns/go-ethereum/internal/ethapi"
+	"github.com/orangeAndSuns/go-ethereum/les"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/node"
+	"github.com/orangeAndSuns/go-ethereum/p2p"
+	"github.com/orangeAndSuns/go-ethereum/rpc"
+)
+
+// Service encapsulates a GraphQL service.
+type Service struct {
+	endpoint string         // The host:port endpoint for this service.
+	cors     []string       // Allowed CORS domains
+	vhosts   []string       // Recognised vhosts
+	backend  ethapi.Backend // The backend that queries will operate on.
+	handler  http.Handler   // The `http.Handler` used to answer queries.
+	listener net.Listener   // The listening socket.
+}
+
+// New constructs a new GraphQL service instance.
+func New(backend ethapi.Backend, endpoint string, cors, vhosts []string) (*Service, error) {
+	return &Service{
+		endpoint: endpoint,
+		cors:     cors,
+		vhosts:   vhosts,
+		backend:  backend,
+	}, nil
+}
+
+// Protocols returns the list of protocols exported by this service.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns the list of APIs exported by this service.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start is called after all services have been constructed and the networking
+// layer was also initialized to spawn any goroutines required by the service.
+func (s *Service) Start(server *p2p.Server) error {
+	var err error
+	s.handler, err = newHandler(s.backend)
+	if err != nil {
+		return err
+	}
+	if s.listener, err = net.Listen("tcp", s.endpoint); err != nil {
+		return err
+	}
+	go (&http.Server{
+		Handler:      rpc.NewHTTPHandlerStack(s.handler, s.cors, s.vhosts),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}).Serve(s.listener)
+	log.Info("GraphQL endpoint opened", "url", fmt.Sprintf("http://%s", s.endpoint))
+	return nil
+}
+
+// newHandler returns a new `http.Handler` that will answer GraphQL queries.
+func newHandler(backend ethapi.Backend) (http.Handler, error) {
+	q := Resolver{backend: backend}
+
+	s, err := graphql.ParseSchema(schema, &q)
+	if err != nil {
+		return nil, err
+	}
+	return &relay.Handler{Schema: s}, nil
+}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (s *Service) Stop() error {
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+		log.Info("GraphQL endpoint closed", "url", fmt.Sprintf("http://%s", s.endpoint))
+	}
+	return nil
+}
+
+// RegisterGraphQLService is a utility function to construct a new service and
+// register it against a node.
+func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []string) error {
+	return stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		// Try to construct the GraphQL service backed by a full node
+		var ethServ *ess.Essentia
+		if err := ctx.Service(&ethServ); err == nil {
+			return New(ethServ.APIBackend, endpoint, cors, vhosts)
+		}
+		// Try to construct the GraphQL service backed by a light node
+		var lesServ *les.LightEthereum
+		if err := ctx.Service(&lesServ); err == nil {
+			return New(lesServ.ApiBackend, endpoint, cors, vhosts)
+		}
+		// Well, this should not have happened, bail out
+		return nil, errors.New("no Essentia service")
+	})
+}
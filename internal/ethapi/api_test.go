@@ -0,0 +1,162 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi_test
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/accounts"
+	"github.com/orangeAndSuns/go-ethereum/accounts/abi"
+	"github.com/orangeAndSuns/go-ethereum/accounts/abi/bind"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/common/math"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/ethclient"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/internal/ethapi"
+	"github.com/orangeAndSuns/go-ethereum/params"
+	"github.com/orangeAndSuns/go-ethereum/rpc"
+)
+
+var (
+	// revertAddr holds a contract reverting every call with the Solidity reason
+	// "test reason", its code returning the revertData following it.
+	revertAddr = common.HexToAddress("0x0bad")
+	revertCode = common.FromHex("0x6064600c60003960646000fd" + revertData[2:])
+	revertData = "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000b" +
+		"7465737420726561736f6e000000000000000000000000000000000000000000"
+
+	revertABI = `[{"constant":true,"inputs":[],"name":"fail","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
+)
+
+// testBackend is a Backend serving calls from a local chain. The methods not
+// needed by the tests are left unimplemented.
+type testBackend struct {
+	ethapi.Backend
+	chain *core.BlockChain
+}
+
+func newTestBackend(t *testing.T, alloc core.GenesisAlloc) *testBackend {
+	var (
+		db    = ethdb.NewMemDatabase()
+		gspec = &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	)
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return &testBackend{chain: chain}
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig  { return b.chain.Config() }
+func (b *testBackend) AccountManager() *accounts.Manager { return accounts.NewManager() }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	if blockNr < 0 {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(blockNr)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, blockNr)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vmCfg), func() error { return nil }, nil
+}
+
+// Tests that reverted calls and gas estimations return the revert reason in the
+// JSON-RPC error message, and the raw returned data in the error data.
+func TestCallRevert(t *testing.T) {
+	backend := newTestBackend(t, core.GenesisAlloc{revertAddr: {Code: revertCode, Balance: new(big.Int)}})
+	defer backend.chain.Stop()
+
+	// The API is exposed in the eth namespace too, as used by ethclient
+	server := rpc.NewServer()
+	defer server.Stop()
+	for _, namespace := range []string{"ess", "eth"} {
+		if err := server.RegisterName(namespace, ethapi.NewPublicBlockChainAPI(backend)); err != nil {
+			t.Fatalf("failed to register API: %v", err)
+		}
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	args := map[string]interface{}{"to": revertAddr}
+	for _, method := range []string{"ess_call", "ess_estimateGas"} {
+		var (
+			result interface{}
+			err    error
+		)
+		if method == "ess_call" {
+			err = client.Call(&result, method, args, "latest")
+		} else {
+			err = client.Call(&result, method, args)
+		}
+		if err == nil {
+			t.Fatalf("%s: reverted call succeeded: %v", method, result)
+		}
+		if have, want := err.Error(), "execution reverted: test reason"; !strings.HasSuffix(have, want) {
+			t.Errorf("%s: error message mismatch: have %q, want suffix %q", method, have, want)
+		}
+		if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != 3 {
+			t.Errorf("%s: error code mismatch: have %v, want 3", method, err)
+		}
+		if dataErr, ok := err.(rpc.DataError); !ok || dataErr.ErrorData() != revertData {
+			t.Errorf("%s: error data mismatch: have %v, want %s", method, err, revertData)
+		}
+	}
+	// Bound contracts should unwrap the JSON-RPC error into a revert error
+	parsed, err := abi.JSON(strings.NewReader(revertABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	contract := bind.NewBoundContract(revertAddr, parsed, ethclient.NewClient(client), nil, nil)
+
+	var out *big.Int
+	err = contract.Call(nil, &out, "fail")
+	revertErr, ok := err.(*bind.RevertError)
+	if !ok {
+		t.Fatalf("bound call error type mismatch: have %T (%v), want *bind.RevertError", err, err)
+	}
+	if revertErr.Reason != "test reason" {
+		t.Errorf("bound call revert reason mismatch: have %q, want %q", revertErr.Reason, "test reason")
+	}
+	if data := hexutil.Encode(revertErr.Data); data != revertData {
+		t.Errorf("bound call revert data mismatch: have %s, want %s", data, revertData)
+	}
+}
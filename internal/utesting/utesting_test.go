@@ -0,0 +1,85 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package utesting
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	tests := []Test{
+		{
+			Name: "successful test",
+			Fn:   func(t *T) {},
+		},
+		{
+			Name: "failing test",
+			Fn: func(t *T) {
+				t.Log("output")
+				t.Error("failed")
+			},
+		},
+		{
+			Name: "fatal test",
+			Fn: func(t *T) {
+				t.Fatal("fatal")
+				t.Log("unreachable")
+			},
+		},
+		{
+			Name: "panicking test",
+			Fn: func(t *T) {
+				panic("oh no")
+			},
+		},
+	}
+	report := new(bytes.Buffer)
+	results := RunTests(tests, report)
+
+	if want := 3; CountFailures(results) != want {
+		t.Fatalf("wrong failure count %d, want %d", CountFailures(results), want)
+	}
+	for i, failed := range []bool{false, true, true, true} {
+		if results[i].Failed != failed {
+			t.Errorf("test %q: failed %v, want %v", results[i].Name, results[i].Failed, failed)
+		}
+	}
+	if results[1].Output != "output\nfailed\n" {
+		t.Errorf("wrong output of failing test: %q", results[1].Output)
+	}
+	if strings.Contains(results[2].Output, "unreachable") {
+		t.Error("fatal test continued after Fatal")
+	}
+	if !strings.Contains(results[3].Output, "panic: oh no") {
+		t.Errorf("panic not reported in output: %q", results[3].Output)
+	}
+	if !strings.Contains(report.String(), "-- FAIL failing test") || !strings.Contains(report.String(), "-- OK successful test") {
+		t.Errorf("wrong report:\n%s", report)
+	}
+}
+
+func TestMatchTests(t *testing.T) {
+	tests := []Test{{Name: "Status/ess64"}, {Name: "Status/ess65"}, {Name: "GetBlockHeaders/ess65"}}
+	if matched := MatchTests(tests, "ess65"); len(matched) != 2 {
+		t.Errorf("wrong number of matched tests: %d", len(matched))
+	}
+	if matched := MatchTests(tests, ""); len(matched) != len(tests) {
+		t.Errorf("empty expression didn't match all tests")
+	}
+}
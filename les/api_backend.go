@@ -101,6 +101,10 @@ func (b *LesApiBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*typ
 	return nil, nil
 }
 
+func (b *LesApiBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	return light.GetTransaction(ctx, b.ess.odr, txHash)
+}
+
 func (b *LesApiBackend) GetTd(hash common.Hash) *big.Int {
 	return b.ess.blockchain.GetTdByHash(hash)
 }
@@ -194,7 +194,9 @@ func (f *lightFetcher) syncLoop() {
 			}
 			f.reqMu.Unlock()
 			if ok {
-				f.pm.serverPool.adjustResponseTime(req.peer.poolEntry, time.Duration(mclock.Now()-req.sent), req.timeout)
+				rtt := time.Duration(mclock.Now() - req.sent)
+				f.pm.serverPool.adjustResponseTime(req.peer.poolEntry, rtt, req.timeout)
+				req.peer.ReportLatency(rtt)
 			}
 			f.lock.Lock()
 			if !ok || !(f.syncing || f.processResponse(req, resp)) {
@@ -213,6 +213,12 @@ func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	lpeer.hasBlock = func(common.Hash, uint64) bool { return true }
 	lpeer.lock.Unlock()
 	test(5)
+	// the round trips of the answered requests should be reported to the peer score
+	for start := time.Now(); lpeer.Stats().Latency == 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("request round trip times not reported")
+		}
+	}
 	// still expect all retrievals to pass, now data should be cached locally
 	peers.Unregister(lpeer.id)
 	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
@@ -27,10 +27,9 @@ import (
 	"math/big"
 
 	"github.com/orangeAndSuns/go-ethereum/common"
-	"github.com/orangeAndSuns/go-ethereum/core"
-	"github.com/orangeAndSuns/go-ethereum/core/rawdb"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/crypto/secp256k1"
+	"github.com/orangeAndSuns/go-ethereum/light"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
 )
 
@@ -222,8 +221,5 @@ type CodeData []struct {
 
 type proofsData [][]rlp.RawValue
 
-type txStatus struct {
-	Status core.TxStatus
-	Lookup *rawdb.TxLookupEntry `rlp:"nil"`
-	Error  string
-}
+// txStatus is the network response entry for a transaction status query.
+type txStatus = light.TxStatus
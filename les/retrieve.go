@@ -316,10 +316,14 @@ func (r *sentReq) tryRequest() {
 	defer func() {
 		// send feedback to server pool and remove peer if hard timeout happened
 		pp, ok := p.(*peer)
+		respTime := time.Duration(mclock.Now() - reqSent)
 		if ok && r.rm.serverPool != nil {
-			respTime := time.Duration(mclock.Now() - reqSent)
 			r.rm.serverPool.adjustResponseTime(pp.poolEntry, respTime, srto)
 		}
+		// feed the round trip time of answered requests into the peer score
+		if ok && !hrto {
+			pp.ReportLatency(respTime)
+		}
 		if hrto {
 			pp.Log().Debug("Request timed out hard")
 			if r.rm.peers != nil {
@@ -170,3 +170,26 @@ func (req *BloomRequest) StoreResult(db ethdb.Database) {
 		rawdb.WriteBloomBits(db, req.BitIdx, sectionIdx, sectionHead, req.BloomBits[i])
 	}
 }
+
+// TxStatus describes the status of a transaction as reported by a server.
+type TxStatus struct {
+	Status core.TxStatus
+	Lookup *rawdb.TxLookupEntry `rlp:"nil"`
+	Error  string
+}
+
+// TxStatusRequest is the ODR request type for retrieving transaction status
+type TxStatusRequest struct {
+	OdrRequest
+	Hashes []common.Hash
+	Status []TxStatus
+}
+
+// StoreResult stores the positions of the included transactions in local database
+func (req *TxStatusRequest) StoreResult(db ethdb.Database) {
+	for i, status := range req.Status {
+		if status.Status == core.TxStatusIncluded && status.Lookup != nil {
+			rawdb.WriteTxLookupEntry(db, req.Hashes[i], *status.Lookup)
+		}
+	}
+}
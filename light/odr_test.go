@@ -87,6 +87,16 @@ func (odr *testOdr) Retrieve(ctx context.Context, req OdrRequest) error {
 		req.Proof = nodes
 	case *CodeRequest:
 		req.Data, _ = odr.sdb.Get(req.Hash[:])
+	case *TxStatusRequest:
+		req.Status = make([]TxStatus, len(req.Hashes))
+		for i, hash := range req.Hashes {
+			if blockHash, number, index := rawdb.ReadTxLookupEntry(odr.sdb, hash); blockHash != (common.Hash{}) {
+				req.Status[i] = TxStatus{
+					Status: core.TxStatusIncluded,
+					Lookup: &rawdb.TxLookupEntry{BlockHash: blockHash, BlockIndex: number, Index: index},
+				}
+			}
+		}
 	}
 	req.StoreResult(odr.ldb)
 	return nil
@@ -132,6 +142,40 @@ func odrGetReceipts(ctx context.Context, db ethdb.Database, bc *core.BlockChain,
 	return rlp, nil
 }
 
+func TestOdrGetTransactionsLes2(t *testing.T) { testChainOdr(t, 2, odrGetTransactions) }
+
+func odrGetTransactions(ctx context.Context, db ethdb.Database, bc *core.BlockChain, lc *LightChain, bhash common.Hash) ([]byte, error) {
+	var block *types.Block
+	if bc != nil {
+		block = bc.GetBlockByHash(bhash)
+	} else {
+		block, _ = lc.GetBlockByHash(ctx, bhash)
+	}
+	if block == nil {
+		return nil, nil
+	}
+	var res []interface{}
+	for _, tx := range block.Transactions() {
+		var (
+			found         *types.Transaction
+			hash          common.Hash
+			number, index uint64
+			err           error
+		)
+		if bc != nil {
+			found, hash, number, index = rawdb.ReadTransaction(db, tx.Hash())
+		} else {
+			found, hash, number, index, err = GetTransaction(ctx, lc.Odr(), tx.Hash())
+		}
+		if err != nil || found == nil {
+			return nil, err
+		}
+		res = append(res, found, hash, number, index)
+	}
+	rlp, _ := rlp.EncodeToBytes(res)
+	return rlp, nil
+}
+
 func TestOdrAccountsLes1(t *testing.T) { testChainOdr(t, 1, odrAccounts) }
 
 func odrAccounts(ctx context.Context, db ethdb.Database, bc *core.BlockChain, lc *LightChain, bhash common.Hash) ([]byte, error) {
@@ -124,6 +124,41 @@ func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint
 	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles), nil
 }
 
+// GetTransaction retrieves a canonical transaction by hash along with its
+// position in the chain, asking the network for the transaction status if the
+// position isn't known locally.
+func GetTransaction(ctx context.Context, odr OdrBackend, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	db := odr.Database()
+	blockHash, number, index := rawdb.ReadTxLookupEntry(db, txHash)
+	if blockHash == (common.Hash{}) || rawdb.ReadCanonicalHash(db, number) != blockHash {
+		r := &TxStatusRequest{Hashes: []common.Hash{txHash}}
+		if err := odr.Retrieve(ctx, r); err != nil {
+			return nil, common.Hash{}, 0, 0, err
+		}
+		if r.Status[0].Status != core.TxStatusIncluded || r.Status[0].Lookup == nil {
+			return nil, common.Hash{}, 0, 0, nil
+		}
+		blockHash, number, index = r.Status[0].Lookup.BlockHash, r.Status[0].Lookup.BlockIndex, r.Status[0].Lookup.Index
+	}
+	// The status isn't proven, make sure the block is canonical and contains the
+	// transaction at the reported position
+	header, err := GetHeaderByNumber(ctx, odr, number)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if header.Hash() != blockHash {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	body, err := GetBody(ctx, odr, blockHash, number)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if uint64(len(body.Transactions)) <= index || body.Transactions[index].Hash() != txHash {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	return body.Transactions[index], blockHash, number, index, nil
+}
+
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, error) {
@@ -0,0 +1,246 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/consensus"
+	"github.com/orangeAndSuns/go-ethereum/consensus/misc"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/log"
+)
+
+var (
+	errNotSealed   = errors.New("consensus engine didn't seal the block")
+	errNoOverride  = errors.New("no state override given")
+	errHeadChanged = errors.New("chain head changed since the next block timestamp was set")
+)
+
+// stateOverrideMarker prefixes the extra-data of the blocks produced by
+// MineStateOverride.
+var stateOverrideMarker = []byte("dev-state-override")
+
+// instantSealer is implemented by consensus engines able to seal a block right
+// away, regardless of the rules throttling block production on a live network.
+type instantSealer interface {
+	SealNow(chain consensus.ChainReader, block *types.Block) (*types.Block, error)
+}
+
+// IsStateOverride reports whether a block was produced by MineStateOverride. The
+// state root of such blocks doesn't follow from their transactions, so they fail
+// verification on any other node and the chain can't be imported past them.
+func IsStateOverride(header *types.Header) bool {
+	return bytes.HasPrefix(header.Extra, stateOverrideMarker)
+}
+
+// MineBlock produces a block on top of the current head right away, including
+// the pending transactions, and inserts it into the chain.
+//
+// Blocks are produced on demand for developer chains, where the sealing rules of
+// the consensus engine are of no concern.
+func (self *Miner) MineBlock() (*types.Block, error) {
+	return self.worker.mineBlock(nil)
+}
+
+// MineStateOverride produces an empty block on top of the current head right
+// away, letting modify alter its state, and inserts it into the chain.
+//
+// The state changes aren't backed by any transaction, so the block can't be
+// verified by re-executing it. It is flagged in its extra-data (see
+// IsStateOverride) and only meant for ephemeral, single node developer chains.
+func (self *Miner) MineStateOverride(modify func(*state.StateDB)) (*types.Block, error) {
+	if modify == nil {
+		return nil, errNoOverride
+	}
+	return self.worker.mineBlock(modify)
+}
+
+// SetNextTimestamp sets the timestamp of the next block produced on demand. It
+// must be acceptable on top of the current head, and is dropped if the head
+// changes before the block is produced.
+func (self *Miner) SetNextTimestamp(timestamp uint64) error {
+	return self.worker.setNextTimestamp(timestamp)
+}
+
+// SetInstantSealing switches the miner to producing a block as soon as new
+// transactions arrive, instead of handing the work to the sealing agents.
+func (self *Miner) SetInstantSealing(instant bool) {
+	self.worker.setInstant(instant)
+}
+
+// Rewind sets the head of the chain back to the given block, discarding all
+// blocks above it.
+func (self *Miner) Rewind(number uint64, hash common.Hash) error {
+	return self.worker.rewind(number, hash)
+}
+
+// minTimestamp returns the earliest timestamp the consensus rules accept for a
+// block on top of parent.
+func (self *worker) minTimestamp(parent *types.Block) uint64 {
+	if self.config.Clique != nil {
+		return parent.Time().Uint64() + self.config.Clique.Period
+	}
+	return parent.Time().Uint64() + 1
+}
+
+func (self *worker) setNextTimestamp(timestamp uint64) error {
+	head := self.chain.CurrentBlock()
+	if min := self.minTimestamp(head); timestamp < min {
+		return fmt.Errorf("timestamp %d before the earliest valid one %d on top of block %d", timestamp, min, head.NumberU64())
+	}
+	self.timestampMu.Lock()
+	defer self.timestampMu.Unlock()
+
+	self.nextTimestamp, self.nextParent = timestamp, head.Hash()
+	return nil
+}
+
+// clearNextTimestamp drops the timestamp requested for the next block unless it
+// was requested on top of head.
+func (self *worker) clearNextTimestamp(head common.Hash) {
+	self.timestampMu.Lock()
+	defer self.timestampMu.Unlock()
+
+	if self.nextTimestamp != 0 && self.nextParent != head {
+		log.Debug("Dropping next block timestamp, chain head changed", "timestamp", self.nextTimestamp)
+		self.nextTimestamp, self.nextParent = 0, common.Hash{}
+	}
+}
+
+func (self *worker) setInstant(instant bool) {
+	if instant {
+		atomic.StoreInt32(&self.instant, 1)
+	} else {
+		atomic.StoreInt32(&self.instant, 0)
+	}
+}
+
+// mineBlock implements MineBlock, and MineStateOverride if modify is non-nil.
+func (self *worker) mineBlock(modify func(*state.StateDB)) (*types.Block, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	parent := self.chain.CurrentBlock()
+
+	// Make sure any requested timestamp is still valid on top of the head
+	self.timestampMu.Lock()
+	next, nextParent := self.nextTimestamp, self.nextParent
+	self.timestampMu.Unlock()
+
+	if next != 0 {
+		if nextParent != parent.Hash() {
+			self.clearNextTimestamp(parent.Hash())
+			return nil, errHeadChanged
+		}
+		if min := self.minTimestamp(parent); next < min {
+			self.clearNextTimestamp(common.Hash{})
+			return nil, fmt.Errorf("next block timestamp %d before the earliest valid one %d", next, min)
+		}
+	}
+	tstamp := time.Now().Unix()
+	if min := int64(self.minTimestamp(parent)); tstamp < min {
+		tstamp = min
+	}
+	header, err := self.prepareHeader(parent, tstamp, self.coinbase)
+	if err != nil {
+		return nil, err
+	}
+	// Some engines pick the timestamp on their own, the requested one overrides it
+	if next != 0 {
+		header.Time = new(big.Int).SetUint64(next)
+	}
+	if modify != nil {
+		extra := make([]byte, len(stateOverrideMarker))
+		if len(header.Extra) > len(extra) {
+			extra = common.CopyBytes(header.Extra)
+		}
+		copy(extra, stateOverrideMarker)
+		header.Extra = extra
+	}
+	work, err := self.makeWork(parent, header)
+	if err != nil {
+		return nil, err
+	}
+	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
+		misc.ApplyDAOHardFork(work.state)
+	}
+	// Overrides are applied on their own, pending transactions wait for a regular block
+	if modify != nil {
+		modify(work.state)
+	} else {
+		pending, err := self.ess.TxPool().Pending()
+		if err != nil {
+			return nil, err
+		}
+		work.commitTransactions(self.mux, self.ordering.Order(work.signer, pending), self.chain, self.coinbase, nil)
+	}
+	block, err := self.engine.Finalize(self.chain, header, work.state, work.txs, nil, work.receipts)
+	if err != nil {
+		return nil, err
+	}
+	var sealed *types.Block
+	if sealer, ok := self.engine.(instantSealer); ok {
+		sealed, err = sealer.SealNow(self.chain, block)
+	} else {
+		sealed, err = self.engine.Seal(self.chain, block, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sealed == nil {
+		return nil, errNotSealed
+	}
+	work.Block = sealed
+	if err := self.writeBlock(work, sealed); err != nil {
+		return nil, err
+	}
+	self.clearNextTimestamp(sealed.Hash())
+
+	if modify != nil {
+		log.Warn("Produced unverifiable state override block", "number", sealed.Number(), "hash", sealed.Hash())
+	} else {
+		log.Info("Produced block on demand", "number", sealed.Number(), "hash", sealed.Hash(), "txs", work.tcount)
+	}
+	return sealed, nil
+}
+
+// rewind implements Rewind.
+func (self *worker) rewind(number uint64, hash common.Hash) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if header := self.chain.GetHeaderByNumber(number); header == nil || header.Hash() != hash {
+		return fmt.Errorf("block %d (%x) not in the canonical chain", number, hash)
+	}
+	if err := self.chain.SetHead(number); err != nil {
+		return err
+	}
+	// Let the transaction pool and the pending block catch up with the new head
+	head := self.chain.CurrentBlock()
+	self.clearNextTimestamp(head.Hash())
+	self.chain.PostChainEvents([]interface{}{core.ChainHeadEvent{Block: head}}, nil)
+	return nil
+}
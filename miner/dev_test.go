@@ -0,0 +1,311 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/state"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/event"
+	"github.com/orangeAndSuns/go-ethereum/params"
+)
+
+func newTestTransaction(t *testing.T, nonce uint64) *types.Transaction {
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{2}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testBankKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// Tests that blocks produced on demand include the pending transactions and
+// honour the requested timestamp.
+func TestMineBlock(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+
+	// Empty blocks are produced too
+	block, err := w.mineBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to mine empty block: %v", err)
+	}
+	if block.NumberU64() != 1 || len(block.Transactions()) != 0 {
+		t.Fatalf("wrong first block: number %d, %d txs", block.NumberU64(), len(block.Transactions()))
+	}
+	// Pending transactions get included at the requested time
+	tx := newTestTransaction(t, 0)
+	if err := backend.txPool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	timestamp := block.Time().Uint64() + 1000
+	for _, ts := range []uint64{0, block.Time().Uint64() - 1, block.Time().Uint64()} {
+		if err := w.setNextTimestamp(ts); err == nil {
+			t.Fatalf("timestamp %d accepted on top of head with timestamp %v", ts, block.Time())
+		}
+	}
+	if err := w.setNextTimestamp(timestamp); err != nil {
+		t.Fatalf("failed to set next timestamp: %v", err)
+	}
+	if block, err = w.mineBlock(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if len(block.Transactions()) != 1 || block.Transactions()[0].Hash() != tx.Hash() {
+		t.Errorf("pending transaction not included")
+	}
+	if block.Time().Uint64() != timestamp {
+		t.Errorf("wrong timestamp %v, want %d", block.Time(), timestamp)
+	}
+	if backend.chain.CurrentBlock().Hash() != block.Hash() {
+		t.Errorf("produced block is not the head")
+	}
+	// The requested timestamp only applies to a single block
+	if block, err = w.mineBlock(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if block.Time().Uint64() != timestamp+1 {
+		t.Errorf("wrong timestamp %v after the requested one, want %d", block.Time(), timestamp+1)
+	}
+}
+
+// Tests that a requested timestamp is dropped if the head changes before the
+// block is produced, instead of producing a block with an invalid timestamp.
+func TestNextTimestampHeadChange(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+
+	base, err := w.mineBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	// Move the head away behind the back of the worker
+	if err := w.setNextTimestamp(base.Time().Uint64() + 1); err != nil {
+		t.Fatalf("failed to set next timestamp: %v", err)
+	}
+	other := newTestWorkerBackend(t)
+	defer other.chain.Stop()
+	defer other.txPool.Stop()
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, other.chain.Genesis(), ethash.NewFaker(), other.db, 2, func(i int, b *core.BlockGen) {
+		b.OffsetTime(100)
+	})
+	if _, err := backend.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	if head := backend.chain.CurrentBlock(); head.Hash() != blocks[1].Hash() {
+		t.Fatalf("side chain didn't become canonical")
+	}
+	if _, err := w.mineBlock(nil); err != errHeadChanged {
+		t.Fatalf("error mismatch: have %v, want %v", err, errHeadChanged)
+	}
+	block, err := w.mineBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block after dropped timestamp: %v", err)
+	}
+	if block.Time().Cmp(blocks[1].Time()) <= 0 {
+		t.Errorf("block timestamp %v not after parent timestamp %v", block.Time(), blocks[1].Time())
+	}
+	// Rewinding drops the requested timestamp too
+	if err := w.setNextTimestamp(block.Time().Uint64() + 1); err != nil {
+		t.Fatalf("failed to set next timestamp: %v", err)
+	}
+	if err := w.rewind(blocks[0].NumberU64(), blocks[0].Hash()); err != nil {
+		t.Fatalf("failed to rewind: %v", err)
+	}
+	if w.nextTimestamp != 0 {
+		t.Errorf("next timestamp %d kept after rewind", w.nextTimestamp)
+	}
+}
+
+// Tests that state overrides are produced as flagged blocks without any pending
+// transaction, and that other nodes refuse to import them.
+func TestStateOverride(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+
+	first, err := w.mineBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if err := backend.txPool.AddLocal(newTestTransaction(t, 0)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	addr, balance := common.Address{3}, big.NewInt(12345)
+	override, err := w.mineBlock(func(statedb *state.StateDB) { statedb.SetBalance(addr, balance) })
+	if err != nil {
+		t.Fatalf("failed to override state: %v", err)
+	}
+	if len(override.Transactions()) != 0 {
+		t.Errorf("override block includes %d pending transactions", len(override.Transactions()))
+	}
+	if pending, _ := backend.txPool.Stats(); pending != 1 {
+		t.Errorf("pending transactions mismatch: have %d, want 1", pending)
+	}
+	statedb, _ := backend.chain.State()
+	if have := statedb.GetBalance(addr); have.Cmp(balance) != 0 {
+		t.Errorf("wrong balance %v, want %v", have, balance)
+	}
+	if IsStateOverride(first.Header()) || !IsStateOverride(override.Header()) {
+		t.Errorf("override flag mismatch: regular %v, override %v", IsStateOverride(first.Header()), IsStateOverride(override.Header()))
+	}
+	// Regular blocks import fine elsewhere, the override doesn't
+	other := newTestWorkerBackend(t)
+	defer other.chain.Stop()
+	defer other.txPool.Stop()
+
+	if _, err := other.chain.InsertChain(types.Blocks{first}); err != nil {
+		t.Fatalf("failed to import regular block: %v", err)
+	}
+	if _, err := other.chain.InsertChain(types.Blocks{override}); err == nil {
+		t.Fatalf("override block imported")
+	}
+}
+
+// Tests that rewinding drops the later blocks and their transactions.
+func TestRewind(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+
+	base, err := w.mineBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if err := backend.txPool.AddLocal(newTestTransaction(t, 0)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := w.mineBlock(nil); err != nil {
+			t.Fatalf("failed to mine block: %v", err)
+		}
+	}
+	if err := w.rewind(base.NumberU64(), common.Hash{}); err == nil {
+		t.Fatal("rewind to unknown block succeeded")
+	}
+	if err := w.rewind(base.NumberU64(), base.Hash()); err != nil {
+		t.Fatalf("failed to rewind: %v", err)
+	}
+	if head := backend.chain.CurrentBlock(); head.Hash() != base.Hash() {
+		t.Fatalf("wrong head after rewind: %d (%x), want %d (%x)", head.NumberU64(), head.Hash(), base.NumberU64(), base.Hash())
+	}
+	statedb, _ := backend.chain.State()
+	if nonce := statedb.GetNonce(testBankAddress); nonce != 0 {
+		t.Errorf("transaction of discarded block still applied: nonce %d", nonce)
+	}
+	// The pool resets asynchronously, dropping the state of the discarded blocks
+	for i := 0; backend.txPool.Nonce(testBankAddress) != 0; i++ {
+		if i == 100 {
+			t.Fatalf("pool nonce %d not reset by rewind", backend.txPool.Nonce(testBankAddress))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	block, err := w.mineBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block after rewind: %v", err)
+	}
+	if block.NumberU64() != base.NumberU64()+1 || block.ParentHash() != base.Hash() {
+		t.Errorf("block %d (parent %x) doesn't extend the rewound chain", block.NumberU64(), block.ParentHash())
+	}
+}
+
+// Tests that blocks are produced as soon as transactions arrive in instant mode.
+func TestInstantSealing(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+	w.setInstant(true)
+
+	heads := make(chan core.ChainHeadEvent, 1)
+	sub := backend.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	tx := newTestTransaction(t, 0)
+	if err := backend.txPool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	select {
+	case ev := <-heads:
+		if txs := ev.Block.Transactions(); len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Errorf("produced block doesn't contain the transaction")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no block produced")
+	}
+}
+
+// Tests that producing blocks on demand as transactions arrive doesn't stall the
+// worker while it is mining and adjusting its recommit interval.
+func TestInstantSealingWithRecommit(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+	w.register(&testAgent{workCh: make(chan *Work, 256)})
+	w.setInstant(true)
+	w.start()
+	defer w.stop()
+
+	heads := make(chan core.ChainHeadEvent, 256)
+	sub := backend.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	// Fiddle with the recommit interval while the blocks are produced
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case w.resubmitAdjustCh <- &intervalAdjust{ratio: 0.5, inc: i%2 == 0}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	const count = 64
+	for i := uint64(0); i < count; i++ {
+		if err := backend.txPool.AddLocal(newTestTransaction(t, i)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case <-heads:
+			statedb, _ := backend.chain.State()
+			if statedb.GetNonce(testBankAddress) == count {
+				return
+			}
+		case <-timeout:
+			statedb, _ := backend.chain.State()
+			t.Fatalf("worker stalled: %d of %d transactions included", statedb.GetNonce(testBankAddress), count)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+)
+
+// Names of the built-in transaction ordering policies.
+const (
+	PriceOrderingName    = "price"
+	PriorityOrderingName = "priority"
+	FIFOOrderingName     = "fifo"
+	QuotaOrderingName    = "quota"
+)
+
+// TransactionSet is a set of transactions handed out one at a time in the order
+// they should be packed into a block.
+type TransactionSet interface {
+	// Peek returns the next transaction to include, or nil if none are left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of the same sender.
+	Shift()
+
+	// Pop drops the current transaction without replacing it, skipping all the
+	// remaining transactions of its sender.
+	Pop()
+}
+
+// TxOrdering is a policy deciding which pending transactions the worker tries
+// to include in a block, and in which order.
+type TxOrdering interface {
+	// Order creates the transaction set to pack a block from. The pending
+	// transactions are grouped by sender and sorted by nonce. The map is
+	// reowned by the ordering.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions) TransactionSet
+}
+
+// OrderingConfig contains the parameters of the built-in ordering policies, each
+// of them used by a single policy only.
+type OrderingConfig struct {
+	Priority []common.Address                 // Senders preferred by the priority ordering
+	Quota    uint64                           // Gas available to each sender per block with the quota ordering
+	Arrival  func(hash common.Hash) time.Time // Arrival time of pooled transactions for the FIFO ordering
+}
+
+// NewTxOrdering creates the built-in ordering policy with the given name.
+func NewTxOrdering(name string, config OrderingConfig) (TxOrdering, error) {
+	switch name {
+	case "", PriceOrderingName:
+		return PriceOrdering{}, nil
+	case PriorityOrderingName:
+		return NewPriorityOrdering(config.Priority), nil
+	case FIFOOrderingName:
+		if config.Arrival == nil {
+			return nil, errors.New("fifo ordering needs the arrival times of transactions")
+		}
+		return NewFIFOOrdering(config.Arrival), nil
+	case QuotaOrderingName:
+		if config.Quota == 0 {
+			return nil, errors.New("quota ordering needs a gas quota")
+		}
+		return NewQuotaOrdering(config.Quota), nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering %q", name)
+	}
+}
+
+// PriceOrdering is the default ordering policy, including the best paying
+// transactions first while honouring the nonce order of each sender.
+type PriceOrdering struct{}
+
+// Order implements TxOrdering.
+func (PriceOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TransactionSet {
+	return types.NewTransactionsByPriceAndNonce(signer, pending)
+}
+
+// PriorityOrdering includes all transactions of a whitelist of senders before
+// those of anyone else. Both groups are ordered by price.
+type PriorityOrdering struct {
+	senders map[common.Address]struct{}
+}
+
+// NewPriorityOrdering creates an ordering preferring the given senders.
+func NewPriorityOrdering(senders []common.Address) *PriorityOrdering {
+	o := &PriorityOrdering{senders: make(map[common.Address]struct{}, len(senders))}
+	for _, addr := range senders {
+		o.senders[addr] = struct{}{}
+	}
+	return o
+}
+
+// Order implements TxOrdering.
+func (o *PriorityOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TransactionSet {
+	priority := make(map[common.Address]types.Transactions)
+	for addr, txs := range pending {
+		if _, ok := o.senders[addr]; ok {
+			priority[addr] = txs
+			delete(pending, addr)
+		}
+	}
+	return &chainedSet{sets: []TransactionSet{
+		types.NewTransactionsByPriceAndNonce(signer, priority),
+		types.NewTransactionsByPriceAndNonce(signer, pending),
+	}}
+}
+
+// FIFOOrdering includes transactions in the order they arrived at the pool,
+// while honouring the nonce order of each sender.
+type FIFOOrdering struct {
+	arrival func(hash common.Hash) time.Time
+}
+
+// NewFIFOOrdering creates an ordering by arrival, looking up the arrival times
+// of the transactions with the given function.
+func NewFIFOOrdering(arrival func(hash common.Hash) time.Time) *FIFOOrdering {
+	return &FIFOOrdering{arrival: arrival}
+}
+
+// Order implements TxOrdering.
+func (o *FIFOOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TransactionSet {
+	set := &arrivalSet{
+		txs:     pending,
+		heads:   make(txsByArrival, 0, len(pending)),
+		arrival: o.arrival,
+	}
+	for from, txs := range pending {
+		set.heads = append(set.heads, set.entry(from, txs[0]))
+		pending[from] = txs[1:]
+	}
+	heap.Init(&set.heads)
+	return set
+}
+
+// arrivalEntry is the next transaction of a sender, along with its arrival time.
+type arrivalEntry struct {
+	tx      *types.Transaction
+	from    common.Address
+	arrived time.Time
+}
+
+// txsByArrival implements the heap interface, ordering the next transactions of
+// all senders by their arrival time, and by price if they arrived at once.
+type txsByArrival []*arrivalEntry
+
+func (s txsByArrival) Len() int { return len(s) }
+func (s txsByArrival) Less(i, j int) bool {
+	if s[i].arrived.Equal(s[j].arrived) {
+		return s[i].tx.GasPrice().Cmp(s[j].tx.GasPrice()) > 0
+	}
+	return s[i].arrived.Before(s[j].arrived)
+}
+func (s txsByArrival) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByArrival) Push(x interface{}) {
+	*s = append(*s, x.(*arrivalEntry))
+}
+
+func (s *txsByArrival) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// arrivalSet hands out transactions in the order of arrival, honouring the
+// nonce order of each sender.
+type arrivalSet struct {
+	txs     map[common.Address]types.Transactions // Per sender nonce-sorted list of transactions
+	heads   txsByArrival                          // Next transaction of each sender (arrival heap)
+	arrival func(hash common.Hash) time.Time
+}
+
+// entry creates the heap entry for the next transaction of a sender.
+func (s *arrivalSet) entry(from common.Address, tx *types.Transaction) *arrivalEntry {
+	return &arrivalEntry{tx: tx, from: from, arrived: s.arrival(tx.Hash())}
+}
+
+// Peek implements TransactionSet.
+func (s *arrivalSet) Peek() *types.Transaction {
+	if len(s.heads) == 0 {
+		return nil
+	}
+	return s.heads[0].tx
+}
+
+// Shift implements TransactionSet.
+func (s *arrivalSet) Shift() {
+	from := s.heads[0].from
+	if txs := s.txs[from]; len(txs) > 0 {
+		s.heads[0], s.txs[from] = s.entry(from, txs[0]), txs[1:]
+		heap.Fix(&s.heads, 0)
+	} else {
+		heap.Pop(&s.heads)
+	}
+}
+
+// Pop implements TransactionSet.
+func (s *arrivalSet) Pop() {
+	heap.Pop(&s.heads)
+}
+
+// QuotaOrdering includes the best paying transactions first like the default
+// policy, but limits the gas each sender may use in a block. The transactions
+// exceeding the quota of their sender are left for later blocks.
+//
+// Senders are charged with the gas limit of their included transactions, as the
+// gas actually used isn't known to the ordering.
+type QuotaOrdering struct {
+	quota uint64
+}
+
+// NewQuotaOrdering creates an ordering granting each sender the given amount of
+// gas per block.
+func NewQuotaOrdering(quota uint64) *QuotaOrdering {
+	return &QuotaOrdering{quota: quota}
+}
+
+// Order implements TxOrdering.
+func (o *QuotaOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TransactionSet {
+	return &quotaSet{
+		set:    types.NewTransactionsByPriceAndNonce(signer, pending),
+		signer: signer,
+		quota:  o.quota,
+		used:   make(map[common.Address]uint64),
+	}
+}
+
+// quotaSet hands out the transactions of a set until their sender runs out of
+// its gas quota.
+type quotaSet struct {
+	set    TransactionSet
+	signer types.Signer
+	quota  uint64
+	used   map[common.Address]uint64 // Gas charged to each sender so far
+}
+
+// Peek implements TransactionSet.
+func (s *quotaSet) Peek() *types.Transaction {
+	for {
+		tx := s.set.Peek()
+		if tx == nil {
+			return nil
+		}
+		from, _ := types.Sender(s.signer, tx)
+		if s.used[from]+tx.Gas() <= s.quota {
+			return tx
+		}
+		// Quota exceeded, the sender's remaining transactions must wait
+		s.set.Pop()
+	}
+}
+
+// Shift implements TransactionSet.
+func (s *quotaSet) Shift() {
+	if tx := s.Peek(); tx != nil {
+		from, _ := types.Sender(s.signer, tx)
+		s.used[from] += tx.Gas()
+		s.set.Shift()
+	}
+}
+
+// Pop implements TransactionSet.
+func (s *quotaSet) Pop() {
+	if s.Peek() != nil {
+		s.set.Pop()
+	}
+}
+
+// chainedSet hands out the transactions of several sets, exhausting each set
+// before moving on to the next one.
+type chainedSet struct {
+	sets []TransactionSet
+}
+
+// Peek implements TransactionSet.
+func (s *chainedSet) Peek() *types.Transaction {
+	for len(s.sets) > 0 {
+		if tx := s.sets[0].Peek(); tx != nil {
+			return tx
+		}
+		s.sets = s.sets[1:]
+	}
+	return nil
+}
+
+// Shift implements TransactionSet.
+func (s *chainedSet) Shift() {
+	if s.Peek() != nil {
+		s.sets[0].Shift()
+	}
+}
+
+// Pop implements TransactionSet.
+func (s *chainedSet) Pop() {
+	if s.Peek() != nil {
+		s.sets[0].Pop()
+	}
+}
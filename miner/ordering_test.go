@@ -0,0 +1,165 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+)
+
+// Tests that the priority ordering hands out all transactions of the preferred
+// senders before anyone else's, keeping price and nonce order within each group.
+func TestPriorityOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// Sender i pays a gas price of 10*(i+1), making the last one the best paying
+	pending := make(map[common.Address]types.Transactions)
+	for i, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), 100, big.NewInt(int64(10*(i+1))), nil), signer, key)
+			pending[addr] = append(pending[addr], tx)
+		}
+	}
+	preferred := crypto.PubkeyToAddress(keys[0].PublicKey)
+	set := NewPriorityOrdering([]common.Address{preferred}).Order(signer, pending)
+
+	var senders []common.Address
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		from, _ := types.Sender(signer, tx)
+		senders = append(senders, from)
+		set.Shift()
+	}
+	if len(senders) != 6 {
+		t.Fatalf("wrong number of transactions: have %d, want 6", len(senders))
+	}
+	want := []common.Address{
+		preferred, preferred,
+		crypto.PubkeyToAddress(keys[2].PublicKey), crypto.PubkeyToAddress(keys[2].PublicKey),
+		crypto.PubkeyToAddress(keys[1].PublicKey), crypto.PubkeyToAddress(keys[1].PublicKey),
+	}
+	for i := range want {
+		if senders[i] != want[i] {
+			t.Errorf("transaction %d: sender %x, want %x", i, senders[i], want[i])
+		}
+	}
+}
+
+// Tests that the FIFO ordering hands out transactions by arrival time, keeping
+// the nonce order of each sender.
+func TestFIFOOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// The transactions of the senders arrived interleaved, the cheapest first
+	var (
+		pending = make(map[common.Address]types.Transactions)
+		arrived = make(map[common.Hash]time.Time)
+		start   = time.Now()
+		want    []common.Hash
+	)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		for i, key := range keys {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), 100, big.NewInt(int64(10*(i+1))), nil), signer, key)
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			pending[addr] = append(pending[addr], tx)
+			arrived[tx.Hash()] = start.Add(time.Duration(len(want)) * time.Second)
+			want = append(want, tx.Hash())
+		}
+	}
+	set := NewFIFOOrdering(func(hash common.Hash) time.Time { return arrived[hash] }).Order(signer, pending)
+
+	var have []common.Hash
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		have = append(have, tx.Hash())
+		set.Shift()
+	}
+	if len(have) != len(want) {
+		t.Fatalf("wrong number of transactions: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("transaction %d: hash %x, want %x", i, have[i], want[i])
+		}
+	}
+}
+
+// Tests that the quota ordering skips the transactions of senders which ran out
+// of their gas quota, without affecting the other senders.
+func TestQuotaOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// The best paying sender sends three transactions, the quota allows two
+	pending := make(map[common.Address]types.Transactions)
+	for i, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for nonce := uint64(0); nonce < 3; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), 21000, big.NewInt(int64(10*(i+1))), nil), signer, key)
+			pending[addr] = append(pending[addr], tx)
+		}
+	}
+	set := NewQuotaOrdering(50000).Order(signer, pending)
+
+	counts := make(map[common.Address]int)
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		from, _ := types.Sender(signer, tx)
+		counts[from]++
+		set.Shift()
+	}
+	for i, key := range keys {
+		if have := counts[crypto.PubkeyToAddress(key.PublicKey)]; have != 2 {
+			t.Errorf("sender %d: included transactions %d, want 2", i, have)
+		}
+	}
+}
+
+func TestNewTxOrdering(t *testing.T) {
+	if o, err := NewTxOrdering("", OrderingConfig{}); err != nil || o != (PriceOrdering{}) {
+		t.Errorf("default ordering: have %v (err %v), want price ordering", o, err)
+	}
+	if o, err := NewTxOrdering(PriorityOrderingName, OrderingConfig{}); err != nil {
+		t.Errorf("priority ordering failed: %v", err)
+	} else if _, ok := o.(*PriorityOrdering); !ok {
+		t.Errorf("wrong ordering type %T", o)
+	}
+	if _, err := NewTxOrdering(FIFOOrderingName, OrderingConfig{}); err == nil {
+		t.Error("fifo ordering without arrival times accepted")
+	}
+	if _, err := NewTxOrdering(QuotaOrderingName, OrderingConfig{}); err == nil {
+		t.Error("quota ordering without quota accepted")
+	}
+	if _, err := NewTxOrdering("lifo", OrderingConfig{}); err == nil {
+		t.Error("unknown ordering accepted")
+	}
+}
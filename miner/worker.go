@@ -49,6 +49,28 @@ const (
 	chainHeadChanSize = 10
 	// chainSideChanSize is the size of channel listening to ChainSideEvent.
 	chainSideChanSize = 10
+	// resubmitAdjustChanSize is the size of resubmitting interval adjustment channel.
+	resubmitAdjustChanSize = 10
+
+	// minRecommitInterval is the minimal time interval to recreate the mining block with
+	// any newly arrived transactions.
+	minRecommitInterval = 1 * time.Second
+	// maxRecommitInterval is the maximum time interval to recreate the mining block with
+	// any newly arrived transactions.
+	maxRecommitInterval = 15 * time.Second
+
+	// intervalAdjustRatio is the impact a single interval adjustment has on sealing work
+	// resubmitting interval.
+	intervalAdjustRatio = 0.1
+	// intervalAdjustBias is applied during the new resubmit interval calculation in favor of
+	// increasing upper limit or decreasing lower limit so that the limit can be reachable.
+	intervalAdjustBias = 200 * 1000.0 * 1000.0
+)
+
+const (
+	commitInterruptNone int32 = iota
+	commitInterruptNewHead
+	commitInterruptResubmit
 )
 
 // Agent can register themself with the worker
@@ -87,6 +109,18 @@ type Result struct {
 	Block *types.Block
 }
 
+// newWorkReq represents a request for new sealing work submitting with relative interrupt notifier.
+type newWorkReq struct {
+	interrupt *int32
+	timestamp int64
+}
+
+// intervalAdjust represents a resubmitting interval adjustment.
+type intervalAdjust struct {
+	ratio float64
+	inc   bool
+}
+
 // worker is the main object which takes care of applying messages to the new state
 type worker struct {
 	config *params.ChainConfig
@@ -104,6 +138,13 @@ type worker struct {
 	chainSideSub event.Subscription
 	wg           sync.WaitGroup
 
+	// work loop
+	newWorkCh          chan *newWorkReq
+	startCh            chan struct{}
+	exitCh             chan struct{}
+	resubmitIntervalCh chan time.Duration
+	resubmitAdjustCh   chan *intervalAdjust
+
 	agents map[Agent]struct{}
 	recv   chan *Result
 
@@ -114,6 +155,11 @@ type worker struct {
 
 	coinbase common.Address
 	extra    []byte
+	ordering TxOrdering // Policy selecting the transactions to include
+
+	timestampMu   sync.Mutex
+	nextTimestamp uint64      // Timestamp of the next block produced on demand, zero if unset
+	nextParent    common.Hash // Head the next timestamp was requested on top of
 
 	currentMu sync.Mutex
 	current   *Work
@@ -130,9 +176,15 @@ type worker struct {
 	// atomic status counters
 	mining int32
 	atWork int32
+	newTxs int32 // New arrival transaction count since last sealing work submitting.
+
+	instant int32 // Whether blocks are produced on demand as soon as transactions arrive
+
+	// Test hooks
+	resubmitHook func(time.Duration, time.Duration) // Method to call upon updating resubmitting interval.
 }
 
-func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase common.Address, ess Backend, mux *event.TypeMux) *worker {
+func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase common.Address, ess Backend, mux *event.TypeMux, recommit time.Duration, ordering TxOrdering) *worker {
 	worker := &worker{
 		config:         config,
 		engine:         engine,
@@ -141,24 +193,42 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		txsCh:          make(chan core.NewTxsEvent, txChanSize),
 		chainHeadCh:    make(chan core.ChainHeadEvent, chainHeadChanSize),
 		chainSideCh:    make(chan core.ChainSideEvent, chainSideChanSize),
+		newWorkCh:      make(chan *newWorkReq),
+		startCh:        make(chan struct{}, 1),
+		exitCh:         make(chan struct{}),
 		chainDb:        ess.ChainDb(),
 		recv:           make(chan *Result, resultQueueSize),
 		chain:          ess.BlockChain(),
 		proc:           ess.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
+		ordering:       ordering,
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(ess.BlockChain(), miningLogAtDepth),
+
+		resubmitIntervalCh: make(chan time.Duration),
+		resubmitAdjustCh:   make(chan *intervalAdjust, resubmitAdjustChanSize),
 	}
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = ess.TxPool().SubscribeNewTxsEvent(worker.txsCh)
 	// Subscribe events for blockchain
 	worker.chainHeadSub = ess.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
 	worker.chainSideSub = ess.BlockChain().SubscribeChainSideEvent(worker.chainSideCh)
-	go worker.update()
 
+	// Sanitize recommit interval if the user-specified one is too short.
+	if recommit < minRecommitInterval {
+		log.Warn("Sanitizing miner recommit interval", "provided", recommit, "updated", minRecommitInterval)
+		recommit = minRecommitInterval
+	}
+	if worker.ordering == nil {
+		worker.ordering = PriceOrdering{}
+	}
+	// Assemble the initial pending block before any event arrives
+	worker.commitNewWork(nil, time.Now().Unix())
+
+	go worker.mainLoop()
+	go worker.newWorkLoop(recommit)
 	go worker.wait()
-	worker.commitNewWork()
 
 	return worker
 }
@@ -175,6 +245,14 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+// setRecommitInterval updates the interval for miner sealing work recommitting.
+func (self *worker) setRecommitInterval(interval time.Duration) {
+	select {
+	case self.resubmitIntervalCh <- interval:
+	case <-self.exitCh:
+	}
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	if atomic.LoadInt32(&self.mining) == 0 {
 		// return a snapshot to avoid contention on currentMu mutex
@@ -203,14 +281,16 @@ func (self *worker) pendingBlock() *types.Block {
 
 func (self *worker) start() {
 	self.mu.Lock()
-	defer self.mu.Unlock()
-
 	atomic.StoreInt32(&self.mining, 1)
 
 	// spin up agents
 	for agent := range self.agents {
 		agent.Start()
 	}
+	self.mu.Unlock()
+
+	// Request fresh sealing work, outside the lock as the commit itself needs it
+	self.startCh <- struct{}{}
 }
 
 func (self *worker) stop() {
@@ -241,17 +321,134 @@ func (self *worker) unregister(agent Agent) {
 	agent.Stop()
 }
 
-func (self *worker) update() {
+// newWorkLoop is a standalone goroutine to submit new mining work upon received
+// events, and to periodically recommit the pending block while mining so newly
+// arrived transactions get included.
+func (self *worker) newWorkLoop(recommit time.Duration) {
+	var (
+		interrupt   *int32
+		minRecommit = recommit // minimal resubmit interval specified by user.
+		timestamp   int64      // timestamp for each round of mining.
+	)
+
+	timer := time.NewTimer(0)
+	<-timer.C // discard the initial tick
+
+	// commit aborts in-flight transaction execution with given signal and resubmits a new one.
+	commit := func(s int32) {
+		if interrupt != nil {
+			atomic.StoreInt32(interrupt, s)
+		}
+		interrupt = new(int32)
+		req := &newWorkReq{interrupt: interrupt, timestamp: timestamp}
+		for sent := false; !sent; {
+			select {
+			case self.newWorkCh <- req:
+				sent = true
+			case head := <-self.chainHeadCh:
+				// Keep draining head events while the main loop is busy, e.g. producing
+				// blocks on demand, the queued request builds on the latest head anyway.
+				self.clearNextTimestamp(head.Block.Hash())
+				timestamp = time.Now().Unix()
+				req.timestamp = timestamp
+			case <-self.exitCh:
+				return
+			}
+		}
+		timer.Reset(recommit)
+		atomic.StoreInt32(&self.newTxs, 0)
+	}
+	// recalcRecommit recalculates the resubmitting interval upon feedback.
+	recalcRecommit := func(target float64, inc bool) {
+		var (
+			prev = float64(recommit.Nanoseconds())
+			next float64
+		)
+		if inc {
+			next = prev*(1-intervalAdjustRatio) + intervalAdjustRatio*(target+intervalAdjustBias)
+			// Recap if interval is larger than the maximum time interval
+			if next > float64(maxRecommitInterval.Nanoseconds()) {
+				next = float64(maxRecommitInterval.Nanoseconds())
+			}
+		} else {
+			next = prev*(1-intervalAdjustRatio) + intervalAdjustRatio*(target-intervalAdjustBias)
+			// Recap if interval is less than the user specified minimum
+			if next < float64(minRecommit.Nanoseconds()) {
+				next = float64(minRecommit.Nanoseconds())
+			}
+		}
+		recommit = time.Duration(int64(next))
+	}
+
+	for {
+		select {
+		case <-self.startCh:
+			timestamp = time.Now().Unix()
+			commit(commitInterruptNewHead)
+
+		case head := <-self.chainHeadCh:
+			self.clearNextTimestamp(head.Block.Hash())
+			timestamp = time.Now().Unix()
+			commit(commitInterruptNewHead)
+
+		case <-timer.C:
+			// If mining is running resubmit a new work cycle periodically to pull in
+			// higher priced transactions. Disable this overhead for pending blocks.
+			if atomic.LoadInt32(&self.mining) == 1 && (self.config.Clique == nil || self.config.Clique.Period > 0) {
+				// Short circuit if no new transaction arrives.
+				if atomic.LoadInt32(&self.newTxs) == 0 {
+					timer.Reset(recommit)
+					continue
+				}
+				commit(commitInterruptResubmit)
+			}
+
+		case interval := <-self.resubmitIntervalCh:
+			// Adjust resubmit interval explicitly by user.
+			if interval < minRecommitInterval {
+				log.Warn("Sanitizing miner recommit interval", "provided", interval, "updated", minRecommitInterval)
+				interval = minRecommitInterval
+			}
+			log.Info("Miner recommit interval update", "from", minRecommit, "to", interval)
+			minRecommit, recommit = interval, interval
+
+			if self.resubmitHook != nil {
+				self.resubmitHook(minRecommit, recommit)
+			}
+
+		case adjust := <-self.resubmitAdjustCh:
+			// Adjust resubmit interval by feedback.
+			before := recommit
+			if adjust.inc {
+				recalcRecommit(float64(recommit.Nanoseconds())/adjust.ratio, true)
+				log.Trace("Increase miner recommit interval", "from", before, "to", recommit)
+			} else {
+				recalcRecommit(float64(minRecommit.Nanoseconds()), false)
+				log.Trace("Decrease miner recommit interval", "from", before, "to", recommit)
+			}
+
+			if self.resubmitHook != nil {
+				self.resubmitHook(minRecommit, recommit)
+			}
+
+		case <-self.exitCh:
+			return
+		}
+	}
+}
+
+// mainLoop is a standalone goroutine to regenerate the sealing work based on
+// the received events.
+func (self *worker) mainLoop() {
 	defer self.txsSub.Unsubscribe()
 	defer self.chainHeadSub.Unsubscribe()
 	defer self.chainSideSub.Unsubscribe()
+	defer close(self.exitCh)
 
 	for {
-		// A real event arrived, process interesting content
 		select {
-		// Handle ChainHeadEvent
-		case <-self.chainHeadCh:
-			self.commitNewWork()
+		case req := <-self.newWorkCh:
+			self.commitNewWork(req.interrupt, req.timestamp)
 
 		// Handle ChainSideEvent
 		case ev := <-self.chainSideCh:
@@ -266,23 +463,29 @@ func (self *worker) update() {
 			// Note all transactions received may not be continuous with transactions
 			// already included in the current mining block. These transactions will
 			// be automatically eliminated.
-			if atomic.LoadInt32(&self.mining) == 0 {
+			if atomic.LoadInt32(&self.instant) == 1 {
+				// Produce a block with the new transactions right away
+				if _, err := self.mineBlock(nil); err != nil {
+					log.Error("Failed to produce block", "err", err)
+				}
+			} else if atomic.LoadInt32(&self.mining) == 0 {
 				self.currentMu.Lock()
 				txs := make(map[common.Address]types.Transactions)
 				for _, tx := range ev.Txs {
 					acc, _ := types.Sender(self.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
-				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
+				txset := self.ordering.Order(self.current.signer, txs)
+				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase, nil)
 				self.updateSnapshot()
 				self.currentMu.Unlock()
 			} else {
 				// If we're mining, but nothing is being processed, wake on new transactions
 				if self.config.Clique != nil && self.config.Clique.Period == 0 {
-					self.commitNewWork()
+					self.commitNewWork(nil, time.Now().Unix())
 				}
 			}
+			atomic.AddInt32(&self.newTxs, int32(len(ev.Txs)))
 
 		// System stopped
 		case <-self.txsSub.Err():
@@ -303,45 +506,55 @@ func (self *worker) wait() {
 			if result == nil {
 				continue
 			}
-			block := result.Block
-			work := result.Work
-
-			// Update the block hash in all logs since it is now available and not when the
-			// receipt/log of individual transactions were created.
-			for _, r := range work.receipts {
-				for _, l := range r.Logs {
-					l.BlockHash = block.Hash()
-				}
-			}
-			for _, log := range work.state.Logs() {
-				log.BlockHash = block.Hash()
-			}
-			stat, err := self.chain.WriteBlockWithState(block, work.receipts, work.state)
-			if err != nil {
+			self.mu.Lock()
+			// Blocks may have been produced on demand meanwhile, don't fork the chain
+			if head := self.chain.CurrentBlock(); result.Block.ParentHash() != head.Hash() {
+				log.Debug("Discarding stale sealing result", "number", result.Block.Number(), "hash", result.Block.Hash())
+			} else if err := self.writeBlock(result.Work, result.Block); err != nil {
 				log.Error("Failed writing block to chain", "err", err)
-				continue
 			}
-			// Broadcast the block and announce chain insertion event
-			self.mux.Post(core.NewMinedBlockEvent{Block: block})
-			var (
-				events []interface{}
-				logs   = work.state.Logs()
-			)
-			events = append(events, core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
-			if stat == core.CanonStatTy {
-				events = append(events, core.ChainHeadEvent{Block: block})
-			}
-			self.chain.PostChainEvents(events, logs)
+			self.mu.Unlock()
+		}
+	}
+}
 
-			// Insert the block into the set of pending ones to wait for confirmations
-			self.unconfirmed.Insert(block.NumberU64(), block.Hash())
+// writeBlock inserts a sealed block into the chain and announces it.
+func (self *worker) writeBlock(work *Work, block *types.Block) error {
+	// Update the block hash in all logs since it is now available and not when the
+	// receipt/log of individual transactions were created.
+	for _, r := range work.receipts {
+		for _, l := range r.Logs {
+			l.BlockHash = block.Hash()
 		}
 	}
+	for _, log := range work.state.Logs() {
+		log.BlockHash = block.Hash()
+	}
+	stat, err := self.chain.WriteBlockWithState(block, work.receipts, work.state)
+	if err != nil {
+		return err
+	}
+	// Broadcast the block and announce chain insertion event
+	self.mux.Post(core.NewMinedBlockEvent{Block: block})
+	var (
+		events []interface{}
+		logs   = work.state.Logs()
+	)
+	events = append(events, core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+	if stat == core.CanonStatTy {
+		events = append(events, core.ChainHeadEvent{Block: block})
+	}
+	self.chain.PostChainEvents(events, logs)
+
+	// Insert the block into the set of pending ones to wait for confirmations
+	self.unconfirmed.Insert(block.NumberU64(), block.Hash())
+	return nil
 }
 
 // push sends a new work task to currently live miner agents.
 func (self *worker) push(work *Work) {
-	if atomic.LoadInt32(&self.mining) != 1 {
+	// Blocks are produced on demand in instant mode, the agents stay idle
+	if atomic.LoadInt32(&self.mining) != 1 || atomic.LoadInt32(&self.instant) == 1 {
 		return
 	}
 	for agent := range self.agents {
@@ -352,12 +565,53 @@ func (self *worker) push(work *Work) {
 	}
 }
 
+// prepareHeader assembles the header of a block on top of parent, letting the
+// consensus engine fill in its fields.
+func (self *worker) prepareHeader(parent *types.Block, tstamp int64, coinbase common.Address) (*types.Header, error) {
+	num := parent.Number()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     num.Add(num, common.Big1),
+		GasLimit:   core.CalcGasLimit(parent),
+		Extra:      self.extra,
+		Time:       big.NewInt(tstamp),
+		Coinbase:   coinbase,
+	}
+	if err := self.engine.Prepare(self.chain, header); err != nil {
+		return nil, err
+	}
+	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
+	if daoBlock := self.config.DAOForkBlock; daoBlock != nil {
+		// Check whether the block is among the fork extra-override range
+		limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
+		if header.Number.Cmp(daoBlock) >= 0 && header.Number.Cmp(limit) < 0 {
+			// Depending whether we support or oppose the fork, override differently
+			if self.config.DAOForkSupport {
+				header.Extra = common.CopyBytes(params.DAOForkBlockExtra)
+			} else if bytes.Equal(header.Extra, params.DAOForkBlockExtra) {
+				header.Extra = []byte{} // If miner opposes, don't let it use the reserved extra-data
+			}
+		}
+	}
+	return header, nil
+}
+
 // makeCurrent creates a new environment for the current cycle.
 func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error {
-	state, err := self.chain.StateAt(parent.Root())
+	work, err := self.makeWork(parent, header)
 	if err != nil {
 		return err
 	}
+	self.current = work
+	return nil
+}
+
+// makeWork creates a new sealing environment for a block on top of parent.
+func (self *worker) makeWork(parent *types.Block, header *types.Header) (*Work, error) {
+	state, err := self.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
 	work := &Work{
 		config:    self.config,
 		signer:    types.NewEIP155Signer(self.config.ChainID),
@@ -380,11 +634,14 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 
 	// Keep track of transactions which return errors so they can be removed
 	work.tcount = 0
-	self.current = work
-	return nil
+	return work, nil
 }
 
-func (self *worker) commitNewWork() {
+// commitNewWork generates several new sealing tasks based on the parent block.
+// A non-nil interrupt aborts the transaction execution once signalled by the
+// work loop: new heads drop the work altogether, resubmits seal what has been
+// packed so far.
+func (self *worker) commitNewWork(interrupt *int32, timestamp int64) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 	self.uncleMu.Lock()
@@ -395,49 +652,31 @@ func (self *worker) commitNewWork() {
 	tstart := time.Now()
 	parent := self.chain.CurrentBlock()
 
-	tstamp := tstart.Unix()
+	tstamp := timestamp
 	if parent.Time().Cmp(new(big.Int).SetInt64(tstamp)) >= 0 {
 		tstamp = parent.Time().Int64() + 1
 	}
-	// this will ensure we're not going off too far in the future
-	if now := time.Now().Unix(); tstamp > now+1 {
+	// this will ensure we're not going off too far in the future, unless blocks
+	// are produced on demand, running ahead of time when produced in quick succession
+	if now := time.Now().Unix(); tstamp > now+1 && atomic.LoadInt32(&self.instant) == 0 {
 		wait := time.Duration(tstamp-now) * time.Second
 		log.Info("Mining too far in the future", "wait", common.PrettyDuration(wait))
 		time.Sleep(wait)
 	}
 
-	num := parent.Number()
-	header := &types.Header{
-		ParentHash: parent.Hash(),
-		Number:     num.Add(num, common.Big1),
-		GasLimit:   core.CalcGasLimit(parent),
-		Extra:      self.extra,
-		Time:       big.NewInt(tstamp),
-	}
 	// Only set the coinbase if we are mining (avoid spurious block rewards)
+	var coinbase common.Address
 	if atomic.LoadInt32(&self.mining) == 1 {
-		header.Coinbase = self.coinbase
+		coinbase = self.coinbase
 	}
-	if err := self.engine.Prepare(self.chain, header); err != nil {
+	header, err := self.prepareHeader(parent, tstamp, coinbase)
+	if err != nil {
 		log.Error("Failed to prepare header for mining", "err", err)
 		return
 	}
-	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
-	if daoBlock := self.config.DAOForkBlock; daoBlock != nil {
-		// Check whether the block is among the fork extra-override range
-		limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
-		if header.Number.Cmp(daoBlock) >= 0 && header.Number.Cmp(limit) < 0 {
-			// Depending whether we support or oppose the fork, override differently
-			if self.config.DAOForkSupport {
-				header.Extra = common.CopyBytes(params.DAOForkBlockExtra)
-			} else if bytes.Equal(header.Extra, params.DAOForkBlockExtra) {
-				header.Extra = []byte{} // If miner opposes, don't let it use the reserved extra-data
-			}
-		}
-	}
 	// Could potentially happen if starting to mine in an odd state.
-	err := self.makeCurrent(parent, header)
-	if err != nil {
+	prev := self.current
+	if err := self.makeCurrent(parent, header); err != nil {
 		log.Error("Failed to create mining context", "err", err)
 		return
 	}
@@ -451,8 +690,27 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
-	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
+	txs := self.ordering.Order(work.signer, pending)
+	switch work.commitTransactions(self.mux, txs, self.chain, self.coinbase, interrupt) {
+	case commitInterruptNewHead:
+		// A newer head arrived meanwhile, the work is stale already. Keep serving
+		// the previous pending block until the next one is assembled.
+		self.current = prev
+		return
+	case commitInterruptResubmit:
+		// Notify resubmit loop to increase resubmitting interval due to too frequent commits.
+		ratio := float64(work.header.GasLimit-work.gasPool.Gas()) / float64(work.header.GasLimit)
+		if ratio < 0.1 {
+			ratio = 0.1
+		}
+		self.resubmitAdjustCh <- &intervalAdjust{ratio: ratio, inc: true}
+	default:
+		// Notify resubmit loop to decrease resubmitting interval if current interval is larger
+		// than the user-specified one.
+		if interrupt != nil {
+			self.resubmitAdjustCh <- &intervalAdjust{inc: false}
+		}
+	}
 
 	// compute uncles for the new block.
 	var (
@@ -518,14 +776,29 @@ func (self *worker) updateSnapshot() {
 	self.snapshotState = self.current.state.Copy()
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+// commitTransactions applies transactions to the work until the block is full,
+// the transactions run out or the work is interrupted. It returns the interrupt
+// signal it stopped at, or commitInterruptNone if it ran to completion.
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TransactionSet, bc *core.BlockChain, coinbase common.Address, interrupt *int32) int32 {
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
 	}
 
-	var coalescedLogs []*types.Log
-
+	var (
+		coalescedLogs []*types.Log
+		signal        = commitInterruptNone
+	)
 	for {
+		// In the following two cases, we will interrupt the execution of the transaction.
+		// (1) new head block event arrival, the interrupt signal is 1
+		// (2) worker recommit timer fires, the interrupt signal is 2.
+		// For the first case, the semi-finished work will be discarded.
+		// For the second case, the semi-finished work will be submitted to the consensus engine.
+		if interrupt != nil {
+			if signal = atomic.LoadInt32(interrupt); signal != commitInterruptNone {
+				break
+			}
+		}
 		// If we don't have enough gas for any further transactions then we're done
 		if env.gasPool.Gas() < params.TxGas {
 			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
@@ -601,6 +874,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			}
 		}(cpy, env.tcount)
 	}
+	return signal
 }
 
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, coinbase common.Address, gp *core.GasPool) (error, []*types.Log) {
@@ -0,0 +1,210 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/accounts"
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/consensus/ethash"
+	"github.com/orangeAndSuns/go-ethereum/core"
+	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/core/vm"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/ethdb"
+	"github.com/orangeAndSuns/go-ethereum/event"
+	"github.com/orangeAndSuns/go-ethereum/params"
+)
+
+var (
+	testBankKey, _  = crypto.GenerateKey()
+	testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+	testBankFunds   = big.NewInt(1000000000000000000)
+)
+
+// testWorkerBackend implements Backend on top of an in-memory chain with a
+// funded test account.
+type testWorkerBackend struct {
+	db      ethdb.Database
+	chain   *core.BlockChain
+	txPool  *core.PricedTxPool
+	manager *accounts.Manager
+}
+
+func newTestWorkerBackend(t *testing.T) *testWorkerBackend {
+	var (
+		db     = ethdb.NewMemDatabase()
+		config = params.TestChainConfig
+		gspec  = core.Genesis{
+			Config: config,
+			Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+		}
+	)
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	txpoolConfig := core.DefaultTxPoolConfig
+	txpoolConfig.Journal = ""
+
+	return &testWorkerBackend{
+		db:      db,
+		chain:   chain,
+		txPool:  core.NewTxPool(txpoolConfig, config, chain),
+		manager: accounts.NewManager(),
+	}
+}
+
+func (b *testWorkerBackend) AccountManager() *accounts.Manager { return b.manager }
+func (b *testWorkerBackend) BlockChain() *core.BlockChain      { return b.chain }
+func (b *testWorkerBackend) TxPool() core.TxPool               { return b.txPool }
+func (b *testWorkerBackend) ChainDb() ethdb.Database           { return b.db }
+
+// testAgent is an Agent which never seals anything, only collecting the work
+// pushed to it by the worker.
+type testAgent struct {
+	workCh chan *Work
+}
+
+func (a *testAgent) Work() chan<- *Work         { return a.workCh }
+func (a *testAgent) SetReturnCh(chan<- *Result) {}
+func (a *testAgent) Stop()                      {}
+func (a *testAgent) Start()                     {}
+func (a *testAgent) GetHashRate() int64         { return 0 }
+func (a *testAgent) next(t *testing.T) *Work {
+	select {
+	case work := <-a.workCh:
+		return work
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for new work")
+		return nil
+	}
+}
+
+// Tests that the worker periodically recommits the block being mined, pulling
+// in the transactions which arrived since the last commit.
+func TestRecommitPendingTransactions(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+	agent := &testAgent{workCh: make(chan *Work, 16)}
+	w.register(agent)
+	w.start()
+	defer w.stop()
+
+	if work := agent.next(t); len(work.txs) != 0 {
+		t.Fatalf("initial work has %d transactions, want none", len(work.txs))
+	}
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{2}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testBankKey)
+	if err := backend.txPool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	work := agent.next(t)
+	if len(work.txs) != 1 || work.txs[0].Hash() != tx.Hash() {
+		t.Fatalf("recommitted work has %d transactions, want the added one", len(work.txs))
+	}
+	if work.Block.NumberU64() != 1 {
+		t.Errorf("recommitted work builds block %d, want 1", work.Block.NumberU64())
+	}
+}
+
+// Tests that the recommit interval follows the feedback of the commits: growing
+// when commits are interrupted by resubmits and decaying back to the minimum
+// otherwise, while staying within bounds.
+func TestAdjustInterval(t *testing.T) {
+	backend := newTestWorkerBackend(t)
+	defer backend.chain.Stop()
+	defer backend.txPool.Stop()
+
+	w := newWorker(params.TestChainConfig, ethash.NewFaker(), common.Address{1}, backend, new(event.TypeMux), time.Second, nil)
+	defer w.stop()
+
+	type intervals struct{ min, recommit time.Duration }
+	updates := make(chan intervals)
+	w.resubmitHook = func(min, recommit time.Duration) {
+		updates <- intervals{min, recommit}
+	}
+	adjust := func(ratio float64, inc bool) time.Duration {
+		w.resubmitAdjustCh <- &intervalAdjust{ratio: ratio, inc: inc}
+		select {
+		case update := <-updates:
+			if update.min != 3*time.Second {
+				t.Fatalf("minimum interval mismatch: have %v, want %v", update.min, 3*time.Second)
+			}
+			return update.recommit
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for interval update")
+			return 0
+		}
+	}
+	go w.setRecommitInterval(3 * time.Second)
+	if update := <-updates; update.min != 3*time.Second || update.recommit != 3*time.Second {
+		t.Fatalf("interval mismatch: have %v/%v, want 3s/3s", update.min, update.recommit)
+	}
+	// A resubmit interrupting the commit increases the interval
+	var (
+		prev = float64(3 * time.Second)
+		want = time.Duration(prev*(1-intervalAdjustRatio) + intervalAdjustRatio*(prev/0.8+intervalAdjustBias))
+	)
+	if have := adjust(0.8, true); have != want {
+		t.Fatalf("increased interval mismatch: have %v, want %v", have, want)
+	}
+	// Uninterrupted commits decay it back to the minimum, but not below
+	prev = float64(want)
+	want = time.Duration(prev*(1-intervalAdjustRatio) + intervalAdjustRatio*(float64(3*time.Second)-intervalAdjustBias))
+	if have := adjust(0, false); have != want {
+		t.Fatalf("decreased interval mismatch: have %v, want %v", have, want)
+	}
+	for i := 0; ; i++ {
+		have := adjust(0, false)
+		if have < 3*time.Second {
+			t.Fatalf("interval decreased below the minimum: %v", have)
+		}
+		if have == 3*time.Second {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("interval not decayed to the minimum: %v", have)
+		}
+	}
+	// Repeated resubmits grow it up to the maximum, but not above
+	for i := 0; ; i++ {
+		have := adjust(0.1, true)
+		if have > maxRecommitInterval {
+			t.Fatalf("interval increased above the maximum: %v", have)
+		}
+		if have == maxRecommitInterval {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("interval not grown to the maximum: %v", have)
+		}
+	}
+	// Explicit intervals below the global minimum are sanitized
+	go w.setRecommitInterval(minRecommitInterval / 2)
+	if update := <-updates; update.min != minRecommitInterval || update.recommit != minRecommitInterval {
+		t.Fatalf("interval mismatch: have %v/%v, want %v", update.min, update.recommit, minRecommitInterval)
+	}
+}
@@ -71,12 +71,14 @@ func (t TCPDialer) Dial(dest *discover.Node) (net.Conn, error) {
 type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
+	sources     []nodeSource
 	netrestrict *netutil.Netlist
 
 	lookupRunning bool
 	dialing       map[discover.ESSNodeID]connFlag
 	lookupBuf     []*discover.Node // current discovery lookup results
 	randomNodes   []*discover.Node // filled from Table
+	sourceNodes   []*discover.Node // filled from the node sources
 	static        map[discover.ESSNodeID]*dialTask
 	hist          *dialHistory
 
@@ -92,6 +94,12 @@ type discoverTable interface {
 	ReadRandomNodes([]*discover.Node) int
 }
 
+// nodeSource is a source of dial candidates besides the discovery table, e.g.
+// a DNS node list.
+type nodeSource interface {
+	ReadRandomNodes([]*discover.Node) int
+}
+
 // the dial history remembers recent dials.
 type dialHistory []pastDial
 
@@ -127,15 +135,17 @@ type waitExpireTask struct {
 	time.Duration
 }
 
-func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab discoverTable, maxdyn int, netrestrict *netutil.Netlist) *dialstate {
+func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab discoverTable, sources []nodeSource, maxdyn int, netrestrict *netutil.Netlist) *dialstate {
 	s := &dialstate{
 		maxDynDials: maxdyn,
 		ntab:        ntab,
+		sources:     sources,
 		netrestrict: netrestrict,
 		static:      make(map[discover.ESSNodeID]*dialTask),
 		dialing:     make(map[discover.ESSNodeID]connFlag),
 		bootnodes:   make([]*discover.Node, len(bootnodes)),
 		randomNodes: make([]*discover.Node, maxdyn/2),
+		sourceNodes: make([]*discover.Node, maxdyn),
 		hist:        new(dialHistory),
 	}
 	copy(s.bootnodes, bootnodes)
@@ -218,7 +228,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.ESSNodeID]*Peer, n
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
@@ -226,6 +236,22 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.ESSNodeID]*Peer, n
 			}
 		}
 	}
+	// Use random nodes from the node sources (e.g. DNS node lists) for half of
+	// the remaining dynamic dials. Without a discovery table they are the only
+	// dial candidates, so use them for all dials.
+	sourceCandidates := needDynDials / 2
+	if s.ntab == nil {
+		sourceCandidates = needDynDials
+	}
+	for _, src := range s.sources {
+		n := src.ReadRandomNodes(s.sourceNodes)
+		for i := 0; i < n && sourceCandidates > 0; i++ {
+			if addDial(dynDialedConn, s.sourceNodes[i]) {
+				needDynDials--
+				sourceCandidates--
+			}
+		}
+	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
 	i := 0
@@ -236,7 +262,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.ESSNodeID]*Peer, n
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
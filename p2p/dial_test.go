@@ -84,10 +84,42 @@ func (t fakeTable) Lookup(discover.ESSNodeID) []*discover.Node { return nil }
 func (t fakeTable) Resolve(discover.ESSNodeID) *discover.Node  { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int   { return copy(buf, t) }
 
+// This test checks that dynamic dials are launched from node sources when
+// discovery is disabled.
+func TestDialStateNodeSource(t *testing.T) {
+	source := fakeTable{
+		{ID: uintID(1), IP: net.ParseIP("127.0.0.1"), TCP: 30303},
+		{ID: uintID(2), IP: net.ParseIP("127.0.0.2"), TCP: 30303},
+		{ID: uintID(3), IP: net.ParseIP("127.0.0.3"), TCP: 30303},
+	}
+	runDialTest(t, dialtest{
+		init: newDialState(nil, nil, nil, []nodeSource{source}, 2, nil),
+		rounds: []round{
+			// Dials are launched from the source up to the limit, no lookup is started.
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: source[0]},
+					&dialTask{flags: dynDialedConn, dest: source[1]},
+				},
+			},
+			// Recently dialed nodes are not dialed again.
+			{
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: source[0]},
+					&dialTask{flags: dynDialedConn, dest: source[1]},
+				},
+				new: []task{
+					&waitExpireTask{Duration: 30 * time.Second},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
 	runDialTest(t, dialtest{
-		init: newDialState(nil, nil, fakeTable{}, 5, nil),
+		init: newDialState(nil, nil, fakeTable{}, nil, 5, nil),
 		rounds: []round{
 			// A discovery query is launched.
 			{
@@ -234,7 +266,7 @@ func TestDialStateDynDialBootnode(t *testing.T) {
 		{ID: uintID(8)},
 	}
 	runDialTest(t, dialtest{
-		init: newDialState(nil, bootnodes, table, 5, nil),
+		init: newDialState(nil, bootnodes, table, nil, 5, nil),
 		rounds: []round{
 			// 2 dynamic dials attempted, bootnodes pending fallback interval
 			{
@@ -322,7 +354,7 @@ func TestDialStateDynDialFromTable(t *testing.T) {
 	}
 
 	runDialTest(t, dialtest{
-		init: newDialState(nil, nil, table, 10, nil),
+		init: newDialState(nil, nil, table, nil, 10, nil),
 		rounds: []round{
 			// 5 out of 8 of the nodes returned by ReadRandomNodes are dialed.
 			{
@@ -420,7 +452,7 @@ func TestDialStateNetRestrict(t *testing.T) {
 	restrict.Add("127.0.2.0/24")
 
 	runDialTest(t, dialtest{
-		init: newDialState(nil, nil, table, 10, restrict),
+		init: newDialState(nil, nil, table, nil, 10, restrict),
 		rounds: []round{
 			{
 				new: []task{
@@ -443,7 +475,7 @@ func TestDialStateStaticDial(t *testing.T) {
 	}
 
 	runDialTest(t, dialtest{
-		init: newDialState(wantStatic, nil, fakeTable{}, 0, nil),
+		init: newDialState(wantStatic, nil, fakeTable{}, nil, 0, nil),
 		rounds: []round{
 			// Static dials are launched for the nodes that
 			// aren't yet connected.
@@ -547,7 +579,7 @@ func TestDialStaticAfterReset(t *testing.T) {
 		},
 	}
 	dTest := dialtest{
-		init:   newDialState(wantStatic, nil, fakeTable{}, 0, nil),
+		init:   newDialState(wantStatic, nil, fakeTable{}, nil, 0, nil),
 		rounds: rounds,
 	}
 	runDialTest(t, dTest)
@@ -568,7 +600,7 @@ func TestDialStateCache(t *testing.T) {
 	}
 
 	runDialTest(t, dialtest{
-		init: newDialState(wantStatic, nil, fakeTable{}, 0, nil),
+		init: newDialState(wantStatic, nil, fakeTable{}, nil, 0, nil),
 		rounds: []round{
 			// Static dials are launched for the nodes that
 			// aren't yet connected.
@@ -630,7 +662,7 @@ func TestDialStateCache(t *testing.T) {
 func TestDialResolve(t *testing.T) {
 	resolved := discover.NewNode(uintID(1), net.IP{127, 0, 55, 234}, 3333, 4444)
 	table := &resolveMock{answer: resolved}
-	state := newDialState(nil, nil, table, 0, nil)
+	state := newDialState(nil, nil, table, nil, 0, nil)
 
 	// Check that the task is generated with an incomplete ID.
 	dest := discover.NewNode(uintID(1), nil, 0, 0)
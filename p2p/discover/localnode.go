@@ -0,0 +1,161 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
+)
+
+// LocalNode maintains the signed node record (EIP-778) of the local node.
+//
+// Entries can be added, updated and removed at any time. The record is signed
+// lazily when it is next requested, incrementing its sequence number if the
+// previously handed out version became outdated. The sequence number starts at
+// the current time in milliseconds, so it keeps increasing across restarts
+// without having to be persisted.
+type LocalNode struct {
+	key *ecdsa.PrivateKey
+	id  ESSNodeID
+
+	mu      sync.Mutex
+	seq     uint64                  // sequence number of the next signed record
+	entries map[string]enr.Entry    // entries of the record, by key
+	encoded map[string]rlp.RawValue // encoded entries, to detect redundant updates
+	cur     *Node                   // node for the current record, nil if outdated
+}
+
+// NewLocalNode creates a local node record signed with the given key.
+func NewLocalNode(key *ecdsa.PrivateKey) *LocalNode {
+	return &LocalNode{
+		key:     key,
+		id:      PubkeyID(&key.PublicKey),
+		seq:     uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		entries: make(map[string]enr.Entry),
+		encoded: make(map[string]rlp.RawValue),
+	}
+}
+
+// ID returns the node ID of the local node.
+func (ln *LocalNode) ID() ESSNodeID {
+	return ln.id
+}
+
+// Set adds or updates the given entry in the local record. Setting an entry to
+// its current value doesn't modify the record.
+func (ln *LocalNode) Set(e enr.Entry) {
+	blob, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		panic(fmt.Errorf("discover: can't encode %s: %v", e.ENRKey(), err))
+	}
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if old, ok := ln.encoded[e.ENRKey()]; ok && bytes.Equal(old, blob) {
+		return
+	}
+	ln.entries[e.ENRKey()] = e
+	ln.encoded[e.ENRKey()] = blob
+	ln.invalidate()
+}
+
+// Delete removes the entry with the given key from the local record.
+func (ln *LocalNode) Delete(e enr.Entry) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if _, ok := ln.entries[e.ENRKey()]; ok {
+		delete(ln.entries, e.ENRKey())
+		delete(ln.encoded, e.ENRKey())
+		ln.invalidate()
+	}
+}
+
+// SetEndpoint sets the IP address and port entries of the local record. The IP
+// is omitted if it is unspecified (e.g. when listening on all interfaces), the
+// ports are omitted if zero.
+func (ln *LocalNode) SetEndpoint(ip net.IP, udp, tcp uint16) {
+	if ip == nil || ip.IsUnspecified() {
+		ln.Delete(enr.IP{})
+	} else {
+		ln.Set(enr.IP(ip))
+	}
+	if udp == 0 {
+		ln.Delete(enr.UDP(0))
+	} else {
+		ln.Set(enr.UDP(udp))
+	}
+	if tcp == 0 {
+		ln.Delete(enr.TCP(0))
+	} else {
+		ln.Set(enr.TCP(tcp))
+	}
+}
+
+// Node returns the local node backed by the current signed record.
+func (ln *LocalNode) Node() *Node {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ln.cur == nil {
+		ln.sign()
+	}
+	return ln.cur
+}
+
+// Record returns the current signed record of the local node.
+func (ln *LocalNode) Record() *enr.Record {
+	return ln.Node().Record()
+}
+
+// Seq returns the sequence number of the current signed record.
+func (ln *LocalNode) Seq() uint64 {
+	return ln.Node().Seq()
+}
+
+// invalidate marks the current record outdated. The sequence number is only
+// bumped if the outdated record was already signed, i.e. possibly handed out.
+func (ln *LocalNode) invalidate() {
+	if ln.cur != nil {
+		ln.seq++
+		ln.cur = nil
+	}
+}
+
+// sign assembles and signs the record from the current entries.
+func (ln *LocalNode) sign() {
+	r := new(enr.Record)
+	for _, e := range ln.entries {
+		r.Set(e)
+	}
+	r.SetSeq(ln.seq)
+	if err := enr.SignV4(r, ln.key); err != nil {
+		panic(fmt.Errorf("discover: can't sign local record: %v", err))
+	}
+	n, err := NodeFromRecord(r)
+	if err != nil {
+		panic(fmt.Errorf("discover: invalid local record: %v", err))
+	}
+	ln.cur = n
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
+)
+
+// Tests that the local record is only re-signed with a higher sequence number
+// if it actually changed after being handed out.
+func TestLocalNodeSeq(t *testing.T) {
+	ln := NewLocalNode(newkey())
+	ln.SetEndpoint(net.IP{10, 0, 0, 1}, 30303, 30303)
+
+	n := ln.Node()
+	if n.ID != ln.ID() {
+		t.Fatalf("node ID mismatch: have %v, want %v", n.ID, ln.ID())
+	}
+	if !n.IP.Equal(net.IP{10, 0, 0, 1}) || n.UDP != 30303 || n.TCP != 30303 {
+		t.Fatalf("endpoint mismatch: have %v:%d/%d", n.IP, n.UDP, n.TCP)
+	}
+	seq := ln.Seq()
+
+	// Setting an entry to its current value must not bump the sequence number
+	ln.Set(enr.TCP(30303))
+	if ln.Seq() != seq {
+		t.Fatalf("seq changed by redundant update: have %d, want %d", ln.Seq(), seq)
+	}
+	// Multiple updates before re-signing must only bump the sequence number once
+	ln.Set(enr.TCP(30304))
+	ln.Set(enr.WithEntry("foo", "bar"))
+	if ln.Seq() != seq+1 {
+		t.Fatalf("seq mismatch after update: have %d, want %d", ln.Seq(), seq+1)
+	}
+	if ln.Node().TCP != 30304 {
+		t.Fatalf("TCP port mismatch: have %d, want %d", ln.Node().TCP, 30304)
+	}
+	// Deleting entries must bump the sequence number too
+	ln.SetEndpoint(nil, 30303, 30304)
+	if ln.Seq() != seq+2 {
+		t.Fatalf("seq mismatch after delete: have %d, want %d", ln.Seq(), seq+2)
+	}
+	if !ln.Node().Incomplete() {
+		t.Fatalf("node without IP is complete")
+	}
+}
@@ -19,6 +19,7 @@ package discover
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -34,6 +35,8 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/crypto/secp256k1"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
+	"github.com/orangeAndSuns/go-ethereum/rlp"
 )
 
 const ESSNodeIDBits = 512
@@ -54,6 +57,9 @@ type Node struct {
 
 	// Time when the node was added to the table.
 	addedAt time.Time
+
+	// Signed node record (EIP-778) the node was created from, if any.
+	record *enr.Record
 }
 
 // NewNode creates a new node. It is mostly meant to be used for
@@ -71,6 +77,57 @@ func NewNode(id ESSNodeID, ip net.IP, udpPort, tcpPort uint16) *Node {
 	}
 }
 
+// NodeFromRecord creates a node from a signed node record. The record must use
+// the "v4" identity scheme. Records without an IP address yield incomplete nodes
+// and a missing UDP port defaults to the TCP port.
+func NodeFromRecord(r *enr.Record) (*Node, error) {
+	if !r.Signed() {
+		return nil, errors.New("unsigned node record")
+	}
+	var scheme enr.ID
+	if err := r.Load(&scheme); err != nil {
+		return nil, err
+	}
+	if scheme != enr.IDv4 {
+		return nil, fmt.Errorf("unsupported identity scheme %q", scheme)
+	}
+	var pubkey enr.Secp256k1
+	if err := r.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	var (
+		ip  enr.IP
+		tcp enr.TCP
+		udp enr.UDP
+	)
+	for _, entry := range []enr.Entry{&ip, &tcp, &udp} {
+		if err := r.Load(entry); err != nil && !enr.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	if udp == 0 {
+		udp = enr.UDP(tcp)
+	}
+	n := NewNode(PubkeyID((*ecdsa.PublicKey)(&pubkey)), net.IP(ip), uint16(udp), uint16(tcp))
+	n.record = r
+	return n, nil
+}
+
+// Record returns the signed node record the node was created from, or nil if
+// the node isn't backed by a record.
+func (n *Node) Record() *enr.Record {
+	return n.record
+}
+
+// Seq returns the sequence number of the node's record, or zero if the node
+// isn't backed by a record.
+func (n *Node) Seq() uint64 {
+	if n.record == nil {
+		return 0
+	}
+	return n.record.Seq()
+}
+
 func (n *Node) addr() *net.UDPAddr {
 	return &net.UDPAddr{IP: n.IP, Port: int(n.UDP)}
 }
@@ -117,6 +174,9 @@ func (n *Node) String() string {
 
 var incompleteNodeURL = regexp.MustCompile("(?i)^(?:essnode://)?([0-9a-f]+)$")
 
+// recordURLPrefix is the prefix of the textual form of node records.
+const recordURLPrefix = "enr:"
+
 // ParseNode parses a node designator.
 //
 // There are two basic forms of node designators
@@ -125,8 +185,8 @@ var incompleteNodeURL = regexp.MustCompile("(?i)^(?:essnode://)?([0-9a-f]+)$")
 //
 // For incomplete nodes, the designator must look like one of these
 //
-//    essnode://<hex node id>
-//    <hex node id>
+//	essnode://<hex node id>
+//	<hex node id>
 //
 // For complete nodes, the node ID is encoded in the username portion
 // of the URL, separated from the host by an @ sign. The hostname can
@@ -139,8 +199,17 @@ var incompleteNodeURL = regexp.MustCompile("(?i)^(?:essnode://)?([0-9a-f]+)$")
 // a node with IP address 10.3.58.6, TCP listening port 30303
 // and UDP discovery port 30301.
 //
-//    essnode://<hex node id>@10.3.58.6:30303?discport=30301
+//	essnode://<hex node id>@10.3.58.6:30303?discport=30301
+//
+// Signed node records are also accepted in their textual form, which is the
+// URL-safe base64 encoding (without padding) of the RLP encoded record with
+// an "enr:" prefix:
+//
+//	enr:<base64 record>
 func ParseNode(rawurl string) (*Node, error) {
+	if strings.HasPrefix(rawurl, recordURLPrefix) {
+		return parseRecordURL(rawurl)
+	}
 	if m := incompleteNodeURL.FindStringSubmatch(rawurl); m != nil {
 		id, err := HexID(m[1])
 		if err != nil {
@@ -198,6 +267,28 @@ func parseComplete(rawurl string) (*Node, error) {
 	return NewNode(id, ip, uint16(udpPort), uint16(tcpPort)), nil
 }
 
+func parseRecordURL(rawurl string) (*Node, error) {
+	blob, err := base64.RawURLEncoding.DecodeString(rawurl[len(recordURLPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("invalid record encoding (%v)", err)
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(blob, &r); err != nil {
+		return nil, fmt.Errorf("invalid node record (%v)", err)
+	}
+	return NodeFromRecord(&r)
+}
+
+// RecordURL returns the textual "enr:" form of a signed node record, as accepted
+// by ParseNode. It returns an empty string for unsigned records.
+func RecordURL(r *enr.Record) string {
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return ""
+	}
+	return recordURLPrefix + base64.RawURLEncoding.EncodeToString(blob)
+}
+
 // MustParseNode parses a node URL. It panics if the URL is not valid.
 func MustParseNode(rawurl string) *Node {
 	n, err := ParseNode(rawurl)
@@ -29,7 +29,9 @@ import (
 	"time"
 
 	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
 )
 
 func ExampleNewNode() {
@@ -169,6 +171,51 @@ func TestParseNode(t *testing.T) {
 	}
 }
 
+// Tests parsing of the textual node record form, using the example record of
+// EIP-778 and a freshly signed one.
+func TestParseNodeRecord(t *testing.T) {
+	n, err := ParseNode("enr:-IS4QHCYrYZbAKWCBRlAy5zzaDZXJBGkcnh4MHcBFZntXNFrdvJjX04jRzjzCBOonrkTfj499SZuOh8R33Ls8RRcy5wBgmlkgnY0gmlwhH8AAAGJc2VjcDI1NmsxoQPKY0yuDUmstAHYpMa2_oxVtw0RW_QAdpzBQA8yWM0xOIN1ZHCCdl8")
+	if err != nil {
+		t.Fatalf("failed to parse example record: %v", err)
+	}
+	pubkey, err := n.ID.Pubkey()
+	if err != nil {
+		t.Fatalf("invalid node ID: %v", err)
+	}
+	if have, want := hexutil.Encode(crypto.CompressPubkey(pubkey)), "0x03ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd3138"; have != want {
+		t.Errorf("public key mismatch: have %s, want %s", have, want)
+	}
+	if !n.IP.Equal(net.IP{127, 0, 0, 1}) || n.UDP != 30303 || n.TCP != 0 || n.Seq() != 1 {
+		t.Errorf("example record mismatch: ip %v, udp %d, tcp %d, seq %d", n.IP, n.UDP, n.TCP, n.Seq())
+	}
+	// Sign a new record and ensure it round-trips through its textual form
+	key := newkey()
+
+	var r enr.Record
+	r.Set(enr.IP(net.IP{10, 3, 58, 6}))
+	r.Set(enr.TCP(30303))
+	r.Set(enr.UDP(30301))
+	r.SetSeq(7)
+	if err := enr.SignV4(&r, key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	n, err = ParseNode(RecordURL(&r))
+	if err != nil {
+		t.Fatalf("failed to parse signed record: %v", err)
+	}
+	want := NewNode(PubkeyID(&key.PublicKey), net.IP{10, 3, 58, 6}, 30301, 30303)
+	if n.String() != want.String() || n.Seq() != 7 {
+		t.Errorf("signed record mismatch: have %v (seq %d), want %v (seq 7)", n, n.Seq(), want)
+	}
+	// Corrupted records must be rejected
+	url := RecordURL(&r)
+	for _, bad := range []string{"enr:", "enr:!!!", url[:len(url)-4] + "AAAA"} {
+		if _, err := ParseNode(bad); err == nil {
+			t.Errorf("invalid record %q parsed successfully", bad)
+		}
+	}
+}
+
 func TestNodeString(t *testing.T) {
 	for i, test := range parseNodeTests {
 		if test.wantError == "" && strings.HasPrefix(test.rawurl, "essnode://") {
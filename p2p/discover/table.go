@@ -77,15 +77,17 @@ type Table struct {
 
 	nodeAddedHook func(*Node) // for testing
 
-	net  transport
-	self *Node // metadata of the local node
+	net       transport
+	self      *Node      // metadata of the local node
+	localNode *LocalNode // signed record of the local node, nil without UDP transport
 }
 
 // transport is implemented by the UDP transport.
 // it is an interface so we can test without opening lots of UDP
 // sockets and without generating a private key.
 type transport interface {
-	ping(ESSNodeID, *net.UDPAddr) error
+	ping(ESSNodeID, *net.UDPAddr) (seq uint64, err error)
+	requestENR(*Node) (*Node, error)
 	findnode(toid ESSNodeID, addr *net.UDPAddr, target ESSNodeID) ([]*Node, error)
 	close()
 }
@@ -148,6 +150,17 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// LocalNode returns the signed record of the local node.
+func (tab *Table) LocalNode() *LocalNode {
+	return tab.localNode
+}
+
+// RequestENR fetches the signed record of the given node. The returned node is
+// backed by the fetched record if it is newer than the one n is backed by.
+func (tab *Table) RequestENR(n *Node) (*Node, error) {
+	return tab.net.requestENR(n)
+}
+
 // ReadRandomNodes fills the given slice with random nodes from the
 // table. It will not write the same node more than once. The nodes in
 // the slice are copies and can be modified by the caller.
@@ -459,8 +472,20 @@ func (tab *Table) doRevalidate(done chan<- struct{}) {
 	}
 
 	// Ping the selected node and wait for a pong.
-	err := tab.net.ping(last.ID, last.addr())
-
+	remoteSeq, err := tab.net.ping(last.ID, last.addr())
+
+	// Also fetch the node's record if it announced a newer one. Records with a
+	// different endpoint are ignored, the node has to be rediscovered instead.
+	if err == nil && remoteSeq > last.Seq() {
+		n, rerr := tab.net.requestENR(last)
+		switch {
+		case rerr != nil:
+			log.Debug("ENR request failed", "id", last.ID, "addr", last.addr(), "err", rerr)
+		case n.IP.Equal(last.IP) && n.UDP == last.UDP:
+			n.addedAt = last.addedAt
+			last = n
+		}
+	}
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
 	b := tab.buckets[bi]
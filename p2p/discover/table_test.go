@@ -30,6 +30,7 @@ import (
 
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
 )
 
 func TestTable_pingReplace(t *testing.T) {
@@ -164,6 +165,47 @@ func TestTable_BucketIPLimit(t *testing.T) {
 	}
 }
 
+// This checks that revalidation picks up newer records announced in pongs.
+func TestTable_revalidateSyncRecord(t *testing.T) {
+	transport := newPingRecorder()
+	tab, _ := newTable(transport, ESSNodeID{}, &net.UDPAddr{}, "", nil)
+	defer tab.Close()
+
+	<-tab.initDone
+
+	// Insert a node without a record.
+	key := newkey()
+	n := NewNode(PubkeyID(&key.PublicKey), net.IP{127, 0, 0, 1}, 30303, 30303)
+	tab.add(n)
+
+	// Update the node record.
+	var r enr.Record
+	r.Set(enr.IP(n.IP))
+	r.Set(enr.UDP(n.UDP))
+	r.Set(enr.TCP(30304))
+	r.SetSeq(1)
+	if err := enr.SignV4(&r, key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	n2, err := NodeFromRecord(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.records[n.ID] = n2
+
+	tab.doRevalidate(make(chan struct{}, 1))
+
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+	entries := tab.bucket(n.sha).entries
+	if len(entries) != 1 {
+		t.Fatalf("wrong bucket size: got %d, want 1", len(entries))
+	}
+	if got := entries[0]; got.Seq() != 1 || got.TCP != 30304 {
+		t.Errorf("node record not updated: got seq %d, TCP %d", got.Seq(), got.TCP)
+	}
+}
+
 // fillBucket inserts nodes into the given bucket until
 // it is full. The node's IDs dont correspond to their
 // hashes.
@@ -189,12 +231,14 @@ func nodeAtDistance(base common.Hash, ld int) (n *Node) {
 type pingRecorder struct {
 	mu           sync.Mutex
 	dead, pinged map[ESSNodeID]bool
+	records      map[ESSNodeID]*Node // record-backed nodes served via requestENR
 }
 
 func newPingRecorder() *pingRecorder {
 	return &pingRecorder{
-		dead:   make(map[ESSNodeID]bool),
-		pinged: make(map[ESSNodeID]bool),
+		dead:    make(map[ESSNodeID]bool),
+		pinged:  make(map[ESSNodeID]bool),
+		records: make(map[ESSNodeID]*Node),
 	}
 }
 
@@ -202,16 +246,28 @@ func (t *pingRecorder) findnode(toid ESSNodeID, toaddr *net.UDPAddr, target ESSN
 	return nil, nil
 }
 
-func (t *pingRecorder) ping(toid ESSNodeID, toaddr *net.UDPAddr) error {
+func (t *pingRecorder) ping(toid ESSNodeID, toaddr *net.UDPAddr) (uint64, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	t.pinged[toid] = true
 	if t.dead[toid] {
-		return errTimeout
-	} else {
-		return nil
+		return 0, errTimeout
+	}
+	if n := t.records[toid]; n != nil {
+		return n.Seq(), nil
+	}
+	return 0, nil
+}
+
+func (t *pingRecorder) requestENR(n *Node) (*Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dead[n.ID] || t.records[n.ID] == nil {
+		return nil, errTimeout
 	}
+	return t.records[n.ID], nil
 }
 
 func (t *pingRecorder) close() {}
@@ -570,9 +626,12 @@ func (tn *preminedTestnet) findnode(toid ESSNodeID, toaddr *net.UDPAddr, target
 	return result, nil
 }
 
-func (*preminedTestnet) close()                                         {}
-func (*preminedTestnet) waitping(from ESSNodeID) error                  { return nil }
-func (*preminedTestnet) ping(toid ESSNodeID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) close()                        {}
+func (*preminedTestnet) waitping(from ESSNodeID) error { return nil }
+func (*preminedTestnet) ping(toid ESSNodeID, toaddr *net.UDPAddr) (uint64, error) {
+	return 0, nil
+}
+func (*preminedTestnet) requestENR(n *Node) (*Node, error) { return n, nil }
 
 // mine generates a testnet struct literal with nodes at
 // various distances to the given target.
@@ -27,6 +27,7 @@ import (
 
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
 	"github.com/orangeAndSuns/go-ethereum/p2p/nat"
 	"github.com/orangeAndSuns/go-ethereum/p2p/netutil"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
@@ -42,6 +43,7 @@ var (
 	errTimeout          = errors.New("RPC timeout")
 	errClockWarp        = errors.New("reply deadline too far in the future")
 	errClosed           = errors.New("socket closed")
+	errInvalidRecord    = errors.New("record does not belong to node")
 )
 
 // Timeouts
@@ -60,6 +62,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -68,7 +72,8 @@ type (
 		Version    uint
 		From, To   rpcEndpoint
 		Expiration uint64
-		// Ignore additional fields (for forward compatibility).
+		// Additional fields, the first one being the sender's ENR sequence
+		// number (EIP-868). Others are ignored (for forward compatibility).
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
@@ -81,7 +86,8 @@ type (
 
 		ReplyTok   []byte // This contains the hash of the ping packet.
 		Expiration uint64 // Absolute timestamp at which the packet becomes invalid.
-		// Ignore additional fields (for forward compatibility).
+		// Additional fields, the first one being the sender's ENR sequence
+		// number (EIP-868). Others are ignored (for forward compatibility).
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
@@ -101,6 +107,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest queries for the remote node's record.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -115,6 +136,23 @@ type (
 	}
 )
 
+// makeSeqRest encodes an ENR sequence number as the additional fields of a
+// ping or pong packet.
+func makeSeqRest(seq uint64) []rlp.RawValue {
+	blob, _ := rlp.EncodeToBytes(seq)
+	return []rlp.RawValue{blob}
+}
+
+// seqFromRest decodes the ENR sequence number from the additional fields of a
+// ping or pong packet. Zero is returned if the sender didn't include one.
+func seqFromRest(rest []rlp.RawValue) uint64 {
+	var seq uint64
+	if len(rest) > 0 {
+		rlp.DecodeBytes(rest[0], &seq)
+	}
+	return seq
+}
+
 func makeEndpoint(addr *net.UDPAddr, tcpPort uint16) rpcEndpoint {
 	ip := addr.IP.To4()
 	if ip == nil {
@@ -160,6 +198,7 @@ type udp struct {
 	netrestrict *netutil.Netlist
 	priv        *ecdsa.PrivateKey
 	ourEndpoint rpcEndpoint
+	localNode   *LocalNode
 
 	addpending chan *pending
 	gotreply   chan reply
@@ -219,6 +258,7 @@ type Config struct {
 	PrivateKey *ecdsa.PrivateKey
 
 	// These settings are optional:
+	LocalNode    *LocalNode        // local node record, created from PrivateKey if nil
 	AnnounceAddr *net.UDPAddr      // local address announced in the DHT
 	NodeDBPath   string            // if set, the node database is stored at this filesystem location
 	NetRestrict  *netutil.Netlist  // network whitelist
@@ -251,11 +291,24 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	}
 	// TODO: separate TCP port
 	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
+
+	// Maintain the local record. If the caller provided one, it is responsible
+	// for announcing the TCP endpoint, otherwise assume it's the UDP one.
+	if udp.localNode = cfg.LocalNode; udp.localNode == nil {
+		udp.localNode = NewLocalNode(cfg.PrivateKey)
+		udp.localNode.Set(enr.TCP(realaddr.Port))
+	}
+	if ip := realaddr.IP; ip != nil && !ip.IsUnspecified() {
+		udp.localNode.Set(enr.IP(ip))
+	}
+	udp.localNode.Set(enr.UDP(realaddr.Port))
+
 	tab, err := newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes)
 	if err != nil {
 		return nil, nil, err
 	}
 	udp.Table = tab
+	tab.localNode = udp.localNode
 
 	go udp.loop()
 	go udp.readLoop(cfg.Unhandled)
@@ -268,19 +321,22 @@ func (t *udp) close() {
 	// TODO: wait for the loops to end.
 }
 
-// ping sends a ping message to the given node and waits for a reply.
-func (t *udp) ping(toid ESSNodeID, toaddr *net.UDPAddr) error {
-	return <-t.sendPing(toid, toaddr, nil)
+// ping sends a ping message to the given node and waits for a reply. The ENR
+// sequence number announced in the reply is returned.
+func (t *udp) ping(toid ESSNodeID, toaddr *net.UDPAddr) (seq uint64, err error) {
+	err = <-t.sendPing(toid, toaddr, func(p *pong) { seq = seqFromRest(p.Rest) })
+	return seq, err
 }
 
 // sendPing sends a ping message to the given node and invokes the callback
 // when the reply arrives.
-func (t *udp) sendPing(toid ESSNodeID, toaddr *net.UDPAddr, callback func()) <-chan error {
+func (t *udp) sendPing(toid ESSNodeID, toaddr *net.UDPAddr, callback func(*pong)) <-chan error {
 	req := &ping{
 		Version:    4,
 		From:       t.ourEndpoint,
 		To:         makeEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       makeSeqRest(t.localNode.Seq()),
 	}
 	packet, hash, err := encodePacket(t.priv, pingPacket, req)
 	if err != nil {
@@ -291,7 +347,7 @@ func (t *udp) sendPing(toid ESSNodeID, toaddr *net.UDPAddr, callback func()) <-c
 	errc := t.pending(toid, pongPacket, func(p interface{}) bool {
 		ok := bytes.Equal(p.(*pong).ReplyTok, hash)
 		if ok && callback != nil {
-			callback()
+			callback(p.(*pong))
 		}
 		return ok
 	})
@@ -306,12 +362,7 @@ func (t *udp) waitping(from ESSNodeID) error {
 // findnode sends a findnode request to the given node and waits until
 // the node has sent up to k neighbors.
 func (t *udp) findnode(toid ESSNodeID, toaddr *net.UDPAddr, target ESSNodeID) ([]*Node, error) {
-	// If we haven't seen a ping from the destination node for a while, it won't remember
-	// our endpoint proof and reject findnode. Solicit a ping first.
-	if time.Since(t.db.lastPingReceived(toid)) > nodeDBNodeExpiration {
-		t.ping(toid, toaddr)
-		t.waitping(toid)
-	}
+	t.ensureBond(toid, toaddr)
 
 	nodes := make([]*Node, 0, bucketSize)
 	nreceived := 0
@@ -335,6 +386,57 @@ func (t *udp) findnode(toid ESSNodeID, toaddr *net.UDPAddr, target ESSNodeID) ([
 	return nodes, <-errc
 }
 
+// requestENR sends an ENR request to the given node and waits for the response.
+// The returned node is backed by the received record if it is newer than the
+// one the given node is backed by, otherwise the given node is returned.
+func (t *udp) requestENR(n *Node) (*Node, error) {
+	addr := n.addr()
+	t.ensureBond(n.ID, addr)
+
+	req := &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, hash, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	var record *enr.Record
+	errc := t.pending(n.ID, enrResponsePacket, func(r interface{}) bool {
+		resp := r.(*enrResponse)
+		if !bytes.Equal(resp.ReplyTok, hash) {
+			return false
+		}
+		record = &resp.Record
+		return true
+	})
+	t.write(addr, req.name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	// Verify the response record belongs to the node and is newer
+	rn, err := NodeFromRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	if rn.ID != n.ID {
+		return nil, errInvalidRecord
+	}
+	if rn.Seq() <= n.Seq() {
+		return n, nil
+	}
+	return rn, nil
+}
+
+// ensureBond solicits a ping from the node if we haven't seen one for a while,
+// since the node won't remember our endpoint proof otherwise and will reject
+// our findnode and ENR requests.
+func (t *udp) ensureBond(toid ESSNodeID, toaddr *net.UDPAddr) {
+	if time.Since(t.db.lastPingReceived(toid)) > nodeDBNodeExpiration {
+		t.ping(toid, toaddr)
+		t.waitping(toid)
+	}
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id ESSNodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -586,6 +688,10 @@ func decodePacket(buf []byte) (packet, ESSNodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -602,6 +708,7 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID ESSNodeID, mac []byte)
 		To:         makeEndpoint(from, req.From.TCP),
 		ReplyTok:   mac,
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       makeSeqRest(t.localNode.Seq()),
 	})
 	t.handleReply(fromID, pingPacket, req)
 
@@ -609,7 +716,7 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID ESSNodeID, mac []byte)
 	// recorded in the database so their findnode requests will be accepted later.
 	n := NewNode(fromID, from.IP, uint16(from.Port), req.From.TCP)
 	if time.Since(t.db.lastPongReceived(fromID)) > nodeDBNodeExpiration {
-		t.sendPing(fromID, from, func() { t.addThroughPing(n) })
+		t.sendPing(fromID, from, func(*pong) { t.addThroughPing(n) })
 	} else {
 		t.addThroughPing(n)
 	}
@@ -684,6 +791,32 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID ESSNodeID, mac []
 
 func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID ESSNodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.db.hasBond(fromID) {
+		// No endpoint proof pong exists, don't reply to avoid traffic amplification.
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, &enrResponse{
+		ReplyTok: mac,
+		Record:   *t.localNode.Record(),
+	})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID ESSNodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
 )
 
@@ -128,6 +129,7 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
 	test.packetIn(errUnknownNode, findnodePacket, &findnode{Expiration: futureExp})
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
 }
 
 func TestUDP_pingTimeout(t *testing.T) {
@@ -137,7 +139,7 @@ func TestUDP_pingTimeout(t *testing.T) {
 
 	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
 	toid := ESSNodeID{1, 2, 3, 4}
-	if err := test.udp.ping(toid, toaddr); err != errTimeout {
+	if _, err := test.udp.ping(toid, toaddr); err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
 }
@@ -347,6 +349,9 @@ func TestUDP_successfulPing(t *testing.T) {
 		if !reflect.DeepEqual(p.To, wantTo) {
 			t.Errorf("got pong.To %v, want %v", p.To, wantTo)
 		}
+		if seq := seqFromRest(p.Rest); seq != test.udp.localNode.Seq() {
+			t.Errorf("got pong ENR seq %d, want %d", seq, test.udp.localNode.Seq())
+		}
 	})
 
 	// remote is unknown, the table pings back.
@@ -388,6 +393,73 @@ func TestUDP_successfulPing(t *testing.T) {
 	}
 }
 
+func TestUDP_ENRRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// ensure there's a bond with the test node,
+	// ENR requests won't be accepted otherwise.
+	test.table.db.updateLastPongReceived(PubkeyID(&test.remotekey.PublicKey), time.Now())
+
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		if hash := test.sent[0][:macSize]; !bytes.Equal(p.ReplyTok, hash) {
+			t.Errorf("got enrResponse.ReplyTok %x, want %x", p.ReplyTok, hash)
+		}
+		n, err := NodeFromRecord(&p.Record)
+		if err != nil {
+			t.Fatalf("invalid record in response: %v", err)
+		}
+		if n.ID != PubkeyID(&test.localkey.PublicKey) {
+			t.Errorf("got record of %v, want %v", n.ID, PubkeyID(&test.localkey.PublicKey))
+		}
+		if n.Seq() != test.udp.localNode.Seq() {
+			t.Errorf("got record seq %d, want %d", n.Seq(), test.udp.localNode.Seq())
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := PubkeyID(&test.remotekey.PublicKey)
+	test.table.db.updateLastPingReceived(rid, time.Now())
+
+	// Sign the record the remote side will respond with
+	var record enr.Record
+	record.Set(enr.IP(test.remoteaddr.IP))
+	record.Set(enr.UDP(test.remoteaddr.Port))
+	record.Set(enr.TCP(testRemote.TCP))
+	record.SetSeq(5)
+	if err := enr.SignV4(&record, test.remotekey); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	// queue a pending ENR request
+	resultc, errc := make(chan *Node, 1), make(chan error, 1)
+	go func() {
+		n, err := test.udp.requestENR(NewNode(rid, test.remoteaddr.IP, uint16(test.remoteaddr.Port), testRemote.TCP))
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- n
+		}
+	}()
+	hash, _ := test.waitPacketOut(func(p *enrRequest) {})
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: record})
+
+	select {
+	case n := <-resultc:
+		if n.ID != rid || n.Seq() != 5 || n.TCP != testRemote.TCP {
+			t.Errorf("result mismatch: got %v (seq %d)", n, n.Seq())
+		}
+	case err := <-errc:
+		t.Errorf("ENR request error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("ENR request did not return within 5 seconds")
+	}
+}
+
 var testPackets = []struct {
 	input      string
 	wantPacket interface{}
@@ -0,0 +1,431 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/log"
+	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
+)
+
+var (
+	errNoRoot        = errors.New("no valid root found")
+	errNoEntry       = errors.New("no valid tree entry found")
+	errHashMismatch  = errors.New("hash mismatch")
+	errENRInLinkTree = errors.New("enr entry in link tree")
+	errLinkInENRTree = errors.New("link entry in ENR tree")
+	errStaleRoot     = errors.New("root sequence number lower than synced root")
+)
+
+// retryInterval is the initial delay before a failed refresh of the trees is
+// retried. It doubles on every consecutive failure, up to the recheck interval.
+var retryInterval = 5 * time.Second
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache
+}
+
+// Config holds configuration options for the DNS discovery client.
+type Config struct {
+	Timeout         time.Duration // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration // time between tree root update checks (default 30min)
+	CacheLimit      int           // maximum number of cached records (default 1000)
+	Resolver        Resolver      // the DNS resolver to use (defaults to system DNS)
+	Logger          log.Logger    // destination of client log messages (defaults to root logger)
+}
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	const (
+		defaultTimeout = 5 * time.Second
+		defaultRecheck = 30 * time.Minute
+		defaultCache   = 1000
+	)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = defaultRecheck
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = defaultCache
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// NewClient creates a client.
+func NewClient(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+	cache, err := lru.New(cfg.CacheLimit)
+	if err != nil {
+		panic(err)
+	}
+	return &Client{cfg: cfg, entries: cache}
+}
+
+// SyncTree downloads the entire node tree at the given URL. Links to other trees
+// are returned but not followed.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ct := newClientTree(c, le)
+	t := &Tree{entries: make(map[string]entry)}
+	if err := ct.syncAll(context.Background(), t.entries); err != nil {
+		return nil, err
+	}
+	t.root = ct.root
+	return t, nil
+}
+
+// NewSource creates a node source which keeps the given trees, and all trees
+// linked from them, synced in the background.
+func (c *Client) NewSource(urls ...string) (*Source, error) {
+	s := &Source{
+		c:     c,
+		trees: make(map[string]*clientTree),
+	}
+	for _, url := range urls {
+		le, err := parseLink(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+		}
+		s.roots = append(s.roots, le.String())
+		s.trees[le.String()] = newClientTree(c, le)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+// resolveRoot retrieves a root entry via DNS and verifies its signature.
+func (c *Client) resolveRoot(ctx context.Context, loc *linkEntry) (rootEntry, error) {
+	txts, err := c.lookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			e, err := parseRoot(txt)
+			if err != nil {
+				return e, nameError{loc.domain, err}
+			}
+			if !e.verifySignature(loc.pubkey) {
+				return e, nameError{loc.domain, entryError{typ: "root", err: errInvalidSig}}
+			}
+			return e, nil
+		}
+	}
+	return rootEntry{}, nameError{loc.domain, errNoRoot}
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	cacheKey := hash + "." + domain
+	if e, ok := c.entries.Get(cacheKey); ok {
+		return e.(entry), nil
+	}
+	e, err := c.doResolveEntry(ctx, domain, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Add(cacheKey, e)
+	return e, nil
+}
+
+// doResolveEntry fetches an entry via DNS.
+func (c *Client) doResolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	wantHash, err := b32format.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 hash")
+	}
+	name := hash + "." + domain
+	txts, err := c.lookupTXT(ctx, name)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt)
+		if err == errUnknownEntry {
+			continue
+		}
+		if !bytes.HasPrefix(crypto.Keccak256([]byte(txt)), wantHash) {
+			err = nameError{name, errHashMismatch}
+		} else if err != nil {
+			err = nameError{name, err}
+		}
+		return e, err
+	}
+	return nil, nameError{name, errNoEntry}
+}
+
+// lookupTXT performs a single TXT lookup, applying the configured timeout.
+func (c *Client) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	return c.cfg.Resolver.LookupTXT(ctx, name)
+}
+
+// clientTree is a tree being synced by the client.
+type clientTree struct {
+	c     *Client
+	loc   *linkEntry
+	root  *rootEntry
+	nodes []*discover.Node // nodes of the last synced tree version
+	links []string         // links of the last synced tree version
+}
+
+func newClientTree(c *Client, loc *linkEntry) *clientTree {
+	return &clientTree{c: c, loc: loc}
+}
+
+// update checks the root of the tree and resyncs the tree if the root changed.
+func (ct *clientTree) update(ctx context.Context) error {
+	root, err := ct.c.resolveRoot(ctx, ct.loc)
+	if err != nil {
+		return err
+	}
+	if ct.root != nil && root.seq < ct.root.seq {
+		return nameError{ct.loc.domain, errStaleRoot}
+	}
+	if ct.root != nil && ct.root.eroot == root.eroot && ct.root.lroot == root.lroot {
+		return nil
+	}
+	t := &Tree{entries: make(map[string]entry)}
+	if err := ct.syncSubtrees(ctx, &root, t.entries); err != nil {
+		return err
+	}
+	ct.root, ct.nodes, ct.links = &root, t.Nodes(), t.Links()
+	return nil
+}
+
+// syncAll retrieves the root and all entries of the tree.
+func (ct *clientTree) syncAll(ctx context.Context, dest map[string]entry) error {
+	root, err := ct.c.resolveRoot(ctx, ct.loc)
+	if err != nil {
+		return err
+	}
+	if err := ct.syncSubtrees(ctx, &root, dest); err != nil {
+		return err
+	}
+	ct.root = &root
+	return nil
+}
+
+// syncSubtrees retrieves the node and link subtrees of the given root.
+func (ct *clientTree) syncSubtrees(ctx context.Context, root *rootEntry, dest map[string]entry) error {
+	if err := ct.syncSubtree(ctx, root.eroot, false, dest); err != nil {
+		return err
+	}
+	return ct.syncSubtree(ctx, root.lroot, true, dest)
+}
+
+// syncSubtree retrieves the entry with the given hash and everything below it.
+func (ct *clientTree) syncSubtree(ctx context.Context, hash string, link bool, dest map[string]entry) error {
+	if _, ok := dest[hash]; ok {
+		return nil
+	}
+	e, err := ct.c.resolveEntry(ctx, ct.loc.domain, hash)
+	if err != nil {
+		return err
+	}
+	dest[hash] = e
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := ct.syncSubtree(ctx, child, link, dest); err != nil {
+				return err
+			}
+		}
+	case *enrEntry:
+		if link {
+			return errENRInLinkTree
+		}
+	case *linkEntry:
+		if !link {
+			return errLinkInENRTree
+		}
+	}
+	return nil
+}
+
+// Source keeps the nodes of a set of DNS trees up to date in the background and
+// serves random nodes out of them. Links to other trees are followed.
+type Source struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// These fields are only accessed by loop.
+	roots []string               // URLs of the configured trees
+	trees map[string]*clientTree // all trees reachable from roots, by URL
+
+	mu    sync.Mutex
+	nodes []*discover.Node // union of the nodes of all trees
+}
+
+// ReadRandomNodes fills the given slice with random nodes from the synced trees.
+// It returns the number of nodes written.
+func (s *Source) ReadRandomNodes(buf []*discover.Node) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := 0
+	for _, j := range rand.Perm(len(s.nodes)) {
+		if i == len(buf) {
+			break
+		}
+		buf[i] = s.nodes[j]
+		i++
+	}
+	return i
+}
+
+// Close stops the background syncing of the source.
+func (s *Source) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Source) loop() {
+	defer s.wg.Done()
+
+	var (
+		timer = time.NewTimer(0)
+		retry time.Duration
+	)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if err := s.refresh(); err == nil {
+				retry = 0
+				timer.Reset(s.c.cfg.RecheckInterval)
+				continue
+			}
+			// Some tree failed to update, try again soon with exponential backoff
+			if retry == 0 {
+				retry = retryInterval
+			} else {
+				retry *= 2
+			}
+			if retry > s.c.cfg.RecheckInterval {
+				retry = s.c.cfg.RecheckInterval
+			}
+			timer.Reset(retry)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh updates all trees reachable from the configured roots and collects
+// their nodes. Trees which are no longer linked are dropped. The first error
+// encountered while updating the trees is returned, the nodes of the trees
+// which failed to update are retained from their last successful sync.
+func (s *Source) refresh() error {
+	var (
+		queue   = append([]string{}, s.roots...)
+		visited = make(map[string]bool)
+		nodes   []*discover.Node
+		seen    = make(map[discover.ESSNodeID]bool)
+		failure error
+	)
+	for len(queue) > 0 {
+		url := queue[0]
+		queue = queue[1:]
+		if visited[url] {
+			continue
+		}
+		visited[url] = true
+
+		ct := s.trees[url]
+		if ct == nil {
+			le, err := parseLink(url)
+			if err != nil {
+				continue
+			}
+			ct = newClientTree(s.c, le)
+			s.trees[url] = ct
+		}
+		if err := ct.update(s.ctx); err != nil {
+			s.c.cfg.Logger.Debug("Failed to update DNS discovery tree", "tree", ct.loc.domain, "err", err)
+			if failure == nil {
+				failure = err
+			}
+		}
+		for _, n := range ct.nodes {
+			if !seen[n.ID] {
+				seen[n.ID] = true
+				nodes = append(nodes, n)
+			}
+		}
+		queue = append(queue, ct.links...)
+	}
+	for url := range s.trees {
+		if !visited[url] {
+			delete(s.trees, url)
+		}
+	}
+	s.mu.Lock()
+	s.nodes = nodes
+	s.mu.Unlock()
+
+	return failure
+}
+
+// nameError wraps a lookup error with the queried name.
+type nameError struct {
+	name string
+	err  error
+}
+
+func (err nameError) Error() string {
+	if ee, ok := err.err.(entryError); ok {
+		return fmt.Sprintf("invalid %s entry at %s: %v", ee.typ, err.name, ee.err)
+	}
+	return err.name + ": " + err.err.Error()
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 5)
+	links := []string{linkURL(testKey(nodesSeed2), "morenodes.example.org")}
+	tree, url := makeTestTree("n", nodes, links)
+
+	c := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n"))})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByIDCopy(stree.Nodes()), sortByIDCopy(nodes)) {
+		t.Errorf("wrong nodes in synced tree:\nhave %v\nwant %v", spew.Sdump(stree.Nodes()), spew.Sdump(nodes))
+	}
+	if !reflect.DeepEqual(stree.Links(), links) {
+		t.Errorf("wrong links in synced tree: %v", stree.Links())
+	}
+	if stree.Seq() != tree.Seq() || stree.Signature() != tree.Signature() {
+		t.Errorf("synced tree root mismatch: seq %d, sig %s", stree.Seq(), stree.Signature())
+	}
+}
+
+// In this test, syncing the tree fails because it contains an invalid ENR entry.
+func TestClientSyncTreeBadNode(t *testing.T) {
+	tree, url := makeTestTree("n", testNodes(nodesSeed1, 3), nil)
+	txt := tree.ToTXT("n")
+	// Replace the records of one node with the record of another.
+	for name, record := range txt {
+		if name != "n" && record[:len(enrPrefix)] == enrPrefix {
+			txt[name] = discover.RecordURL(testNodes(nodesSeed2, 1)[0].Record())
+			break
+		}
+	}
+	c := NewClient(Config{Resolver: newMapResolver(txt)})
+	_, err := c.SyncTree(url)
+	if nerr, ok := err.(nameError); !ok || nerr.err != errHashMismatch {
+		t.Fatalf("expected hash mismatch error, got %v", err)
+	}
+}
+
+// In this test, syncing the tree fails because the root is signed by another key.
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	tree, _ := makeTestTree("n", testNodes(nodesSeed1, 3), nil)
+	url := linkURL(testKey(nodesSeed2), "n")
+
+	c := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n"))})
+	_, err := c.SyncTree(url)
+	if nerr, ok := err.(nameError); !ok || nerr.err != (entryError{"root", errInvalidSig}) {
+		t.Fatalf("expected invalid signature error, got %v", err)
+	}
+}
+
+// This test checks that the source serves the nodes of the configured tree and
+// of the trees linked from it, and picks up updates of the trees.
+func TestSourceLinks(t *testing.T) {
+	var (
+		nodes1 = testNodes(nodesSeed1, 5)
+		nodes2 = testNodes(nodesSeed2, 5)
+
+		tree2, url2 = makeTestTree("t2", nodes2, nil)
+		tree1, url1 = makeTestTree("t1", nodes1, []string{url2})
+		resolver    = newMapResolver(tree1.ToTXT("t1"), tree2.ToTXT("t2"))
+	)
+	c := NewClient(Config{Resolver: resolver, RecheckInterval: 10 * time.Millisecond})
+	src, err := c.NewSource(url1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	waitSourceNodes(t, src, append(append([]*discover.Node{}, nodes1...), nodes2...))
+
+	// Update the first tree to drop the link, only its own nodes remain.
+	tree1, _ = makeTestTreeSeq("t1", 2, nodes1, nil)
+	resolver.add(tree1.ToTXT("t1"))
+	waitSourceNodes(t, src, nodes1)
+}
+
+// Tests that a source retries failed updates well before the recheck interval.
+func TestSourceRetry(t *testing.T) {
+	defer func(interval time.Duration) { retryInterval = interval }(retryInterval)
+	retryInterval = 10 * time.Millisecond
+
+	var (
+		nodes     = testNodes(nodesSeed1, 5)
+		tree, url = makeTestTree("n", nodes, nil)
+		resolver  = newMapResolver()
+	)
+	c := NewClient(Config{Resolver: resolver, RecheckInterval: time.Hour})
+	src, err := c.NewSource(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	// The tree isn't published yet, the initial refresh fails
+	time.Sleep(50 * time.Millisecond)
+	resolver.add(tree.ToTXT("n"))
+	waitSourceNodes(t, src, nodes)
+}
+
+// Tests that roots with a sequence number lower than the synced one are rejected.
+func TestClientTreeStaleRoot(t *testing.T) {
+	var (
+		nodes1   = testNodes(nodesSeed1, 5)
+		nodes2   = testNodes(nodesSeed2, 5)
+		tree2, _ = makeTestTreeSeq("n", 2, nodes2, nil)
+		tree1, _ = makeTestTreeSeq("n", 1, nodes1, nil)
+		resolver = newMapResolver(tree2.ToTXT("n"))
+		loc, err = parseLink(linkURL(testKey(signingKeySeed), "n"))
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := newClientTree(NewClient(Config{Resolver: resolver}), loc)
+	if err := ct.update(context.Background()); err != nil {
+		t.Fatal("update error:", err)
+	}
+	// Roll the published tree back, the update must be rejected
+	resolver.add(tree1.ToTXT("n"))
+	if err := ct.update(context.Background()); err == nil || err.(nameError).err != errStaleRoot {
+		t.Fatalf("wrong error for stale root: %v", err)
+	}
+	if ct.root.seq != 2 || !reflect.DeepEqual(sortByIDCopy(ct.nodes), sortByIDCopy(nodes2)) {
+		t.Errorf("tree changed by stale root: seq %d", ct.root.seq)
+	}
+}
+
+// waitSourceNodes waits until the source serves exactly the given nodes.
+func waitSourceNodes(t *testing.T, src *Source, want []*discover.Node) {
+	t.Helper()
+
+	var (
+		have     []*discover.Node
+		buf      = make([]*discover.Node, len(want)+1)
+		deadline = time.Now().Add(5 * time.Second)
+	)
+	for time.Now().Before(deadline) {
+		have = sortByIDCopy(buf[:src.ReadRandomNodes(buf)])
+		if reflect.DeepEqual(have, sortByIDCopy(want)) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("wrong source nodes: have %d, want %d", len(have), len(want))
+}
+
+func makeTestTree(domain string, nodes []*discover.Node, links []string) (*Tree, string) {
+	return makeTestTreeSeq(domain, 1, nodes, links)
+}
+
+func makeTestTreeSeq(domain string, seq uint, nodes []*discover.Node, links []string) (*Tree, string) {
+	tree, err := MakeTree(seq, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(testKey(signingKeySeed), domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		var blob [16]byte
+		binary.BigEndian.PutUint64(blob[:8], uint64(seed))
+		binary.BigEndian.PutUint64(blob[8:], uint64(i))
+		key, err := crypto.ToECDSA(crypto.Keccak256(blob[:]))
+		if err != nil {
+			panic("can't generate key: " + err.Error())
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+// testNodes creates record-backed nodes with deterministic keys.
+func testNodes(seed int64, n int) []*discover.Node {
+	var nodes []*discover.Node
+	for i, key := range testKeys(seed, n) {
+		var r enr.Record
+		r.Set(enr.IP(net.IP{127, 0, 0, byte(i)}))
+		r.Set(enr.UDP(30303))
+		r.Set(enr.TCP(30303))
+		r.SetSeq(uint64(i))
+		if err := enr.SignV4(&r, key); err != nil {
+			panic(err)
+		}
+		n, err := discover.NodeFromRecord(&r)
+		if err != nil {
+			panic(err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func linkURL(key *ecdsa.PrivateKey, domain string) string {
+	return (&linkEntry{domain, &key.PublicKey}).String()
+}
+
+func sortByIDCopy(nodes []*discover.Node) []*discover.Node {
+	cpy := append([]*discover.Node{}, nodes...)
+	sortByID(cpy)
+	return cpy
+}
+
+// mapResolver is an in-memory DNS resolver serving the given TXT records.
+type mapResolver struct {
+	mu      sync.Mutex
+	records map[string]string
+}
+
+func newMapResolver(maps ...map[string]string) *mapResolver {
+	mr := &mapResolver{records: make(map[string]string)}
+	for _, m := range maps {
+		mr.add(m)
+	}
+	return mr
+}
+
+func (mr *mapResolver) add(m map[string]string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for k, v := range m {
+		mr.records[k] = v
+	}
+}
+
+func (mr *mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if record, ok := mr.records[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, nil
+}
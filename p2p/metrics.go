@@ -29,6 +29,12 @@ var (
 	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/InboundTraffic", nil)
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/OutboundConnects", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/OutboundTraffic", nil)
+
+	// Payload sizes of Snappy compressed messages before and after compression.
+	ingressSnappyRawMeter        = metrics.NewRegisteredMeter("p2p/snappy/InboundRaw", nil)
+	ingressSnappyCompressedMeter = metrics.NewRegisteredMeter("p2p/snappy/InboundCompressed", nil)
+	egressSnappyRawMeter         = metrics.NewRegisteredMeter("p2p/snappy/OutboundRaw", nil)
+	egressSnappyCompressedMeter  = metrics.NewRegisteredMeter("p2p/snappy/OutboundCompressed", nil)
 )
 
 // meteredConn is a wrapper around a net.Conn that meters both the
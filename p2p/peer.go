@@ -112,6 +112,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	stats   peerStats // quality signals used for scoring
+	evicted bool      // set by Server.run when the peer is evicted, only accessed there
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -243,6 +246,7 @@ func (p *Peer) pingLoop() {
 	for {
 		select {
 		case <-ping.C:
+			p.pingSent(time.Now())
 			if err := SendItems(p.rw, pingMsg); err != nil {
 				p.protoErr <- err
 				return
@@ -275,6 +279,9 @@ func (p *Peer) handle(msg Msg) error {
 	case msg.Code == pingMsg:
 		msg.Discard()
 		go SendItems(p.rw, pongMsg)
+	case msg.Code == pongMsg:
+		msg.Discard()
+		p.pongReceived(msg.ReceivedAt)
 	case msg.Code == discMsg:
 		var reason [1]DiscReason
 		// This is the last message. We don't need to discard or
@@ -417,9 +424,14 @@ func (rw *protoRW) ReadMsg() (Msg, error) {
 // peer. Sub-protocol independent fields are contained and initialized here, with
 // protocol specifics delegated to all connected sub-protocols.
 type PeerInfo struct {
-	ID      string   `json:"id"`   // Unique node identifier (also the encryption key)
-	Name    string   `json:"name"` // Name of the node, including client type, version, OS, custom data
-	Caps    []string `json:"caps"` // Sum-protocols advertised by this particular peer
+	ID    string   `json:"id"`    // Unique node identifier (also the encryption key)
+	Name  string   `json:"name"`  // Name of the node, including client type, version, OS, custom data
+	Caps  []string `json:"caps"`  // Sum-protocols advertised by this particular peer
+	Score float64  `json:"score"` // Score assigned by the server's peer scorer
+	Stats struct {
+		Latency    float64 `json:"latency"`    // Average round trip time in milliseconds
+		Usefulness float64 `json:"usefulness"` // Usefulness reported by sub-protocols
+	} `json:"stats"`
 	Network struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
@@ -449,6 +461,9 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	stats := p.Stats()
+	info.Stats.Latency = float64(stats.Latency) / float64(time.Millisecond)
+	info.Stats.Usefulness = stats.Usefulness
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
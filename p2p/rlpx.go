@@ -605,10 +605,12 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 			return errPlainMessageTooLarge
 		}
 		payload, _ := ioutil.ReadAll(msg.Payload)
-		payload = snappy.Encode(nil, payload)
+		compressed := snappy.Encode(nil, payload)
+		egressSnappyRawMeter.Mark(int64(len(payload)))
+		egressSnappyCompressedMeter.Mark(int64(len(compressed)))
 
-		msg.Payload = bytes.NewReader(payload)
-		msg.Size = uint32(len(payload))
+		msg.Payload = bytes.NewReader(compressed)
+		msg.Size = uint32(len(compressed))
 	}
 	// write header
 	headbuf := make([]byte, 32)
@@ -709,10 +711,12 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 		if size > int(maxUint24) {
 			return msg, errPlainMessageTooLarge
 		}
+		ingressSnappyCompressedMeter.Mark(int64(len(payload)))
 		payload, err = snappy.Decode(nil, payload)
 		if err != nil {
 			return msg, err
 		}
+		ingressSnappyRawMeter.Mark(int64(len(payload)))
 		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
 	}
 	return msg, nil
@@ -379,6 +379,65 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 }
 
+func TestRLPXFrameRWSnappy(t *testing.T) {
+	var (
+		aesSecret      = make([]byte, 16)
+		macSecret      = make([]byte, 16)
+		egressMACinit  = make([]byte, 32)
+		ingressMACinit = make([]byte, 32)
+	)
+	for _, s := range [][]byte{aesSecret, macSecret, egressMACinit, ingressMACinit} {
+		rand.Read(s)
+	}
+	conn := new(bytes.Buffer)
+
+	s1 := secrets{
+		AES:        aesSecret,
+		MAC:        macSecret,
+		EgressMAC:  sha3.NewKeccak256(),
+		IngressMAC: sha3.NewKeccak256(),
+	}
+	s1.EgressMAC.Write(egressMACinit)
+	s1.IngressMAC.Write(ingressMACinit)
+	rw1 := newRLPXFrameRW(conn, s1)
+	rw1.snappy = true
+
+	s2 := secrets{
+		AES:        aesSecret,
+		MAC:        macSecret,
+		EgressMAC:  sha3.NewKeccak256(),
+		IngressMAC: sha3.NewKeccak256(),
+	}
+	s2.EgressMAC.Write(ingressMACinit)
+	s2.IngressMAC.Write(egressMACinit)
+	rw2 := newRLPXFrameRW(conn, s2)
+	rw2.snappy = true
+
+	// Highly compressible messages should shrink on the wire.
+	wmsg := []interface{}{"foo", strings.Repeat("test", 1000)}
+	wantPayload, _ := rlp.EncodeToBytes(wmsg)
+	if err := Send(rw1, 8, wmsg); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if conn.Len() >= len(wantPayload) {
+		t.Errorf("message not compressed: %d bytes on the wire, payload is %d bytes", conn.Len(), len(wantPayload))
+	}
+	msg, err := rw2.ReadMsg()
+	if err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	if msg.Code != 8 {
+		t.Fatalf("msg code mismatch: got %d, want %d", msg.Code, 8)
+	}
+	if msg.Size != uint32(len(wantPayload)) {
+		t.Errorf("msg size mismatch: got %d, want %d", msg.Size, len(wantPayload))
+	}
+	payload, _ := ioutil.ReadAll(msg.Payload)
+	if !bytes.Equal(payload, wantPayload) {
+		t.Fatalf("msg payload mismatch:\ngot  %x\nwant %x", payload, wantPayload)
+	}
+}
+
 type handshakeAuthTest struct {
 	input       string
 	isPlain     bool
@@ -0,0 +1,176 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/orangeAndSuns/go-ethereum/common/mclock"
+	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
+)
+
+const (
+	// evictionGracePeriod is the minimum time a peer stays connected before it
+	// can be evicted to make room for a new connection. Peers need some time to
+	// prove themselves useful.
+	evictionGracePeriod = 2 * time.Minute
+
+	// evictionThreshold is the score below which a peer may be evicted to make
+	// room for a new connection. It leaves room for the latency penalty of
+	// healthy peers which haven't proven useful yet.
+	evictionThreshold = -2
+
+	// evictionMargin is the minimum difference by which the new connection has
+	// to outscore the evicted peer, to avoid churning between similar peers.
+	evictionMargin = 1
+
+	// Bounds of the usefulness counter, so a long lived peer can't accumulate
+	// an unbeatable score (or a hopeless one) over its lifetime.
+	maxUsefulness = 10
+	minUsefulness = -10
+
+	// latencyEWMAWeight is the weight of a new measurement in the moving
+	// average of the peer's round trip time.
+	latencyEWMAWeight = 0.1
+)
+
+// PeerScorer assigns scores to connected peers. When the peer limit is reached,
+// the server evicts the lowest scoring peer to make room for a new connection if
+// the peer scores below the eviction threshold and clearly below the new
+// connection, which is scored as a freshly connected peer without any stats.
+type PeerScorer interface {
+	Score(p *Peer) float64
+}
+
+// PeerScorerFunc is an adapter to allow the use of ordinary functions as peer
+// scorers.
+type PeerScorerFunc func(p *Peer) float64
+
+// Score implements PeerScorer.
+func (f PeerScorerFunc) Score(p *Peer) float64 {
+	return f(p)
+}
+
+// DefaultPeerScorer is the scorer used by Server if Config.PeerScorer is not
+// set. It rewards usefulness reported by protocols and penalizes high latency,
+// one point per second of average round trip time.
+var DefaultPeerScorer PeerScorer = PeerScorerFunc(func(p *Peer) float64 {
+	stats := p.Stats()
+	return stats.Usefulness - stats.Latency.Seconds()
+})
+
+// PeerStats contains the quality signals gathered for a peer.
+type PeerStats struct {
+	Latency    time.Duration // moving average of the measured round trip time
+	Usefulness float64       // sum of the usefulness reported by protocols
+}
+
+// peerStats tracks the quality signals of a single peer.
+type peerStats struct {
+	mu       sync.Mutex
+	stats    PeerStats
+	pingSent time.Time // time of the last unanswered ping
+}
+
+// Stats returns the quality signals gathered for the peer so far.
+func (p *Peer) Stats() PeerStats {
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+	return p.stats.stats
+}
+
+// ReportLatency feeds a request round trip time measured by a protocol into the
+// peer's latency average. The base protocol reports ping round trip times.
+func (p *Peer) ReportLatency(rtt time.Duration) {
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+
+	if p.stats.stats.Latency == 0 {
+		p.stats.stats.Latency = rtt
+	} else {
+		avg := (1-latencyEWMAWeight)*float64(p.stats.stats.Latency) + latencyEWMAWeight*float64(rtt)
+		p.stats.stats.Latency = time.Duration(avg)
+	}
+}
+
+// ReportUsefulness adjusts the usefulness of the peer as judged by a protocol.
+// Positive values should be reported for valuable responses and announcements,
+// negative ones for useless or stale data.
+func (p *Peer) ReportUsefulness(delta float64) {
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+
+	u := p.stats.stats.Usefulness + delta
+	p.stats.stats.Usefulness = math.Max(minUsefulness, math.Min(maxUsefulness, u))
+}
+
+// pingSent records the sending time of a ping message.
+func (p *Peer) pingSent(t time.Time) {
+	p.stats.mu.Lock()
+	p.stats.pingSent = t
+	p.stats.mu.Unlock()
+}
+
+// pongReceived reports the round trip time of the last ping.
+func (p *Peer) pongReceived(t time.Time) {
+	p.stats.mu.Lock()
+	sent := p.stats.pingSent
+	p.stats.pingSent = time.Time{}
+	p.stats.mu.Unlock()
+
+	if !sent.IsZero() {
+		p.ReportLatency(t.Sub(sent))
+	}
+}
+
+// scorer returns the peer scorer of the server.
+func (srv *Server) scorer() PeerScorer {
+	if srv.PeerScorer != nil {
+		return srv.PeerScorer
+	}
+	return DefaultPeerScorer
+}
+
+// evictionCandidate returns the lowest scoring peer which may be disconnected to
+// make room for the new connection c, or nil if no peer scores badly enough. If
+// inbound is set, only inbound peers are considered.
+func (srv *Server) evictionCandidate(peers map[discover.ESSNodeID]*Peer, inbound bool, c *conn) *Peer {
+	var (
+		worst      *Peer
+		worstScore float64
+		now        = mclock.Now()
+		limit      = math.Min(evictionThreshold, srv.scorer().Score(newPeer(c, nil))-evictionMargin)
+	)
+	for _, p := range peers {
+		switch {
+		case p.evicted:
+			continue
+		case p.rw.is(trustedConn | staticDialedConn):
+			continue
+		case inbound && !p.Inbound():
+			continue
+		case time.Duration(now-p.created) < evictionGracePeriod:
+			continue
+		}
+		if score := srv.scorer().Score(p); score < limit && (worst == nil || score < worstScore) {
+			worst, worstScore = p, score
+		}
+	}
+	return worst
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerStats(t *testing.T) {
+	p := NewPeer(randomID(), "test", nil)
+
+	// The first measurement initializes the average, later ones are smoothed.
+	p.ReportLatency(time.Second)
+	if lat := p.Stats().Latency; lat != time.Second {
+		t.Errorf("wrong initial latency: got %v, want %v", lat, time.Second)
+	}
+	p.ReportLatency(2 * time.Second)
+	if lat, want := p.Stats().Latency, 1100*time.Millisecond; lat != want {
+		t.Errorf("wrong average latency: got %v, want %v", lat, want)
+	}
+	// Usefulness is bounded.
+	for i := 0; i < 2*maxUsefulness; i++ {
+		p.ReportUsefulness(1)
+	}
+	if u := p.Stats().Usefulness; u != maxUsefulness {
+		t.Errorf("usefulness not capped: got %v, want %v", u, maxUsefulness)
+	}
+	p.ReportUsefulness(-100)
+	if u := p.Stats().Usefulness; u != minUsefulness {
+		t.Errorf("usefulness not bounded: got %v, want %v", u, minUsefulness)
+	}
+	if score, want := DefaultPeerScorer.Score(p), float64(minUsefulness)-1.1; score != want {
+		t.Errorf("wrong default score: got %v, want %v", score, want)
+	}
+}
+
+func TestPeerInfoStats(t *testing.T) {
+	closer, _, p, _ := testPeer(nil)
+	defer closer()
+
+	p.ReportLatency(1500 * time.Millisecond)
+	p.ReportUsefulness(2)
+
+	info := p.Info()
+	if info.Stats.Latency != 1500 {
+		t.Errorf("wrong latency: got %v ms, want 1500 ms", info.Stats.Latency)
+	}
+	if info.Stats.Usefulness != 2 {
+		t.Errorf("wrong usefulness: got %v, want 2", info.Stats.Usefulness)
+	}
+}
+
+func TestPeerPingLatency(t *testing.T) {
+	closer, rw, p, _ := testPeer(nil)
+	defer closer()
+
+	sent := time.Now()
+	p.pingSent(sent)
+	if err := SendItems(rw, pongMsg); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Latency == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("pong did not update latency")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
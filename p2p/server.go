@@ -31,6 +31,8 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/log"
 	"github.com/orangeAndSuns/go-ethereum/p2p/discover"
 	"github.com/orangeAndSuns/go-ethereum/p2p/discv5"
+	"github.com/orangeAndSuns/go-ethereum/p2p/dnsdisc"
+	"github.com/orangeAndSuns/go-ethereum/p2p/enr"
 	"github.com/orangeAndSuns/go-ethereum/p2p/nat"
 	"github.com/orangeAndSuns/go-ethereum/p2p/netutil"
 )
@@ -93,6 +95,16 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// DNSDiscovery contains enrtree:// URLs of node lists published in DNS
+	// (EIP-1459). Nodes of these lists are used as dial candidates next to the
+	// ones found by discovery, even if discovery is disabled.
+	DNSDiscovery []string `toml:",omitempty"`
+
+	// PeerScorer rates connected peers. When the peer limit is reached, the
+	// lowest scoring peer is evicted in favor of a new connection if it scores
+	// badly enough. DefaultPeerScorer is used if nil.
+	PeerScorer PeerScorer `toml:"-"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*discover.Node
@@ -157,6 +169,8 @@ type Server struct {
 	running bool
 
 	ntab         discoverTable
+	dnsdisc      *dnsdisc.Source
+	localnode    *discover.LocalNode
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
@@ -316,6 +330,17 @@ func (srv *Server) Self() *discover.Node {
 	return srv.makeSelf(srv.listener, srv.ntab)
 }
 
+// LocalNode returns the local node record, or nil if the server isn't running.
+func (srv *Server) LocalNode() *discover.LocalNode {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil
+	}
+	return srv.localnode
+}
+
 func (srv *Server) makeSelf(listener net.Listener, ntab discoverTable) *discover.Node {
 	// If the server's not running, return an empty node.
 	// If the node is running but discovery is off, manually assemble the node infos.
@@ -412,6 +437,7 @@ func (srv *Server) Start() (err error) {
 	srv.removestatic = make(chan *discover.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.localnode = discover.NewLocalNode(srv.PrivateKey)
 
 	var (
 		conn      *net.UDPConn
@@ -455,12 +481,19 @@ func (srv *Server) Start() (err error) {
 			NetRestrict:  srv.NetRestrict,
 			Bootnodes:    srv.BootstrapNodes,
 			Unhandled:    unhandled,
+			LocalNode:    srv.localnode,
 		}
 		ntab, err := discover.ListenUDP(conn, cfg)
 		if err != nil {
 			return err
 		}
 		srv.ntab = ntab
+	} else if realaddr != nil {
+		// Discovery v4 is off, announce the discovery v5 endpoint instead
+		if !realaddr.IP.IsUnspecified() {
+			srv.localnode.Set(enr.IP(realaddr.IP))
+		}
+		srv.localnode.Set(enr.UDP(realaddr.Port))
 	}
 
 	if srv.DiscoveryV5 {
@@ -482,8 +515,20 @@ func (srv *Server) Start() (err error) {
 		srv.DiscV5 = ntab
 	}
 
+	// DNS node lists
+	var sources []nodeSource
+	if len(srv.DNSDiscovery) > 0 {
+		client := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log})
+		src, err := client.NewSource(srv.DNSDiscovery...)
+		if err != nil {
+			return err
+		}
+		srv.dnsdisc = src
+		sources = append(sources, src)
+	}
+
 	dynPeers := srv.maxDialedConns()
-	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, sources, dynPeers, srv.NetRestrict)
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -515,6 +560,7 @@ func (srv *Server) startListening() error {
 	laddr := listener.Addr().(*net.TCPAddr)
 	srv.ListenAddr = laddr.String()
 	srv.listener = listener
+	srv.localnode.Set(enr.TCP(laddr.Port))
 	srv.loopWG.Add(1)
 	go srv.listenLoop()
 	// Map the TCP listening port if NAT is configured.
@@ -634,6 +680,12 @@ running:
 			// Its capabilities are known and the remote identity is verified.
 			err := srv.protoHandshakeChecks(peers, inboundCount, c)
 			if err == nil {
+				// Make room for the new peer if the limits are reached.
+				if victim, _ := srv.checkPeerLimits(peers, inboundCount, c); victim != nil {
+					victim.log.Debug("Evicting low scoring p2p peer", "score", srv.scorer().Score(victim))
+					victim.evicted = true
+					victim.Disconnect(DiscTooManyPeers)
+				}
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
 				// If message events are enabled, pass the peerFeed
@@ -677,6 +729,9 @@ running:
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	if srv.dnsdisc != nil {
+		srv.dnsdisc.Close()
+	}
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -702,11 +757,10 @@ func (srv *Server) protoHandshakeChecks(peers map[discover.ESSNodeID]*Peer, inbo
 }
 
 func (srv *Server) encHandshakeChecks(peers map[discover.ESSNodeID]*Peer, inboundCount int, c *conn) error {
+	if _, err := srv.checkPeerLimits(peers, inboundCount, c); err != nil {
+		return err
+	}
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
-		return DiscTooManyPeers
-	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
-		return DiscTooManyPeers
 	case peers[c.id] != nil:
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
@@ -716,12 +770,39 @@ func (srv *Server) encHandshakeChecks(peers map[discover.ESSNodeID]*Peer, inboun
 	}
 }
 
+// checkPeerLimits checks whether c fits into the peer limits. If the limits are
+// reached but a low scoring peer can be evicted to make room for c, that peer is
+// returned.
+func (srv *Server) checkPeerLimits(peers map[discover.ESSNodeID]*Peer, inboundCount int, c *conn) (*Peer, error) {
+	if c.is(trustedConn) {
+		return nil, nil
+	}
+	// Peers which are already being evicted don't count against the limits.
+	count := len(peers)
+	for _, p := range peers {
+		if p.evicted {
+			count--
+			if p.Inbound() {
+				inboundCount--
+			}
+		}
+	}
+	inboundFull := c.is(inboundConn) && inboundCount >= srv.maxInboundConns()
+	if !inboundFull && (c.is(staticDialedConn) || count < srv.MaxPeers) {
+		return nil, nil
+	}
+	if p := srv.evictionCandidate(peers, inboundFull, c); p != nil {
+		return p, nil
+	}
+	return nil, DiscTooManyPeers
+}
+
 func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
 
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if srv.NoDial || (srv.NoDiscovery && len(srv.DNSDiscovery) == 0) {
 		return 0
 	}
 	r := srv.DialRatio
@@ -910,6 +991,7 @@ type NodeInfo struct {
 	ID      string `json:"id"`      // Unique node identifier (also the encryption key)
 	Name    string `json:"name"`    // Name of the node, including client type, version, OS, custom data
 	ESSNode string `json:"essnode"` // ESSNode URL for adding this peer from remote peers
+	ENR     string `json:"enr"`     // Signed node record (EIP-778) of the node, empty if not running
 	IP      string `json:"ip"`      // IP address of the node
 	Ports   struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
@@ -934,6 +1016,9 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
+	if ln := srv.LocalNode(); ln != nil {
+		info.ENR = discover.RecordURL(ln.Record())
+	}
 
 	// Gather all the running protocol infos (only once per protocol type)
 	for _, proto := range srv.Protocols {
@@ -954,7 +1039,9 @@ func (srv *Server) PeersInfo() []*PeerInfo {
 	infos := make([]*PeerInfo, 0, srv.PeerCount())
 	for _, peer := range srv.Peers() {
 		if peer != nil {
-			infos = append(infos, peer.Info())
+			info := peer.Info()
+			info.Score = srv.scorer().Score(peer)
+			infos = append(infos, info)
 		}
 	}
 	// Sort the result array alphabetically by node identifier
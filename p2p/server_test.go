@@ -25,6 +25,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/orangeAndSuns/go-ethereum/common/mclock"
 	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/crypto/sha3"
 	"github.com/orangeAndSuns/go-ethereum/log"
@@ -122,6 +123,24 @@ func TestServerListen(t *testing.T) {
 	}
 }
 
+// This test checks that the node info contains the signed local record.
+func TestServerNodeInfoRecord(t *testing.T) {
+	srv := startTestServer(t, randomID(), nil)
+	defer srv.Stop()
+
+	info := srv.NodeInfo()
+	n, err := discover.ParseNode(info.ENR)
+	if err != nil {
+		t.Fatalf("invalid ENR in node info %q: %v", info.ENR, err)
+	}
+	if n.ID != discover.PubkeyID(&srv.PrivateKey.PublicKey) {
+		t.Errorf("record ID mismatch: got %v, want %v", n.ID, discover.PubkeyID(&srv.PrivateKey.PublicKey))
+	}
+	if laddr := srv.listener.Addr().(*net.TCPAddr); n.TCP != uint16(laddr.Port) {
+		t.Errorf("record TCP port mismatch: got %d, want %d", n.TCP, laddr.Port)
+	}
+}
+
 func TestServerDial(t *testing.T) {
 	// run a one-shot TCP server to handle the connection.
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -366,6 +385,135 @@ func TestServerAtCap(t *testing.T) {
 
 }
 
+func TestServerPeerEviction(t *testing.T) {
+	badID, badCandidateID := randomID(), randomID()
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+			PeerScorer: PeerScorerFunc(func(p *Peer) float64 {
+				if p.ID() == badID || p.ID() == badCandidateID {
+					return -5
+				}
+				return 0
+			}),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id discover.ESSNodeID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(id, fd)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, id: id, cont: make(chan error)}
+	}
+	// Fill up the peer set, including the low scoring peer.
+	for i := 0; i < 10; i++ {
+		id := randomID()
+		if i == 0 {
+			id = badID
+		}
+		if err := srv.checkpoint(newconn(id), srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	// Peers within the grace period are never evicted.
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for insert during grace period:", err)
+	}
+	// Age all peers beyond the grace period. This runs on the server loop.
+	srv.peerOp <- func(peers map[discover.ESSNodeID]*Peer) {
+		for _, p := range peers {
+			p.created -= mclock.AbsTime(evictionGracePeriod)
+		}
+	}
+	<-srv.peerOpDone
+
+	// A connection scoring no better than the low scoring peer doesn't replace it.
+	if err := srv.checkpoint(newconn(badCandidateID), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for insert of low scoring conn:", err)
+	}
+	// The new connection should now replace the low scoring peer.
+	c := newconn(randomID())
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Fatal("unexpected error at posthandshake:", err)
+	}
+	if err := srv.checkpoint(c, srv.addpeer); err != nil {
+		t.Fatal("unexpected error at addpeer:", err)
+	}
+	// No other peer scores badly enough, so the next connection is rejected.
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Error("wrong error for insert after eviction:", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var found bool
+		for _, p := range srv.Peers() {
+			found = found || p.ID() == badID
+		}
+		if !found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("low scoring peer was not evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := srv.PeerCount(); n != 10 {
+		t.Errorf("wrong peer count after eviction: got %d, want 10", n)
+	}
+}
+
+// Tests that a full set of healthy peers isn't churned by the default scorer,
+// even though their latency makes them score below a fresh connection.
+func TestServerPeerEvictionHealthy(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id discover.ESSNodeID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(id, fd)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, id: id, cont: make(chan error)}
+	}
+	for i := 0; i < 10; i++ {
+		if err := srv.checkpoint(newconn(randomID()), srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	// Age all peers beyond the grace period, with usual latencies and a few
+	// useless responses.
+	srv.peerOp <- func(peers map[discover.ESSNodeID]*Peer) {
+		for _, p := range peers {
+			p.created -= mclock.AbsTime(evictionGracePeriod)
+			p.ReportLatency(300 * time.Millisecond)
+			p.ReportUsefulness(-1)
+		}
+	}
+	<-srv.peerOpDone
+
+	// No peer may be evicted, so new connections are rejected.
+	for i := 0; i < 5; i++ {
+		if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+			t.Fatalf("wrong error for insert %d: %v", i, err)
+		}
+	}
+	if n := srv.PeerCount(); n != 10 {
+		t.Errorf("wrong peer count: got %d, want 10", n)
+	}
+}
+
 func TestServerSetupConn(t *testing.T) {
 	id := randomID()
 	srvkey := newkey()
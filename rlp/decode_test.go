@@ -284,6 +284,35 @@ func TestStreamRaw(t *testing.T) {
 	}
 }
 
+// eofReader reads from a byte slice, returning io.EOF along with the last
+// chunk of data.
+type eofReader []byte
+
+func (r *eofReader) Read(buf []byte) (n int, err error) {
+	n = copy(buf, *r)
+	*r = (*r)[n:]
+	if len(*r) == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Tests that reading the last bytes of the input doesn't fail if the reader
+// signals EOF along with them.
+func TestStreamReadEOF(t *testing.T) {
+	want := make([]byte, 8192)
+	input, _ := EncodeToBytes(want)
+
+	r := eofReader(input)
+	var have []byte
+	if err := NewStream(&r, uint64(len(input))).Decode(&have); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("content mismatch: have %x, want %x", have, want)
+	}
+}
+
 func TestDecodeErrors(t *testing.T) {
 	r := bytes.NewReader(nil)
 
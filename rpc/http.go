@@ -163,8 +163,7 @@ func (t *httpReadWriteNopCloser) Close() error {
 // Deprecated: Server implements http.Handler
 func NewHTTPServer(cors []string, vhosts []string, srv *Server) *http.Server {
 	// Wrap the CORS-handler within a host-handler
-	handler := newCorsHandler(srv, cors)
-	handler = newVHostHandler(vhosts, handler)
+	handler := NewHTTPHandlerStack(srv, cors, vhosts)
 	return &http.Server{
 		Handler:      handler,
 		ReadTimeout:  5 * time.Second,
@@ -217,7 +216,15 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+// NewHTTPHandlerStack returns wrapped http-related handlers, enforcing the
+// configured CORS origins and virtual hosts on top of the given handler.
+func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string) http.Handler {
+	// Wrap the CORS-handler within a host-handler
+	handler := newCorsHandler(srv, cors)
+	return newVHostHandler(vhosts, handler)
+}
+
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
@@ -59,6 +59,16 @@ func (s *Service) Rets() (string, error) {
 	return "", nil
 }
 
+func (s *Service) ReturnError() error {
+	return testError{}
+}
+
+type testError struct{}
+
+func (testError) Error() string          { return "testError" }
+func (testError) ErrorCode() int         { return 444 }
+func (testError) ErrorData() interface{} { return "testError data" }
+
 func (s *Service) InvalidRets1() (error, string) {
 	return nil, ""
 }
@@ -92,8 +102,8 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("Expected service calc to be registered")
 	}
 
-	if len(svc.callbacks) != 5 {
-		t.Errorf("Expected 5 callbacks for service 'calc', got %d", len(svc.callbacks))
+	if len(svc.callbacks) != 6 {
+		t.Errorf("Expected 6 callbacks for service 'calc', got %d", len(svc.callbacks))
 	}
 
 	if len(svc.subscriptions) != 1 {
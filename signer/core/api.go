@@ -34,6 +34,7 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/internal/ethapi"
 	"github.com/orangeAndSuns/go-ethereum/log"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
+	"github.com/orangeAndSuns/go-ethereum/signer/typeddata"
 )
 
 // ExternalAPI defines the external API through which signing requests are made.
@@ -46,6 +47,8 @@ type ExternalAPI interface {
 	SignTransaction(ctx context.Context, args SendTxArgs, methodSelector *string) (*ethapi.SignTransactionResult, error)
 	// Sign - request to sign the given data (plus prefix)
 	Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error)
+	// SignTypedData - request to sign the given EIP-712 typed data
+	SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data typeddata.TypedData) (hexutil.Bytes, error)
 	// EcRecover - request to perform ecrecover
 	EcRecover(ctx context.Context, data, sig hexutil.Bytes) (common.Address, error)
 	// Export - request to export an account
@@ -155,11 +158,12 @@ type (
 		NewPassword string `json:"new_password"`
 	}
 	SignDataRequest struct {
-		Address common.MixedcaseAddress `json:"address"`
-		Rawdata hexutil.Bytes           `json:"raw_data"`
-		Message string                  `json:"message"`
-		Hash    hexutil.Bytes           `json:"hash"`
-		Meta    Metadata                `json:"meta"`
+		Address  common.MixedcaseAddress    `json:"address"`
+		Rawdata  hexutil.Bytes              `json:"raw_data"`
+		Message  string                     `json:"message"`
+		Messages []*typeddata.NameValueType `json:"messages"` // Decoded fields of typed data
+		Hash     hexutil.Bytes              `json:"hash"`
+		Meta     Metadata                   `json:"meta"`
 	}
 	SignDataResponse struct {
 		Approved bool `json:"approved"`
@@ -404,6 +408,46 @@ func (api *SignerAPI) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 	return signature, nil
 }
 
+// SignTypedData calculates an Essentia ECDSA signature for the given EIP-712
+// typed data:
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+//
+// The decoded fields of the domain and message are shown to the UI for approval.
+// As with Sign, the V value of the signature will be 27 or 28.
+func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data typeddata.TypedData) (hexutil.Bytes, error) {
+	sighash, rawData, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	messages, err := data.Format()
+	if err != nil {
+		return nil, err
+	}
+	req := &SignDataRequest{Address: addr, Rawdata: rawData, Messages: messages, Hash: sighash[:], Meta: MetadataFromContext(ctx)}
+	res, err := api.UI.ApproveSignData(req)
+
+	if err != nil {
+		return nil, err
+	}
+	if !res.Approved {
+		return nil, ErrRequestDenied
+	}
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: addr.Address()}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// Assemble sign the data with the wallet
+	signature, err := wallet.SignHashWithPassphrase(account, res.Password, sighash[:])
+	if err != nil {
+		api.UI.ShowError(err.Error())
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
 // EcRecover returns the address for the Account that was used to create the signature.
 // Note, this function is compatible with eth_sign and personal_sign. As such it recovers
 // the address of:
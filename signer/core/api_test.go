@@ -32,8 +32,10 @@ import (
 	"github.com/orangeAndSuns/go-ethereum/common"
 	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
 	"github.com/orangeAndSuns/go-ethereum/core/types"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/internal/ethapi"
 	"github.com/orangeAndSuns/go-ethereum/rlp"
+	"github.com/orangeAndSuns/go-ethereum/signer/typeddata"
 )
 
 //Used for testing
@@ -254,6 +256,61 @@ func TestSignData(t *testing.T) {
 		t.Errorf("Expected 65 byte signature (got %d bytes)", len(h))
 	}
 }
+
+func TestSignTypedData(t *testing.T) {
+	api, control := setup(t)
+	createAccount(control, api, t)
+	control <- "A"
+	list, err := api.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := common.NewMixedcaseAddress(list[0].Address)
+
+	data := typeddata.TypedData{
+		Types: typeddata.Types{
+			"EIP712Domain": {{Name: "name", Type: "string"}, {Name: "chainId", Type: "uint256"}},
+			"Permit":       {{Name: "spender", Type: "address"}, {Name: "value", Type: "uint256"}},
+		},
+		PrimaryType: "Permit",
+		Domain:      typeddata.TypedDataDomain{Name: "Test", ChainId: big.NewInt(1)},
+		Message: map[string]interface{}{
+			"spender": "0x0000000000000000000000000000000000001337",
+			"value":   "1000",
+		},
+	}
+	control <- "No way"
+	if _, err := api.SignTypedData(context.Background(), a, data); err != ErrRequestDenied {
+		t.Errorf("Expected ErrRequestDenied! %v", err)
+	}
+	control <- "Y"
+	control <- "apassword"
+	sig, err := api.SignTypedData(context.Background(), a, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 65 {
+		t.Fatalf("Expected 65 byte signature (got %d bytes)", len(sig))
+	}
+	hash, _, err := data.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] -= 27
+	pubkey, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.PubkeyToAddress(*pubkey); addr != a.Address() {
+		t.Errorf("Signer mismatch: have %x, want %x", addr, a.Address())
+	}
+	// Malformed typed data must be rejected before asking the UI
+	delete(data.Message, "value")
+	if _, err := api.SignTypedData(context.Background(), a, data); err == nil {
+		t.Errorf("Expected error for malformed typed data")
+	}
+}
+
 func mkTestTx(from common.MixedcaseAddress) SendTxArgs {
 	to := common.NewMixedcaseAddress(common.HexToAddress("0x1337"))
 	gas := hexutil.Uint64(21000)
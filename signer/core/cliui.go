@@ -143,7 +143,14 @@ func (ui *CommandlineUI) ApproveSignData(request *SignDataRequest) (SignDataResp
 
 	fmt.Printf("-------- Sign data request--------------\n")
 	fmt.Printf("Account:  %s\n", request.Address.String())
-	fmt.Printf("message:  \n%q\n", request.Message)
+	if len(request.Messages) > 0 {
+		fmt.Printf("typed data:\n")
+		for _, nvt := range request.Messages {
+			fmt.Print(nvt.Pprint(1))
+		}
+	} else {
+		fmt.Printf("message:  \n%q\n", request.Message)
+	}
 	fmt.Printf("raw data: \n%v\n", request.Rawdata)
 	fmt.Printf("message hash:  %v\n", request.Hash)
 	fmt.Printf("-------------------------------------------\n")
@@ -0,0 +1,575 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package typeddata implements hashing of typed structured data as specified
+// by EIP-712.
+//
+// It is kept separate from the signer so that both clef and the node's own
+// account APIs can produce identical signatures.
+package typeddata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/common/hexutil"
+	"github.com/orangeAndSuns/go-ethereum/common/math"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+)
+
+// DomainType is the name of the type describing the signing domain, which
+// must be present in the types of every typed data request.
+const DomainType = "EIP712Domain"
+
+// TypedData is a type to encapsulate EIP-712 typed messages.
+type TypedData struct {
+	Types       Types                  `json:"types"`
+	PrimaryType string                 `json:"primaryType"`
+	Domain      TypedDataDomain        `json:"domain"`
+	Message     map[string]interface{} `json:"message"`
+}
+
+// Type is the inner type of an EIP-712 message.
+type Type struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Types is the set of struct definitions a typed message is built from.
+type Types map[string][]Type
+
+// TypedDataDomain represents the domain part of an EIP-712 message.
+type TypedDataDomain struct {
+	Name              string   `json:"name"`
+	Version           string   `json:"version"`
+	ChainId           *big.Int `json:"chainId"`
+	VerifyingContract string   `json:"verifyingContract"`
+	Salt              string   `json:"salt"`
+}
+
+// NameValueType is a very simple struct with Name, Value and Type. It's meant
+// for simple json structures used to communicate signing-info about typed data
+// with the UI.
+type NameValueType struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	Typ   string      `json:"type"`
+}
+
+var typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\d*\])*$`)
+
+// Hash returns the hash to be signed for the given typed data, which is
+// calculated as
+//   keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+//
+// The raw preimage is returned alongside the hash so it can be shown to users.
+func (typedData *TypedData) Hash() (common.Hash, []byte, error) {
+	if err := typedData.validate(); err != nil {
+		return common.Hash{}, nil, err
+	}
+	domainSeparator, err := typedData.HashStruct(DomainType, typedData.Domain.Map())
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
+	return crypto.Keccak256Hash(rawData), rawData, nil
+}
+
+// HashStruct generates a keccak256 hash of the encoding of the provided data.
+func (typedData *TypedData) HashStruct(primaryType string, data map[string]interface{}) (hexutil.Bytes, error) {
+	encodedData, err := typedData.EncodeData(primaryType, data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(encodedData), nil
+}
+
+// Dependencies returns an array of custom types ordered by their hierarchical
+// reference tree.
+func (typedData *TypedData) Dependencies(primaryType string, found []string) []string {
+	for _, dep := range found {
+		if dep == primaryType {
+			return found
+		}
+	}
+	if typedData.Types[primaryType] == nil {
+		return found
+	}
+	found = append(found, primaryType)
+	for _, field := range typedData.Types[primaryType] {
+		found = typedData.Dependencies(baseType(field.Type), found)
+	}
+	return found
+}
+
+// EncodeType generates the following encoding:
+//   `name ‖ "(" ‖ member₁ ‖ "," ‖ member₂ ‖ "," ‖ … ‖ memberₙ ")"`
+//
+// each member is written as `type ‖ " " ‖ name` encodings cascade down and
+// are sorted by name.
+func (typedData *TypedData) EncodeType(primaryType string) hexutil.Bytes {
+	// Get dependencies primary first, then alphabetical
+	deps := typedData.Dependencies(primaryType, []string{})
+	if len(deps) > 0 {
+		sort.Strings(deps[1:])
+	}
+	var buffer bytes.Buffer
+	for _, dep := range deps {
+		buffer.WriteString(dep)
+		buffer.WriteString("(")
+		for i, obj := range typedData.Types[dep] {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(obj.Type)
+			buffer.WriteString(" ")
+			buffer.WriteString(obj.Name)
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.Bytes()
+}
+
+// TypeHash creates the keccak256 hash of the data.
+func (typedData *TypedData) TypeHash(primaryType string) hexutil.Bytes {
+	return crypto.Keccak256(typedData.EncodeType(primaryType))
+}
+
+// EncodeData generates the following encoding:
+//   `enc(value₁) ‖ enc(value₂) ‖ … ‖ enc(valueₙ)`
+//
+// each encoded member is 32-byte long.
+func (typedData *TypedData) EncodeData(primaryType string, data map[string]interface{}, depth int) (hexutil.Bytes, error) {
+	if err := typedData.validateData(primaryType, data, depth); err != nil {
+		return nil, err
+	}
+	buffer := bytes.Buffer{}
+
+	// Add typehash
+	buffer.Write(typedData.TypeHash(primaryType))
+
+	// Add field contents. Structs and arrays have special handlers.
+	for _, field := range typedData.Types[primaryType] {
+		encType := field.Type
+		encValue := data[field.Name]
+
+		if strings.HasSuffix(encType, "]") {
+			arrayValue, ok := encValue.([]interface{})
+			if !ok {
+				return nil, dataMismatchError(encType, encValue)
+			}
+			elemType, length, err := parseArrayType(encType)
+			if err != nil {
+				return nil, err
+			}
+			if length >= 0 && len(arrayValue) != length {
+				return nil, fmt.Errorf("provided array has %d items, type '%s' requires %d", len(arrayValue), encType, length)
+			}
+			arrayBuffer := bytes.Buffer{}
+			for _, item := range arrayValue {
+				encoded, err := typedData.encodeValue(elemType, item, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arrayBuffer.Write(encoded)
+			}
+			buffer.Write(crypto.Keccak256(arrayBuffer.Bytes()))
+			continue
+		}
+		encoded, err := typedData.encodeValue(encType, encValue, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encoded)
+	}
+	return buffer.Bytes(), nil
+}
+
+// encodeValue encodes a single struct member, hashing nested structs.
+func (typedData *TypedData) encodeValue(encType string, encValue interface{}, depth int) ([]byte, error) {
+	if typedData.Types[encType] == nil {
+		return encodePrimitiveValue(encType, encValue)
+	}
+	mapValue, ok := encValue.(map[string]interface{})
+	if !ok {
+		return nil, dataMismatchError(encType, encValue)
+	}
+	encoded, err := typedData.EncodeData(encType, mapValue, depth)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(encoded), nil
+}
+
+// encodePrimitiveValue deals with the primitive values found while searching
+// through the typed data.
+func encodePrimitiveValue(encType string, encValue interface{}) ([]byte, error) {
+	switch encType {
+	case "address":
+		stringValue, ok := encValue.(string)
+		if !ok || !common.IsHexAddress(stringValue) {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		return common.LeftPadBytes(common.HexToAddress(stringValue).Bytes(), 32), nil
+
+	case "bool":
+		boolValue, ok := encValue.(bool)
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		if boolValue {
+			return math.PaddedBigBytes(common.Big1, 32), nil
+		}
+		return math.PaddedBigBytes(common.Big0, 32), nil
+
+	case "string":
+		strVal, ok := encValue.(string)
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		return crypto.Keccak256([]byte(strVal)), nil
+
+	case "bytes":
+		bytesValue, ok := parseBytes(encValue)
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		return crypto.Keccak256(bytesValue), nil
+	}
+	if strings.HasPrefix(encType, "bytes") {
+		length, err := strconv.Atoi(strings.TrimPrefix(encType, "bytes"))
+		if err != nil || length < 1 || length > 32 {
+			return nil, fmt.Errorf("invalid size on bytes: %v", encType)
+		}
+		bytesValue, ok := parseBytes(encValue)
+		if !ok || len(bytesValue) > length {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		return common.RightPadBytes(bytesValue, 32), nil
+	}
+	if strings.HasPrefix(encType, "int") || strings.HasPrefix(encType, "uint") {
+		length, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(encType, "u"), "int"))
+		if err != nil || length < 8 || length > 256 || length%8 != 0 {
+			return nil, fmt.Errorf("invalid size on integer: %v", encType)
+		}
+		b, err := parseInteger(encType, encValue)
+		if err != nil {
+			return nil, err
+		}
+		// Unsigned integers span [0, 2^N), signed ones [-2^(N-1), 2^(N-1))
+		min, max := new(big.Int), new(big.Int).Lsh(common.Big1, uint(length))
+		if encType[0] != 'u' {
+			max.Rsh(max, 1)
+			min.Neg(max)
+		}
+		if b.Cmp(min) < 0 || b.Cmp(max) >= 0 {
+			return nil, fmt.Errorf("integer %v out of range for %v", b, encType)
+		}
+		return math.PaddedBigBytes(math.U256(new(big.Int).Set(b)), 32), nil
+	}
+	return nil, fmt.Errorf("unrecognized type '%s'", encType)
+}
+
+// parseArrayType splits an array type into the type of its elements and its
+// length, which is -1 for dynamically sized arrays.
+func parseArrayType(encType string) (string, int, error) {
+	open := strings.LastIndex(encType, "[")
+	if open < 0 || !strings.HasSuffix(encType, "]") {
+		return "", 0, fmt.Errorf("invalid array type: %v", encType)
+	}
+	size := encType[open+1 : len(encType)-1]
+	if size == "" {
+		return encType[:open], -1, nil
+	}
+	length, err := strconv.Atoi(size)
+	if err != nil || length < 1 {
+		return "", 0, fmt.Errorf("invalid size on array: %v", encType)
+	}
+	return encType[:open], length, nil
+}
+
+// dataMismatchError generates an error for a mismatch between the provided
+// type and data.
+func dataMismatchError(encType string, encValue interface{}) error {
+	return fmt.Errorf("provided data '%v' doesn't match type '%s'", encValue, encType)
+}
+
+// parseBytes returns the byte content of a hex string or byte slice.
+func parseBytes(encValue interface{}) ([]byte, bool) {
+	switch v := encValue.(type) {
+	case []byte:
+		return v, true
+	case hexutil.Bytes:
+		return v, true
+	case string:
+		bytes, err := hexutil.Decode(v)
+		if err != nil {
+			return nil, false
+		}
+		return bytes, true
+	default:
+		return nil, false
+	}
+}
+
+// parseInteger converts the supported JSON representations of an integer,
+// i.e. numbers and decimal or hexadecimal strings, into a big.Int.
+func parseInteger(encType string, encValue interface{}) (*big.Int, error) {
+	switch v := encValue.(type) {
+	case *big.Int:
+		return v, nil
+	case float64:
+		// JSON numbers are decoded as float64, only accept exact integers
+		b, acc := new(big.Float).SetFloat64(v).Int(nil)
+		if acc != big.Exact {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		return b, nil
+	case json.Number:
+		b, ok := new(big.Int).SetString(v.String(), 10)
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		return b, nil
+	case string:
+		negative := strings.HasPrefix(v, "-")
+		b, ok := math.ParseBig256(strings.TrimPrefix(v, "-"))
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		if negative {
+			b.Neg(b)
+		}
+		return b, nil
+	default:
+		return nil, dataMismatchError(encType, encValue)
+	}
+}
+
+// baseType strips any array suffixes from a type name.
+func baseType(typ string) string {
+	if i := strings.Index(typ, "["); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+// validate makes sure the types are sound and the primary type is defined.
+func (typedData *TypedData) validate() error {
+	if err := typedData.Types.validate(); err != nil {
+		return err
+	}
+	if typedData.Types[DomainType] == nil {
+		return fmt.Errorf("missing %s type definition", DomainType)
+	}
+	if typedData.PrimaryType == "" || typedData.Types[typedData.PrimaryType] == nil {
+		return fmt.Errorf("unknown primary type '%s'", typedData.PrimaryType)
+	}
+	return nil
+}
+
+// validate checks that every referenced type name is either a known struct
+// or looks like a primitive.
+func (t Types) validate() error {
+	for typeKey, typeArr := range t {
+		if len(typeKey) == 0 {
+			return errors.New("empty type key")
+		}
+		for i, typeObj := range typeArr {
+			if len(typeObj.Type) == 0 {
+				return fmt.Errorf("type %q:%d: empty Type", typeKey, i)
+			}
+			if len(typeObj.Name) == 0 {
+				return fmt.Errorf("type %q:%d: empty Name", typeKey, i)
+			}
+			if typeKey == typeObj.Type {
+				return fmt.Errorf("type %q cannot reference itself", typeObj.Type)
+			}
+			if typedDataReferenceTypeRegexp.MatchString(typeObj.Type) {
+				if _, exist := t[baseType(typeObj.Type)]; !exist {
+					return fmt.Errorf("reference type %q is undefined", typeObj.Type)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateData checks that data holds exactly the fields of the given type.
+func (typedData *TypedData) validateData(primaryType string, data map[string]interface{}, depth int) error {
+	fields, ok := typedData.Types[primaryType]
+	if !ok {
+		return fmt.Errorf("unknown type '%s'", primaryType)
+	}
+	if len(data) != len(fields) {
+		return fmt.Errorf("type '%s' has %d fields, data has %d", primaryType, len(fields), len(data))
+	}
+	for _, field := range fields {
+		if _, ok := data[field.Name]; !ok {
+			return fmt.Errorf("missing field '%s' of type '%s' at depth %d", field.Name, primaryType, depth)
+		}
+	}
+	return nil
+}
+
+// Map is a helper function to generate a map version of the domain.
+func (domain *TypedDataDomain) Map() map[string]interface{} {
+	dataMap := map[string]interface{}{}
+
+	if domain.ChainId != nil {
+		dataMap["chainId"] = domain.ChainId
+	}
+	if len(domain.Name) > 0 {
+		dataMap["name"] = domain.Name
+	}
+	if len(domain.Version) > 0 {
+		dataMap["version"] = domain.Version
+	}
+	if len(domain.VerifyingContract) > 0 {
+		dataMap["verifyingContract"] = domain.VerifyingContract
+	}
+	if len(domain.Salt) > 0 {
+		dataMap["salt"] = domain.Salt
+	}
+	return dataMap
+}
+
+// Format returns a representation of the domain and message suitable for
+// presenting to the user before signing.
+func (typedData *TypedData) Format() ([]*NameValueType, error) {
+	domain, err := typedData.formatData(DomainType, typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	message, err := typedData.formatData(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+	return []*NameValueType{
+		{Name: DomainType, Value: domain, Typ: "domain"},
+		{Name: typedData.PrimaryType, Value: message, Typ: "primary type"},
+	}, nil
+}
+
+// formatData recursively formats the fields of a struct for display.
+func (typedData *TypedData) formatData(primaryType string, data map[string]interface{}) ([]*NameValueType, error) {
+	var output []*NameValueType
+
+	for _, field := range typedData.Types[primaryType] {
+		encName := field.Name
+		encValue := data[encName]
+		item := &NameValueType{Name: encName, Typ: field.Type}
+
+		switch {
+		case strings.HasSuffix(field.Type, "]"):
+			arrayValue, ok := encValue.([]interface{})
+			if !ok {
+				return nil, dataMismatchError(field.Type, encValue)
+			}
+			elemType := field.Type[:strings.LastIndex(field.Type, "[")]
+			var items []interface{}
+			for _, v := range arrayValue {
+				if typedData.Types[elemType] == nil {
+					items = append(items, formatPrimitiveValue(elemType, v))
+					continue
+				}
+				mapValue, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, dataMismatchError(elemType, v)
+				}
+				formatted, err := typedData.formatData(elemType, mapValue)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, formatted)
+			}
+			item.Value = items
+
+		case typedData.Types[field.Type] != nil:
+			mapValue, ok := encValue.(map[string]interface{})
+			if !ok {
+				return nil, dataMismatchError(field.Type, encValue)
+			}
+			formatted, err := typedData.formatData(field.Type, mapValue)
+			if err != nil {
+				return nil, err
+			}
+			item.Value = formatted
+
+		default:
+			item.Value = formatPrimitiveValue(field.Type, encValue)
+		}
+		output = append(output, item)
+	}
+	return output, nil
+}
+
+// formatPrimitiveValue renders primitive values in their canonical form,
+// falling back to the raw value if it can't be parsed.
+func formatPrimitiveValue(encType string, encValue interface{}) interface{} {
+	switch {
+	case encType == "address":
+		if stringValue, ok := encValue.(string); ok && common.IsHexAddress(stringValue) {
+			return common.HexToAddress(stringValue).Hex()
+		}
+	case strings.HasPrefix(encType, "int") || strings.HasPrefix(encType, "uint"):
+		if b, err := parseInteger(encType, encValue); err == nil {
+			return b.String()
+		}
+	}
+	return encValue
+}
+
+// Pprint returns a pretty-printed, indented representation of the value.
+func (nvt *NameValueType) Pprint(depth int) string {
+	output := bytes.Buffer{}
+	output.WriteString(strings.Repeat(" ", depth*2))
+	output.WriteString(fmt.Sprintf("%s [%s]: ", nvt.Name, nvt.Typ))
+
+	switch value := nvt.Value.(type) {
+	case []*NameValueType:
+		output.WriteString("\n")
+		for _, next := range value {
+			output.WriteString(next.Pprint(depth + 1))
+		}
+	case []interface{}:
+		output.WriteString("\n")
+		for i, item := range value {
+			if nested, ok := item.([]*NameValueType); ok {
+				elem := &NameValueType{Name: strconv.Itoa(i), Value: nested, Typ: strings.TrimSuffix(nvt.Typ, "[]")}
+				output.WriteString(elem.Pprint(depth + 1))
+				continue
+			}
+			output.WriteString(strings.Repeat(" ", (depth+1)*2))
+			output.WriteString(fmt.Sprintf("%d: %v\n", i, item))
+		}
+	default:
+		output.WriteString(fmt.Sprintf("%v\n", value))
+	}
+	return output.String()
+}
@@ -0,0 +1,226 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package typeddata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
+)
+
+// mailJSON is the example message from the EIP-712 specification.
+const mailJSON = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func parseMail(t *testing.T) *TypedData {
+	var typedData TypedData
+	if err := json.Unmarshal([]byte(mailJSON), &typedData); err != nil {
+		t.Fatalf("failed to unmarshal typed data: %v", err)
+	}
+	return &typedData
+}
+
+func TestEncodeType(t *testing.T) {
+	typedData := parseMail(t)
+
+	want := "Mail(Person from,Person to,string contents)Person(string name,address wallet)"
+	if have := string(typedData.EncodeType("Mail")); have != want {
+		t.Errorf("type encoding mismatch: have %s, want %s", have, want)
+	}
+	want = "0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2"
+	if have := typedData.TypeHash("Mail").String(); have != want {
+		t.Errorf("type hash mismatch: have %s, want %s", have, want)
+	}
+}
+
+func TestHash(t *testing.T) {
+	typedData := parseMail(t)
+
+	domainSeparator, err := typedData.HashStruct(DomainType, typedData.Domain.Map())
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	if want := "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; domainSeparator.String() != want {
+		t.Errorf("domain separator mismatch: have %s, want %s", domainSeparator, want)
+	}
+	structHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		t.Fatalf("failed to hash message: %v", err)
+	}
+	if want := "0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"; structHash.String() != want {
+		t.Errorf("struct hash mismatch: have %s, want %s", structHash, want)
+	}
+	hash, _, err := typedData.Hash()
+	if err != nil {
+		t.Fatalf("failed to hash typed data: %v", err)
+	}
+	if want := common.HexToHash("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"); hash != want {
+		t.Errorf("signing hash mismatch: have %x, want %x", hash, want)
+	}
+	// Sign with the key from the specification and compare against its signature
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("cow")))
+	if addr := crypto.PubkeyToAddress(key.PublicKey); addr != common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826") {
+		t.Fatalf("key mismatch: have %x", addr)
+	}
+	sig, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := fmt.Sprintf("%x", sig[:32]); r != "4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" {
+		t.Errorf("signature r mismatch: %s", r)
+	}
+	if s := fmt.Sprintf("%x", sig[32:64]); s != "07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" {
+		t.Errorf("signature s mismatch: %s", s)
+	}
+}
+
+func TestHashInvalid(t *testing.T) {
+	tests := []struct {
+		mutate func(*TypedData)
+		err    string
+	}{
+		{func(td *TypedData) { delete(td.Message, "contents") }, "type 'Mail' has 3 fields, data has 2"},
+		{func(td *TypedData) { td.Message["contents"] = 1.0 }, "provided data '1' doesn't match type 'string'"},
+		{func(td *TypedData) { td.Message["to"].(map[string]interface{})["wallet"] = "0xbeef" }, "provided data '0xbeef' doesn't match type 'address'"},
+		{func(td *TypedData) { td.PrimaryType = "Letter" }, "unknown primary type 'Letter'"},
+		{func(td *TypedData) { td.Types["Mail"][0].Type = "Human" }, `reference type "Human" is undefined`},
+		{func(td *TypedData) { td.Types["Person"][0].Type = "uint7" }, "invalid size on integer: uint7"},
+		{func(td *TypedData) { delete(td.Types, DomainType) }, "missing EIP712Domain type definition"},
+	}
+	for i, tt := range tests {
+		typedData := parseMail(t)
+		tt.mutate(typedData)
+		if _, _, err := typedData.Hash(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, tt.err)
+		}
+	}
+}
+
+func TestEncodeInteger(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+		ok    bool
+	}{
+		{"uint8", 0.0, true},
+		{"uint8", 255.0, true},
+		{"uint8", 256.0, false},
+		{"uint8", -1.0, false},
+		{"int8", 127.0, true},
+		{"int8", 128.0, false},
+		{"int8", 200.0, false},
+		{"int8", -128.0, true},
+		{"int8", -129.0, false},
+		{"uint256", "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", true},
+		{"int256", "0x7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", true},
+		{"int256", "0x8000000000000000000000000000000000000000000000000000000000000000", false},
+		{"int256", "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", false},
+		{"int256", "-0x8000000000000000000000000000000000000000000000000000000000000000", true},
+	}
+	for i, tt := range tests {
+		_, err := encodePrimitiveValue(tt.typ, tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("test %d: %s %v: error mismatch: have %v, want ok %v", i, tt.typ, tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestEncodeFixedArray(t *testing.T) {
+	tests := []struct {
+		typ   string
+		items int
+		err   string
+	}{
+		{"uint8[]", 0, ""},
+		{"uint8[]", 3, ""},
+		{"uint8[2]", 2, ""},
+		{"uint8[2]", 1, "provided array has 1 items, type 'uint8[2]' requires 2"},
+		{"uint8[2]", 3, "provided array has 3 items, type 'uint8[2]' requires 2"},
+		{"uint8[0]", 0, "invalid size on array: uint8[0]"},
+	}
+	for i, tt := range tests {
+		typedData := &TypedData{Types: Types{"Group": {{Name: "members", Type: tt.typ}}}}
+		items := make([]interface{}, tt.items)
+		for j := range items {
+			items[j] = float64(j)
+		}
+		_, err := typedData.EncodeData("Group", map[string]interface{}{"members": items}, 1)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("test %d: %s with %d items: unexpected error: %v", i, tt.typ, tt.items, err)
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("test %d: %s with %d items: error mismatch: have %v, want %s", i, tt.typ, tt.items, err, tt.err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	typedData := parseMail(t)
+
+	output, err := typedData.Format()
+	if err != nil {
+		t.Fatalf("failed to format typed data: %v", err)
+	}
+	var pretty string
+	for _, item := range output {
+		pretty += item.Pprint(0)
+	}
+	for _, want := range []string{
+		"EIP712Domain [domain]:",
+		"  chainId [uint256]: 1",
+		"Mail [primary type]:",
+		"    wallet [address]: 0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+		"  contents [string]: Hello, Bob!",
+	} {
+		if !strings.Contains(pretty, want) {
+			t.Errorf("formatted output missing %q:\n%s", want, pretty)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package trie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/orangeAndSuns/go-ethereum/common"
@@ -99,6 +100,17 @@ func (t *SecureTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.Putter) err
 	return t.trie.Prove(key, fromLevel, proofDb)
 }
 
+// ProveRange constructs the edge proofs of the range of keys between firstKey
+// and lastKey, i.e. the merkle proofs of the two boundary keys. Either of them
+// may be a proof of absence. Together with the leaves in between, the result
+// can be verified with VerifyRangeProof.
+func (t *Trie) ProveRange(firstKey []byte, lastKey []byte, proofDb ethdb.Putter) error {
+	if err := t.Prove(firstKey, 0, proofDb); err != nil {
+		return err
+	}
+	return t.Prove(lastKey, 0, proofDb)
+}
+
 // VerifyProof checks merkle proofs. The given proof must contain the value for
 // key in a trie with the given root hash. VerifyProof returns an error if the
 // proof contains invalid trie nodes or the wrong value.
@@ -114,7 +126,7 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 		if err != nil {
 			return nil, i, fmt.Errorf("bad proof node %d: %v", i, err)
 		}
-		keyrest, cld := get(n, key)
+		keyrest, cld := get(n, key, true)
 		switch cld := cld.(type) {
 		case nil:
 			// The trie doesn't contain the key.
@@ -128,7 +140,11 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 	}
 }
 
-func get(tn node, key []byte) ([]byte, node) {
+// get returns the child of the given node at key, along with the remaining
+// part of the key. If skipResolved is set, already resolved nodes are walked
+// through until a hash node, a value or a missing child is reached. Otherwise
+// only a single step is taken.
+func get(tn node, key []byte, skipResolved bool) ([]byte, node) {
 	for {
 		switch n := tn.(type) {
 		case *shortNode:
@@ -137,9 +153,15 @@ func get(tn node, key []byte) ([]byte, node) {
 			}
 			tn = n.Val
 			key = key[len(n.Key):]
+			if !skipResolved {
+				return key, tn
+			}
 		case *fullNode:
 			tn = n.Children[key[0]]
 			key = key[1:]
+			if !skipResolved {
+				return key, tn
+			}
 		case hashNode:
 			return key, n
 		case nil:
@@ -151,3 +173,339 @@ func get(tn node, key []byte) ([]byte, node) {
 		}
 	}
 }
+
+// proofToPath resolves the path to key from the nodes contained in the proof,
+// linking them into the partial trie rooted at root (which is loaded from the
+// proof if nil). Nodes off the path are left as hash nodes. If the proof is a
+// proof of absence, the path is resolved as far as it exists, which is enough
+// to anchor a range boundary.
+func proofToPath(rootHash common.Hash, root node, key []byte, proofDb DatabaseReader, allowNonExistent bool) (node, []byte, error) {
+	resolve := func(hash hashNode) (node, error) {
+		buf, _ := proofDb.Get(hash)
+		if buf == nil {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %v", err)
+		}
+		return n, nil
+	}
+	if root == nil {
+		n, err := resolve(rootHash[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		root = n
+	}
+	var (
+		err     error
+		child   node
+		parent  = root
+		keyrest []byte
+		valnode []byte
+	)
+	key = keybytesToHex(key)
+	for {
+		keyrest, child = get(parent, key, false)
+		switch cld := child.(type) {
+		case nil:
+			// The trie doesn't contain the key. The resolved part of the path is
+			// still authenticated, which is enough to prove a range boundary.
+			if allowNonExistent {
+				return root, nil, nil
+			}
+			return nil, nil, errors.New("the node is not contained in trie")
+		case *shortNode, *fullNode:
+			// Already resolved by an earlier path (or embedded in its parent).
+			key, parent = keyrest, child
+			continue
+		case hashNode:
+			child, err = resolve(cld)
+			if err != nil {
+				return nil, nil, err
+			}
+		case valueNode:
+			valnode = cld
+		}
+		// Link the resolved child into its parent.
+		switch pnode := parent.(type) {
+		case *shortNode:
+			pnode.Val = child
+		case *fullNode:
+			pnode.Children[key[0]] = child
+		default:
+			panic(fmt.Sprintf("%T: invalid node: %v", pnode, pnode))
+		}
+		if len(valnode) > 0 {
+			return root, valnode, nil
+		}
+		key, parent = keyrest, child
+	}
+}
+
+// errEmptyRange is returned by unsetInternal if the proven paths show that there
+// are no leaves between the two keys.
+var errEmptyRange = errors.New("empty range")
+
+// unsetInternal removes all nodes between the paths of the left and right keys
+// from the partial trie, so they can be rebuilt from the range's leaves. The
+// nodes along the two paths lose their cached hashes. It reports whether the
+// whole trie is covered by the range, in which case the root must be dropped.
+func unsetInternal(n node, left []byte, right []byte) (bool, error) {
+	left, right = keybytesToHex(left), keybytesToHex(right)
+
+	// Step down to the fork point of the two paths. It is either a short node
+	// whose key doesn't match one of the paths, or a full node at which the
+	// paths take different children.
+	var (
+		pos    = 0
+		parent node
+
+		// Position of the paths relative to the fork short node's key: 0 if the
+		// path matches it, -1 if it is smaller and 1 if it is larger.
+		shortForkLeft, shortForkRight int
+	)
+findFork:
+	for {
+		switch rn := n.(type) {
+		case *shortNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			shortForkLeft = compareKeyPrefix(left[pos:], rn.Key)
+			shortForkRight = compareKeyPrefix(right[pos:], rn.Key)
+			if shortForkLeft != 0 || shortForkRight != 0 {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Val, pos+len(rn.Key)
+		case *fullNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if left[pos] != right[pos] || rn.Children[left[pos]] == nil {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Children[left[pos]], pos+1
+		default:
+			panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+		}
+	}
+	switch rn := n.(type) {
+	case *shortNode:
+		// Both paths on the same side of the short node mean there's nothing in
+		// the range at all.
+		if shortForkLeft == shortForkRight {
+			return false, errEmptyRange
+		}
+		// The short node is strictly inside the range, remove it entirely.
+		if shortForkLeft != 0 && shortForkRight != 0 {
+			return removeChild(parent, left, pos)
+		}
+		// One of the paths continues below the short node. If it ends right
+		// here, the leaf is inside the range and removed.
+		if _, ok := rn.Val.(valueNode); ok {
+			return removeChild(parent, left, pos)
+		}
+		if shortForkRight != 0 {
+			return false, unset(rn, rn.Val, left[pos:], len(rn.Key), false)
+		}
+		return false, unset(rn, rn.Val, right[pos:], len(rn.Key), true)
+
+	case *fullNode:
+		// Remove all children between the two paths, then the parts of the
+		// boundary subtries which are inside the range.
+		for i := left[pos] + 1; i < right[pos]; i++ {
+			rn.Children[i] = nil
+		}
+		if err := unset(rn, rn.Children[left[pos]], left[pos:], 1, false); err != nil {
+			return false, err
+		}
+		if err := unset(rn, rn.Children[right[pos]], right[pos:], 1, true); err != nil {
+			return false, err
+		}
+		return false, nil
+
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// removeChild removes the fork point below parent from the partial trie. If the
+// fork point is the root, the whole trie is inside the range.
+func removeChild(parent node, key []byte, pos int) (bool, error) {
+	if parent == nil {
+		return true, nil
+	}
+	parent.(*fullNode).Children[key[pos-1]] = nil
+	return false, nil
+}
+
+// unset removes the nodes on one side of the boundary path key from the subtrie
+// rooted at child. If removeLeft is set, everything left of the path (i.e. the
+// part of the right boundary's subtrie inside the range) is removed, otherwise
+// everything right of it.
+func unset(parent node, child node, key []byte, pos int, removeLeft bool) error {
+	switch cld := child.(type) {
+	case *fullNode:
+		if removeLeft {
+			for i := 0; i < int(key[pos]); i++ {
+				cld.Children[i] = nil
+			}
+		} else {
+			for i := key[pos] + 1; i < 16; i++ {
+				cld.Children[i] = nil
+			}
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Children[key[pos]], key, pos+1, removeLeft)
+
+	case *shortNode:
+		if len(key[pos:]) < len(cld.Key) || !bytes.Equal(cld.Key, key[pos:pos+len(cld.Key)]) {
+			// The path ends in a proof of absence here. The short node is inside
+			// the range if it is on the removed side of the path.
+			cmp := bytes.Compare(cld.Key, key[pos:])
+			if (removeLeft && cmp < 0) || (!removeLeft && cmp > 0) {
+				parent.(*fullNode).Children[key[pos-1]] = nil
+			}
+			return nil
+		}
+		if _, ok := cld.Val.(valueNode); ok {
+			// The boundary leaf itself is part of the range.
+			parent.(*fullNode).Children[key[pos-1]] = nil
+			return nil
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Val, key, pos+len(cld.Key), removeLeft)
+
+	case nil:
+		// The path ends in a missing child of the parent full node.
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected %T on range boundary", child)
+	}
+}
+
+// compareKeyPrefix compares the leading part of a hex key path with a short
+// node's key.
+func compareKeyPrefix(path, key []byte) int {
+	if len(path) < len(key) {
+		return bytes.Compare(path, key)
+	}
+	return bytes.Compare(path[:len(key)], key)
+}
+
+// hasRightElement reports whether the partial trie contains any leaves to the
+// right of the path to key.
+func hasRightElement(n node, key []byte) bool {
+	pos, key := 0, keybytesToHex(key)
+	for n != nil {
+		switch rn := n.(type) {
+		case *fullNode:
+			for i := key[pos] + 1; i < 16; i++ {
+				if rn.Children[i] != nil {
+					return true
+				}
+			}
+			n, pos = rn.Children[key[pos]], pos+1
+		case *shortNode:
+			if len(key)-pos < len(rn.Key) || !bytes.Equal(rn.Key, key[pos:pos+len(rn.Key)]) {
+				return bytes.Compare(rn.Key, key[pos:]) > 0
+			}
+			n, pos = rn.Val, pos+len(rn.Key)
+		case valueNode:
+			return false
+		default:
+			panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+		}
+	}
+	return false
+}
+
+// VerifyRangeProof checks that the given leaves are the complete, contiguous
+// range of the trie with the given root between firstKey and lastKey, both
+// inclusive. The proof must contain the edge proofs of the two boundary keys as
+// created by ProveRange. Either edge may be a proof of absence, so the leaves
+// don't need to start or end exactly at the boundaries. If the proof is nil,
+// the leaves must make up the entire trie.
+//
+// The returned flag reports whether the trie contains more leaves after lastKey.
+func VerifyRangeProof(rootHash common.Hash, firstKey []byte, lastKey []byte, keys [][]byte, values [][]byte, proofDb DatabaseReader) (bool, error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	if bytes.Compare(firstKey, lastKey) > 0 {
+		return false, errors.New("invalid edge keys")
+	}
+	// Ensure the leaves are sorted, inside the range and not deletions.
+	for i := 0; i < len(keys)-1; i++ {
+		if bytes.Compare(keys[i], keys[i+1]) >= 0 {
+			return false, errors.New("range is not monotonically increasing")
+		}
+	}
+	if len(keys) > 0 && (bytes.Compare(firstKey, keys[0]) > 0 || bytes.Compare(keys[len(keys)-1], lastKey) > 0) {
+		return false, errors.New("range exceeds the edge keys")
+	}
+	for _, value := range values {
+		if len(value) == 0 {
+			return false, errors.New("range contains deletion")
+		}
+	}
+	// Without a proof, the leaves must rebuild the whole trie.
+	if proofDb == nil {
+		tr := new(Trie)
+		for i, key := range keys {
+			tr.TryUpdate(key, values[i])
+		}
+		if have := tr.Hash(); have != rootHash {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+		}
+		return false, nil
+	}
+	// A range with identical edges is proven by the Merkle path of the edge key.
+	if bytes.Equal(firstKey, lastKey) {
+		root, val, err := proofToPath(rootHash, nil, firstKey, proofDb, true)
+		if err != nil {
+			return false, err
+		}
+		if len(keys) == 0 && val != nil {
+			return false, errors.New("more entries available")
+		}
+		if len(keys) == 1 && !bytes.Equal(val, values[0]) {
+			return false, errors.New("correct proof but invalid data")
+		}
+		return hasRightElement(root, firstKey), nil
+	}
+	// Otherwise resolve both edge paths, drop everything between them and
+	// rebuild that part of the trie from the leaves.
+	root, _, err := proofToPath(rootHash, nil, firstKey, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	root, _, err = proofToPath(rootHash, root, lastKey, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	empty, err := unsetInternal(root, firstKey, lastKey)
+	if err == errEmptyRange && len(keys) == 0 {
+		// The edge proofs show there's nothing between them.
+		return hasRightElement(root, lastKey), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	tr := &Trie{root: root, db: NewDatabase(ethdb.NewMemDatabase())}
+	if empty {
+		tr.root = nil
+	}
+	for i, key := range keys {
+		if err := tr.TryUpdate(key, values[i]); err != nil {
+			return false, err
+		}
+	}
+	if have := tr.Hash(); have != rootHash {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+	}
+	return hasRightElement(tr.root, lastKey), nil
+}
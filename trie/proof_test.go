@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -143,6 +144,262 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+// entrySlice sorts trie leaves by key.
+type entrySlice []*kv
+
+func (p entrySlice) Len() int           { return len(p) }
+func (p entrySlice) Less(i, j int) bool { return bytes.Compare(p[i].k, p[j].k) < 0 }
+func (p entrySlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// sortedRandomTrie creates a random trie and returns its leaves sorted by key.
+func sortedRandomTrie(n int) (*Trie, entrySlice) {
+	trie, vals := randomTrie(n)
+	var entries entrySlice
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Sort(entries)
+	return trie, entries
+}
+
+// proveRange creates the edge proofs of a range of the trie.
+func proveRange(t *testing.T, trie *Trie, first, last []byte) *ethdb.MemDatabase {
+	proof := ethdb.NewMemDatabase()
+	if err := trie.ProveRange(first, last, proof); err != nil {
+		t.Fatalf("failed to prove range edges: %v", err)
+	}
+	return proof
+}
+
+// rangeData splits a range of leaves into keys and values.
+func rangeData(entries entrySlice) (keys [][]byte, vals [][]byte) {
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		vals = append(vals, entry.v)
+	}
+	return keys, vals
+}
+
+// decreaseKey returns the key preceding the given one.
+func decreaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] > 0 {
+			key[i]--
+			return key
+		}
+		key[i] = 0xff
+	}
+	return nil
+}
+
+// increaseKey returns the key following the given one.
+func increaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] < 0xff {
+			key[i]++
+			return key
+		}
+		key[i] = 0
+	}
+	return nil
+}
+
+// Tests that random ranges of the trie are verified correctly, with each edge key
+// either being part of the range or a proof of absence just outside of it.
+func TestRangeProof(t *testing.T) {
+	trie, entries := sortedRandomTrie(4096)
+	root := trie.Hash()
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+
+		keys, vals := rangeData(entries[start:end])
+		for _, first := range [][]byte{entries[start].k, decreaseKey(entries[start].k)} {
+			if first == nil || (start > 0 && bytes.Equal(first, entries[start-1].k)) {
+				continue
+			}
+			for _, last := range [][]byte{entries[end-1].k, increaseKey(entries[end-1].k)} {
+				if last == nil || (end < len(entries) && bytes.Equal(last, entries[end].k)) {
+					continue
+				}
+				proof := proveRange(t, trie, first, last)
+				more, err := VerifyRangeProof(root, first, last, keys, vals, proof)
+				if err != nil {
+					t.Fatalf("range %d-%d (first %x, last %x): %v", start, end, first, last, err)
+				}
+				if more != (end < len(entries)) {
+					t.Fatalf("range %d-%d: more flag mismatch: have %v, want %v", start, end, more, end < len(entries))
+				}
+			}
+		}
+	}
+}
+
+// Tests that ranges reaching beyond the edge keys are rejected.
+func TestRangeProofOutOfBounds(t *testing.T) {
+	trie, entries := sortedRandomTrie(4096)
+	root := trie.Hash()
+
+	start, end := len(entries)/4, len(entries)/2
+	keys, vals := rangeData(entries[start:end])
+	first, last := increaseKey(keys[0]), decreaseKey(keys[len(keys)-1])
+
+	proof := proveRange(t, trie, first, keys[len(keys)-1])
+	if _, err := VerifyRangeProof(root, first, keys[len(keys)-1], keys, vals, proof); err == nil {
+		t.Fatal("range starting before the first edge accepted")
+	}
+	proof = proveRange(t, trie, keys[0], last)
+	if _, err := VerifyRangeProof(root, keys[0], last, keys, vals, proof); err == nil {
+		t.Fatal("range ending after the last edge accepted")
+	}
+	if _, err := VerifyRangeProof(root, keys[len(keys)-1], keys[0], keys, vals, proof); err == nil {
+		t.Fatal("inverted edge keys accepted")
+	}
+}
+
+// Tests that the whole trie can be verified with and without boundary proofs.
+func TestAllElementsRangeProof(t *testing.T) {
+	trie, entries := sortedRandomTrie(4096)
+	root := trie.Hash()
+	keys, vals := rangeData(entries)
+
+	if _, err := VerifyRangeProof(root, keys[0], keys[len(keys)-1], keys, vals, nil); err != nil {
+		t.Fatalf("failed to verify whole trie without proof: %v", err)
+	}
+	proof := proveRange(t, trie, keys[0], keys[len(keys)-1])
+	more, err := VerifyRangeProof(root, keys[0], keys[len(keys)-1], keys, vals, proof)
+	if err != nil {
+		t.Fatalf("failed to verify whole trie with proof: %v", err)
+	}
+	if more {
+		t.Fatal("more elements reported after the whole trie")
+	}
+	// Without a proof, an incomplete trie is rejected.
+	if _, err := VerifyRangeProof(root, keys[0], keys[len(keys)-1], keys[1:], vals[1:], nil); err == nil {
+		t.Fatal("incomplete trie accepted without proof")
+	}
+}
+
+// Tests that single element ranges are verified correctly.
+func TestSingleElementRangeProof(t *testing.T) {
+	trie, entries := sortedRandomTrie(4096)
+	root := trie.Hash()
+	for _, i := range []int{0, mrand.Intn(len(entries)), len(entries) - 1} {
+		entry := entries[i]
+		proof := proveRange(t, trie, entry.k, entry.k)
+		more, err := VerifyRangeProof(root, entry.k, entry.k, [][]byte{entry.k}, [][]byte{entry.v}, proof)
+		if err != nil {
+			t.Fatalf("element %d: %v", i, err)
+		}
+		if more != (i < len(entries)-1) {
+			t.Fatalf("element %d: more flag mismatch: have %v", i, more)
+		}
+		if _, err := VerifyRangeProof(root, entry.k, entry.k, [][]byte{entry.k}, [][]byte{randBytes(20)}, proof); err == nil {
+			t.Fatalf("element %d: wrong value accepted", i)
+		}
+	}
+	// A single element trie is the whole trie.
+	single := new(Trie)
+	single.Update(entries[0].k, entries[0].v)
+	proof := proveRange(t, single, entries[0].k, entries[0].k)
+	if _, err := VerifyRangeProof(single.Hash(), entries[0].k, entries[0].k, [][]byte{entries[0].k}, [][]byte{entries[0].v}, proof); err != nil {
+		t.Fatalf("single element trie: %v", err)
+	}
+}
+
+// Tests that empty ranges are only accepted if there are no elements between the
+// edge keys.
+func TestEmptyRangeProof(t *testing.T) {
+	trie, entries := sortedRandomTrie(4096)
+	root := trie.Hash()
+
+	// Nothing after the last element.
+	first := increaseKey(entries[len(entries)-1].k)
+	for _, last := range [][]byte{first, bytes.Repeat([]byte{0xff}, 32)} {
+		proof := proveRange(t, trie, first, last)
+		more, err := VerifyRangeProof(root, first, last, nil, nil, proof)
+		if err != nil {
+			t.Fatalf("empty range after the last element rejected: %v", err)
+		}
+		if more {
+			t.Fatal("more elements reported after the last element")
+		}
+	}
+	// Nothing between two neighbouring elements.
+	for i := 0; i < 100; i++ {
+		n := mrand.Intn(len(entries) - 1)
+		first, last := increaseKey(entries[n].k), decreaseKey(entries[n+1].k)
+		if bytes.Compare(first, last) > 0 {
+			continue
+		}
+		proof := proveRange(t, trie, first, last)
+		more, err := VerifyRangeProof(root, first, last, nil, nil, proof)
+		if err != nil {
+			t.Fatalf("empty range between elements %d and %d rejected: %v", n, n+1, err)
+		}
+		if !more {
+			t.Fatalf("no more elements reported before element %d", n+1)
+		}
+	}
+	// Elements inside the range.
+	middle := entries[len(entries)/2].k
+	for _, edges := range [][2][]byte{
+		{middle, middle},
+		{decreaseKey(middle), middle},
+		{decreaseKey(middle), increaseKey(middle)},
+		{decreaseKey(middle), bytes.Repeat([]byte{0xff}, 32)},
+	} {
+		proof := proveRange(t, trie, edges[0], edges[1])
+		if _, err := VerifyRangeProof(root, edges[0], edges[1], nil, nil, proof); err == nil {
+			t.Fatalf("empty range %x-%x with elements in it accepted", edges[0], edges[1])
+		}
+	}
+}
+
+// Tests that tampered ranges are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, entries := sortedRandomTrie(4096)
+	root := trie.Hash()
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries) - 3)
+		end := start + 3 + mrand.Intn(len(entries)-start-3)
+		keys, vals := rangeData(entries[start:end])
+		first, last := keys[0], keys[len(keys)-1]
+		proof := proveRange(t, trie, first, last)
+
+		var (
+			index = 1 + mrand.Intn(len(keys)-2)
+			test  = mrand.Intn(4)
+			kind  string
+		)
+		switch test {
+		case 0:
+			kind = "modified value"
+			vals[index] = randBytes(20)
+		case 1:
+			kind = "missing element"
+			keys = append(keys[:index:index], keys[index+1:]...)
+			vals = append(vals[:index:index], vals[index+1:]...)
+		case 2:
+			kind = "unsorted elements"
+			keys[index], keys[index+1] = keys[index+1], keys[index]
+		case 3:
+			kind = "extra element"
+			extra := decreaseKey(keys[index])
+			if bytes.Equal(extra, keys[index-1]) {
+				continue
+			}
+			keys = append(keys[:index:index], append([][]byte{extra}, keys[index:]...)...)
+			vals = append(vals[:index:index], append([][]byte{randBytes(20)}, vals[index:]...)...)
+		}
+		if _, err := VerifyRangeProof(root, first, last, keys, vals, proof); err == nil {
+			t.Fatalf("range %d-%d with %s accepted", start, end, kind)
+		}
+	}
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {
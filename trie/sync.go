@@ -317,10 +317,11 @@ func (s *Sync) commit(req *request) (err error) {
 
 	delete(s.requests, req.hash)
 
-	// Check all parents for completion
+	// Check all parents for completion (parents not yet retrieved themselves are
+	// committed when they arrive)
 	for _, parent := range req.parents {
 		parent.deps--
-		if parent.deps == 0 {
+		if parent.deps == 0 && parent.data != nil {
 			if err := s.commit(parent); err != nil {
 				return err
 			}
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/orangeAndSuns/go-ethereum/common"
+	"github.com/orangeAndSuns/go-ethereum/crypto"
 	"github.com/orangeAndSuns/go-ethereum/ethdb"
 )
 
@@ -356,3 +357,55 @@ func TestIncompleteSync(t *testing.T) {
 		diskdb.Put(key, value)
 	}
 }
+
+// Tests that entries attached to a parent which hasn't been retrieved yet don't
+// cause the parent to be committed prematurely when they complete.
+func TestSyncEntryBeforeParent(t *testing.T) {
+	// Create a random trie to copy
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	// Create a destination trie and attach a raw entry to its root
+	diskdb := ethdb.NewMemDatabase()
+	triedb := NewDatabase(diskdb)
+	sched := NewSync(srcTrie.Hash(), diskdb, nil)
+
+	blob := []byte("raw entry")
+	hash := crypto.Keccak256Hash(blob)
+	sched.AddRawEntry(hash, 64, srcTrie.Hash())
+
+	// Retrieve the raw entry first and ensure the root isn't written yet
+	queue := []common.Hash{}
+	for _, missing := range sched.Missing(0) {
+		if missing != hash {
+			queue = append(queue, missing)
+		}
+	}
+	if _, index, err := sched.Process([]SyncResult{{hash, blob}}); err != nil {
+		t.Fatalf("failed to process result #%d: %v", index, err)
+	}
+	if index, err := sched.Commit(diskdb); err != nil {
+		t.Fatalf("failed to commit data #%d: %v", index, err)
+	}
+	if ok, _ := diskdb.Has(srcTrie.Hash().Bytes()); ok {
+		t.Fatalf("trie root committed before being retrieved")
+	}
+	// Sync the rest of the trie and check that it's complete
+	for len(queue) > 0 {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if index, err := sched.Commit(diskdb); err != nil {
+			t.Fatalf("failed to commit data #%d: %v", index, err)
+		}
+		queue = append(queue[:0], sched.Missing(0)...)
+	}
+	checkTrieContents(t, triedb, srcTrie.Root(), srcData)
+}